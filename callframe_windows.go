@@ -0,0 +1,18 @@
+//go:build windows && !arm64
+// +build windows,!arm64
+
+package ole
+
+import "syscall"
+
+// call calls fn with the words of the frame, which the syscall package
+// passes as 386 and amd64 expect: on the stack, and on amd64 the first four
+// in both the integer and floating-point registers.
+func (f *callFrame) call(fn uintptr) uintptr {
+	args := make([]uintptr, len(f.stack))
+	for i, w := range f.stack {
+		args[i] = uintptr(w)
+	}
+	r1, _, _ := syscall.SyscallN(fn, args...)
+	return r1
+}
@@ -0,0 +1,49 @@
+package ole
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// arm64Call is a call callARM64 makes. Its layout is known to
+// callframe_windows_arm64.s.
+type arm64Call struct {
+	fn     uintptr
+	nstack uintptr // count of words of stack, even
+	x      [8]uint64
+	d      [8]uint64
+	stack  *uint64
+}
+
+// callARM64PC is the address of callARM64, which calls the function of the
+// arm64Call it is passed with its registers and stack.
+var callARM64PC uintptr
+
+// call calls fn with the registers and stack of the frame. The syscall
+// package only sets the integer registers, so calls passing floating-point
+// numbers go through callARM64.
+func (f *callFrame) call(fn uintptr) uintptr {
+	if len(f.floats) == 0 {
+		args := make([]uintptr, 0, len(f.ints)+len(f.stack))
+		for _, w := range f.ints {
+			args = append(args, uintptr(w))
+		}
+		for _, w := range f.stack {
+			args = append(args, uintptr(w))
+		}
+		r1, _, _ := syscall.SyscallN(fn, args...)
+		return r1
+	}
+
+	c := &arm64Call{fn: fn}
+	copy(c.x[:], f.ints)
+	copy(c.d[:], f.floats)
+	if len(f.stack) > 0 {
+		stack := make([]uint64, (len(f.stack)+1)&^1)
+		copy(stack, f.stack)
+		c.nstack = uintptr(len(stack))
+		c.stack = &stack[0]
+	}
+	r1, _, _ := syscall.SyscallN(callARM64PC, uintptr(unsafe.Pointer(c)))
+	return r1
+}
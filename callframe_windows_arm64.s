@@ -0,0 +1,53 @@
+#include "textflag.h"
+
+// callARM64 is called by the Windows calling convention with an arm64Call
+// in R0. It copies its stack words below the stack pointer, loads its
+// registers and calls its function, whose result it returns in R0.
+TEXT callARM64<>(SB),NOSPLIT|NOFRAME,$0
+	STP.W	(R29, R30), -32(RSP)
+	MOVD	RSP, R29
+	MOVD	R19, 16(RSP)
+	MOVD	R0, R19
+
+	// Copy the stack arguments.
+	MOVD	8(R19), R9
+	LSL	$3, R9, R10
+	SUB	R10, RSP
+	MOVD	144(R19), R11
+	MOVD	$0, R12
+	MOVD	RSP, R8
+copy:
+	CMP	R12, R10
+	BEQ	registers
+	MOVD	(R11)(R12), R13
+	MOVD	R13, (R8)(R12)
+	ADD	$8, R12
+	B	copy
+
+registers:
+	FMOVD	80(R19), F0
+	FMOVD	88(R19), F1
+	FMOVD	96(R19), F2
+	FMOVD	104(R19), F3
+	FMOVD	112(R19), F4
+	FMOVD	120(R19), F5
+	FMOVD	128(R19), F6
+	FMOVD	136(R19), F7
+	MOVD	16(R19), R0
+	MOVD	24(R19), R1
+	MOVD	32(R19), R2
+	MOVD	40(R19), R3
+	MOVD	48(R19), R4
+	MOVD	56(R19), R5
+	MOVD	64(R19), R6
+	MOVD	72(R19), R7
+	MOVD	0(R19), R16
+	CALL	(R16)
+
+	MOVD	R29, RSP
+	MOVD	16(RSP), R19
+	LDP.P	32(RSP), (R29, R30)
+	RET
+
+GLOBL	·callARM64PC(SB), RODATA, $8
+DATA	·callARM64PC+0(SB)/8, $callARM64<>(SB)
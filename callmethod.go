@@ -0,0 +1,594 @@
+package ole
+
+import (
+	"math"
+	"runtime"
+	"unsafe"
+)
+
+// HRESULT is the result code of a COM method. Negative codes report
+// failures, others success, such as S_OK and S_FALSE.
+type HRESULT int32
+
+// Failed reports whether hr is a failure code.
+func (hr HRESULT) Failed() bool {
+	return hr < 0
+}
+
+type argKind uint8
+
+const (
+	argInt         argKind = iota // integers of 8 bytes or less
+	argPointer                    // pointers and integers of the size of pointers
+	argFloat                      // float32 and float64
+	argStruct                     // structures passed by value
+	argFloatStruct                // structures of 1 to 4 members of one floating-point type
+)
+
+// Arg is an argument of a method called with CallMethod. The functions named
+// after the types of arguments, such as Int32Arg and PointerArg, make them.
+type Arg struct {
+	kind argKind
+	size uintptr // size of integers and floats, or of the members of float structures
+	bits uint64  // value of integers and floats, zero-extended
+	// ptr holds a pointer argument in a box on the heap. Storing the pointer
+	// there makes what it points to escape to the heap, which does not move.
+	ptr  *unsafe.Pointer
+	data []byte // bytes of structures
+}
+
+// Int32Arg returns an argument of type int32, and of the smaller integer
+// types, which are passed alike.
+func Int32Arg(v int32) Arg {
+	return Arg{kind: argInt, size: 4, bits: uint64(uint32(v))}
+}
+
+// Uint32Arg returns an argument of type uint32.
+func Uint32Arg(v uint32) Arg {
+	return Arg{kind: argInt, size: 4, bits: uint64(v)}
+}
+
+// Int64Arg returns an argument of type int64, such as LONGLONG and CY.
+func Int64Arg(v int64) Arg {
+	return Arg{kind: argInt, size: 8, bits: uint64(v)}
+}
+
+// Uint64Arg returns an argument of type uint64.
+func Uint64Arg(v uint64) Arg {
+	return Arg{kind: argInt, size: 8, bits: v}
+}
+
+// UintptrArg returns an argument of the size of pointers that is not a Go
+// pointer, such as a handle.
+func UintptrArg(v uintptr) Arg {
+	return Arg{kind: argPointer, bits: uint64(v)}
+}
+
+// PointerArg returns a pointer argument. What p points to is kept alive and
+// in place during the call.
+func PointerArg(p unsafe.Pointer) Arg {
+	return Arg{kind: argPointer, ptr: &p}
+}
+
+// Float32Arg returns an argument of type float.
+func Float32Arg(v float32) Arg {
+	return Arg{kind: argFloat, size: 4, bits: uint64(math.Float32bits(v))}
+}
+
+// Float64Arg returns an argument of type double, or DATE.
+func Float64Arg(v float64) Arg {
+	return Arg{kind: argFloat, size: 8, bits: math.Float64bits(v)}
+}
+
+// StructArg returns a structure argument passed by value, a copy of the size
+// bytes at p. The structure must not hold floating-point members only, as
+// the structures of Float32StructArg and Float64StructArg do.
+func StructArg(p unsafe.Pointer, size uintptr) Arg {
+	data := make([]byte, size)
+	if size > 0 {
+		copy(data, (*[1 << 30]byte)(p)[:size:size])
+	}
+	return Arg{kind: argStruct, size: size, data: data}
+}
+
+// VariantArg returns a VARIANT argument passed by value.
+func VariantArg(v VARIANT) Arg {
+	return StructArg(unsafe.Pointer(&v), unsafe.Sizeof(v))
+}
+
+// Float32StructArg returns an argument passed by value of a structure of
+// float members, such as D2D1_POINT_2F, which arm64 passes in floating-point
+// registers.
+func Float32StructArg(members ...float32) Arg {
+	data := make([]byte, 4*len(members))
+	for i, m := range members {
+		putUint(data[4*i:], uint64(math.Float32bits(m)), 4)
+	}
+	return floatStruct(data, 4, len(members))
+}
+
+// Float64StructArg returns an argument passed by value of a structure of
+// double members.
+func Float64StructArg(members ...float64) Arg {
+	data := make([]byte, 8*len(members))
+	for i, m := range members {
+		putUint(data[8*i:], math.Float64bits(m), 8)
+	}
+	return floatStruct(data, 8, len(members))
+}
+
+// floatStruct returns a structure of n floating-point members of size
+// bytes, which is a homogeneous floating-point aggregate for arm64 if it has
+// at most 4 of them.
+func floatStruct(data []byte, size uintptr, n int) Arg {
+	if n == 0 || n > 4 {
+		return Arg{kind: argStruct, size: uintptr(len(data)), data: data}
+	}
+	return Arg{kind: argFloatStruct, size: size, data: data}
+}
+
+// word returns the value of an integer, pointer or float argument.
+func (a *Arg) word() uint64 {
+	if a.ptr != nil {
+		return uint64(uintptr(*a.ptr))
+	}
+	return a.bits
+}
+
+// callFrame holds the arguments of a call laid out for a calling
+// convention, as the words the syscall package passes, and for arm64 the
+// floating-point registers it does not.
+type callFrame struct {
+	// ints are the integer registers X0 to X7 of arm64, and floats the
+	// registers D0 to D7.
+	ints   []uint64
+	floats []uint64
+	// stack are the words of the stack: 4-byte words on 386, and 8-byte
+	// ones on amd64, where the first four also go in the registers both
+	// integers and floats use, and arm64.
+	stack []uint64
+	// copies are the structures passed by reference, which the frame keeps
+	// alive.
+	copies [][]uint64
+}
+
+// maxCallWords is the maximum count of words syscall.SyscallN passes.
+const maxCallWords = 42
+
+// layoutCall lays out args for the calling convention of the methods of
+// COM on Windows for arch, which is 386, amd64 or arm64.
+func layoutCall(arch string, args []Arg) (*callFrame, error) {
+	f := &callFrame{}
+	var err error
+	switch arch {
+	case "386":
+		f.layout386(args)
+	case "amd64":
+		f.layoutAMD64(args)
+	case "arm64":
+		f.layoutARM64(args)
+	default:
+		err = NewErrorWithDescription(E_NOTIMPL, "cannot call methods on "+arch)
+	}
+	if err == nil && len(f.ints)+len(f.stack) > maxCallWords {
+		err = NewErrorWithDescription(E_INVALIDARG, "too many arguments")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// layout386 pushes every argument on the stack, in 4-byte words.
+func (f *callFrame) layout386(args []Arg) {
+	for i := range args {
+		a := &args[i]
+		switch a.kind {
+		case argStruct, argFloatStruct:
+			f.stack = append(f.stack, words(a.data, 4)...)
+		default:
+			w := a.word()
+			f.stack = append(f.stack, w&0xffffffff)
+			if a.size == 8 {
+				f.stack = append(f.stack, w>>32)
+			}
+		}
+	}
+}
+
+// layoutAMD64 gives each argument an 8-byte slot. Structures of other sizes
+// than 1, 2, 4 or 8 bytes are passed by reference.
+func (f *callFrame) layoutAMD64(args []Arg) {
+	for i := range args {
+		a := &args[i]
+		switch {
+		case a.data == nil:
+			f.stack = append(f.stack, a.word())
+		case len(a.data) == 1, len(a.data) == 2, len(a.data) == 4, len(a.data) == 8:
+			f.stack = append(f.stack, words(a.data, 8)[0])
+		default:
+			f.stack = append(f.stack, f.copy(a.data))
+		}
+	}
+}
+
+// layoutARM64 passes arguments in 8 integer and 8 floating-point registers,
+// then on the stack in 8-byte words. Structures of more than 16 bytes are
+// passed by reference.
+func (f *callFrame) layoutARM64(args []Arg) {
+	var ngrn, nsrn int // next integer and floating-point registers
+	for i := range args {
+		a := &args[i]
+		switch {
+		case a.kind == argFloat:
+			if nsrn < 8 {
+				f.floats = append(f.floats, a.word())
+				nsrn++
+			} else {
+				f.stack = append(f.stack, a.word())
+			}
+		case a.kind == argFloatStruct:
+			n := len(a.data) / int(a.size)
+			if nsrn+n <= 8 {
+				f.floats = append(f.floats, words(a.data, int(a.size))...)
+				nsrn += n
+			} else {
+				nsrn = 8
+				f.stack = append(f.stack, words(a.data, 8)...)
+			}
+		case a.data == nil, len(a.data) > 16:
+			w := a.word()
+			if a.data != nil {
+				w = f.copy(a.data)
+			}
+			if ngrn < 8 {
+				f.ints = append(f.ints, w)
+				ngrn++
+			} else {
+				f.stack = append(f.stack, w)
+			}
+		default:
+			ws := words(a.data, 8)
+			if ngrn+len(ws) <= 8 {
+				f.ints = append(f.ints, ws...)
+				ngrn += len(ws)
+			} else {
+				ngrn = 8
+				f.stack = append(f.stack, ws...)
+			}
+		}
+	}
+	if len(f.stack) > 0 {
+		// The stack begins after the 8 registers.
+		for len(f.ints) < 8 {
+			f.ints = append(f.ints, 0)
+		}
+	}
+}
+
+// copy copies a structure passed by reference and returns its address.
+func (f *callFrame) copy(data []byte) uint64 {
+	buf := make([]uint64, (len(data)+15)/16*2)
+	copy((*[1 << 30]byte)(unsafe.Pointer(&buf[0]))[:len(data)], data)
+	f.copies = append(f.copies, buf)
+	return uint64(uintptr(unsafe.Pointer(&buf[0])))
+}
+
+// words splits data in little-endian words of size bytes, padding the last
+// one with zeros.
+func words(data []byte, size int) []uint64 {
+	ws := make([]uint64, (len(data)+size-1)/size)
+	for i := range data {
+		ws[i/size] |= uint64(data[i]) << (8 * uint(i%size))
+	}
+	return ws
+}
+
+// putUint stores the size low bytes of v in b, in little-endian order.
+func putUint(b []byte, v uint64, size int) {
+	for i := 0; i < size; i++ {
+		b[i] = byte(v >> (8 * uint(i)))
+	}
+}
+
+// CallMethod calls the method at vtblIndex in the vtable of the interface
+// obj points to, passing obj then args by the calling convention of COM:
+// floating-point numbers in the registers meant for them and structures by
+// value as the platform passes them, which the syscall package does not do.
+//
+// The result is the HRESULT the method returns, the low 32 bits of the
+// result of the method for those that return another type, such as AddRef.
+// The error is not nil when the HRESULT reports a failure, or when the call
+// cannot be made: only on Windows, for 386, amd64 and arm64.
+func CallMethod(obj unsafe.Pointer, vtblIndex int, args ...Arg) (HRESULT, error) {
+	refUse(obj, "CallMethod")
+	r1, err := callMethod(obj, vtblIndex, args)
+	if err != nil {
+		return 0, err
+	}
+	hr := HRESULT(int32(uint32(r1)))
+	if hr.Failed() {
+		return hr, NewError(uintptr(uint32(hr)))
+	}
+	return hr, nil
+}
+
+// methodAddress returns the address of the method at vtblIndex of obj.
+func methodAddress(obj unsafe.Pointer, vtblIndex int) (uintptr, error) {
+	if obj == nil {
+		return 0, NewError(E_POINTER)
+	}
+	if vtblIndex < 0 {
+		return 0, NewErrorWithDescription(E_INVALIDARG, "negative vtable index")
+	}
+	vtbl := *(*unsafe.Pointer)(obj)
+	return *(*uintptr)(unsafe.Pointer(uintptr(vtbl) + uintptr(vtblIndex)*unsafe.Sizeof(uintptr(0)))), nil
+}
+
+// CallFuncDesc calls the method of a dual or vtable interface fdesc
+// describes, which tinfo returned, on obj. The arguments are converted to
+// the types of the parameters, and missing optional parameters filled with
+// their default values. The value of an [out, retval] parameter, or the
+// value of a method returning another type than HRESULT, is the result.
+//
+// tinfo resolves the user-defined types of the parameters, and may be nil
+// for methods without.
+func CallFuncDesc(obj unsafe.Pointer, tinfo *ITypeInfo, fdesc *FUNCDESC, args ...VARIANT) (*VARIANT, error) {
+	call, err := prepareFuncDesc(fdesc, args, typeInfoResolver(tinfo))
+	if err != nil {
+		return nil, err
+	}
+	r1, err := callMethod(obj, call.index, call.args)
+	runtime.KeepAlive(args)
+	if err != nil {
+		return nil, err
+	}
+	switch call.ret {
+	case VT_HRESULT:
+		if hr := HRESULT(int32(uint32(r1))); hr.Failed() {
+			return nil, NewError(uintptr(uint32(hr)))
+		}
+	case VT_VOID:
+	default:
+		call.result.VT = call.ret
+		call.result.Val = int64(r1)
+	}
+	if call.decimal {
+		call.result.VT = VT_DECIMAL
+	}
+	return call.result, nil
+}
+
+// funcDescCall is a call of a method described by a FUNCDESC.
+type funcDescCall struct {
+	index  int
+	args   []Arg
+	result *VARIANT // the value of the [out, retval] parameter, or the result
+	ret    VT       // type of the result of the method, if it goes in result
+
+	// decimal is set when the [out, retval] parameter is a DECIMAL, written
+	// over all of result.
+	decimal bool
+}
+
+// resolver returns the VT a user-defined type is passed as: VT_I4 for
+// enumerations, VT_UNKNOWN or VT_DISPATCH for interfaces and the type of
+// aliases.
+type resolver func(hreftype uint32) (VT, error)
+
+// typeInfoResolver returns the resolver of the user-defined types of tinfo.
+func typeInfoResolver(tinfo *ITypeInfo) resolver {
+	return func(hreftype uint32) (VT, error) {
+		if tinfo == nil {
+			return 0, NewErrorWithDescription(E_INVALIDARG, "no type information for a user-defined type")
+		}
+		ref, err := tinfo.GetRefTypeInfo(hreftype)
+		if err != nil {
+			return 0, err
+		}
+		defer ref.Release()
+		attr, err := ref.GetTypeAttr()
+		if err != nil {
+			return 0, err
+		}
+		switch attr.Typekind {
+		case TKIND_ENUM:
+			return VT_I4, nil
+		case TKIND_INTERFACE, TKIND_COCLASS:
+			return VT_UNKNOWN, nil
+		case TKIND_DISPATCH:
+			return VT_DISPATCH, nil
+		case TKIND_ALIAS:
+			switch vt := VT(attr.TdescAlias.VT); vt {
+			case VT_USERDEFINED:
+				return typeInfoResolver(ref)(attr.TdescAlias.Hreftype)
+			case VT_PTR, VT_CARRAY:
+				return 0, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "unsupported alias type")
+			default:
+				return vt, nil
+			}
+		}
+		return 0, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "structures are not passed by value")
+	}
+}
+
+// prepareFuncDesc converts the arguments of a call of the method fdesc
+// describes.
+func prepareFuncDesc(fdesc *FUNCDESC, args []VARIANT, resolve resolver) (*funcDescCall, error) {
+	if fdesc.FuncKind != FUNC_VIRTUAL && fdesc.FuncKind != FUNC_PUREVIRTUAL {
+		return nil, NewErrorWithDescription(E_INVALIDARG, "not a method of a vtable")
+	}
+	if fdesc.CallConv != CC_STDCALL && fdesc.CallConv != CC_CDECL {
+		return nil, NewErrorWithDescription(E_INVALIDARG, "unsupported calling convention")
+	}
+	ptrSize := int(unsafe.Sizeof(uintptr(0)))
+	if fdesc.OVft < 0 || int(fdesc.OVft)%ptrSize != 0 {
+		return nil, NewErrorWithDescription(E_INVALIDARG, "invalid vtable offset")
+	}
+	call := &funcDescCall{index: int(fdesc.OVft) / ptrSize, result: new(VARIANT)}
+
+	params := fdesc.Params
+	var retval *ELEMDESC
+	if n := len(params); n > 0 && params[n-1].Flags&PARAMFLAG_FRETVAL != 0 {
+		retval = &params[n-1]
+		params = params[:n-1]
+	}
+	if len(args) > len(params) {
+		return nil, NewError(DISP_E_BADPARAMCOUNT)
+	}
+	for i := range params {
+		p := &params[i]
+		var arg *VARIANT
+		switch {
+		case i < len(args):
+			arg = &args[i]
+		case p.Flags&PARAMFLAG_FHASDEFAULT != 0 && p.Default != nil:
+			arg = new(VARIANT)
+			*arg = *p.Default
+		case p.Flags&PARAMFLAG_FOPT != 0 && VT(p.Tdesc.VT) == VT_VARIANT:
+			missing := NewVariant(VT_ERROR, DISP_E_PARAMNOTFOUND)
+			arg = &missing
+		default:
+			return nil, NewError(DISP_E_BADPARAMCOUNT)
+		}
+		a, err := argOf(&p.Tdesc, arg, resolve)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, a)
+	}
+
+	if retval != nil {
+		if VT(retval.Tdesc.VT) != VT_PTR || retval.Tdesc.Lptdesc == nil {
+			return nil, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "[retval] parameter is not a pointer")
+		}
+		vt, err := passedVT(retval.Tdesc.Lptdesc, resolve)
+		if err != nil {
+			return nil, err
+		}
+		switch vt {
+		case VT_VARIANT:
+			call.args = append(call.args, PointerArg(unsafe.Pointer(call.result)))
+		case VT_DECIMAL:
+			// A DECIMAL takes the whole VARIANT, its reserved word in
+			// place of VT, which is set once the method returns.
+			call.decimal = true
+			call.args = append(call.args, PointerArg(unsafe.Pointer(call.result)))
+		default:
+			call.result.VT = vt
+			call.args = append(call.args, PointerArg(unsafe.Pointer(&call.result.Val)))
+		}
+	}
+
+	ret, err := passedVT(&fdesc.ElemdescFunc.Tdesc, resolve)
+	if err != nil {
+		return nil, err
+	}
+	switch ret {
+	case VT_HRESULT, VT_VOID:
+	case VT_I1, VT_UI1, VT_I2, VT_UI2, VT_I4, VT_UI4, VT_INT, VT_UINT, VT_BOOL, VT_ERROR,
+		VT_BSTR, VT_UNKNOWN, VT_DISPATCH, VT_INT_PTR, VT_UINT_PTR:
+		if retval != nil {
+			return nil, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "method with both a result and a [retval] parameter")
+		}
+	default:
+		return nil, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "unsupported result type "+ret.String())
+	}
+	call.ret = ret
+	return call, nil
+}
+
+// passedVT returns the type of a parameter, with user-defined types
+// resolved and arrays as VT_ARRAY combined with the type of the elements.
+func passedVT(t *TYPEDESC, resolve resolver) (VT, error) {
+	switch VT(t.VT) {
+	case VT_USERDEFINED:
+		return resolve(t.Hreftype)
+	case VT_SAFEARRAY:
+		if t.Lptdesc == nil {
+			return VT_ARRAY | VT_VARIANT, nil
+		}
+		elem, err := passedVT(t.Lptdesc, resolve)
+		return VT_ARRAY | elem, err
+	}
+	return VT(t.VT), nil
+}
+
+// argOf converts v to an argument of type t.
+func argOf(t *TYPEDESC, v *VARIANT, resolve resolver) (Arg, error) {
+	vt, err := passedVT(t, resolve)
+	if err != nil {
+		return Arg{}, err
+	}
+
+	if vt == VT_PTR {
+		if t.Lptdesc == nil {
+			return Arg{}, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "pointer to no type")
+		}
+		elem, err := passedVT(t.Lptdesc, resolve)
+		if err != nil {
+			return Arg{}, err
+		}
+		switch {
+		case elem == VT_UNKNOWN || elem == VT_DISPATCH:
+			// Interface pointers.
+			if v.VT == VT_UNKNOWN || v.VT == VT_DISPATCH {
+				return PointerArg(v.pointer()), nil
+			}
+			if v.VT == VT_EMPTY || v.VT == VT_NULL {
+				return PointerArg(nil), nil
+			}
+		case v.VT == VT_BYREF|elem:
+			return PointerArg(v.pointer()), nil
+		case elem == VT_VARIANT:
+			return PointerArg(unsafe.Pointer(v)), nil
+		}
+		return Arg{}, typeMismatch(v.VT, vt)
+	}
+
+	if vt == VT_VARIANT {
+		return VariantArg(*v), nil
+	}
+	if v.VT != vt && !(vt == VT_INT && v.VT == VT_I4) && !(vt == VT_UINT && v.VT == VT_UI4) {
+		return Arg{}, typeMismatch(v.VT, vt)
+	}
+	if vt&VT_ARRAY != 0 {
+		return PointerArg(v.pointer()), nil
+	}
+	switch vt {
+	case VT_I1:
+		return Int32Arg(int32(int8(v.Val))), nil
+	case VT_UI1:
+		return Uint32Arg(uint32(uint8(v.Val))), nil
+	case VT_I2, VT_BOOL:
+		return Int32Arg(int32(int16(v.Val))), nil
+	case VT_UI2:
+		return Uint32Arg(uint32(uint16(v.Val))), nil
+	case VT_I4, VT_INT, VT_ERROR, VT_HRESULT:
+		return Int32Arg(int32(v.Val)), nil
+	case VT_UI4, VT_UINT:
+		return Uint32Arg(uint32(v.Val)), nil
+	case VT_I8, VT_UI8, VT_CY:
+		return Int64Arg(v.Val), nil
+	case VT_R4:
+		return Float32Arg(math.Float32frombits(uint32(v.Val))), nil
+	case VT_R8, VT_DATE:
+		return Float64Arg(math.Float64frombits(uint64(v.Val))), nil
+	case VT_INT_PTR, VT_UINT_PTR:
+		return UintptrArg(uintptr(v.Val)), nil
+	case VT_BSTR, VT_UNKNOWN, VT_DISPATCH, VT_LPSTR, VT_LPWSTR:
+		return PointerArg(v.pointer()), nil
+	case VT_DECIMAL:
+		return StructArg(unsafe.Pointer(v), 16), nil
+	}
+	return Arg{}, NewErrorWithDescription(DISP_E_TYPEMISMATCH, "unsupported parameter type "+vt.String())
+}
+
+// pointer returns the pointer a VARIANT holds.
+func (v *VARIANT) pointer() unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&v.Val))
+}
+
+func typeMismatch(got, want VT) error {
+	return NewErrorWithDescription(DISP_E_TYPEMISMATCH, "argument of type "+got.String()+" for a parameter of type "+want.String())
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package ole
+
+import "unsafe"
+
+func callMethod(obj unsafe.Pointer, vtblIndex int, args []Arg) (uintptr, error) {
+	return 0, NewError(E_NOTIMPL)
+}
@@ -0,0 +1,225 @@
+package ole
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func f64(v float64) uint64 { return math.Float64bits(v) }
+func f32(v float32) uint64 { return uint64(math.Float32bits(v)) }
+
+func TestLayoutCall(t *testing.T) {
+	point := struct{ X, Y int32 }{1, 2}
+	rect := struct{ L, T, R, B int32 }{1, 2, 3, 4}
+	args := []Arg{
+		UintptrArg(0x10),
+		Int32Arg(-1),
+		Float64Arg(1.5),
+		Int64Arg(-2),
+		Float32Arg(0.5),
+		StructArg(unsafe.Pointer(&point), unsafe.Sizeof(point)),
+		Float32StructArg(3, 4),
+	}
+
+	tests := []struct {
+		arch                string
+		ints, floats, stack []uint64
+	}{
+		{
+			arch: "386",
+			stack: []uint64{
+				0x10, 0xffffffff,
+				f64(1.5) & 0xffffffff, f64(1.5) >> 32,
+				0xfffffffe, 0xffffffff,
+				f32(0.5),
+				1, 2,
+				f32(3), f32(4),
+			},
+		},
+		{
+			arch:  "amd64",
+			stack: []uint64{0x10, 0xffffffff, f64(1.5), 0xfffffffffffffffe, f32(0.5), 2<<32 | 1, f32(4)<<32 | f32(3)},
+		},
+		{
+			arch:   "arm64",
+			ints:   []uint64{0x10, 0xffffffff, 0xfffffffffffffffe, 2<<32 | 1},
+			floats: []uint64{f64(1.5), f32(0.5), f32(3), f32(4)},
+		},
+	}
+	for _, test := range tests {
+		f, err := layoutCall(test.arch, args)
+		if err != nil {
+			t.Errorf("%s: %v", test.arch, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.ints, test.ints) || !reflect.DeepEqual(f.floats, test.floats) ||
+			!reflect.DeepEqual(f.stack, test.stack) || len(f.copies) != 0 {
+			t.Errorf("%s: frame = %#x %#x %#x, want %#x %#x %#x",
+				test.arch, f.ints, f.floats, f.stack, test.ints, test.floats, test.stack)
+		}
+	}
+
+	// Structures of other sizes.
+	f, err := layoutCall("amd64", []Arg{StructArg(unsafe.Pointer(&rect), unsafe.Sizeof(rect))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.copies) != 1 || f.stack[0] != uint64(uintptr(unsafe.Pointer(&f.copies[0][0]))) ||
+		f.copies[0][0] != 2<<32|1 || f.copies[0][1] != 4<<32|3 {
+		t.Errorf("amd64 does not pass a 16-byte structure by reference: %#x %#x", f.stack, f.copies)
+	}
+	f, err = layoutCall("arm64", []Arg{StructArg(unsafe.Pointer(&rect), unsafe.Sizeof(rect))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.ints, []uint64{2<<32 | 1, 4<<32 | 3}) || len(f.copies) != 0 {
+		t.Errorf("arm64 does not pass a 16-byte structure in two registers: %#x", f.ints)
+	}
+	var v VARIANT
+	f, err = layoutCall("arm64", []Arg{VariantArg(v)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unsafe.Sizeof(v) > 16 && (len(f.copies) != 1 || len(f.ints) != 1) {
+		t.Errorf("arm64 does not pass a %d-byte VARIANT by reference: %#x", unsafe.Sizeof(v), f.ints)
+	}
+}
+
+func TestLayoutCall_arm64Stack(t *testing.T) {
+	var args []Arg
+	for i := 0; i < 9; i++ {
+		args = append(args, Int32Arg(int32(i)), Float64Arg(float64(i)))
+	}
+	// A structure of 2 words does not fit in the last register, and no
+	// longer does anything after it.
+	pair := [2]uint64{100, 101}
+	args = append(args[:14], StructArg(unsafe.Pointer(&pair), 16), Int32Arg(7), Float64StructArg(8, 9))
+	f, err := layoutCall("arm64", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInts := []uint64{0, 1, 2, 3, 4, 5, 6, 0}
+	wantFloats := []uint64{f64(0), f64(1), f64(2), f64(3), f64(4), f64(5), f64(6)}
+	wantStack := []uint64{100, 101, 7, f64(8), f64(9)}
+	if !reflect.DeepEqual(f.ints, wantInts) || !reflect.DeepEqual(f.floats, wantFloats) || !reflect.DeepEqual(f.stack, wantStack) {
+		t.Errorf("frame = %#x %#x %#x, want %#x %#x %#x", f.ints, f.floats, f.stack, wantInts, wantFloats, wantStack)
+	}
+}
+
+func TestLayoutCall_errors(t *testing.T) {
+	if _, err := layoutCall("mips", nil); err == nil {
+		t.Error("laid out a call for mips")
+	}
+	args := make([]Arg, maxCallWords+1)
+	for i := range args {
+		args[i] = Int32Arg(0)
+	}
+	if _, err := layoutCall("amd64", args); err == nil {
+		t.Error("laid out a call of too many arguments")
+	}
+}
+
+func TestPointerArg(t *testing.T) {
+	x := new(int)
+	a := PointerArg(unsafe.Pointer(x))
+	if a.word() != uint64(uintptr(unsafe.Pointer(x))) {
+		t.Errorf("PointerArg(%p) = %#x", x, a.word())
+	}
+	if _, err := CallMethod(nil, 0); err == nil {
+		t.Error("CallMethod(nil) succeeded")
+	}
+}
+
+func TestPrepareFuncDesc(t *testing.T) {
+	i4 := TYPEDESC{VT: uint16(VT_I4)}
+	bstr := TYPEDESC{VT: uint16(VT_BSTR)}
+	variant := TYPEDESC{VT: uint16(VT_VARIANT)}
+	enum := TYPEDESC{VT: uint16(VT_USERDEFINED), Hreftype: 7}
+	def := NewVariant(VT_I4, 9)
+	fdesc := &FUNCDESC{
+		FuncKind: FUNC_PUREVIRTUAL,
+		CallConv: CC_STDCALL,
+		OVft:     int16(7 * unsafe.Sizeof(uintptr(0))),
+		Params: []ELEMDESC{
+			{Tdesc: TYPEDESC{VT: uint16(VT_R8)}},
+			{Tdesc: enum},
+			{Tdesc: TYPEDESC{VT: uint16(VT_PTR), Lptdesc: &variant}},
+			{Tdesc: i4, Flags: PARAMFLAG_FOPT | PARAMFLAG_FHASDEFAULT, Default: &def},
+			{Tdesc: variant, Flags: PARAMFLAG_FOPT},
+			{Tdesc: TYPEDESC{VT: uint16(VT_PTR), Lptdesc: &bstr}, Flags: PARAMFLAG_FOUT | PARAMFLAG_FRETVAL},
+		},
+		ElemdescFunc: ELEMDESC{Tdesc: TYPEDESC{VT: uint16(VT_HRESULT)}},
+	}
+	resolve := func(hreftype uint32) (VT, error) {
+		if hreftype != 7 {
+			t.Errorf("resolved hreftype %d", hreftype)
+		}
+		return VT_I4, nil
+	}
+
+	inout := NewVariant(VT_BSTR, 0)
+	args := []VARIANT{NewVariant(VT_R8, int64(f64(2.5))), NewVariant(VT_I4, 3), inout}
+	call, err := prepareFuncDesc(fdesc, args, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.index != 7 || call.ret != VT_HRESULT || call.result.VT != VT_BSTR || len(call.args) != 6 {
+		t.Fatalf("call = %+v", call)
+	}
+	want := []Arg{Float64Arg(2.5), Int32Arg(3), PointerArg(unsafe.Pointer(&args[2])), Int32Arg(9)}
+	for i, w := range want {
+		if a := call.args[i]; a.kind != w.kind || a.word() != w.word() {
+			t.Errorf("argument %d = %#x, want %#x", i, a.word(), w.word())
+		}
+	}
+	missing := VARIANT{VT: VT_ERROR, Val: DISP_E_PARAMNOTFOUND}
+	if a := call.args[4]; a.kind != argStruct || !reflect.DeepEqual(a.data, VariantArg(missing).data) {
+		t.Errorf("missing optional VARIANT = %v", a.data)
+	}
+	if a := call.args[5]; a.word() != uint64(uintptr(unsafe.Pointer(&call.result.Val))) {
+		t.Errorf("[retval] argument = %#x, want the address of the result", a.word())
+	}
+
+	for _, bad := range [][]VARIANT{
+		{},
+		{NewVariant(VT_R8, 0), NewVariant(VT_BSTR, 0), inout},
+		{NewVariant(VT_I4, 0), NewVariant(VT_I4, 0), inout},
+		{NewVariant(VT_R8, 0), NewVariant(VT_I4, 0), inout, def, def, def},
+	} {
+		if _, err := prepareFuncDesc(fdesc, bad, resolve); err == nil {
+			t.Errorf("prepared a call with arguments %v", bad)
+		}
+	}
+
+	dispatch := *fdesc
+	dispatch.FuncKind = FUNC_DISPATCH
+	if _, err := prepareFuncDesc(&dispatch, args, resolve); err == nil {
+		t.Error("prepared a call of a FUNC_DISPATCH function")
+	}
+}
+
+func TestPrepareFuncDesc_decimalRetval(t *testing.T) {
+	decimal := TYPEDESC{VT: uint16(VT_DECIMAL)}
+	fdesc := &FUNCDESC{
+		FuncKind: FUNC_PUREVIRTUAL,
+		CallConv: CC_STDCALL,
+		OVft:     int16(7 * unsafe.Sizeof(uintptr(0))),
+		Params: []ELEMDESC{
+			{Tdesc: TYPEDESC{VT: uint16(VT_PTR), Lptdesc: &decimal}, Flags: PARAMFLAG_FOUT | PARAMFLAG_FRETVAL},
+		},
+		ElemdescFunc: ELEMDESC{Tdesc: TYPEDESC{VT: uint16(VT_HRESULT)}},
+	}
+	call, err := prepareFuncDesc(fdesc, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(call.args) != 1 || !call.decimal {
+		t.Fatalf("call = %+v", call)
+	}
+	// The DECIMAL is as large as the VARIANT, so it must start with it.
+	if a := call.args[0]; a.word() != uint64(uintptr(unsafe.Pointer(call.result))) {
+		t.Errorf("[retval] argument = %#x, want the address of the result VARIANT", a.word())
+	}
+}
@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+package ole
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+func callMethod(obj unsafe.Pointer, vtblIndex int, args []Arg) (uintptr, error) {
+	fn, err := methodAddress(obj, vtblIndex)
+	if err != nil {
+		return 0, err
+	}
+	all := make([]Arg, 0, len(args)+1)
+	all = append(all, PointerArg(obj))
+	return callFunc(fn, append(all, args...))
+}
+
+// callFunc calls the function at address fn with args.
+func callFunc(fn uintptr, args []Arg) (uintptr, error) {
+	f, err := layoutCall(runtime.GOARCH, args)
+	if err != nil {
+		return 0, err
+	}
+	r1 := f.call(fn)
+	runtime.KeepAlive(args)
+	runtime.KeepAlive(f)
+	return r1, nil
+}
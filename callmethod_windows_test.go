@@ -0,0 +1,58 @@
+//go:build windows
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestCallMethod_windows(t *testing.T) {
+	sum := syscall.NewCallback(func(this, a, b, c, d, e, f, g, h, i, j uintptr) uintptr {
+		return a + 2*b + 3*c + 4*d + 5*e + 6*f + 7*g + 8*h + 9*i + 10*j
+	})
+	fail := syscall.NewCallback(func(this uintptr) uintptr {
+		return E_FAIL
+	})
+	vtbl := []uintptr{0, 0, 0, sum, fail}
+	obj := &struct{ vtbl *uintptr }{&vtbl[0]}
+
+	var args []Arg
+	for i := int32(1); i <= 10; i++ {
+		args = append(args, Int32Arg(i))
+	}
+	hr, err := CallMethod(unsafe.Pointer(obj), 3, args...)
+	if err != nil || hr != 385 {
+		t.Errorf("CallMethod = %d, %v, want 385", hr, err)
+	}
+	hr, err = CallMethod(unsafe.Pointer(obj), 4)
+	if err == nil || uint32(hr) != E_FAIL {
+		t.Errorf("CallMethod = %#x, %v, want E_FAIL", uint32(hr), err)
+	}
+}
+
+func TestCallFunc_floats(t *testing.T) {
+	round := modoleaut32.NewProc("VarR8Round")
+	fromR8 := modoleaut32.NewProc("VarBstrFromR8")
+	if err := round.Find(); err != nil {
+		t.Skip(err)
+	}
+
+	var result float64
+	r1, err := callFunc(round.Addr(), []Arg{Float64Arg(2.75), Int32Arg(0), PointerArg(unsafe.Pointer(&result))})
+	if err != nil || r1 != 0 || result != 3 {
+		t.Errorf("VarR8Round(2.75, 0) = %v, %#x, %v, want 3", result, r1, err)
+	}
+
+	var bstr *uint16
+	r1, err = callFunc(fromR8.Addr(), []Arg{Float64Arg(1.5), Uint32Arg(0x409), Uint32Arg(0), PointerArg(unsafe.Pointer(&bstr))})
+	if err != nil || r1 != 0 {
+		t.Fatalf("VarBstrFromR8(1.5) = %#x, %v", r1, err)
+	}
+	defer SysFreeString((*int16)(unsafe.Pointer(bstr)))
+	if s := BstrToString(bstr); s != "1.5" {
+		t.Errorf("VarBstrFromR8(1.5) = %q, want 1.5", s)
+	}
+}
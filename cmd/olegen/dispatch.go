@@ -0,0 +1,411 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// member is a method of a wrapper, calling a member of the interface.
+type member struct {
+	name   string
+	member string // name in the library
+	doc    string
+	memid  int32
+	flags  string // DISPATCH_* constant
+	params []param
+	result string // Go type, or empty for none
+}
+
+// param is a parameter of a generated method.
+type param struct {
+	name     string
+	typ      string
+	optional bool
+	def      *typelib.Value
+}
+
+// embeddedNames are the names the wrappers get from the embedded
+// *ole.IDispatch, which their methods must not shadow.
+var embeddedNames = func() nameSet {
+	names := nameSet{"IDispatch": true}
+	t := reflect.TypeOf(&ole.IDispatch{})
+	for i := 0; i < t.NumMethod(); i++ {
+		names[t.Method(i).Name] = true
+	}
+	for i := 0; i < t.Elem().NumField(); i++ {
+		names[t.Elem().Field(i).Name] = true
+	}
+	names["RawVTable"] = true
+	return names
+}()
+
+// iface declares the IID of an interface and, for those that can be called
+// through IDispatch, the struct wrapping it.
+func (g *generator) iface(t *typelib.TypeInfo) {
+	prefix := "IID_"
+	if t.Attr.Typekind == ole.TKIND_DISPATCH {
+		prefix = "DIID_"
+	}
+	iid := g.guid(prefix, t.Name, t.Attr.Guid, fmt.Sprintf("identifies the %s interface.", t.Name))
+	wrapper, ok := g.wrappers[t]
+	if !ok {
+		return
+	}
+	g.usesOleutil = true
+
+	kind, queried, queriedName := "dual interface", iid, t.Name
+	if t.Attr.Typekind == ole.TKIND_DISPATCH {
+		kind, queried, queriedName = "dispinterface", "ole.IID_IDispatch", "IDispatch"
+	}
+	g.doc(fmt.Sprintf("%s calls the %s %s through IDispatch.", wrapper, kind, t.Name), t.DocString)
+	g.printf("type %s struct {\n*ole.IDispatch\n}\n", wrapper)
+
+	query := g.queries[t]
+	g.printf(`
+// %s returns the %s interface of obj, which the caller releases.
+func %s(obj *ole.IUnknown) (*%s, error) {
+	disp, err := obj.QueryInterface(%s)
+	if err != nil {
+		return nil, err
+	}
+	return &%s{disp}, nil
+}
+`, query, queriedName, query, wrapper, queried, wrapper)
+
+	for _, m := range g.members(t) {
+		g.method(wrapper, m)
+	}
+	if g.isEventSource(t) {
+		g.events(t, iid)
+	}
+}
+
+// members lists the methods of the wrapper of t: those of the interfaces it
+// inherits within the library, then its own.
+func (g *generator) members(t *typelib.TypeInfo) []member {
+	var chain []*typelib.TypeInfo
+	for base := t; base != nil && len(chain) < 32; {
+		chain = append([]*typelib.TypeInfo{base}, chain...)
+		var next *typelib.TypeInfo
+		if len(base.ImplTypes) > 0 && base.Attr.Typekind == ole.TKIND_INTERFACE {
+			if ref := g.lib.Ref(base.ImplTypes[0].Hreftype); ref != nil {
+				next = ref.Type
+			}
+		}
+		base = next
+	}
+
+	names := nameSet{}
+	for name := range embeddedNames {
+		names[name] = true
+	}
+	var members []member
+	for _, it := range chain {
+		puts := map[int32]bool{}
+		for _, f := range it.Funcs {
+			if f.InvKind == ole.INVOKE_PROPERTYPUT {
+				puts[f.Memid] = true
+			}
+		}
+		for _, f := range it.Funcs {
+			if f.Flags&ole.FUNCFLAG_FRESTRICTED != 0 {
+				continue
+			}
+			members = append(members, g.funcMember(f, names, puts[f.Memid]))
+		}
+		for _, v := range it.Vars {
+			if v.VarKind != ole.VAR_DISPATCH {
+				continue
+			}
+			members = append(members, g.propertyMembers(v, names)...)
+		}
+	}
+	return members
+}
+
+// funcMember describes the method calling f. hasPut tells a property
+// putref apart from a property put of the same name.
+func (g *generator) funcMember(f *typelib.Func, names nameSet, hasPut bool) member {
+	m := member{member: f.Name, doc: f.DocString, memid: f.Memid}
+	name := exported(f.Name)
+	switch f.InvKind {
+	case ole.INVOKE_PROPERTYGET:
+		m.flags = "ole.DISPATCH_PROPERTYGET"
+	case ole.INVOKE_PROPERTYPUT:
+		m.flags, name = "ole.DISPATCH_PROPERTYPUT", "Set"+name
+	case ole.INVOKE_PROPERTYPUTREF:
+		m.flags, name = "ole.DISPATCH_PROPERTYPUTREF", "Set"+name
+		if hasPut {
+			name += "Ref"
+		}
+	default:
+		m.flags = "ole.DISPATCH_METHOD"
+	}
+	m.name = names.unique(name)
+
+	var params []typelib.Param
+	for _, p := range f.Params {
+		switch {
+		case p.Flags&ole.PARAMFLAG_FLCID != 0:
+			// Invoke passes the locale.
+		case p.Flags&ole.PARAMFLAG_FRETVAL != 0:
+			if p.Type.Lptdesc != nil {
+				m.result = g.resultType(p.Type.Lptdesc)
+			}
+		default:
+			params = append(params, p)
+		}
+	}
+	if m.result == "" {
+		switch ole.VT(f.Result.VT) {
+		case ole.VT_VOID, ole.VT_HRESULT:
+		default:
+			m.result = g.resultType(&f.Result)
+		}
+	}
+
+	var declared []string
+	for _, p := range params {
+		declared = append(declared, p.Name)
+	}
+	for i, name := range paramNames(declared) {
+		m.params = append(m.params, g.param(name, &params[i]))
+	}
+	return m
+}
+
+// param describes a parameter. Optional ones are passed as pointers, nil
+// omitting them, and parameters the server sets are pointers to the
+// variables receiving the values.
+func (g *generator) param(name string, p *typelib.Param) param {
+	out := param{name: name, def: p.Default}
+	if p.Flags&ole.PARAMFLAG_FOUT != 0 && ole.VT(p.Type.VT) == ole.VT_PTR && p.Type.Lptdesc != nil {
+		out.typ = "*" + g.resultType(p.Type.Lptdesc)
+		return out
+	}
+	out.typ = g.goType(&p.Type)
+	out.optional = p.Flags&(ole.PARAMFLAG_FOPT|ole.PARAMFLAG_FHASDEFAULT) != 0
+	if out.optional && out.typ != variantType {
+		out.typ = "*" + out.typ
+	}
+	return out
+}
+
+// resultType returns the Go type of values the server returns, which are
+// converted to Go values except for VARIANTs.
+func (g *generator) resultType(desc *ole.TYPEDESC) string {
+	typ := g.goType(desc)
+	if typ == variantType {
+		return "*ole.VARIANT"
+	}
+	return typ
+}
+
+// propertyMembers describes the methods getting and, unless it is read-only,
+// setting a dispinterface property.
+func (g *generator) propertyMembers(v *typelib.Var, names nameSet) []member {
+	name := exported(v.Name)
+	get := member{
+		name:   names.unique(name),
+		member: v.Name,
+		doc:    v.DocString,
+		memid:  v.Memid,
+		flags:  "ole.DISPATCH_PROPERTYGET",
+		result: g.resultType(&v.Type),
+	}
+	if v.Flags&ole.VARFLAG_FREADONLY != 0 {
+		return []member{get}
+	}
+	set := member{
+		name:   names.unique("Set" + name),
+		member: v.Name,
+		doc:    v.DocString,
+		memid:  v.Memid,
+		flags:  "ole.DISPATCH_PROPERTYPUT",
+		params: []param{{name: "value", typ: g.goType(&v.Type)}},
+	}
+	return []member{get, set}
+}
+
+// method writes the method of the wrapper calling m.
+func (g *generator) method(wrapper string, m member) {
+	summary := fmt.Sprintf("%s %s, DISPID %d.", m.name, describeCall(m), m.memid)
+	g.doc(summary, m.doc)
+	var defaults []string
+	for _, p := range m.params {
+		if p.def != nil {
+			if value, ok := literal(p.def); ok {
+				defaults = append(defaults, p.name+" "+value)
+			}
+		}
+	}
+	if len(defaults) > 0 {
+		g.printf("//\n// Default values: %s.\n", strings.Join(defaults, ", "))
+	}
+
+	var decls, args []string
+	for _, p := range m.params {
+		decls = append(decls, p.name+" "+p.typ)
+		if p.optional {
+			args = append(args, "oleutil.Optional("+p.name+")")
+		} else {
+			args = append(args, p.name)
+		}
+	}
+	call := fmt.Sprintf("oleutil.Invoke(obj.IDispatch, %d, %s", m.memid, m.flags)
+	if len(args) > 0 {
+		call += ", " + strings.Join(args, ", ")
+	}
+	call += ")"
+
+	sig := fmt.Sprintf("func (obj *%s) %s(%s)", wrapper, m.name, strings.Join(decls, ", "))
+	switch m.result {
+	case "":
+		g.printf(`%s error {
+	res, err := %s
+	if err != nil {
+		return err
+	}
+	return res.Clear()
+}
+`, sig, call)
+	case "*ole.VARIANT":
+		g.printf("%s (*ole.VARIANT, error) {\nreturn %s\n}\n", sig, call)
+	default:
+		g.printf(`%s (ret %s, err error) {
+	res, err := %s
+	if err != nil {
+		return
+	}
+	err = oleutil.ResultAs(res, &ret)
+	return
+}
+`, sig, m.result, call)
+	}
+}
+
+// describeCall tells what a method does with its member, for its comment.
+func describeCall(m member) string {
+	switch m.flags {
+	case "ole.DISPATCH_PROPERTYGET":
+		return "gets the property " + m.member
+	case "ole.DISPATCH_PROPERTYPUT":
+		return "sets the property " + m.member
+	case "ole.DISPATCH_PROPERTYPUTREF":
+		return "sets the property " + m.member + " by reference"
+	}
+	return "calls the method " + m.member
+}
+
+// isEventSource reports whether a class of the library raises its events
+// through t.
+func (g *generator) isEventSource(t *typelib.TypeInfo) bool {
+	for _, class := range g.lib.Types {
+		if class.Attr.Typekind != ole.TKIND_COCLASS {
+			continue
+		}
+		for _, impl := range class.ImplTypes {
+			if ref := g.lib.Ref(impl.Hreftype); ref != nil && ref.Type == t && impl.Flags&ole.IMPLTYPEFLAG_FSOURCE != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// events writes the handler of the events raised through t, whose IID
+// variable is iid, and the functions connecting it to an object.
+func (g *generator) events(t *typelib.TypeInfo, iid string) {
+	base := exported(t.Name)
+	handler := g.names.unique(base + "Handler")
+	sink := g.names.unique(unexported(base) + "Sink")
+	connect := g.names.unique("Connect" + base)
+	disconnect := g.names.unique("Disconnect" + base)
+
+	type event struct {
+		name   string
+		memid  int32
+		params []param
+		result string
+	}
+	var events []event
+	names := nameSet{"DispIDs": true}
+	for _, f := range t.Funcs {
+		if f.Flags&ole.FUNCFLAG_FRESTRICTED != 0 || f.InvKind != ole.INVOKE_FUNC {
+			continue
+		}
+		e := event{name: names.unique(exported(f.Name)), memid: f.Memid}
+		var fnames []string
+		for _, p := range f.Params {
+			fnames = append(fnames, p.Name)
+		}
+		for i, name := range paramNames(fnames) {
+			p := f.Params[i]
+			typ := g.goType(&p.Type)
+			if p.Flags&ole.PARAMFLAG_FOUT != 0 && ole.VT(p.Type.VT) == ole.VT_PTR && p.Type.Lptdesc != nil {
+				typ = "*" + g.resultType(p.Type.Lptdesc)
+			}
+			e.params = append(e.params, param{name: name, typ: typ})
+		}
+		switch ole.VT(f.Result.VT) {
+		case ole.VT_VOID, ole.VT_HRESULT:
+		default:
+			e.result = g.goType(&f.Result)
+		}
+		events = append(events, e)
+	}
+
+	g.doc(fmt.Sprintf("%s receives the events of %s.", handler, t.Name), "Events whose function is nil are ignored.")
+	g.printf("type %s struct {\n", handler)
+	for _, e := range events {
+		g.printf("%s func(%s) %s\n", e.name, paramList(e.params), e.result)
+	}
+	g.printf("}\n")
+
+	g.printf("\n// %s serves a %s as %s.\ntype %s struct {\nhandler *%s\n}\n", sink, handler, t.Name, sink, handler)
+	g.printf("\n// DispIDs implements oleutil.DispIDMapper.\nfunc (s *%s) DispIDs() map[string]int32 {\nreturn map[string]int32{\n", sink)
+	for _, e := range events {
+		g.printf("%q: %d,\n", e.name, e.memid)
+	}
+	g.printf("}\n}\n")
+
+	for _, e := range events {
+		var args []string
+		for _, p := range e.params {
+			args = append(args, p.name)
+		}
+		g.printf("\nfunc (s *%s) %s(%s)", sink, e.name, paramList(e.params))
+		if e.result == "" {
+			g.printf(" {\nif s.handler.%s != nil {\ns.handler.%s(%s)\n}\n}\n", e.name, e.name, strings.Join(args, ", "))
+		} else {
+			g.printf(" (ret %s) {\nif s.handler.%s != nil {\nret = s.handler.%s(%s)\n}\nreturn\n}\n", e.result, e.name, e.name, strings.Join(args, ", "))
+		}
+	}
+
+	g.printf(`
+// %s calls h for the events obj raises through
+// %s, until %s is called with the cookie
+// returned.
+func %s(obj *ole.IDispatch, h *%s) (cookie uint32, err error) {
+	return oleutil.ConnectObject(obj, %s, &%s{h})
+}
+
+// %s stops calling the handler connected with cookie.
+func %s(obj *ole.IDispatch, cookie uint32) error {
+	return oleutil.DisconnectObject(obj, %s, cookie)
+}
+`, connect, t.Name, disconnect, connect, handler, iid, sink, disconnect, disconnect, iid)
+}
+
+// paramList declares params.
+func paramList(params []param) string {
+	var decls []string
+	for _, p := range params {
+		decls = append(decls, p.name+" "+p.typ)
+	}
+	return strings.Join(decls, ", ")
+}
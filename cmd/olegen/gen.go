@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// generator writes the Go package wrapping a type library.
+type generator struct {
+	lib *typelib.Library
+	buf bytes.Buffer
+
+	// names holds the package-level identifiers, typeNames those of the
+	// types declared for enumerations, aliases and records, wrappers
+	// those of the structs wrapping dispatchable interfaces and queries
+	// those of the functions returning them.
+	names     nameSet
+	typeNames map[*typelib.TypeInfo]string
+	wrappers  map[*typelib.TypeInfo]string
+	queries   map[*typelib.TypeInfo]string
+
+	usesTime    bool
+	usesOleutil bool
+}
+
+// generate returns the source of package pkg wrapping lib. source names the
+// library in the header of the file.
+func generate(lib *typelib.Library, pkg, source string) ([]byte, error) {
+	g := &generator{
+		lib:       lib,
+		names:     nameSet{},
+		typeNames: map[*typelib.TypeInfo]string{},
+		wrappers:  map[*typelib.TypeInfo]string{},
+		queries:   map[*typelib.TypeInfo]string{},
+	}
+	g.declareNames()
+	g.library()
+	for _, t := range lib.Types {
+		switch t.Attr.Typekind {
+		case ole.TKIND_ENUM:
+			g.enum(t)
+		case ole.TKIND_RECORD:
+			g.record(t)
+		case ole.TKIND_ALIAS:
+			g.alias(t)
+		case ole.TKIND_INTERFACE, ole.TKIND_DISPATCH:
+			g.iface(t)
+		case ole.TKIND_COCLASS:
+			g.coclass(t)
+		case ole.TKIND_MODULE:
+			g.module(t)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by olegen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "// Package %s wraps the %s type library.\n", pkg, lib.Name)
+	if lib.DocString != "" {
+		fmt.Fprintf(&out, "//\n// %s\n", comment(lib.DocString))
+	}
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkg)
+	if g.usesTime {
+		out.WriteString("\t\"time\"\n\n")
+	}
+	out.WriteString("\tole \"github.com/go-ole/go-ole\"\n")
+	if g.usesOleutil {
+		out.WriteString("\t\"github.com/go-ole/go-ole/oleutil\"\n")
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// declareNames names the Go types of the library before any is written, as
+// types may refer to types declared after them.
+func (g *generator) declareNames() {
+	for _, t := range g.lib.Types {
+		switch t.Attr.Typekind {
+		case ole.TKIND_ENUM, ole.TKIND_RECORD, ole.TKIND_ALIAS:
+			g.typeNames[t] = g.names.unique(exported(t.Name))
+		case ole.TKIND_INTERFACE, ole.TKIND_DISPATCH:
+			if isDispatchable(t) {
+				g.wrappers[t] = g.names.unique(exported(t.Name))
+				g.queries[t] = g.names.unique("Query" + g.wrappers[t])
+			}
+		}
+	}
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// doc writes the comment of a declaration: what it is, then the help string
+// of the library.
+func (g *generator) doc(summary, docString string) {
+	g.printf("\n// %s\n", summary)
+	if docString != "" {
+		g.printf("//\n// %s\n", comment(docString))
+	}
+}
+
+// comment makes text fit on comment lines.
+func comment(text string) string {
+	text = strings.TrimSpace(strings.Replace(text, "\r\n", "\n", -1))
+	return strings.Replace(text, "\n", "\n// ", -1)
+}
+
+// guid declares the variable holding a GUID of the library.
+func (g *generator) guid(prefix, name string, guid ole.GUID, summary string) string {
+	ident := g.names.unique(prefix + identifier(name))
+	g.printf("\n// %s %s\nvar %s = ole.NewGUID(%q)\n", ident, summary, ident, guid.String())
+	return ident
+}
+
+func (g *generator) library() {
+	g.guid("LIBID_", g.lib.Name, g.lib.Attr.Guid,
+		fmt.Sprintf("identifies the %s type library, version %d.%d.", g.lib.Name, g.lib.Attr.WMajorVerNum, g.lib.Attr.WMinorVerNum))
+}
+
+// enum declares an enumeration as a named integer type and its constants.
+func (g *generator) enum(t *typelib.TypeInfo) {
+	name := g.typeNames[t]
+	g.doc(fmt.Sprintf("%s is the enumeration %s.", name, t.Name), t.DocString)
+	g.printf("type %s int32\n", name)
+	g.constants(t.Vars, name, "")
+}
+
+// constants declares the constants among vars, of type typeName or, when it
+// is empty, untyped. doc comments the block.
+func (g *generator) constants(vars []*typelib.Var, typeName, doc string) {
+	var decls []string
+	for _, v := range vars {
+		if v.VarKind != ole.VAR_CONST || v.Value == nil {
+			continue
+		}
+		value, ok := literal(v.Value)
+		if !ok {
+			continue
+		}
+		decl := g.names.unique(exported(v.Name))
+		if typeName != "" {
+			decl += " " + typeName
+		}
+		decl += " = " + value
+		if v.DocString != "" {
+			decl = "// " + comment(v.DocString) + "\n" + decl
+		}
+		decls = append(decls, decl)
+	}
+	if len(decls) > 0 {
+		g.printf("\n")
+		if doc != "" {
+			g.printf("// %s\n", doc)
+		}
+		g.printf("const (\n%s\n)\n", strings.Join(decls, "\n"))
+	}
+}
+
+// record declares a structure with the layout of the library's.
+func (g *generator) record(t *typelib.TypeInfo) {
+	name := g.typeNames[t]
+	g.doc(fmt.Sprintf("%s is the structure %s.", name, t.Name), t.DocString)
+	g.printf("type %s struct {\n", name)
+	fields := nameSet{}
+	for _, v := range t.Vars {
+		if v.DocString != "" {
+			g.printf("// %s\n", comment(v.DocString))
+		}
+		g.printf("%s %s\n", fields.unique(exported(v.Name)), g.fieldType(&v.Type))
+	}
+	g.printf("}\n")
+}
+
+// fieldType returns the Go type of a structure field. Unlike arguments,
+// fields have fixed-size arrays and are never VARIANTs, which stand for the
+// VARIANT structure itself.
+func (g *generator) fieldType(desc *ole.TYPEDESC) string {
+	switch ole.VT(desc.VT) {
+	case ole.VT_CARRAY:
+		if desc.Lpadesc == nil {
+			break
+		}
+		var dims string
+		for _, bound := range desc.Lpadesc.Bounds {
+			dims += fmt.Sprintf("[%d]", bound.Elements)
+		}
+		return dims + g.fieldType(&desc.Lpadesc.TdescElem)
+	case ole.VT_VARIANT:
+		return "ole.VARIANT"
+	}
+	return g.goType(desc)
+}
+
+func (g *generator) alias(t *typelib.TypeInfo) {
+	name := g.typeNames[t]
+	g.doc(fmt.Sprintf("%s is the type %s.", name, t.Name), t.DocString)
+	g.printf("type %s = %s\n", name, g.goType(&t.Attr.TdescAlias))
+}
+
+// module declares the constants of a module. Its functions are DLL entry
+// points, which are not wrapped.
+func (g *generator) module(t *typelib.TypeInfo) {
+	g.constants(t.Vars, "", fmt.Sprintf("Constants of the module %s.", t.Name))
+}
+
+// coclass declares the CLSID of a class and the function creating its objects
+// through their default interface.
+func (g *generator) coclass(t *typelib.TypeInfo) {
+	clsid := g.guid("CLSID_", t.Name, t.Attr.Guid, fmt.Sprintf("identifies the %s class.", t.Name))
+	if t.Attr.WTypeFlags&ole.TYPEFLAG_FCANCREATE == 0 {
+		return
+	}
+
+	var def *typelib.TypeInfo
+	for _, impl := range t.ImplTypes {
+		if impl.Flags&ole.IMPLTYPEFLAG_FSOURCE != 0 {
+			continue
+		}
+		ref := g.lib.Ref(impl.Hreftype)
+		if ref == nil || ref.Type == nil {
+			continue
+		}
+		if def == nil || impl.Flags&ole.IMPLTYPEFLAG_FDEFAULT != 0 {
+			def = ref.Type
+		}
+	}
+	wrapper, ok := g.wrappers[def]
+	if !ok {
+		return
+	}
+
+	name := g.names.unique("New" + exported(t.Name))
+	g.doc(fmt.Sprintf("%s creates a %s object.", name, t.Name), t.DocString)
+	g.printf(`func %s() (*%s, error) {
+	unknown, err := ole.CreateInstance(%s, ole.IID_IUnknown)
+	if err != nil {
+		return nil, err
+	}
+	defer unknown.Release()
+	return %s(unknown)
+}
+`, name, wrapper, clsid, g.queries[def])
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-ole/go-ole/typelib"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const goldenFile = "testdata/gooletest/gooletest.go"
+
+func TestGenerate(t *testing.T) {
+	lib, err := typelib.ReadFile("../../typelib/testdata/test.tlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(lib, "gooletest", "test.tlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile(goldenFile, src, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, golden) {
+		t.Errorf("generated code differs from %s; run go test -update to accept it", goldenFile)
+	}
+}
+
+// TestGenerate_builds vets the golden packages for Windows and another
+// platform, which the go command skips in testdata otherwise.
+func TestGenerate_builds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	gocmd := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(gocmd); err != nil {
+		if gocmd, err = exec.LookPath("go"); err != nil {
+			t.Skip("go command not found")
+		}
+	}
+	for _, goos := range []string{"windows", "linux"} {
+		cmd := exec.Command(gocmd, "vet", "./"+filepath.Dir(goldenFile), "./"+filepath.Dir(vtableGolden))
+		cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=amd64")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("GOOS=%s go vet: %v\n%s", goos, err, strings.TrimSpace(string(out)))
+		}
+	}
+}
+
+func TestParamNames(t *testing.T) {
+	got := paramNames([]string{"type", "", "err", "value", "value", "2d"})
+	want := []string{"type_", "arg2", "err_", "value", "value_", "arg2d"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("paramNames = %q, want %q", got, want)
+			break
+		}
+	}
+}
+
+func TestExported(t *testing.T) {
+	for name, want := range map[string]string{
+		"_Application": "Application",
+		"count":        "Count",
+		"__":           "X",
+		"3D":           "X3D",
+		"a-b":          "A_b",
+	} {
+		if got := exported(name); got != want {
+			t.Errorf("exported(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// idlMain runs the idl subcommand, which writes a library or one of its
+// types as IDL.
+func idlMain(args []string) {
+	flags := flag.NewFlagSet("olegen idl", flag.ExitOnError)
+	typeName := flags.String("type", "", "write only the type called `name`")
+	output := flags.String("o", "", "output `file`, by default the standard output")
+	object := flags.String("object", "", "read the library of a running instance of `progid` (Windows only)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: olegen idl [-type name] [-o file] {library | -object progid}\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	lib, _ := load(flags, *object)
+	var buf bytes.Buffer
+	var err error
+	if *typeName == "" {
+		err = typelib.WriteIDL(&buf, lib)
+	} else if t := lib.Type(*typeName); t != nil {
+		err = typelib.WriteTypeIDL(&buf, lib, t)
+	} else {
+		err = fmt.Errorf("no type %s in %s", *typeName, lib.Name)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	write(*output, buf.Bytes())
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// loadObject reads the type library of a running object, which needs COM.
+func loadObject(progid string) (*typelib.Library, error) {
+	return nil, ole.NewErrorWithDescription(ole.E_NOTIMPL, "-object is only supported on Windows")
+}
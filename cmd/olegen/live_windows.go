@@ -0,0 +1,42 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// loadObject reads the type library holding the type information of a new
+// instance of progid.
+func loadObject(progid string) (*typelib.Library, error) {
+	if err := ole.CoInitialize(0); err != nil {
+		return nil, err
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject(progid)
+	if err != nil {
+		return nil, err
+	}
+	defer unknown.Release()
+	disp, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return nil, err
+	}
+	defer disp.Release()
+
+	tinfo, err := disp.GetTypeInfo()
+	if err != nil {
+		return nil, err
+	}
+	defer tinfo.Release()
+	tlib, _, err := tinfo.GetContainingTypeLib()
+	if err != nil {
+		return nil, err
+	}
+	defer tlib.Release()
+	return typelib.Load(tlib)
+}
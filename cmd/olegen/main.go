@@ -0,0 +1,122 @@
+// Command olegen generates Go packages wrapping type libraries.
+//
+// Usage:
+//
+//	olegen [-pkg name] [-o file] library.tlb
+//	olegen [-pkg name] [-o file] -object progid
+//	olegen idl [-type name] [-o file] {library.tlb | -object progid}
+//	olegen vtable [-pkg name] [-o prefix] file.idl
+//
+// The library is read from a .tlb file, or from the first TYPELIB resource
+// of a DLL or executable. On Windows, -object reads instead the library of
+// the type information of a running instance of progid.
+//
+// The package holds the GUIDs of the library, a named type and constants for
+// each enumeration, a struct for each record and, for each dispinterface and
+// dual interface, a struct embedding *ole.IDispatch whose methods call the
+// members of the interface through IDispatch with their DISPIDs. Optional
+// parameters are pointers, nil omitting them. Each creatable class gets a
+// function creating an object, and each event interface of a class a
+// handler struct of functions, connected with the generated Connect
+// function. The package builds on every platform; calls fail with E_NOTIMPL
+// outside Windows.
+//
+// The idl subcommand writes instead the library, or only its type called
+// name, as IDL.
+//
+// The vtable subcommand reads instead the interfaces of an IDL file, in the
+// subset of MIDL of the interface headers of the Windows SDK, and writes
+// bindings calling their vtables in the style of the interfaces of the ole
+// package: prefix.go holds the IIDs, the types and a struct and vtable struct
+// for each interface, registered for ole.QueryInterfaceAs, prefix_windows.go
+// methods calling the vtable and prefix_func.go methods failing with
+// E_NOTIMPL elsewhere. [out] parameters are returned as results, strings
+// converted, and a failed HRESULT returned as an error. Methods taking
+// floating-point numbers or 64-bit integers, which the syscall package cannot
+// pass, are called with ole.CallMethod; those taking structures by value keep
+// only their slot in the vtable.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ole/go-ole/typelib"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "idl" {
+		idlMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "vtable" {
+		vtableMain(os.Args[2:])
+		return
+	}
+
+	flags := flag.NewFlagSet("olegen", flag.ExitOnError)
+	pkg := flags.String("pkg", "", "package `name`, by default the library name in lower case")
+	output := flags.String("o", "", "output `file`, by default the standard output")
+	object := flags.String("object", "", "read the library of a running instance of `progid` (Windows only)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: olegen [-pkg name] [-o file] {library | -object progid}\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	lib, source := load(flags, *object)
+	name := *pkg
+	if name == "" {
+		name = strings.ToLower(identifier(lib.Name))
+	}
+	src, err := generate(lib, name, source)
+	if err != nil {
+		fatal(err)
+	}
+	write(*output, src)
+}
+
+// load reads the library the arguments name: the file argument of flags, or
+// the library of object. It returns the library and a name for its source.
+func load(flags *flag.FlagSet, object string) (*typelib.Library, string) {
+	var lib *typelib.Library
+	var source string
+	var err error
+	switch {
+	case object != "" && flags.NArg() == 0:
+		source = object
+		lib, err = loadObject(object)
+	case object == "" && flags.NArg() == 1:
+		source = filepath.Base(flags.Arg(0))
+		lib, err = typelib.ReadFile(flags.Arg(0))
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	return lib, source
+}
+
+// write writes data to the file output, or the standard output if empty.
+func write(output string, data []byte) {
+	var err error
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(output, data, 0666)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "olegen:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,801 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The vtable subcommand reads the subset of MIDL that describes COM
+// interfaces: interface blocks deriving from IUnknown, their methods with
+// [in], [out] and [retval] parameters, and the structures, enumerations,
+// typedefs and constants they use. Other declarations, such as coclasses,
+// dispinterfaces and the contents of cpp_quote, are skipped.
+
+// midlFile is what a MIDL file declares, in the order of the file.
+type midlFile struct {
+	Interfaces []*midlInterface
+	Structs    []*midlStruct
+	Enums      []*midlEnum
+	Aliases    []*midlAlias
+	Consts     []*midlConst
+
+	// Forward holds the interfaces declared but not defined, such as
+	// those of imported files.
+	Forward map[string]bool
+
+	// Tags maps the tags of structures and enumerations to the names
+	// their typedefs give them.
+	Tags map[string]string
+}
+
+// midlAttrs are the attributes of a declaration, by name. Their arguments
+// are kept as written.
+type midlAttrs map[string]string
+
+func (a midlAttrs) has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// midlType is a type as written: a name, and the count of pointers to it.
+// Multi-word C types have names such as "unsigned long".
+type midlType struct {
+	Name string
+	Ptr  int
+}
+
+func (t *midlType) String() string {
+	return t.Name + strings.Repeat("*", t.Ptr)
+}
+
+type midlInterface struct {
+	Name    string
+	Base    string
+	Attrs   midlAttrs
+	Methods []*midlMethod
+	Line    int
+}
+
+type midlMethod struct {
+	Name   string
+	Attrs  midlAttrs
+	Result midlType
+	Params []*midlParam
+	Line   int
+}
+
+type midlParam struct {
+	Name  string
+	Attrs midlAttrs
+	Type  midlType
+}
+
+type midlStruct struct {
+	Name   string
+	Attrs  midlAttrs
+	Fields []*midlField
+}
+
+type midlField struct {
+	Name  string
+	Type  midlType
+	Array []int64 // dimensions of an array field
+}
+
+type midlEnum struct {
+	Name   string
+	Attrs  midlAttrs
+	Values []midlEnumValue
+}
+
+type midlEnumValue struct {
+	Name  string
+	Value int64
+}
+
+// midlAlias is a typedef of a type under another name.
+type midlAlias struct {
+	Name string
+	Type midlType
+}
+
+// midlConst is a constant: an int64 or a string.
+type midlConst struct {
+	Name  string
+	Type  midlType
+	Value interface{}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type midlToken struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// lexMIDL splits src into tokens, dropping comments and preprocessor
+// lines.
+func lexMIDL(src string) ([]midlToken, error) {
+	var toks []midlToken
+	line := 1
+	startOfLine := true
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			startOfLine = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+			continue
+		case c == '#' && startOfLine:
+			// Preprocessor lines, continued by backslashes.
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '\n' {
+					line++
+					i++
+				}
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+			continue
+		}
+		startOfLine = false
+
+		start := i
+		switch {
+		case c == '_' || unicode.IsLetter(rune(c)):
+			if c == 'L' && i+1 < len(src) && src[i+1] == '"' {
+				// A wide string literal.
+				i++
+				start = i
+				break
+			}
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			toks = append(toks, midlToken{tokIdent, src[start:i], line})
+			continue
+		case unicode.IsDigit(rune(c)):
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			toks = append(toks, midlToken{tokNumber, src[start:i], line})
+			continue
+		}
+		if src[i] == '"' {
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && src[i] == '\n' {
+					return nil, fmt.Errorf("%d: newline in string", line)
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("%d: unterminated string", line)
+			}
+			i++
+			s, err := strconv.Unquote(src[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("%d: invalid string %s", line, src[start:i])
+			}
+			toks = append(toks, midlToken{tokString, s, line})
+			continue
+		}
+		if strings.HasPrefix(src[i:], "<<") || strings.HasPrefix(src[i:], ">>") {
+			i += 2
+		} else {
+			i++
+		}
+		toks = append(toks, midlToken{tokPunct, src[start:i], line})
+	}
+	return append(toks, midlToken{tokEOF, "", line}), nil
+}
+
+// midlParser parses the tokens of a file. Errors are reported by panicking
+// with a midlError, which parseMIDL recovers.
+type midlParser struct {
+	toks []midlToken
+	pos  int
+	file *midlFile
+
+	// values holds the constants and enumeration values declared so far,
+	// which expressions may use.
+	values map[string]int64
+}
+
+type midlError struct {
+	line int
+	msg  string
+}
+
+func (e *midlError) Error() string {
+	return fmt.Sprintf("%d: %s", e.line, e.msg)
+}
+
+// parseMIDL parses a MIDL file. Errors are prefixed with name and the line.
+func parseMIDL(name, src string) (file *midlFile, err error) {
+	toks, err := lexMIDL(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", name, err)
+	}
+	p := &midlParser{
+		toks:   toks,
+		file:   &midlFile{Forward: map[string]bool{}, Tags: map[string]string{}},
+		values: map[string]int64{},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*midlError)
+			if !ok {
+				panic(r)
+			}
+			file, err = nil, fmt.Errorf("%s:%v", name, e)
+		}
+	}()
+	for p.peek().kind != tokEOF {
+		p.declaration()
+	}
+	for _, iface := range p.file.Interfaces {
+		delete(p.file.Forward, iface.Name)
+	}
+	return p.file, nil
+}
+
+func (p *midlParser) peek() midlToken {
+	return p.toks[p.pos]
+}
+
+// peekAt returns the token n tokens ahead.
+func (p *midlParser) peekAt(n int) midlToken {
+	if p.pos+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+n]
+}
+
+func (p *midlParser) next() midlToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *midlParser) fail(format string, args ...interface{}) {
+	panic(&midlError{p.peek().line, fmt.Sprintf(format, args...)})
+}
+
+// is reports whether the next token is text.
+func (p *midlParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokPunct || t.kind == tokIdent) && t.text == text
+}
+
+// accept consumes the next token if it is text.
+func (p *midlParser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *midlParser) expect(text string) {
+	if !p.accept(text) {
+		p.fail("expected %s, found %s", text, p.describe())
+	}
+}
+
+func (p *midlParser) ident() string {
+	t := p.peek()
+	if t.kind != tokIdent {
+		p.fail("expected identifier, found %s", p.describe())
+	}
+	p.pos++
+	return t.text
+}
+
+func (p *midlParser) describe() string {
+	switch t := p.peek(); t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return t.text
+	}
+}
+
+// skipBalanced skips tokens up to the bracket closing the one just read.
+func (p *midlParser) skipBalanced(open, close string) {
+	for depth := 1; depth > 0; {
+		switch t := p.next(); {
+		case t.kind == tokEOF:
+			p.fail("missing %s", close)
+		case t.kind == tokPunct && t.text == open:
+			depth++
+		case t.kind == tokPunct && t.text == close:
+			depth--
+		}
+	}
+}
+
+// attributes parses an optional attribute list.
+func (p *midlParser) attributes() midlAttrs {
+	attrs := midlAttrs{}
+	if !p.accept("[") {
+		return attrs
+	}
+	for !p.accept("]") {
+		name := p.ident()
+		var args []string
+		if p.accept("(") {
+			for depth := 1; ; {
+				t := p.next()
+				if t.kind == tokEOF {
+					p.fail("missing )")
+				}
+				if t.kind == tokPunct && t.text == "(" {
+					depth++
+				} else if t.kind == tokPunct && t.text == ")" {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+				args = append(args, t.text)
+			}
+		}
+		attrs[name] = strings.Join(args, "")
+		if !p.accept(",") && !p.is("]") {
+			p.fail("expected , or ] in attributes, found %s", p.describe())
+		}
+	}
+	return attrs
+}
+
+// declaration parses a declaration at the level of the file, of a library
+// or of an interface.
+func (p *midlParser) declaration() {
+	attrs := p.attributes()
+	switch t := p.peek(); {
+	case t.kind == tokPunct && t.text == ";":
+		p.next()
+	case p.accept("import"):
+		for p.next().kind == tokString && p.accept(",") {
+		}
+		p.expect(";")
+	case p.accept("importlib"), p.accept("cpp_quote"), p.accept("midl_pragma"):
+		p.expect("(")
+		p.skipBalanced("(", ")")
+		p.accept(";")
+	case p.accept("interface"):
+		p.iface(attrs)
+	case p.accept("library"):
+		p.ident()
+		p.expect("{")
+		for !p.accept("}") {
+			if p.peek().kind == tokEOF {
+				p.fail("missing }")
+			}
+			p.declaration()
+		}
+		p.accept(";")
+	case p.accept("coclass"), p.accept("dispinterface"), p.accept("module"):
+		p.ident()
+		if p.accept("{") {
+			p.skipBalanced("{", "}")
+		}
+		p.accept(";")
+	case p.accept("typedef"):
+		p.typedef()
+	case p.accept("const"):
+		p.constant()
+	case p.is("struct"), p.is("enum"), p.is("union"):
+		p.typeSpec(attrs, "")
+		p.expect(";")
+	default:
+		p.fail("unexpected %s", p.describe())
+	}
+}
+
+func (p *midlParser) iface(attrs midlAttrs) {
+	line := p.peek().line
+	name := p.ident()
+	if p.accept(";") {
+		p.file.Forward[name] = true
+		return
+	}
+	iface := &midlInterface{Name: name, Attrs: attrs, Line: line}
+	if p.accept(":") {
+		iface.Base = p.ident()
+	}
+	p.expect("{")
+	for !p.accept("}") {
+		switch {
+		case p.peek().kind == tokEOF:
+			p.fail("missing } of interface %s", name)
+		case p.is("typedef"), p.is("const"), p.is("struct"), p.is("enum"), p.is("union"), p.is("cpp_quote"):
+			p.declaration()
+		default:
+			if m := p.method(); m != nil {
+				iface.Methods = append(iface.Methods, m)
+			}
+		}
+	}
+	p.accept(";")
+	p.file.Interfaces = append(p.file.Interfaces, iface)
+}
+
+// callingConventions are the keywords of calling conventions, which
+// methods may be declared with.
+var callingConventions = map[string]bool{
+	"STDMETHODCALLTYPE": true, "__stdcall": true, "_stdcall": true, "WINAPI": true, "CALLBACK": true,
+}
+
+// method parses a method. Methods with the call_as attribute are the remote
+// forms of [local] methods, which do not have a slot in the vtable; method
+// returns nil for them.
+func (p *midlParser) method() *midlMethod {
+	attrs := p.attributes()
+	line := p.peek().line
+	m := &midlMethod{Attrs: attrs, Result: p.typeName(), Line: line}
+	for callingConventions[p.peek().text] {
+		p.next()
+	}
+	m.Name = p.ident()
+	p.expect("(")
+	if p.is("void") && p.peekAt(1).text == ")" {
+		p.next()
+	}
+	for !p.accept(")") {
+		param := &midlParam{Attrs: p.attributes(), Type: p.typeName()}
+		if p.peek().kind == tokIdent {
+			param.Name = p.ident()
+		}
+		for p.accept("[") {
+			// An array parameter is a pointer.
+			p.skipBalanced("[", "]")
+			param.Type.Ptr++
+		}
+		m.Params = append(m.Params, param)
+		if !p.accept(",") && !p.is(")") {
+			p.fail("expected , or ) in parameters of %s, found %s", m.Name, p.describe())
+		}
+	}
+	p.expect(";")
+	if attrs.has("call_as") {
+		return nil
+	}
+	return m
+}
+
+// cTypeWords are the words of multi-word C types.
+var cTypeWords = map[string]bool{
+	"unsigned": true, "signed": true, "long": true, "short": true, "int": true, "char": true,
+	"__int64": true, "__int32": true, "__int3264": true, "hyper": true, "small": true,
+}
+
+// typeName parses a type with its qualifiers and pointers.
+func (p *midlParser) typeName() midlType {
+	var t midlType
+	p.accept("const")
+	switch {
+	case p.accept("struct"), p.accept("enum"), p.accept("union"), p.accept("interface"):
+		t.Name = p.ident()
+	case p.is("SAFEARRAY") && p.peekAt(1).text == "(":
+		// SAFEARRAY(type) is a pointer to a safe array of the type.
+		p.next()
+		p.next()
+		p.skipBalanced("(", ")")
+		t = midlType{Name: "SAFEARRAY", Ptr: 1}
+	case cTypeWords[p.peek().text]:
+		var words []string
+		for cTypeWords[p.peek().text] {
+			words = append(words, p.next().text)
+		}
+		t.Name = strings.Join(words, " ")
+	default:
+		t.Name = p.ident()
+	}
+	for {
+		switch {
+		case p.accept("const"), p.accept("__RPC_FAR"):
+		case p.accept("*"):
+			t.Ptr++
+		default:
+			return t
+		}
+	}
+}
+
+// typeSpec parses a struct, enum or union and returns its name: the name
+// of its typedef if any, or its tag. Unions are skipped.
+func (p *midlParser) typeSpec(attrs midlAttrs, name string) string {
+	kind := p.next().text
+	tag := ""
+	if p.peek().kind == tokIdent {
+		tag = p.ident()
+	}
+	if !p.accept("{") {
+		// A reference to a declared type.
+		if tag == "" {
+			p.fail("%s without name or body", kind)
+		}
+		return tag
+	}
+	switch {
+	case name == "":
+		name = tag
+	case tag != "" && tag != name:
+		p.file.Tags[tag] = name
+	}
+	if name == "" {
+		p.fail("%s without name", kind)
+	}
+	tag = name
+	switch kind {
+	case "struct":
+		s := &midlStruct{Name: tag, Attrs: attrs}
+		for !p.accept("}") {
+			p.attributes()
+			typ := p.typeName()
+			for {
+				f := &midlField{Type: typ}
+				for p.accept("*") {
+					f.Type.Ptr++
+				}
+				f.Name = p.ident()
+				for p.accept("[") {
+					f.Array = append(f.Array, p.expr())
+					p.expect("]")
+				}
+				s.Fields = append(s.Fields, f)
+				if !p.accept(",") {
+					break
+				}
+			}
+			p.expect(";")
+		}
+		p.file.Structs = append(p.file.Structs, s)
+	case "enum":
+		e := &midlEnum{Name: tag, Attrs: attrs}
+		var next int64
+		for !p.accept("}") {
+			p.attributes()
+			v := midlEnumValue{Name: p.ident(), Value: next}
+			if p.accept("=") {
+				v.Value = p.expr()
+			}
+			p.values[v.Name] = v.Value
+			next = v.Value + 1
+			e.Values = append(e.Values, v)
+			if !p.accept(",") && !p.is("}") {
+				p.fail("expected , or } in enum %s, found %s", tag, p.describe())
+			}
+		}
+		p.file.Enums = append(p.file.Enums, e)
+	default:
+		p.skipBalanced("{", "}")
+	}
+	return tag
+}
+
+// typedef parses a typedef: of a struct or enum, which then takes the first
+// name that is not a pointer, or of another type.
+func (p *midlParser) typedef() {
+	attrs := p.attributes()
+	var typ midlType
+	var spec *midlType
+	if p.is("struct") || p.is("enum") || p.is("union") {
+		kindPos := p.pos
+		p.next()
+		hasBody := p.is("{") || p.peekAt(1).text == "{"
+		p.pos = kindPos
+		if hasBody {
+			// The body is parsed once the name is known.
+			spec = &midlType{}
+		}
+	}
+	specPos := p.pos
+	if spec != nil {
+		p.next()
+		if p.peek().kind == tokIdent {
+			p.next()
+		}
+		p.expect("{")
+		p.skipBalanced("{", "}")
+	} else {
+		typ = p.typeName()
+	}
+
+	type declarator struct {
+		name string
+		ptr  int
+	}
+	var decls []declarator
+	for {
+		var d declarator
+		for p.accept("*") {
+			d.ptr++
+		}
+		for p.accept("const") || p.accept("__RPC_FAR") {
+		}
+		d.name = p.ident()
+		for p.accept("[") {
+			p.skipBalanced("[", "]")
+			d.ptr++
+		}
+		decls = append(decls, d)
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(";")
+
+	if spec != nil {
+		name := ""
+		for _, d := range decls {
+			if d.ptr == 0 {
+				name = d.name
+				break
+			}
+		}
+		end := p.pos
+		p.pos = specPos
+		typ = midlType{Name: p.typeSpec(attrs, name)}
+		p.pos = end
+	}
+	for _, d := range decls {
+		if d.name == typ.Name && d.ptr == 0 {
+			continue
+		}
+		alias := &midlAlias{Name: d.name, Type: typ}
+		alias.Type.Ptr += d.ptr
+		p.file.Aliases = append(p.file.Aliases, alias)
+	}
+}
+
+func (p *midlParser) constant() {
+	typ := p.typeName()
+	name := p.ident()
+	p.expect("=")
+	c := &midlConst{Name: name, Type: typ}
+	if p.peek().kind == tokString {
+		c.Value = p.next().text
+	} else {
+		v := p.expr()
+		p.values[name] = v
+		c.Value = v
+	}
+	p.expect(";")
+	p.file.Consts = append(p.file.Consts, c)
+}
+
+// Precedence of the binary operators of constant expressions.
+var binaryPrecedence = map[string]int{
+	"|": 1, "^": 2, "&": 3, "<<": 4, ">>": 4, "+": 5, "-": 5, "*": 6, "/": 6, "%": 6,
+}
+
+// expr parses an integer constant expression.
+func (p *midlParser) expr() int64 {
+	return p.binary(1)
+}
+
+func (p *midlParser) binary(prec int) int64 {
+	x := p.unary()
+	for {
+		op := p.peek()
+		opPrec := binaryPrecedence[op.text]
+		if op.kind != tokPunct || opPrec < prec {
+			return x
+		}
+		p.next()
+		y := p.binary(opPrec + 1)
+		switch op.text {
+		case "|":
+			x |= y
+		case "^":
+			x ^= y
+		case "&":
+			x &= y
+		case "<<":
+			x <<= uint(y)
+		case ">>":
+			x >>= uint(y)
+		case "+":
+			x += y
+		case "-":
+			x -= y
+		case "*":
+			x *= y
+		case "/", "%":
+			if y == 0 {
+				p.fail("division by zero")
+			}
+			if op.text == "/" {
+				x /= y
+			} else {
+				x %= y
+			}
+		}
+	}
+}
+
+func (p *midlParser) unary() int64 {
+	t := p.next()
+	switch {
+	case t.kind == tokPunct && t.text == "-":
+		return -p.unary()
+	case t.kind == tokPunct && t.text == "~":
+		return ^p.unary()
+	case t.kind == tokPunct && t.text == "(":
+		next := p.peek()
+		_, isValue := p.values[next.text]
+		if cTypeWords[next.text] || next.kind == tokIdent && !isValue && p.peekAt(1).text == ")" {
+			// A cast, which constants need not.
+			p.skipBalanced("(", ")")
+			return p.unary()
+		}
+		x := p.expr()
+		p.expect(")")
+		return x
+	case t.kind == tokNumber:
+		text := strings.TrimRight(strings.ToLower(t.text), "ul")
+		base := 10
+		switch {
+		case strings.HasPrefix(text, "0x"):
+			text, base = text[2:], 16
+		case len(text) > 1 && text[0] == '0':
+			text, base = text[1:], 8
+		}
+		n, err := strconv.ParseInt(text, base, 64)
+		if err != nil {
+			u, uerr := strconv.ParseUint(text, base, 64)
+			if uerr != nil {
+				p.pos--
+				p.fail("invalid number %s", t.text)
+			}
+			n = int64(u)
+		}
+		return n
+	case t.kind == tokIdent:
+		v, ok := p.values[t.text]
+		if !ok {
+			p.pos--
+			p.fail("unknown constant %s", t.text)
+		}
+		return v
+	}
+	p.pos--
+	p.fail("unexpected %s in expression", p.describe())
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// exported returns name as an exported Go identifier. Leading underscores,
+// which type libraries use for hidden interfaces such as _Application, are
+// dropped.
+func exported(name string) string {
+	name = identifier(strings.TrimLeft(name, "_"))
+	if name == "" {
+		return "X"
+	}
+	runes := []rune(name)
+	if !unicode.IsLetter(runes[0]) {
+		return "X" + name
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// unexported returns name as an unexported Go identifier.
+func unexported(name string) string {
+	runes := []rune(exported(name))
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// identifier replaces the characters of name Go does not allow in
+// identifiers.
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// reservedParams are the names generated methods use for their receiver,
+// results and packages, which parameters must not shadow.
+var reservedParams = map[string]bool{
+	"obj": true, "res": true, "ret": true, "err": true, "h": true,
+	"ole": true, "oleutil": true, "time": true,
+	"nil": true, "true": true, "false": true,
+}
+
+// paramNames returns Go names for the parameters of a function, numbering
+// those without a name.
+func paramNames(names []string) []string {
+	out := make([]string, len(names))
+	used := map[string]bool{}
+	for i, name := range names {
+		name = identifier(name)
+		switch {
+		case name == "":
+			name = "arg" + strconv.Itoa(i+1)
+		case !unicode.IsLetter([]rune(name)[0]):
+			name = "arg" + name
+		}
+		for token.Lookup(name).IsKeyword() || reservedParams[name] || used[name] {
+			name += "_"
+		}
+		used[name] = true
+		out[i] = name
+	}
+	return out
+}
+
+// nameSet hands out unique identifiers in one scope.
+type nameSet map[string]bool
+
+// unique returns name, suffixed with underscores until it is not taken, and
+// takes it.
+func (s nameSet) unique(name string) string {
+	for s[name] {
+		name += "_"
+	}
+	s[name] = true
+	return name
+}
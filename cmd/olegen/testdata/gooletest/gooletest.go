@@ -0,0 +1,203 @@
+// Code generated by olegen from test.tlb. DO NOT EDIT.
+
+// Package gooletest wraps the GoOleTest type library.
+//
+// Go OLE test library
+package gooletest
+
+import (
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// LIBID_GoOleTest identifies the GoOleTest type library, version 1.2.
+var LIBID_GoOleTest = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E01}")
+
+// Shape is the enumeration Shape.
+//
+// Shapes that can be drawn
+type Shape int32
+
+const (
+	ShapeCircle Shape = 0
+	ShapeSquare Shape = 1
+	ShapeHuge   Shape = 100000000
+	ShapeNone   Shape = -1
+)
+
+// Point is the structure Point.
+type Point struct {
+	X int32
+	Y int32
+	// Display name
+	Label string
+	Data  [2][3]int32
+}
+
+// Handle is the type Handle.
+type Handle = int32
+
+// IID_ICalculator identifies the ICalculator interface.
+var IID_ICalculator = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E02}")
+
+// ICalculator calls the dual interface ICalculator through IDispatch.
+//
+// Calculator interface
+type ICalculator struct {
+	*ole.IDispatch
+}
+
+// QueryICalculator returns the ICalculator interface of obj, which the caller releases.
+func QueryICalculator(obj *ole.IUnknown) (*ICalculator, error) {
+	disp, err := obj.QueryInterface(IID_ICalculator)
+	if err != nil {
+		return nil, err
+	}
+	return &ICalculator{disp}, nil
+}
+
+// Add calls the method Add, DISPID 1610743808.
+//
+// Adds two numbers
+func (obj *ICalculator) Add(a int32, b int32) (ret int32, err error) {
+	res, err := oleutil.Invoke(obj.IDispatch, 1610743808, ole.DISPATCH_METHOD, a, b)
+	if err != nil {
+		return
+	}
+	err = oleutil.ResultAs(res, &ret)
+	return
+}
+
+// Total gets the property Total, DISPID 1.
+func (obj *ICalculator) Total() (ret float64, err error) {
+	res, err := oleutil.Invoke(obj.IDispatch, 1, ole.DISPATCH_PROPERTYGET)
+	if err != nil {
+		return
+	}
+	err = oleutil.ResultAs(res, &ret)
+	return
+}
+
+// SetTotal sets the property Total, DISPID 1.
+func (obj *ICalculator) SetTotal(value float64) error {
+	res, err := oleutil.Invoke(obj.IDispatch, 1, ole.DISPATCH_PROPERTYPUT, value)
+	if err != nil {
+		return err
+	}
+	return res.Clear()
+}
+
+// Move calls the method Move, DISPID 1610743811.
+//
+// Default values: steps 1, mode "fast".
+func (obj *ICalculator) Move(to interface{}, steps *int32, mode *string) error {
+	res, err := oleutil.Invoke(obj.IDispatch, 1610743811, ole.DISPATCH_METHOD, to, oleutil.Optional(steps), oleutil.Optional(mode))
+	if err != nil {
+		return err
+	}
+	return res.Clear()
+}
+
+// Draw calls the method Draw, DISPID 1610743812.
+func (obj *ICalculator) Draw(shapes []interface{}) (ret *ole.IDispatch, err error) {
+	res, err := oleutil.Invoke(obj.IDispatch, 1610743812, ole.DISPATCH_METHOD, shapes)
+	if err != nil {
+		return
+	}
+	err = oleutil.ResultAs(res, &ret)
+	return
+}
+
+// DIID__CalculatorEvents identifies the _CalculatorEvents interface.
+var DIID__CalculatorEvents = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E04}")
+
+// CalculatorEvents calls the dispinterface _CalculatorEvents through IDispatch.
+type CalculatorEvents struct {
+	*ole.IDispatch
+}
+
+// QueryCalculatorEvents returns the IDispatch interface of obj, which the caller releases.
+func QueryCalculatorEvents(obj *ole.IUnknown) (*CalculatorEvents, error) {
+	disp, err := obj.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return nil, err
+	}
+	return &CalculatorEvents{disp}, nil
+}
+
+// Done calls the method Done, DISPID 1.
+func (obj *CalculatorEvents) Done(result int32) error {
+	res, err := oleutil.Invoke(obj.IDispatch, 1, ole.DISPATCH_METHOD, result)
+	if err != nil {
+		return err
+	}
+	return res.Clear()
+}
+
+// Busy gets the property Busy, DISPID 2.
+func (obj *CalculatorEvents) Busy() (ret bool, err error) {
+	res, err := oleutil.Invoke(obj.IDispatch, 2, ole.DISPATCH_PROPERTYGET)
+	if err != nil {
+		return
+	}
+	err = oleutil.ResultAs(res, &ret)
+	return
+}
+
+// CalculatorEventsHandler receives the events of _CalculatorEvents.
+//
+// Events whose function is nil are ignored.
+type CalculatorEventsHandler struct {
+	Done func(result int32)
+}
+
+// calculatorEventsSink serves a CalculatorEventsHandler as _CalculatorEvents.
+type calculatorEventsSink struct {
+	handler *CalculatorEventsHandler
+}
+
+// DispIDs implements oleutil.DispIDMapper.
+func (s *calculatorEventsSink) DispIDs() map[string]int32 {
+	return map[string]int32{
+		"Done": 1,
+	}
+}
+
+func (s *calculatorEventsSink) Done(result int32) {
+	if s.handler.Done != nil {
+		s.handler.Done(result)
+	}
+}
+
+// ConnectCalculatorEvents calls h for the events obj raises through
+// _CalculatorEvents, until DisconnectCalculatorEvents is called with the cookie
+// returned.
+func ConnectCalculatorEvents(obj *ole.IDispatch, h *CalculatorEventsHandler) (cookie uint32, err error) {
+	return oleutil.ConnectObject(obj, DIID__CalculatorEvents, &calculatorEventsSink{h})
+}
+
+// DisconnectCalculatorEvents stops calling the handler connected with cookie.
+func DisconnectCalculatorEvents(obj *ole.IDispatch, cookie uint32) error {
+	return oleutil.DisconnectObject(obj, DIID__CalculatorEvents, cookie)
+}
+
+// CLSID_Calculator identifies the Calculator class.
+var CLSID_Calculator = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E03}")
+
+// NewCalculator creates a Calculator object.
+//
+// Calculator class
+func NewCalculator() (*ICalculator, error) {
+	unknown, err := ole.CreateInstance(CLSID_Calculator, ole.IID_IUnknown)
+	if err != nil {
+		return nil, err
+	}
+	defer unknown.Release()
+	return QueryICalculator(unknown)
+}
+
+// Constants of the module Native.
+const (
+	MaxValue = 1000
+	Greeting = "hello"
+)
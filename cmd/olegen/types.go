@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// Go types of the automation types that have a direct equivalent.
+var scalarTypes = map[ole.VT]string{
+	ole.VT_I1:      "int8",
+	ole.VT_UI1:     "uint8",
+	ole.VT_I2:      "int16",
+	ole.VT_UI2:     "uint16",
+	ole.VT_I4:      "int32",
+	ole.VT_UI4:     "uint32",
+	ole.VT_INT:     "int32",
+	ole.VT_UINT:    "uint32",
+	ole.VT_I8:      "int64",
+	ole.VT_UI8:     "uint64",
+	ole.VT_R4:      "float32",
+	ole.VT_R8:      "float64",
+	ole.VT_BOOL:    "bool",
+	ole.VT_BSTR:    "string",
+	ole.VT_LPSTR:   "string",
+	ole.VT_LPWSTR:  "string",
+	ole.VT_ERROR:   "int32",
+	ole.VT_HRESULT: "int32",
+	ole.VT_DATE:    "time.Time",
+}
+
+// Types standing for values the wrappers do not convert.
+const (
+	variantType  = "interface{}"
+	dispatchType = "*ole.IDispatch"
+	unknownType  = "*ole.IUnknown"
+)
+
+// goType returns the Go type of values of type desc: the named type
+// generated for enumerations and aliases, interface pointers for interfaces,
+// and interface{} for VARIANTs and what has no Go equivalent, such as
+// records, which are passed as VARIANTs holding IRecordInfo.
+func (g *generator) goType(desc *ole.TYPEDESC) string {
+	vt := ole.VT(desc.VT)
+	if name, ok := scalarTypes[vt]; ok {
+		if vt == ole.VT_DATE {
+			g.usesTime = true
+		}
+		return name
+	}
+	switch vt {
+	case ole.VT_DISPATCH:
+		return dispatchType
+	case ole.VT_UNKNOWN:
+		return unknownType
+	case ole.VT_SAFEARRAY:
+		if desc.Lptdesc != nil {
+			return "[]" + g.goType(desc.Lptdesc)
+		}
+	case ole.VT_PTR:
+		// Interfaces are described as pointers to the interface type.
+		if desc.Lptdesc != nil && g.isInterface(desc.Lptdesc) {
+			return g.goType(desc.Lptdesc)
+		}
+	case ole.VT_USERDEFINED:
+		return g.userType(desc.Hreftype)
+	}
+	return variantType
+}
+
+// isInterface reports whether desc is an interface type, whose values are
+// interface pointers.
+func (g *generator) isInterface(desc *ole.TYPEDESC) bool {
+	switch ole.VT(desc.VT) {
+	case ole.VT_DISPATCH, ole.VT_UNKNOWN:
+		return true
+	case ole.VT_USERDEFINED:
+		switch g.userType(desc.Hreftype) {
+		case dispatchType, unknownType:
+			return true
+		}
+	}
+	return false
+}
+
+// userType returns the Go type of the user-defined type hreftype.
+func (g *generator) userType(hreftype uint32) string {
+	ref := g.lib.Ref(hreftype)
+	if ref == nil {
+		return variantType
+	}
+	if ref.Type == nil {
+		switch {
+		case ole.IsEqualGUID(&ref.Guid, ole.IID_IUnknown):
+			return unknownType
+		case ref.Typekind == ole.TKIND_DISPATCH || ole.IsEqualGUID(&ref.Guid, ole.IID_IDispatch):
+			return dispatchType
+		case ref.Typekind == ole.TKIND_INTERFACE || ref.Typekind == ole.TKIND_COCLASS:
+			return unknownType
+		case ref.Typekind == ole.TKIND_ENUM:
+			return "int32"
+		}
+		return variantType
+	}
+
+	t := ref.Type
+	switch t.Attr.Typekind {
+	case ole.TKIND_ENUM, ole.TKIND_ALIAS:
+		return g.typeNames[t]
+	case ole.TKIND_DISPATCH:
+		return dispatchType
+	case ole.TKIND_INTERFACE:
+		if isDispatchable(t) {
+			return dispatchType
+		}
+		return unknownType
+	case ole.TKIND_COCLASS:
+		return unknownType
+	}
+	return variantType
+}
+
+// isDispatchable reports whether t can be called through IDispatch.
+func isDispatchable(t *typelib.TypeInfo) bool {
+	return t.Attr.Typekind == ole.TKIND_DISPATCH ||
+		t.Attr.Typekind == ole.TKIND_INTERFACE && t.Attr.WTypeFlags&(ole.TYPEFLAG_FDUAL|ole.TYPEFLAG_FDISPATCHABLE) != 0
+}
+
+// literal returns the Go literal of a constant, and whether it has one.
+func literal(v *typelib.Value) (string, bool) {
+	switch val := v.Val.(type) {
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, bool:
+		return fmt.Sprint(val), true
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32), true
+	case float64:
+		if v.VT == ole.VT_DATE {
+			return "", false
+		}
+		return strconv.FormatFloat(val, 'g', -1, 64), true
+	case string:
+		return strconv.Quote(val), true
+	}
+	return "", false
+}
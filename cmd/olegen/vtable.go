@@ -0,0 +1,926 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// vtableMain runs the vtable subcommand, which writes bindings calling the
+// vtables of the interfaces of an IDL file.
+func vtableMain(args []string) {
+	flags := flag.NewFlagSet("olegen vtable", flag.ExitOnError)
+	pkg := flags.String("pkg", "", "package `name`, by default the file name in lower case")
+	output := flags.String("o", "", "`prefix` of the output files, by default the file name in lower case")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: olegen vtable [-pkg name] [-o prefix] file.idl\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	source := filepath.Base(path)
+	base := strings.ToLower(identifier(strings.TrimSuffix(source, filepath.Ext(source))))
+	if *pkg == "" {
+		*pkg = base
+	}
+	if *output == "" {
+		*output = base
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		fatal(err)
+	}
+	file, err := parseMIDL(source, string(data))
+	if err != nil {
+		fatal(err)
+	}
+	src, err := generateVtables(file, *pkg, source)
+	if err != nil {
+		fatal(err)
+	}
+	write(*output+".go", src.Common)
+	write(*output+"_windows.go", src.Windows)
+	write(*output+"_func.go", src.Other)
+}
+
+// Kinds of the C types of MIDL, which tell how values are passed.
+type cKind int
+
+const (
+	cInt       cKind = iota // integers of up to 32 bits
+	cInt64                  // 64-bit integers
+	cFloat                  // floating-point numbers
+	cHandle                 // handles and integers of the size of pointers
+	cVoid                   // void, only pointed to
+	cWide                   // pointer to a UTF-16 string, such as LPWSTR
+	cAnsi                   // pointer to an 8-bit string, such as LPSTR
+	cBSTR                   // BSTR
+	cStruct                 // structures, such as GUID and VARIANT
+	cEnum                   // enumerations
+	cInterface              // interfaces, only pointed to
+)
+
+// cType is a type resolved down to its kind: the Go type of its values,
+// qualified with "ole." for types of the ole package, and the count of
+// pointers to it.
+type cType struct {
+	kind   cKind
+	goName string
+	ptr    int
+}
+
+type builtinType struct {
+	kind   cKind
+	goName string
+}
+
+// builtinTypes are the types of the Windows headers and of MIDL.
+var builtinTypes = map[string]builtinType{}
+
+func init() {
+	add := func(kind cKind, goName string, names ...string) {
+		for _, name := range names {
+			builtinTypes[name] = builtinType{kind, goName}
+		}
+	}
+	add(cInt, "int8", "CHAR", "char", "signed char", "small", "INT8")
+	add(cInt, "uint8", "BYTE", "UCHAR", "unsigned char", "boolean", "byte", "UINT8")
+	add(cInt, "int16", "SHORT", "short", "signed short", "INT16", "VARIANT_BOOL")
+	add(cInt, "uint16", "USHORT", "WORD", "unsigned short", "WCHAR", "wchar_t", "OLECHAR", "UINT16", "VARTYPE", "LANGID")
+	add(cInt, "int32", "INT", "int", "signed int", "LONG", "long", "signed long", "BOOL", "HRESULT", "SCODE",
+		"INT32", "LONG32", "DISPID", "MEMBERID", "__int32")
+	add(cInt, "uint32", "UINT", "unsigned", "unsigned int", "ULONG", "unsigned long", "DWORD", "UINT32",
+		"ULONG32", "DWORD32", "LCID", "PROPID", "COLORREF", "unsigned __int32")
+	add(cInt64, "int64", "LONGLONG", "__int64", "hyper", "long long", "INT64", "LONG64")
+	add(cInt64, "uint64", "ULONGLONG", "unsigned __int64", "unsigned hyper", "unsigned long long",
+		"UINT64", "ULONG64", "DWORD64", "DWORDLONG")
+	add(cFloat, "float32", "float", "FLOAT")
+	add(cFloat, "float64", "double", "DOUBLE", "DATE")
+	add(cHandle, "uintptr", "HANDLE", "HWND", "HINSTANCE", "HMODULE", "HICON", "HBITMAP", "HMENU", "HDC",
+		"HKEY", "HGLOBAL", "HBRUSH", "HCURSOR", "HMONITOR", "HRGN", "HFONT", "HPEN", "HTASK", "HMETAFILE",
+		"HENHMETAFILE", "HACCEL", "WPARAM", "LPARAM", "LRESULT", "UINT_PTR", "ULONG_PTR", "DWORD_PTR",
+		"INT_PTR", "LONG_PTR", "SIZE_T", "SSIZE_T", "__int3264", "unsigned __int3264")
+	add(cVoid, "", "void", "VOID")
+	add(cWide, "", "LPWSTR", "LPCWSTR", "PWSTR", "PCWSTR", "LPOLESTR", "LPCOLESTR")
+	add(cAnsi, "", "LPSTR", "LPCSTR", "PSTR", "PCSTR")
+	add(cBSTR, "", "BSTR")
+	add(cStruct, "ole.GUID", "GUID", "IID", "CLSID", "FMTID")
+	add(cStruct, "ole.VARIANT", "VARIANT", "VARIANTARG")
+	add(cStruct, "ole.SafeArray", "SAFEARRAY")
+	add(cStruct, "ole.DISPPARAMS", "DISPPARAMS")
+	add(cStruct, "ole.EXCEPINFO", "EXCEPINFO")
+	add(cStruct, "ole.CONNECTDATA", "CONNECTDATA")
+	for _, name := range []string{
+		"IUnknown", "IDispatch", "IInspectable", "IClassFactory", "IConnectionPoint",
+		"IConnectionPointContainer", "IEnumConnectionPoints", "IEnumConnections", "IEnumVARIANT",
+		"IProvideClassInfo", "IProvideClassInfo2", "ITypeInfo", "ITypeLib",
+	} {
+		add(cInterface, "ole."+name, name)
+	}
+}
+
+// builtinPointers are the names of the Windows headers for pointers.
+var builtinPointers = map[string]string{
+	"REFIID": "IID", "REFCLSID": "CLSID", "REFGUID": "GUID", "REFFMTID": "FMTID",
+	"LPGUID": "GUID", "LPIID": "IID", "LPCLSID": "CLSID",
+	"LPVOID": "void", "PVOID": "void", "LPCVOID": "void",
+	"LPUNKNOWN": "IUnknown", "LPDISPATCH": "IDispatch", "LPVARIANT": "VARIANT", "LPSAFEARRAY": "SAFEARRAY",
+	"LPDWORD": "DWORD", "PDWORD": "DWORD", "LPLONG": "LONG", "PLONG": "LONG", "LPBOOL": "BOOL",
+	"PULONG": "ULONG", "LPBYTE": "BYTE", "PBYTE": "BYTE", "LPWORD": "WORD",
+}
+
+// oleInterfaces are the base interfaces the ole package declares vtables
+// for.
+var oleInterfaces = map[string]bool{
+	"IUnknown": true, "IDispatch": true, "IInspectable": true, "IClassFactory": true,
+	"IConnectionPoint": true, "IConnectionPointContainer": true, "IEnumConnectionPoints": true,
+	"IEnumConnections": true, "IEnumVARIANT": true, "IProvideClassInfo": true,
+	"IProvideClassInfo2": true, "ITypeInfo": true, "ITypeLib": true,
+}
+
+// goFile is a file being generated, with the packages it imports.
+type goFile struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+// vtableSources are the files of the bindings of a MIDL file.
+type vtableSources struct {
+	Common  []byte // IIDs, types and vtables
+	Windows []byte // methods calling the vtables
+	Other   []byte // methods failing with E_NOTIMPL
+}
+
+// vtableGenerator writes the bindings of the interfaces of a MIDL file, in
+// the style of the interfaces of the ole package.
+type vtableGenerator struct {
+	file   *midlFile
+	pkg    string
+	source string
+
+	structs map[string]*midlStruct
+	enums   map[string]*midlEnum
+	aliases map[string]*midlAlias
+	ifaces  map[string]*midlInterface
+
+	// names holds the package-level identifiers and goNames those given
+	// to the types of the file.
+	names   nameSet
+	goNames map[string]string
+
+	out *goFile
+}
+
+// generateVtables returns the bindings of the interfaces of file, in package
+// pkg. source names the file in the headers of the files.
+func generateVtables(file *midlFile, pkg, source string) (*vtableSources, error) {
+	g := &vtableGenerator{
+		file:    file,
+		pkg:     pkg,
+		source:  source,
+		structs: map[string]*midlStruct{},
+		enums:   map[string]*midlEnum{},
+		aliases: map[string]*midlAlias{},
+		ifaces:  map[string]*midlInterface{},
+		names:   nameSet{},
+		goNames: map[string]string{},
+	}
+	for _, s := range file.Structs {
+		g.structs[s.Name] = s
+		g.goNames[s.Name] = g.names.unique(exported(s.Name))
+	}
+	for _, e := range file.Enums {
+		g.enums[e.Name] = e
+		g.goNames[e.Name] = g.names.unique(exported(e.Name))
+	}
+	for _, a := range file.Aliases {
+		g.aliases[a.Name] = a
+		g.goNames[a.Name] = g.names.unique(exported(a.Name))
+	}
+	for _, iface := range file.Interfaces {
+		if _, ok := g.ifaces[iface.Name]; ok {
+			return nil, fmt.Errorf("%s:%d: interface %s defined twice", source, iface.Line, iface.Name)
+		}
+		g.ifaces[iface.Name] = iface
+		g.goNames[iface.Name] = g.names.unique(exported(iface.Name))
+	}
+
+	common, err := g.common()
+	if err != nil {
+		return nil, err
+	}
+	windows, err := g.methods(true)
+	if err != nil {
+		return nil, err
+	}
+	other, err := g.methods(false)
+	if err != nil {
+		return nil, err
+	}
+	return &vtableSources{Common: common, Windows: windows, Other: other}, nil
+}
+
+func (g *vtableGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.out.buf, format, args...)
+}
+
+// q qualifies a name of the ole package written "ole.Name", unless the
+// bindings are generated in the ole package itself.
+func (g *vtableGenerator) q(name string) string {
+	if !strings.HasPrefix(name, "ole.") {
+		return name
+	}
+	if g.pkg == "ole" {
+		return strings.TrimPrefix(name, "ole.")
+	}
+	g.out.imports["ole"] = true
+	return name
+}
+
+// begin starts a file.
+func (g *vtableGenerator) begin() {
+	g.out = &goFile{imports: map[string]bool{}}
+}
+
+// end returns the formatted file begun last, with its header, build
+// constraint and imports.
+func (g *vtableGenerator) end(constraint string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by olegen from %s. DO NOT EDIT.\n\n", g.source)
+	if constraint != "" {
+		fmt.Fprintf(&out, "//go:build %s\n// +build %s\n\n", constraint, constraint)
+	}
+	fmt.Fprintf(&out, "package %s\n", g.pkg)
+
+	var std []string
+	for path := range g.out.imports {
+		if path != "ole" {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	switch {
+	case len(g.out.imports) == 1 && len(std) == 1:
+		fmt.Fprintf(&out, "\nimport %q\n", std[0])
+	case len(g.out.imports) == 1:
+		out.WriteString("\nimport ole \"github.com/go-ole/go-ole\"\n")
+	case len(g.out.imports) > 1:
+		out.WriteString("\nimport (\n")
+		for _, path := range std {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+		if g.out.imports["ole"] {
+			if len(std) > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString("\tole \"github.com/go-ole/go-ole\"\n")
+		}
+		out.WriteString(")\n")
+	}
+	out.Write(g.out.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// resolve resolves t through the typedefs of the file and of Windows.
+func (g *vtableGenerator) resolve(t midlType) (cType, error) {
+	for depth := 0; ; depth++ {
+		if depth > 64 {
+			return cType{}, fmt.Errorf("typedef loop at %s", t.Name)
+		}
+		if name, ok := g.file.Tags[t.Name]; ok {
+			t.Name = name
+		}
+		if name, ok := builtinPointers[t.Name]; ok {
+			t = midlType{Name: name, Ptr: t.Ptr + 1}
+			continue
+		}
+		if alias, ok := g.aliases[t.Name]; ok {
+			t = midlType{Name: alias.Type.Name, Ptr: alias.Type.Ptr + t.Ptr}
+			continue
+		}
+		break
+	}
+	if b, ok := builtinTypes[t.Name]; ok {
+		return cType{b.kind, b.goName, t.Ptr}, nil
+	}
+	switch {
+	case g.structs[t.Name] != nil:
+		return cType{cStruct, g.goNames[t.Name], t.Ptr}, nil
+	case g.enums[t.Name] != nil:
+		return cType{cEnum, g.goNames[t.Name], t.Ptr}, nil
+	case g.ifaces[t.Name] != nil:
+		return cType{cInterface, g.goNames[t.Name], t.Ptr}, nil
+	case g.file.Forward[t.Name]:
+		// Interfaces declared elsewhere are used through IUnknown.
+		return cType{cInterface, "ole.IUnknown", t.Ptr}, nil
+	}
+	return cType{}, fmt.Errorf("unknown type %s", t.Name)
+}
+
+// goType returns the Go type of values of type t in memory, as in
+// structures and behind pointers.
+func (g *vtableGenerator) goType(t cType) (string, error) {
+	stars := strings.Repeat("*", t.ptr)
+	switch t.kind {
+	case cVoid:
+		if t.ptr == 0 {
+			return "", fmt.Errorf("void value")
+		}
+		g.out.imports["unsafe"] = true
+		return stars[1:] + "unsafe.Pointer", nil
+	case cWide, cBSTR:
+		return stars + "*uint16", nil
+	case cAnsi:
+		return stars + "*byte", nil
+	case cInterface:
+		if t.ptr == 0 {
+			return "", fmt.Errorf("interface passed by value")
+		}
+	}
+	return stars + g.q(t.goName), nil
+}
+
+// zero returns the zero value of the Go type goType, which goType returned.
+func (g *vtableGenerator) zero(goType string) string {
+	switch {
+	case strings.HasPrefix(goType, "*"), goType == "unsafe.Pointer":
+		return "nil"
+	case goType == "string":
+		return `""`
+	case numberTypes[goType]:
+		return "0"
+	}
+	for _, e := range g.file.Enums {
+		if g.goNames[e.Name] == goType {
+			return "0"
+		}
+	}
+	return goType + "{}"
+}
+
+// numberTypes are the Go types of numbers.
+var numberTypes = map[string]bool{
+	"int8": true, "uint8": true, "int16": true, "uint16": true, "int32": true,
+	"uint32": true, "int64": true, "uint64": true, "float32": true, "float64": true, "uintptr": true,
+}
+
+// common writes the file of the IIDs, types and vtables.
+func (g *vtableGenerator) common() ([]byte, error) {
+	g.begin()
+	var consts []string
+	for _, c := range g.file.Consts {
+		name := g.names.unique(exported(c.Name))
+		switch v := c.Value.(type) {
+		case string:
+			consts = append(consts, name+" = "+strconv.Quote(v))
+		case int64:
+			consts = append(consts, name+" = "+strconv.FormatInt(v, 10))
+		}
+	}
+	if len(consts) > 0 {
+		g.printf("\n// Constants of %s.\nconst (\n%s\n)\n", g.source, strings.Join(consts, "\n"))
+	}
+
+	for _, e := range g.file.Enums {
+		name := g.goNames[e.Name]
+		g.printf("\n// %s is the enumeration %s.\n", name, e.Name)
+		g.helpString(e.Attrs)
+		g.printf("type %s int32\n", name)
+		if len(e.Values) > 0 {
+			g.printf("\nconst (\n")
+			for _, v := range e.Values {
+				g.printf("%s %s = %d\n", g.names.unique(exported(v.Name)), name, v.Value)
+			}
+			g.printf(")\n")
+		}
+	}
+
+	for _, s := range g.file.Structs {
+		name := g.goNames[s.Name]
+		g.printf("\n// %s is the structure %s.\n", name, s.Name)
+		g.helpString(s.Attrs)
+		g.printf("type %s struct {\n", name)
+		fields := nameSet{}
+		for _, f := range s.Fields {
+			t, err := g.resolve(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s: field %s of %s: %v", g.source, f.Name, s.Name, err)
+			}
+			goType, err := g.goType(t)
+			if err != nil {
+				return nil, fmt.Errorf("%s: field %s of %s: %v", g.source, f.Name, s.Name, err)
+			}
+			var dims string
+			for _, n := range f.Array {
+				dims += "[" + strconv.FormatInt(n, 10) + "]"
+			}
+			g.printf("%s %s%s\n", fields.unique(exported(f.Name)), dims, goType)
+		}
+		g.printf("}\n")
+	}
+
+	for _, a := range g.file.Aliases {
+		if g.structs[a.Type.Name] != nil && a.Type.Ptr == 0 {
+			continue
+		}
+		t, err := g.resolve(a.Type)
+		if err != nil {
+			// Typedefs of what the bindings do not use are not needed.
+			continue
+		}
+		goType, err := g.goType(t)
+		if err != nil {
+			continue
+		}
+		name := g.goNames[a.Name]
+		g.printf("\n// %s is the type %s.\ntype %s = %s\n", name, a.Name, name, goType)
+	}
+
+	var iids, registrations []string
+	for _, iface := range g.file.Interfaces {
+		uuid, ok := iface.Attrs["uuid"]
+		if !ok {
+			continue
+		}
+		name := g.names.unique("IID_" + identifier(iface.Name))
+		iids = append(iids, fmt.Sprintf("// %s identifies the %s interface.\n%s = %s(%q)",
+			name, iface.Name, name, g.q("ole.NewGUID"), "{"+strings.ToUpper(strings.Trim(uuid, `"`))+"}"))
+		if iface.Base != "" {
+			registrations = append(registrations, fmt.Sprintf("%s(%s, (*%s)(nil))",
+				g.q("ole.RegisterInterface"), name, g.goNames[iface.Name]))
+		}
+	}
+	if len(iids) > 0 {
+		g.printf("\nvar (\n%s\n)\n", strings.Join(iids, "\n\n"))
+	}
+	// The interfaces are registered for ole.QueryInterfaceAs.
+	if len(registrations) > 0 {
+		g.printf("\nfunc init() {\n%s\n}\n", strings.Join(registrations, "\n"))
+	}
+
+	for _, iface := range g.file.Interfaces {
+		if err := g.vtable(iface); err != nil {
+			return nil, err
+		}
+	}
+	return g.end("")
+}
+
+// helpString writes the helpstring attribute as a paragraph of a comment.
+func (g *vtableGenerator) helpString(attrs midlAttrs) {
+	if s := attrs["helpstring"]; s != "" {
+		g.printf("//\n// %s\n", comment(s))
+	}
+}
+
+// base returns the Go names of the struct and the vtable struct of the base
+// interface of iface.
+func (g *vtableGenerator) base(iface *midlInterface) (string, string, error) {
+	switch {
+	case iface.Base == "":
+		if iface.Name != "IUnknown" {
+			return "", "", fmt.Errorf("%s:%d: interface %s derives from no interface", g.source, iface.Line, iface.Name)
+		}
+	case g.ifaces[iface.Base] != nil:
+		name := g.goNames[iface.Base]
+		return name, name + "Vtbl", nil
+	case oleInterfaces[iface.Base]:
+		return g.q("ole." + iface.Base), g.q("ole." + iface.Base + "Vtbl"), nil
+	}
+	return "", "", fmt.Errorf("%s:%d: base interface %s of %s is not defined", g.source, iface.Line, iface.Base, iface.Name)
+}
+
+// vtable writes the struct of an interface, its vtable and the method
+// returning it.
+func (g *vtableGenerator) vtable(iface *midlInterface) error {
+	name := g.goNames[iface.Name]
+	base, baseVtbl, err := g.base(iface)
+	if err != nil {
+		return err
+	}
+	g.printf("\n// %s is the interface %s.\n", name, iface.Name)
+	g.helpString(iface.Attrs)
+	g.printf("type %s struct {\n%s\n}\n\n", name, base)
+	g.printf("type %sVtbl struct {\n%s\n", name, baseVtbl)
+	methods, err := g.vtableMethods(iface)
+	if err != nil {
+		return err
+	}
+	for _, m := range methods {
+		if m.unsupported != "" {
+			g.printf("%s uintptr // not wrapped: %s\n", m.name, m.unsupported)
+		} else {
+			g.printf("%s uintptr\n", m.name)
+		}
+	}
+	g.printf("}\n\n")
+	g.out.imports["unsafe"] = true
+	g.printf("func (v *%s) VTable() *%sVtbl {\n\treturn (*%sVtbl)(unsafe.Pointer(v.RawVTable))\n}\n", name, name, name)
+	return nil
+}
+
+// vtableMethod is a method of an interface as the bindings call it.
+type vtableMethod struct {
+	*midlMethod
+	name        string // Go name of the method and its vtable slot
+	unsupported string // why the method is not wrapped, if it is not
+
+	params   []string // declarations of the Go parameters
+	results  []string // declarations of the Go results
+	pre      []string // statements before the call
+	args     []string // arguments of the call after the receiver
+	callArgs []string // the arguments as ole.Arg, for ole.CallMethod
+	post     []string // statements after a successful call
+	hresult  bool     // whether the method returns an HRESULT
+	ret      string   // Go type of a result other than an HRESULT
+
+	// callMethod is set for methods syscall.Syscall cannot call, which
+	// take floating-point numbers or 64-bit integers: they are called with
+	// ole.CallMethod.
+	callMethod bool
+}
+
+// vtableMethods returns the methods of iface with their Go names. Property
+// accessors are named after the property, prefixed with Get, Put or
+// PutRef.
+func (g *vtableGenerator) vtableMethods(iface *midlInterface) ([]*vtableMethod, error) {
+	names := nameSet{"VTable": true, "RawVTable": true}
+	var methods []*vtableMethod
+	for _, m := range iface.Methods {
+		name := exported(m.Name)
+		switch {
+		case m.Attrs.has("propget"):
+			name = "Get" + name
+		case m.Attrs.has("propput"):
+			name = "Put" + name
+		case m.Attrs.has("propputref"):
+			name = "PutRef" + name
+		}
+		vm := &vtableMethod{midlMethod: m, name: names.unique(name)}
+		if err := g.plan(vm); err != nil {
+			if _, ok := err.(errUnsupported); !ok {
+				return nil, fmt.Errorf("%s:%d: method %s of %s: %v", g.source, m.Line, m.Name, iface.Name, err)
+			}
+			vm.unsupported = err.Error()
+		}
+		methods = append(methods, vm)
+	}
+	return methods, nil
+}
+
+// errUnsupported is the error of a method the bindings cannot call.
+type errUnsupported string
+
+func (e errUnsupported) Error() string { return string(e) }
+
+// plan works out how the method passes its parameters and returns its
+// results.
+func (g *vtableGenerator) plan(m *vtableMethod) error {
+	raw := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		raw[i] = p.Name
+		switch p.Name {
+		case "v", "hr", "r1", "syscall", "unsafe":
+			raw[i] = p.Name + "_"
+		}
+	}
+	names := paramNames(raw)
+	temps := nameSet{"v": true, "hr": true, "r1": true, "err": true, "ret": true}
+	for _, name := range names {
+		temps[name] = true
+	}
+
+	var result cType
+	if m.Result.Name == "HRESULT" && m.Result.Ptr == 0 {
+		m.hresult = true
+	} else {
+		t, err := g.resolve(m.Result)
+		if err != nil {
+			return errUnsupported(err.Error())
+		}
+		result = t
+		switch {
+		case t.kind == cVoid && t.ptr == 0:
+		case t.ptr > 0 && t.kind != cInterface, t.kind == cHandle, t.kind == cInt, t.kind == cEnum:
+			ret, err := g.goType(t)
+			if err != nil {
+				return err
+			}
+			m.ret = ret
+		default:
+			return errUnsupported(fmt.Sprintf("returns %s", m.Result.String()))
+		}
+	}
+
+	for i, p := range m.Params {
+		if err := g.planParam(m, p, names[i], temps); err != nil {
+			return err
+		}
+	}
+	if m.ret != "" {
+		m.results = append([]string{"ret " + m.ret}, m.results...)
+	}
+	if m.hresult {
+		m.results = append(m.results, "err error")
+	}
+	switch {
+	case !m.callMethod:
+		if len(m.args) > 14 {
+			return errUnsupported("more than 14 parameters")
+		}
+	case m.ret != "" && (result.ptr > 0 || result.kind == cHandle):
+		// ole.CallMethod returns the low 32 bits of the result.
+		return errUnsupported(fmt.Sprintf("returns %s with floating-point or 64-bit parameters", m.Result.String()))
+	}
+	return nil
+}
+
+// pointerArg adds an argument passing the pointer expr, of a pointer type.
+func (g *vtableGenerator) pointerArg(m *vtableMethod, expr string) {
+	m.args = append(m.args, "uintptr(unsafe.Pointer("+expr+"))")
+	m.callArgs = append(m.callArgs, g.q("ole.PointerArg")+"(unsafe.Pointer("+expr+"))")
+}
+
+// valueArg adds an argument passing the value of name: converted to
+// uintptr for syscall.Syscall, and with the function making an ole.Arg of
+// it for ole.CallMethod.
+func (g *vtableGenerator) valueArg(m *vtableMethod, name, argFunc string) {
+	m.args = append(m.args, "uintptr("+name+")")
+	m.callArgs = append(m.callArgs, g.q("ole."+argFunc)+"("+name+")")
+}
+
+// planParam works out how a parameter is passed.
+func (g *vtableGenerator) planParam(m *vtableMethod, p *midlParam, name string, temps nameSet) error {
+	t, err := g.resolve(p.Type)
+	if err != nil {
+		// Types of imported files are unknown.
+		return errUnsupported(fmt.Sprintf("parameter %s: %v", p.Name, err))
+	}
+	out := p.Attrs.has("out")
+	in := p.Attrs.has("in") || !out
+	sized := p.Attrs.has("size_is") || p.Attrs.has("length_is") || p.Attrs.has("max_is")
+
+	if out && (!in || sized) && t.ptr == 0 {
+		return fmt.Errorf("[out] parameter %s is not a pointer", p.Name)
+	}
+
+	switch {
+	case out && !in && !sized:
+		// Results.
+		elem := t
+		elem.ptr--
+		switch {
+		case elem.kind == cVoid && elem.ptr == 1 && p.Attrs.has("iid_is"):
+			m.results = append(m.results, name+" *"+g.q("ole.IUnknown"))
+			g.pointerArg(m, "&"+name)
+		case elem.kind == cWide && elem.ptr == 0, elem.kind == cBSTR && elem.ptr == 0:
+			if !m.hresult {
+				return errUnsupported("string result of a method not returning HRESULT")
+			}
+			temp := temps.unique(name + "Ptr")
+			m.results = append(m.results, name+" string")
+			m.pre = append(m.pre, "var "+temp+" *uint16")
+			g.pointerArg(m, "&"+temp)
+			if elem.kind == cBSTR {
+				m.post = append(m.post,
+					fmt.Sprintf("%s = %s(%s)", name, g.q("ole.BstrToString"), temp),
+					fmt.Sprintf("%s((*int16)(unsafe.Pointer(%s)))", g.q("ole.SysFreeString"), temp))
+			} else {
+				m.post = append(m.post,
+					fmt.Sprintf("%s = %s(%s)", name, g.q("ole.LpOleStrToString"), temp),
+					fmt.Sprintf("%s(uintptr(unsafe.Pointer(%s)))", g.q("ole.CoTaskMemFree"), temp))
+			}
+		case elem.kind == cAnsi && elem.ptr == 0:
+			return errUnsupported("8-bit string result")
+		default:
+			goType, err := g.goType(elem)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %v", p.Name, err)
+			}
+			m.results = append(m.results, name+" "+goType)
+			g.pointerArg(m, "&"+name)
+		}
+		return nil
+
+	case t.ptr > 0:
+		// Pointers, including [in, out] parameters and buffers.
+		goType, err := g.goType(t)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %v", p.Name, err)
+		}
+		m.params = append(m.params, name+" "+goType)
+		if goType == "unsafe.Pointer" {
+			g.valueArg(m, name, "PointerArg")
+		} else {
+			g.pointerArg(m, name)
+		}
+		return nil
+	}
+
+	switch t.kind {
+	case cInt, cEnum:
+		m.params = append(m.params, name+" "+g.q(t.goName))
+		m.args = append(m.args, "uintptr("+name+")")
+		if strings.HasPrefix(t.goName, "uint") {
+			m.callArgs = append(m.callArgs, g.q("ole.Uint32Arg")+"(uint32("+name+"))")
+		} else {
+			m.callArgs = append(m.callArgs, g.q("ole.Int32Arg")+"(int32("+name+"))")
+		}
+	case cHandle:
+		m.params = append(m.params, name+" uintptr")
+		m.args = append(m.args, name)
+		m.callArgs = append(m.callArgs, g.q("ole.UintptrArg")+"("+name+")")
+	case cWide, cAnsi:
+		if !m.hresult {
+			return errUnsupported("string parameter of a method not returning HRESULT")
+		}
+		temp := temps.unique(name + "Ptr")
+		conv := "syscall.UTF16PtrFromString"
+		if t.kind == cAnsi {
+			conv = "syscall.BytePtrFromString"
+		}
+		m.params = append(m.params, name+" string")
+		m.pre = append(m.pre,
+			fmt.Sprintf("%s, err := %s(%s)", temp, conv, name),
+			"if err != nil {\nreturn\n}")
+		g.pointerArg(m, temp)
+	case cBSTR:
+		temp := temps.unique(name + "Bstr")
+		m.params = append(m.params, name+" string")
+		m.pre = append(m.pre,
+			fmt.Sprintf("%s := %s(%s)", temp, g.q("ole.SysAllocString"), name),
+			fmt.Sprintf("defer %s(%s)", g.q("ole.SysFreeString"), temp))
+		g.pointerArg(m, temp)
+	case cInt64, cFloat:
+		argFunc := map[string]string{
+			"int64": "Int64Arg", "uint64": "Uint64Arg", "float32": "Float32Arg", "float64": "Float64Arg",
+		}[t.goName]
+		m.params = append(m.params, name+" "+t.goName)
+		m.callArgs = append(m.callArgs, g.q("ole."+argFunc)+"("+name+")")
+		m.callMethod = true
+	case cStruct:
+		return errUnsupported("structure parameter " + p.Name + " passed by value")
+	default:
+		return fmt.Errorf("parameter %s of type %s", p.Name, p.Type.String())
+	}
+	return nil
+}
+
+// methods writes the methods of the interfaces: calling their vtables on
+// Windows, failing with E_NOTIMPL elsewhere.
+func (g *vtableGenerator) methods(windows bool) ([]byte, error) {
+	g.begin()
+	for _, iface := range g.file.Interfaces {
+		methods, err := g.vtableMethods(iface)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range methods {
+			if m.unsupported != "" {
+				continue
+			}
+			if windows {
+				g.call(iface, m)
+			} else {
+				g.stub(iface, m)
+			}
+		}
+	}
+	if windows {
+		return g.end("windows")
+	}
+	return g.end("!windows")
+}
+
+// signature returns the parameters and results of a method.
+func (m *vtableMethod) signature() string {
+	results := strings.Join(m.results, ", ")
+	if len(m.results) > 0 {
+		results = " (" + results + ")"
+	}
+	return "(" + strings.Join(m.params, ", ") + ")" + results
+}
+
+// call writes a method calling its slot of the vtable.
+func (g *vtableGenerator) call(iface *midlInterface, m *vtableMethod) {
+	name := g.goNames[iface.Name]
+	g.out.imports["syscall"] = true
+	g.out.imports["unsafe"] = true
+	g.printf("\n// %s calls the method %s of %s.\n", m.name, m.Name, iface.Name)
+	g.helpString(m.Attrs)
+	g.printf("func (v *%s) %s%s {\n", name, m.name, m.signature())
+	for _, stmt := range m.pre {
+		g.printf("%s\n", stmt)
+	}
+
+	if m.callMethod {
+		g.callMethod(name, m)
+		return
+	}
+	args := append([]string{"uintptr(unsafe.Pointer(v))"}, m.args...)
+	syscall := "Syscall"
+	for _, n := range []int{3, 6, 9, 12, 15} {
+		if len(args) <= n {
+			if n > 3 {
+				syscall += strconv.Itoa(n)
+			}
+			for len(args) < n {
+				args = append(args, "0")
+			}
+			break
+		}
+	}
+	call := fmt.Sprintf("syscall.%s(\nv.VTable().%s,\n%d,\n%s)",
+		syscall, m.name, len(m.args)+1, strings.Join(args, ",\n"))
+	switch {
+	case m.hresult:
+		if len(m.post) == 0 {
+			g.printf("hr, _, _ := %s\nif int32(hr) < 0 {\nerr = %s(hr)\n}\n", call, g.q("ole.NewError"))
+		} else {
+			g.printf("hr, _, _ := %s\nif int32(hr) < 0 {\nerr = %s(hr)\nreturn\n}\n", call, g.q("ole.NewError"))
+		}
+	case m.ret != "":
+		g.printf("r1, _, _ := %s\n", call)
+		if strings.HasPrefix(m.ret, "*") || m.ret == "unsafe.Pointer" {
+			g.printf("ret = (%s)(unsafe.Pointer(r1))\n", m.ret)
+		} else {
+			g.printf("ret = %s(r1)\n", m.ret)
+		}
+	default:
+		g.printf("%s\n", call)
+	}
+	for _, stmt := range m.post {
+		g.printf("%s\n", stmt)
+	}
+	if len(m.results) > 0 {
+		g.printf("return\n")
+	}
+	g.printf("}\n")
+}
+
+// callMethod writes the call of a method with ole.CallMethod, and the end
+// of the method, for the interface name.
+func (g *vtableGenerator) callMethod(name string, m *vtableMethod) {
+	args := append([]string{
+		"unsafe.Pointer(v)",
+		fmt.Sprintf("int(unsafe.Offsetof(%sVtbl{}.%s) / unsafe.Sizeof(uintptr(0)))", name, m.name),
+	}, m.callArgs...)
+	call := fmt.Sprintf("%s(\n%s)", g.q("ole.CallMethod"), strings.Join(args, ",\n"))
+	switch {
+	case m.hresult && len(m.post) > 0:
+		g.printf("if _, err = %s; err != nil {\nreturn\n}\n", call)
+	case m.hresult:
+		g.printf("_, err = %s\n", call)
+	case m.ret != "":
+		g.printf("r1, _ := %s\nret = %s(r1)\n", call, m.ret)
+	default:
+		g.printf("%s\n", call)
+	}
+	for _, stmt := range m.post {
+		g.printf("%s\n", stmt)
+	}
+	if len(m.results) > 0 {
+		g.printf("return\n")
+	}
+	g.printf("}\n")
+}
+
+// stub writes a method failing with E_NOTIMPL.
+func (g *vtableGenerator) stub(iface *midlInterface, m *vtableMethod) {
+	var zeros []string
+	for _, r := range m.results {
+		goType := r[strings.IndexByte(r, ' ')+1:]
+		if goType == "error" {
+			zeros = append(zeros, g.q("ole.NewError")+"("+g.q("ole.E_NOTIMPL")+")")
+		} else {
+			zeros = append(zeros, g.zero(goType))
+		}
+	}
+	for _, p := range m.params {
+		if strings.Contains(p, "unsafe.") {
+			g.out.imports["unsafe"] = true
+		}
+	}
+	g.printf("\nfunc (v *%s) %s%s {\n", g.goNames[iface.Name], m.name, m.signature())
+	if len(zeros) > 0 {
+		g.printf("return %s\n", strings.Join(zeros, ", "))
+	}
+	g.printf("}\n")
+}
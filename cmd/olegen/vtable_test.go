@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const vtableGolden = "testdata/vtbltest/vtbltest"
+
+func TestGenerateVtables(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/vtbltest.idl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parseMIDL("vtbltest.idl", string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generateVtables(file, "vtbltest", "vtbltest.idl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for suffix, got := range map[string][]byte{
+		".go":         src.Common,
+		"_windows.go": src.Windows,
+		"_func.go":    src.Other,
+	} {
+		name := vtableGolden + suffix
+		if *update {
+			if err := ioutil.WriteFile(name, got, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, golden) {
+			t.Errorf("generated code differs from %s; run go test -update to accept it", name)
+		}
+	}
+}
+
+func TestGenerateVtables_olePackage(t *testing.T) {
+	file, err := parseMIDL("x.idl", `
+		[object, uuid(00000000-0000-0000-0000-000000000001)]
+		interface IThing : IDispatch {
+			HRESULT Find([in] REFIID riid, [out, retval] IUnknown **ppunk);
+		}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generateVtables(file, "ole", "x.idl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range [][]byte{src.Common, src.Windows, src.Other} {
+		if bytes.Contains(code, []byte("ole.")) {
+			t.Errorf("bindings in package ole qualify its names:\n%s", code)
+		}
+	}
+	if !bytes.Contains(src.Windows, []byte("func (v *IThing) Find(riid *GUID) (ppunk *IUnknown, err error)")) {
+		t.Errorf("unexpected method Find:\n%s", src.Windows)
+	}
+}
+
+func TestParseMIDL(t *testing.T) {
+	file, err := parseMIDL("x.idl", `
+		#include <windows.h>
+		/* A comment. */
+		const int SIZE = (2 + 3) * 4;
+		typedef enum { A = SIZE, B } E;
+		typedef struct tagS { int n; char name[SIZE]; struct tagS *next; } S;
+		[object, uuid(00000000-0000-0000-0000-000000000001), helpstring("An \"object\".")]
+		interface IFoo : IUnknown {
+			[propget] HRESULT Name([out, retval] BSTR *p);
+			HRESULT Fill([in, size_is(n)] long arr[], [in] unsigned long n);
+			[call_as(Fill)] HRESULT RemoteFill([in] int n);
+		}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Consts) != 1 || file.Consts[0].Value != int64(20) {
+		t.Errorf("constants = %+v, want SIZE = 20", file.Consts)
+	}
+	if len(file.Enums) != 1 || !reflect.DeepEqual(file.Enums[0].Values, []midlEnumValue{{"A", 20}, {"B", 21}}) {
+		t.Errorf("enums = %+v", file.Enums)
+	}
+	if len(file.Structs) != 1 {
+		t.Fatalf("structs = %+v", file.Structs)
+	}
+	wantFields := []*midlField{
+		{Name: "n", Type: midlType{Name: "int"}},
+		{Name: "name", Type: midlType{Name: "char"}, Array: []int64{20}},
+		{Name: "next", Type: midlType{Name: "tagS", Ptr: 1}},
+	}
+	if s := file.Structs[0]; s.Name != "S" || !reflect.DeepEqual(s.Fields, wantFields) {
+		t.Errorf("struct %s fields = %+v", s.Name, s.Fields)
+	}
+	if file.Tags["tagS"] != "S" {
+		t.Errorf("tag tagS names %q, want S", file.Tags["tagS"])
+	}
+
+	if len(file.Interfaces) != 1 {
+		t.Fatalf("interfaces = %+v", file.Interfaces)
+	}
+	iface := file.Interfaces[0]
+	if iface.Name != "IFoo" || iface.Base != "IUnknown" || iface.Line != 8 {
+		t.Errorf("interface = %s : %s at line %d", iface.Name, iface.Base, iface.Line)
+	}
+	if iface.Attrs["helpstring"] != `An "object".` {
+		t.Errorf("helpstring = %s", iface.Attrs["helpstring"])
+	}
+	var methods []string
+	for _, m := range iface.Methods {
+		var params []string
+		for _, p := range m.Params {
+			params = append(params, p.Type.String()+" "+p.Name)
+		}
+		methods = append(methods, m.Result.String()+" "+m.Name+"("+strings.Join(params, ", ")+")")
+	}
+	want := []string{"HRESULT Name(BSTR* p)", "HRESULT Fill(long* arr, unsigned long n)"}
+	if !reflect.DeepEqual(methods, want) {
+		t.Errorf("methods = %q, want %q", methods, want)
+	}
+	if !iface.Methods[0].Attrs.has("propget") || !iface.Methods[0].Params[0].Attrs.has("retval") {
+		t.Errorf("attributes of Name lost: %v %v", iface.Methods[0].Attrs, iface.Methods[0].Params[0].Attrs)
+	}
+}
+
+func TestParseMIDL_errors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"interface IFoo : IUnknown {\n\tHRESULT F(int\n}", "x.idl:3: expected , or ) in parameters of F"},
+		{"const int N = 1 / 0;", "x.idl:1: division by zero"},
+		{"typedef struct {\n\tint a\n} S;", "x.idl:3: "},
+		{"\"unterminated", "x.idl:1: "},
+	} {
+		_, err := parseMIDL("x.idl", test.src)
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("parseMIDL(%q) = %v, want error starting with %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestGenerateVtables_errors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"interface IFoo : IBar { HRESULT F(); }", "base interface IBar of IFoo is not defined"},
+		{"interface IFoo : IUnknown { HRESULT F([out] int n); }", "[out] parameter n is not a pointer"},
+		{"typedef struct { RECT r; } S;", "field r of S: unknown type RECT"},
+	} {
+		file, err := parseMIDL("x.idl", test.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = generateVtables(file, "x", "x.idl")
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("generateVtables(%q) = %v, want error containing %q", test.src, err, test.err)
+		}
+	}
+}
@@ -23,10 +23,17 @@ var (
 	procStringFromIID           = modole32.NewProc("StringFromIID")
 	procIIDFromString           = modole32.NewProc("IIDFromString")
 	procCoGetObject             = modole32.NewProc("CoGetObject")
+	procCoRegisterClassObject   = modole32.NewProc("CoRegisterClassObject")
+	procCoRevokeClassObject     = modole32.NewProc("CoRevokeClassObject")
+	procCoSuspendClassObjects   = modole32.NewProc("CoSuspendClassObjects")
+	procCoResumeClassObjects    = modole32.NewProc("CoResumeClassObjects")
+	procCoAddRefServerProcess   = modole32.NewProc("CoAddRefServerProcess")
+	procCoReleaseServerProcess  = modole32.NewProc("CoReleaseServerProcess")
 	procGetUserDefaultLCID      = modkernel32.NewProc("GetUserDefaultLCID")
 	procCopyMemory              = modkernel32.NewProc("RtlMoveMemory")
 	procVariantInit             = modoleaut32.NewProc("VariantInit")
 	procVariantClear            = modoleaut32.NewProc("VariantClear")
+	procVariantCopy             = modoleaut32.NewProc("VariantCopy")
 	procVariantTimeToSystemTime = modoleaut32.NewProc("VariantTimeToSystemTime")
 	procSysAllocString          = modoleaut32.NewProc("SysAllocString")
 	procSysAllocStringLen       = modoleaut32.NewProc("SysAllocStringLen")
@@ -35,6 +42,10 @@ var (
 	procCreateDispTypeInfo      = modoleaut32.NewProc("CreateDispTypeInfo")
 	procCreateStdDispatch       = modoleaut32.NewProc("CreateStdDispatch")
 	procGetActiveObject         = modoleaut32.NewProc("GetActiveObject")
+	procLoadTypeLib             = modoleaut32.NewProc("LoadTypeLib")
+	procLoadRegTypeLib          = modoleaut32.NewProc("LoadRegTypeLib")
+	procRegisterTypeLib         = modoleaut32.NewProc("RegisterTypeLib")
+	procUnRegisterTypeLib       = modoleaut32.NewProc("UnRegisterTypeLib")
 
 	procGetMessageW      = moduser32.NewProc("GetMessageW")
 	procDispatchMessageW = moduser32.NewProc("DispatchMessageW")
@@ -128,6 +139,7 @@ func CoInitializeEx(p uintptr, coinit uint32) (err error) {
 
 // CoUninitialize uninitializes COM Library.
 func CoUninitialize() {
+	reportLeaksAtUninitialize()
 	procCoUninitialize.Call()
 }
 
@@ -233,10 +245,73 @@ func CreateInstance(clsid *GUID, iid *GUID) (unk *IUnknown, err error) {
 		uintptr(unsafe.Pointer(&unk)))
 	if hr != 0 {
 		err = NewError(hr)
+	} else {
+		refAcquired(unsafe.Pointer(unk), interfaceName(iid))
 	}
 	return
 }
 
+// CoRegisterClassObject makes the class object of clsid available to other
+// processes, as local servers do at startup. The flags are REGCLS values.
+// The returned cookie is passed to CoRevokeClassObject.
+func CoRegisterClassObject(clsid *GUID, classObject *IUnknown, clsctx uint32, flags uint32) (cookie uint32, err error) {
+	hr, _, _ := procCoRegisterClassObject.Call(
+		uintptr(unsafe.Pointer(clsid)),
+		uintptr(unsafe.Pointer(classObject)),
+		uintptr(clsctx),
+		uintptr(flags),
+		uintptr(unsafe.Pointer(&cookie)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// CoRevokeClassObject withdraws a class object registered with
+// CoRegisterClassObject.
+func CoRevokeClassObject(cookie uint32) (err error) {
+	hr, _, _ := procCoRevokeClassObject.Call(uintptr(cookie))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// CoSuspendClassObjects stops COM from handing out the class objects the
+// process registered, so that no new object is created while it shuts down.
+func CoSuspendClassObjects() (err error) {
+	hr, _, _ := procCoSuspendClassObjects.Call()
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// CoResumeClassObjects makes the class objects registered with
+// REGCLS_SUSPENDED available to clients.
+func CoResumeClassObjects() (err error) {
+	hr, _, _ := procCoResumeClassObjects.Call()
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// CoAddRefServerProcess increments the reference count of a local server
+// process, held by its objects and LockServer locks, and returns it.
+func CoAddRefServerProcess() uint32 {
+	count, _, _ := procCoAddRefServerProcess.Call()
+	return uint32(count)
+}
+
+// CoReleaseServerProcess decrements the reference count of a local server
+// process and returns it. At zero COM suspends the class objects of the
+// process, which may then exit.
+func CoReleaseServerProcess() uint32 {
+	count, _, _ := procCoReleaseServerProcess.Call()
+	return uint32(count)
+}
+
 // GetActiveObject retrieves pointer to active object.
 func GetActiveObject(clsid *GUID, iid *GUID) (unk *IUnknown, err error) {
 	if iid == nil {
@@ -248,6 +323,81 @@ func GetActiveObject(clsid *GUID, iid *GUID) (unk *IUnknown, err error) {
 		uintptr(unsafe.Pointer(&unk)))
 	if hr != 0 {
 		err = NewError(hr)
+	} else {
+		refAcquired(unsafe.Pointer(unk), interfaceName(iid))
+	}
+	return
+}
+
+// LoadTypeLib loads the type library of a .tlb file, or embedded in a DLL or
+// executable. A resource other than the first type library is selected by
+// appending its index to the path, as in `C:\lib\server.dll\2`.
+func LoadTypeLib(path string) (tlib *ITypeLib, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return
+	}
+	hr, _, _ := procLoadTypeLib.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&tlib)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// LoadRegTypeLib loads the registered type library libid of version
+// major.minor for the locale lcid. It fails with TYPE_E_LIBNOTREGISTERED when
+// no such library is registered.
+func LoadRegTypeLib(libid *GUID, major uint16, minor uint16, lcid uint32) (tlib *ITypeLib, err error) {
+	hr, _, _ := procLoadRegTypeLib.Call(
+		uintptr(unsafe.Pointer(libid)),
+		uintptr(major),
+		uintptr(minor),
+		uintptr(lcid),
+		uintptr(unsafe.Pointer(&tlib)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// RegisterTypeLib registers tlib, loaded from path, along with the
+// interfaces it describes. helpDir is the directory of its help file, or
+// empty for the directory of the library.
+func RegisterTypeLib(tlib *ITypeLib, path string, helpDir string) (err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return
+	}
+	var dir *uint16
+	if helpDir != "" {
+		if dir, err = windows.UTF16PtrFromString(helpDir); err != nil {
+			return
+		}
+	}
+	hr, _, _ := procRegisterTypeLib.Call(
+		uintptr(unsafe.Pointer(tlib)),
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(dir)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// UnRegisterTypeLib removes the registration of the type library libid of
+// version major.minor, as made by RegisterTypeLib. syskind is one of the
+// SYS_* platforms.
+func UnRegisterTypeLib(libid *GUID, major uint16, minor uint16, lcid uint32, syskind int32) (err error) {
+	hr, _, _ := procUnRegisterTypeLib.Call(
+		uintptr(unsafe.Pointer(libid)),
+		uintptr(major),
+		uintptr(minor),
+		uintptr(lcid),
+		uintptr(syskind))
+	if hr != 0 {
+		err = NewError(hr)
 	}
 	return
 }
@@ -289,6 +439,7 @@ func VariantInit(v *VARIANT) (err error) {
 
 // VariantClear clears value in Variant settings to VT_EMPTY.
 func VariantClear(v *VARIANT) (err error) {
+	refVariantCleared(v)
 	hr, _, _ := procVariantClear.Call(uintptr(unsafe.Pointer(v)))
 	if hr != 0 {
 		err = NewError(hr)
@@ -296,6 +447,21 @@ func VariantClear(v *VARIANT) (err error) {
 	return
 }
 
+// VariantCopy copies src to dst, duplicating strings and arrays and AddRef'ing
+// interface pointers. dst is cleared first.
+func VariantCopy(dst *VARIANT, src *VARIANT) (err error) {
+	refVariantCleared(dst)
+	hr, _, _ := procVariantCopy.Call(
+		uintptr(unsafe.Pointer(dst)),
+		uintptr(unsafe.Pointer(src)))
+	if hr != 0 {
+		err = NewError(hr)
+	} else {
+		refVariantAcquired(dst)
+	}
+	return
+}
+
 // SysAllocString allocates memory for string and copies string into memory.
 func SysAllocString(v string) (ss *int16) {
 	pss, _, _ := procSysAllocString.Call(uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(v))))
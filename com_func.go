@@ -44,7 +44,9 @@ func CoInitializeEx(p uintptr, coinit uint32) error {
 }
 
 // CoUninitialize uninitializes COM Library.
-func CoUninitialize() {}
+func CoUninitialize() {
+	reportLeaksAtUninitialize()
+}
 
 // CoTaskMemFree frees memory pointer.
 func CoTaskMemFree(memptr uintptr) {}
@@ -101,6 +103,26 @@ func GetActiveObject(clsid *GUID, iid *GUID) (*IUnknown, error) {
 	return nil, NewError(E_NOTIMPL)
 }
 
+// LoadTypeLib loads the type library of a file.
+func LoadTypeLib(path string) (*ITypeLib, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+// LoadRegTypeLib loads a registered type library.
+func LoadRegTypeLib(libid *GUID, major uint16, minor uint16, lcid uint32) (*ITypeLib, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+// RegisterTypeLib registers a type library.
+func RegisterTypeLib(tlib *ITypeLib, path string, helpDir string) error {
+	return NewError(E_NOTIMPL)
+}
+
+// UnRegisterTypeLib removes the registration of a type library.
+func UnRegisterTypeLib(libid *GUID, major uint16, minor uint16, lcid uint32, syskind int32) error {
+	return NewError(E_NOTIMPL)
+}
+
 // VariantInit initializes variant.
 func VariantInit(v *VARIANT) error {
 	return NewError(E_NOTIMPL)
@@ -111,6 +133,48 @@ func VariantClear(v *VARIANT) error {
 	return NewError(E_NOTIMPL)
 }
 
+// CoRegisterClassObject makes the class object of clsid available to other
+// processes.
+func CoRegisterClassObject(clsid *GUID, classObject *IUnknown, clsctx uint32, flags uint32) (uint32, error) {
+	return 0, NewError(E_NOTIMPL)
+}
+
+// CoRevokeClassObject withdraws a class object registered with
+// CoRegisterClassObject.
+func CoRevokeClassObject(cookie uint32) error {
+	return NewError(E_NOTIMPL)
+}
+
+// CoSuspendClassObjects stops COM from handing out the class objects the
+// process registered.
+func CoSuspendClassObjects() error {
+	return NewError(E_NOTIMPL)
+}
+
+// CoResumeClassObjects makes the class objects registered with
+// REGCLS_SUSPENDED available to clients.
+func CoResumeClassObjects() error {
+	return NewError(E_NOTIMPL)
+}
+
+// CoAddRefServerProcess increments the reference count of a local server
+// process.
+func CoAddRefServerProcess() uint32 {
+	return 0
+}
+
+// CoReleaseServerProcess decrements the reference count of a local server
+// process.
+func CoReleaseServerProcess() uint32 {
+	return 0
+}
+
+// VariantCopy copies src to dst, duplicating strings and arrays and AddRef'ing
+// interface pointers. dst is cleared first.
+func VariantCopy(dst *VARIANT, src *VARIANT) error {
+	return NewError(E_NOTIMPL)
+}
+
 // SysAllocString allocates memory for string and copies string into memory.
 func SysAllocString(v string) *int16 {
 	u := int16(0)
@@ -18,6 +18,15 @@ const (
 	COINIT_SPEED_OVER_MEMORY = 0x8
 )
 
+// REGCLS values control how CoRegisterClassObject serves a class object.
+const (
+	REGCLS_SINGLEUSE      = 0
+	REGCLS_MULTIPLEUSE    = 1
+	REGCLS_MULTI_SEPARATE = 2
+	REGCLS_SUSPENDED      = 4
+	REGCLS_SURROGATE      = 8
+)
+
 const (
 	DISPATCH_METHOD         = 1
 	DISPATCH_PROPERTYGET    = 2
@@ -27,6 +36,7 @@ const (
 
 const (
 	S_OK           = 0x00000000
+	S_FALSE        = 0x00000001
 	E_UNEXPECTED   = 0x8000FFFF
 	E_NOTIMPL      = 0x80004001
 	E_OUTOFMEMORY  = 0x8007000E
@@ -40,20 +50,55 @@ const (
 	E_PENDING      = 0x8000000A
 
 	CO_E_CLASSSTRING = 0x800401F3
+
+	CLASS_E_NOAGGREGATION     = 0x80040110
+	CLASS_E_CLASSNOTAVAILABLE = 0x80040111
+
+	CONNECT_E_NOCONNECTION  = 0x80040200
+	CONNECT_E_ADVISELIMIT   = 0x80040201
+	CONNECT_E_CANNOTCONNECT = 0x80040202
+
+	RPC_E_DISCONNECTED = 0x80010108
+
+	DISP_E_UNKNOWNINTERFACE = 0x80020001
+	DISP_E_MEMBERNOTFOUND   = 0x80020003
+	DISP_E_PARAMNOTFOUND    = 0x80020004
+	DISP_E_TYPEMISMATCH     = 0x80020005
+	DISP_E_UNKNOWNNAME      = 0x80020006
+	DISP_E_NONAMEDARGS      = 0x80020007
+	DISP_E_BADVARTYPE       = 0x80020008
+	DISP_E_EXCEPTION        = 0x80020009
+	DISP_E_OVERFLOW         = 0x8002000A
+	DISP_E_BADINDEX         = 0x8002000B
+	DISP_E_UNKNOWNLCID      = 0x8002000C
+	DISP_E_ARRAYISLOCKED    = 0x8002000D
+	DISP_E_BADPARAMCOUNT    = 0x8002000E
+	DISP_E_PARAMNOTOPTIONAL = 0x8002000F
+	DISP_E_BADCALLEE        = 0x80020010
+	DISP_E_NOTACOLLECTION   = 0x80020011
+	DISP_E_DIVBYZERO        = 0x80020012
+	DISP_E_BUFFERTOOSMALL   = 0x80020013
+
+	TYPE_E_INVDATAREAD      = 0x80028018
+	TYPE_E_UNSUPFORMAT      = 0x80028019
+	TYPE_E_REGISTRYACCESS   = 0x8002801C
+	TYPE_E_LIBNOTREGISTERED = 0x8002801D
+	TYPE_E_ELEMENTNOTFOUND  = 0x8002802B
+	TYPE_E_CANTLOADLIBRARY  = 0x80029C4A
 )
 
 const (
-	CC_FASTCALL = iota
-	CC_CDECL
-	CC_MSCPASCAL
-	CC_PASCAL = CC_MSCPASCAL
-	CC_MACPASCAL
-	CC_STDCALL
-	CC_FPFASTCALL
-	CC_SYSCALL
-	CC_MPWCDECL
-	CC_MPWPASCAL
-	CC_MAX = CC_MPWPASCAL
+	CC_FASTCALL   = 0
+	CC_CDECL      = 1
+	CC_MSCPASCAL  = 2
+	CC_PASCAL     = CC_MSCPASCAL
+	CC_MACPASCAL  = 3
+	CC_STDCALL    = 4
+	CC_FPFASTCALL = 5
+	CC_SYSCALL    = 6
+	CC_MPWCDECL   = 7
+	CC_MPWPASCAL  = 8
+	CC_MAX        = 9
 )
 
 type VT uint16
@@ -130,18 +175,140 @@ const (
 	DISPID_COLLECT     = -8
 )
 
+// TYPEKIND values tell the kinds of types apart.
+const (
+	TKIND_ENUM      = 0
+	TKIND_RECORD    = 1
+	TKIND_MODULE    = 2
+	TKIND_INTERFACE = 3
+	TKIND_DISPATCH  = 4
+	TKIND_COCLASS   = 5
+	TKIND_ALIAS     = 6
+	TKIND_UNION     = 7
+	TKIND_MAX       = 8
+)
+
+// SYSKIND values name the platform a type library targets.
+const (
+	SYS_WIN16 = 0
+	SYS_WIN32 = 1
+	SYS_MAC   = 2
+	SYS_WIN64 = 3
+)
+
+// LIBFLAGS values are the flags of a type library.
+const (
+	LIBFLAG_FRESTRICTED   = 0x1
+	LIBFLAG_FCONTROL      = 0x2
+	LIBFLAG_FHIDDEN       = 0x4
+	LIBFLAG_FHASDISKIMAGE = 0x8
+)
+
+const (
+	IMPLTYPEFLAG_FDEFAULT       = 0x1
+	IMPLTYPEFLAG_FSOURCE        = 0x2
+	IMPLTYPEFLAG_FRESTRICTED    = 0x4
+	IMPLTYPEFLAG_FDEFAULTVTABLE = 0x8
+)
+
+// FUNCKIND values describe how a function of a type is called.
 const (
-	TKIND_ENUM      = 1
-	TKIND_RECORD    = 2
-	TKIND_MODULE    = 3
-	TKIND_INTERFACE = 4
-	TKIND_DISPATCH  = 5
-	TKIND_COCLASS   = 6
-	TKIND_ALIAS     = 7
-	TKIND_UNION     = 8
-	TKIND_MAX       = 9
+	FUNC_VIRTUAL     = 0
+	FUNC_PUREVIRTUAL = 1
+	FUNC_NONVIRTUAL  = 2
+	FUNC_STATIC      = 3
+	FUNC_DISPATCH    = 4
 )
 
+// INVOKEKIND values tell methods from property accessors.
+const (
+	INVOKE_FUNC           = 1
+	INVOKE_PROPERTYGET    = 2
+	INVOKE_PROPERTYPUT    = 4
+	INVOKE_PROPERTYPUTREF = 8
+)
+
+// VARKIND values describe where a variable of a type is stored.
+const (
+	VAR_PERINSTANCE = 0
+	VAR_STATIC      = 1
+	VAR_CONST       = 2
+	VAR_DISPATCH    = 3
+)
+
+// PARAMFLAG values describe parameters. The IDLFLAG values of return values
+// share the first four.
+const (
+	PARAMFLAG_NONE         = 0x0
+	PARAMFLAG_FIN          = 0x1
+	PARAMFLAG_FOUT         = 0x2
+	PARAMFLAG_FLCID        = 0x4
+	PARAMFLAG_FRETVAL      = 0x8
+	PARAMFLAG_FOPT         = 0x10
+	PARAMFLAG_FHASDEFAULT  = 0x20
+	PARAMFLAG_FHASCUSTDATA = 0x40
+)
+
+// FUNCFLAG values are the attributes of functions.
+const (
+	FUNCFLAG_FRESTRICTED       = 0x1
+	FUNCFLAG_FSOURCE           = 0x2
+	FUNCFLAG_FBINDABLE         = 0x4
+	FUNCFLAG_FREQUESTEDIT      = 0x8
+	FUNCFLAG_FDISPLAYBIND      = 0x10
+	FUNCFLAG_FDEFAULTBIND      = 0x20
+	FUNCFLAG_FHIDDEN           = 0x40
+	FUNCFLAG_FUSESGETLASTERROR = 0x80
+	FUNCFLAG_FDEFAULTCOLLELEM  = 0x100
+	FUNCFLAG_FUIDEFAULT        = 0x200
+	FUNCFLAG_FNONBROWSABLE     = 0x400
+	FUNCFLAG_FREPLACEABLE      = 0x800
+	FUNCFLAG_FIMMEDIATEBIND    = 0x1000
+)
+
+// VARFLAG values are the attributes of variables.
+const (
+	VARFLAG_FREADONLY        = 0x1
+	VARFLAG_FSOURCE          = 0x2
+	VARFLAG_FBINDABLE        = 0x4
+	VARFLAG_FREQUESTEDIT     = 0x8
+	VARFLAG_FDISPLAYBIND     = 0x10
+	VARFLAG_FDEFAULTBIND     = 0x20
+	VARFLAG_FHIDDEN          = 0x40
+	VARFLAG_FRESTRICTED      = 0x80
+	VARFLAG_FDEFAULTCOLLELEM = 0x100
+	VARFLAG_FUIDEFAULT       = 0x200
+	VARFLAG_FNONBROWSABLE    = 0x400
+	VARFLAG_FREPLACEABLE     = 0x800
+	VARFLAG_FIMMEDIATEBIND   = 0x1000
+)
+
+// TYPEFLAG values are the attributes of types.
+const (
+	TYPEFLAG_FAPPOBJECT     = 0x1
+	TYPEFLAG_FCANCREATE     = 0x2
+	TYPEFLAG_FLICENSED      = 0x4
+	TYPEFLAG_FPREDECLID     = 0x8
+	TYPEFLAG_FHIDDEN        = 0x10
+	TYPEFLAG_FCONTROL       = 0x20
+	TYPEFLAG_FDUAL          = 0x40
+	TYPEFLAG_FNONEXTENSIBLE = 0x80
+	TYPEFLAG_FOLEAUTOMATION = 0x100
+	TYPEFLAG_FRESTRICTED    = 0x200
+	TYPEFLAG_FAGGREGATABLE  = 0x400
+	TYPEFLAG_FREPLACEABLE   = 0x800
+	TYPEFLAG_FDISPATCHABLE  = 0x1000
+	TYPEFLAG_FREVERSEBIND   = 0x2000
+	TYPEFLAG_FPROXY         = 0x4000
+)
+
+// MEMBERID_NIL refers to the type itself rather than one of its members.
+const MEMBERID_NIL = -1
+
+// GUIDKIND_DEFAULT_SOURCE_DISP_IID asks IProvideClassInfo2.GetGUID for the
+// IID of the object's default outgoing dispinterface.
+const GUIDKIND_DEFAULT_SOURCE_DISP_IID = 1
+
 // Safe Array Feature Flags
 
 const (
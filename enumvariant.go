@@ -0,0 +1,193 @@
+package ole
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	enumServersHook   func(delta int)
+	enumServersHookMu sync.Mutex
+)
+
+// NotifyEnumVARIANTServers sets a function called with 1 whenever
+// NewEnumVARIANT or Clone creates an enumerator and with -1 when one is
+// released for the last time, replacing any previous one; nil removes it.
+// COM servers use it to count enumerators among the live objects of their
+// module.
+func NotifyEnumVARIANTServers(f func(delta int)) {
+	enumServersHookMu.Lock()
+	enumServersHook = f
+	enumServersHookMu.Unlock()
+}
+
+func notifyEnumServers(delta int) {
+	enumServersHookMu.Lock()
+	f := enumServersHook
+	enumServersHookMu.Unlock()
+	if f != nil {
+		f(delta)
+	}
+}
+
+// enumSource is the sequence of values shared by an enumerator and its
+// clones.
+//
+// Values pulled from an iterator are kept, so that cursors can be reset and
+// cloned. VARIANT values are owned by the source and cleared once the last
+// enumerator using it is released.
+type enumSource struct {
+	mu     sync.Mutex
+	slice  reflect.Value
+	pull   func() (interface{}, bool)
+	values []interface{}
+	done   bool
+	refs   int
+}
+
+func newEnumSource(source interface{}) (*enumSource, error) {
+	if pull, ok := source.(func() (interface{}, bool)); ok {
+		return &enumSource{pull: pull, refs: 1}, nil
+	}
+	rv := reflect.ValueOf(source)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return &enumSource{slice: rv, refs: 1}, nil
+	}
+	return nil, NewError(E_INVALIDARG)
+}
+
+// get returns the value at index i, pulling values from the iterator as
+// needed. ok is false past the end.
+func (s *enumSource) get(i int) (value interface{}, ok bool) {
+	if s.pull == nil {
+		if i >= s.slice.Len() {
+			return nil, false
+		}
+		return s.slice.Index(i).Interface(), true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.values) <= i && !s.done {
+		value, ok := s.pull()
+		if !ok {
+			s.done = true
+			break
+		}
+		s.values = append(s.values, value)
+	}
+	if i >= len(s.values) {
+		return nil, false
+	}
+	return s.values[i], true
+}
+
+func (s *enumSource) addRef() {
+	s.mu.Lock()
+	s.refs++
+	s.mu.Unlock()
+}
+
+func (s *enumSource) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+	for i := range s.values {
+		if v, ok := s.values[i].(VARIANT); ok {
+			VariantClear(&v)
+		}
+	}
+	s.values = nil
+}
+
+// enumCursor implements IEnumVARIANT over an enumSource independently of the
+// COM plumbing. Each clone has its own cursor.
+type enumCursor struct {
+	mu     sync.Mutex
+	source *enumSource
+	pos    int
+}
+
+// elementVariant converts a value of the source to a VARIANT owned by the
+// caller.
+func elementVariant(value interface{}) (VARIANT, error) {
+	if v, ok := value.(VARIANT); ok {
+		var copied VARIANT
+		VariantInit(&copied)
+		err := VariantCopy(&copied, &v)
+		return copied, err
+	}
+	return NewVariantFromValue(value)
+}
+
+// next fills out with the following elements and returns how many it stored.
+// S_FALSE is returned when fewer than len(out) elements were left. When an
+// element cannot be converted, nothing is returned and the cursor does not
+// move.
+func (e *enumCursor) next(out []VARIANT) (fetched int, hr uintptr) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for fetched < len(out) {
+		value, ok := e.source.get(e.pos + fetched)
+		if !ok {
+			break
+		}
+		v, err := elementVariant(value)
+		if err != nil {
+			for i := 0; i < fetched; i++ {
+				VariantClear(&out[i])
+			}
+			if oleErr, ok := err.(*OleError); ok {
+				return 0, oleErr.Code()
+			}
+			return 0, E_FAIL
+		}
+		out[fetched] = v
+		fetched++
+	}
+	e.pos += fetched
+	if fetched < len(out) {
+		return fetched, S_FALSE
+	}
+	return fetched, S_OK
+}
+
+// skip moves the cursor past n elements, returning S_FALSE when fewer were
+// left.
+func (e *enumCursor) skip(n uint32) uintptr {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ; n > 0; n-- {
+		if _, ok := e.source.get(e.pos); !ok {
+			return S_FALSE
+		}
+		e.pos++
+	}
+	return S_OK
+}
+
+// reset moves the cursor back to the first element.
+func (e *enumCursor) reset() {
+	e.mu.Lock()
+	e.pos = 0
+	e.mu.Unlock()
+}
+
+// clone returns a cursor over the same elements at the same position.
+func (e *enumCursor) clone() *enumCursor {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.source.addRef()
+	return &enumCursor{source: e.source, pos: e.pos}
+}
+
+// release drops the cursor's reference to the source.
+func (e *enumCursor) release() {
+	e.source.release()
+}
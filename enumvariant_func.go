@@ -0,0 +1,9 @@
+// +build !windows
+
+package ole
+
+// NewEnumVARIANT returns an IEnumVARIANT serving the elements of source to COM
+// clients.
+func NewEnumVARIANT(source interface{}) (*IEnumVARIANT, error) {
+	return nil, NewError(E_NOTIMPL)
+}
@@ -0,0 +1,130 @@
+package ole
+
+import "testing"
+
+func newTestCursor(t *testing.T, source interface{}) *enumCursor {
+	s, err := newEnumSource(source)
+	if err != nil {
+		t.Fatalf("newEnumSource(%T) returned %v", source, err)
+	}
+	return &enumCursor{source: s}
+}
+
+func fetch(t *testing.T, e *enumCursor, celt int) ([]int64, uintptr) {
+	out := make([]VARIANT, celt)
+	n, hr := e.next(out)
+	values := make([]int64, n)
+	for i := range values {
+		if out[i].VT != VT_I4 {
+			t.Errorf("element %d has type %v", i, out[i].VT)
+		}
+		values[i] = out[i].Val
+	}
+	return values, hr
+}
+
+func TestEnumCursor_next(t *testing.T) {
+	e := newTestCursor(t, []int32{1, 2, 3})
+
+	if values, hr := fetch(t, e, 2); hr != S_OK || len(values) != 2 || values[0] != 1 || values[1] != 2 {
+		t.Errorf("Next(2) = %v, %#x", values, hr)
+	}
+	if values, hr := fetch(t, e, 2); hr != S_FALSE || len(values) != 1 || values[0] != 3 {
+		t.Errorf("Next(2) at the end = %v, %#x", values, hr)
+	}
+	if values, hr := fetch(t, e, 1); hr != S_FALSE || len(values) != 0 {
+		t.Errorf("Next(1) past the end = %v, %#x", values, hr)
+	}
+}
+
+func TestEnumCursor_skipReset(t *testing.T) {
+	e := newTestCursor(t, [4]int32{1, 2, 3, 4})
+
+	if hr := e.skip(3); hr != S_OK {
+		t.Errorf("Skip(3) = %#x", hr)
+	}
+	if values, _ := fetch(t, e, 1); len(values) != 1 || values[0] != 4 {
+		t.Errorf("Next after Skip(3) = %v", values)
+	}
+	if hr := e.skip(1); hr != S_FALSE {
+		t.Errorf("Skip past the end = %#x", hr)
+	}
+
+	e.reset()
+	if values, hr := fetch(t, e, 4); hr != S_OK || len(values) != 4 || values[0] != 1 {
+		t.Errorf("Next(4) after Reset = %v, %#x", values, hr)
+	}
+}
+
+func TestEnumCursor_clone(t *testing.T) {
+	e := newTestCursor(t, []int32{1, 2, 3})
+	fetch(t, e, 1)
+
+	clone := e.clone()
+	if values, _ := fetch(t, clone, 1); len(values) != 1 || values[0] != 2 {
+		t.Errorf("clone did not start at the original position: %v", values)
+	}
+	if values, _ := fetch(t, clone, 1); len(values) != 1 || values[0] != 3 {
+		t.Errorf("clone Next = %v", values)
+	}
+	if values, _ := fetch(t, e, 1); len(values) != 1 || values[0] != 2 {
+		t.Errorf("original cursor moved with the clone: %v", values)
+	}
+
+	clone.reset()
+	if values, _ := fetch(t, e, 1); len(values) != 1 || values[0] != 3 {
+		t.Errorf("original cursor reset with the clone: %v", values)
+	}
+
+	if e.source.refs != 2 {
+		t.Errorf("source has %d references, expected 2", e.source.refs)
+	}
+	clone.release()
+	e.release()
+	if e.source.refs != 0 {
+		t.Errorf("source has %d references after release", e.source.refs)
+	}
+}
+
+func TestEnumCursor_iterator(t *testing.T) {
+	pulled := 0
+	pull := func() (interface{}, bool) {
+		if pulled == 3 {
+			return nil, false
+		}
+		pulled++
+		return int32(pulled * 10), true
+	}
+	e := newTestCursor(t, pull)
+
+	if values, _ := fetch(t, e, 1); len(values) != 1 || values[0] != 10 || pulled != 1 {
+		t.Errorf("Next(1) = %v after %d pulls", values, pulled)
+	}
+	clone := e.clone()
+	if values, hr := fetch(t, clone, 5); hr != S_FALSE || len(values) != 2 || values[1] != 30 {
+		t.Errorf("clone Next(5) = %v, %#x", values, hr)
+	}
+
+	// Values already pulled are replayed rather than pulled again.
+	e.reset()
+	if values, hr := fetch(t, e, 3); hr != S_OK || len(values) != 3 || values[0] != 10 || pulled != 3 {
+		t.Errorf("Next(3) after Reset = %v, %#x after %d pulls", values, hr, pulled)
+	}
+}
+
+func TestEnumCursor_conversionError(t *testing.T) {
+	e := newTestCursor(t, []interface{}{int32(1), struct{}{}})
+
+	if values, hr := fetch(t, e, 2); hr != DISP_E_TYPEMISMATCH || len(values) != 0 {
+		t.Errorf("Next over an unconvertible element = %v, %#x", values, hr)
+	}
+	if values, _ := fetch(t, e, 1); len(values) != 1 || values[0] != 1 {
+		t.Errorf("cursor moved on failure: %v", values)
+	}
+}
+
+func TestNewEnumSource_invalid(t *testing.T) {
+	if _, err := newEnumSource(42); err == nil {
+		t.Error("newEnumSource accepted an int")
+	}
+}
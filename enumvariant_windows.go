@@ -0,0 +1,141 @@
+//go:build windows
+// +build windows
+
+package ole
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// enumVariantServer is the COM object handed out by NewEnumVARIANT. The
+// vtable pointer must stay the first field.
+type enumVariantServer struct {
+	lpVtbl *IEnumVARIANTVtbl
+	ref    int32
+	cursor *enumCursor
+}
+
+var (
+	enumVariantServerVtbl     *IEnumVARIANTVtbl
+	enumVariantServerVtblOnce sync.Once
+
+	// liveEnumVariantServers keeps servers reachable by the garbage
+	// collector while COM clients hold references to them.
+	liveEnumVariantServers   = map[*enumVariantServer]struct{}{}
+	liveEnumVariantServersMu sync.Mutex
+)
+
+// NewEnumVARIANT returns an IEnumVARIANT serving the elements of source to COM
+// clients.
+//
+// source is a slice or an array, or a pull iterator of type
+// func() (interface{}, bool) returning false once exhausted. Elements are
+// converted with NewVariantFromValue as they are fetched; VARIANT elements are
+// copied. Values pulled from an iterator are kept for Reset and Clone, and
+// VARIANTs among them are cleared when the last enumerator is released.
+//
+// The returned enumerator has a reference count of one; call Release when
+// done.
+func NewEnumVARIANT(source interface{}) (*IEnumVARIANT, error) {
+	s, err := newEnumSource(source)
+	if err != nil {
+		return nil, err
+	}
+	return newEnumVariantServer(&enumCursor{source: s}), nil
+}
+
+func newEnumVariantServer(cursor *enumCursor) *IEnumVARIANT {
+	enumVariantServerVtblOnce.Do(func() {
+		enumVariantServerVtbl = &IEnumVARIANTVtbl{}
+		enumVariantServerVtbl.QueryInterface = syscall.NewCallback(enumVariantServerQueryInterface)
+		enumVariantServerVtbl.AddRef = syscall.NewCallback(enumVariantServerAddRef)
+		enumVariantServerVtbl.Release = syscall.NewCallback(enumVariantServerRelease)
+		enumVariantServerVtbl.Next = syscall.NewCallback(enumVariantServerNext)
+		enumVariantServerVtbl.Skip = syscall.NewCallback(enumVariantServerSkip)
+		enumVariantServerVtbl.Reset = syscall.NewCallback(enumVariantServerReset)
+		enumVariantServerVtbl.Clone = syscall.NewCallback(enumVariantServerClone)
+	})
+
+	server := &enumVariantServer{
+		lpVtbl: enumVariantServerVtbl,
+		ref:    1,
+		cursor: cursor,
+	}
+	liveEnumVariantServersMu.Lock()
+	liveEnumVariantServers[server] = struct{}{}
+	liveEnumVariantServersMu.Unlock()
+	notifyEnumServers(1)
+	return (*IEnumVARIANT)(unsafe.Pointer(server))
+}
+
+func enumVariantServerQueryInterface(this *enumVariantServer, iid *GUID, punk **IUnknown) uintptr {
+	if punk == nil {
+		return E_POINTER
+	}
+	*punk = nil
+	if !IsEqualGUID(iid, IID_IUnknown) && !IsEqualGUID(iid, IID_IEnumVariant) {
+		return E_NOINTERFACE
+	}
+	atomic.AddInt32(&this.ref, 1)
+	*punk = (*IUnknown)(unsafe.Pointer(this))
+	return S_OK
+}
+
+func enumVariantServerAddRef(this *enumVariantServer) uintptr {
+	return uintptr(atomic.AddInt32(&this.ref, 1))
+}
+
+func enumVariantServerRelease(this *enumVariantServer) uintptr {
+	ref := atomic.AddInt32(&this.ref, -1)
+	if ref == 0 {
+		this.cursor.release()
+		liveEnumVariantServersMu.Lock()
+		delete(liveEnumVariantServers, this)
+		liveEnumVariantServersMu.Unlock()
+		notifyEnumServers(-1)
+	}
+	return uintptr(ref)
+}
+
+func enumVariantServerNext(this *enumVariantServer, celt uint32, rgVar *VARIANT, fetched *uint32) uintptr {
+	if celt > 0 && rgVar == nil {
+		return E_POINTER
+	}
+	// The slice covers the celt elements of rgVar, however many the client
+	// asks for; the cursor stores no more than it has.
+	var out []VARIANT
+	if celt > 0 {
+		n := int(celt)
+		if n < 0 {
+			n = int(^uint(0) >> 1)
+		}
+		header := (*reflect.SliceHeader)(unsafe.Pointer(&out))
+		header.Data, header.Len, header.Cap = uintptr(unsafe.Pointer(rgVar)), n, n
+	}
+	n, hr := this.cursor.next(out)
+	if fetched != nil {
+		*fetched = uint32(n)
+	}
+	return hr
+}
+
+func enumVariantServerSkip(this *enumVariantServer, celt uint32) uintptr {
+	return this.cursor.skip(celt)
+}
+
+func enumVariantServerReset(this *enumVariantServer) uintptr {
+	this.cursor.reset()
+	return S_OK
+}
+
+func enumVariantServerClone(this *enumVariantServer, clone **IEnumVARIANT) uintptr {
+	if clone == nil {
+		return E_POINTER
+	}
+	*clone = newEnumVariantServer(this.cursor.clone())
+	return S_OK
+}
@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestEnumVARIANT_nextMany(t *testing.T) {
+	enum, err := NewEnumVARIANT([]int32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enum.Release()
+
+	// More elements than a fixed-size array view of the buffer would hold.
+	buffer := make([]VARIANT, 1<<20+1)
+	var fetched uint32
+	hr, _, _ := syscall.Syscall6(
+		enum.VTable().Next,
+		4,
+		uintptr(unsafe.Pointer(enum)),
+		uintptr(len(buffer)),
+		uintptr(unsafe.Pointer(&buffer[0])),
+		uintptr(unsafe.Pointer(&fetched)),
+		0,
+		0)
+	if hr != S_FALSE || fetched != 3 {
+		t.Fatalf("Next(%d) = %#x with %d fetched, want S_FALSE with 3", len(buffer), hr, fetched)
+	}
+	for i := 0; i < 3; i++ {
+		if v := buffer[i].Value(); v != int32(i+1) {
+			t.Errorf("element %d = %v, want %d", i, v, i+1)
+		}
+	}
+}
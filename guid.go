@@ -10,6 +10,12 @@ var (
 	// IID_IDispatch is for IDispatch interfaces.
 	IID_IDispatch = NewGUID("{00020400-0000-0000-C000-000000000046}")
 
+	// IID_IDispatchEx is for IDispatchEx interfaces.
+	IID_IDispatchEx = NewGUID("{A6EF9860-C720-11D0-9337-00A0C90DCAA9}")
+
+	// IID_IClassFactory is for IClassFactory interfaces.
+	IID_IClassFactory = NewGUID("{00000001-0000-0000-C000-000000000046}")
+
 	// IID_IEnumVariant is for IEnumVariant interfaces
 	IID_IEnumVariant = NewGUID("{00020404-0000-0000-C000-000000000046}")
 
@@ -19,11 +25,26 @@ var (
 	// IID_IConnectionPoint is for IConnectionPoint interfaces.
 	IID_IConnectionPoint = NewGUID("{B196B286-BAB4-101A-B69C-00AA00341D07}")
 
+	// IID_IEnumConnectionPoints is for IEnumConnectionPoints interfaces.
+	IID_IEnumConnectionPoints = NewGUID("{B196B285-BAB4-101A-B69C-00AA00341D07}")
+
+	// IID_IEnumConnections is for IEnumConnections interfaces.
+	IID_IEnumConnections = NewGUID("{B196B287-BAB4-101A-B69C-00AA00341D07}")
+
 	// IID_IInspectable is for IInspectable interfaces.
 	IID_IInspectable = NewGUID("{AF86E2E0-B12D-4C6A-9C5A-D7AA65101E90}")
 
+	// IID_ITypeInfo is for ITypeInfo interfaces.
+	IID_ITypeInfo = NewGUID("{00020401-0000-0000-C000-000000000046}")
+
+	// IID_ITypeLib is for ITypeLib interfaces.
+	IID_ITypeLib = NewGUID("{00020402-0000-0000-C000-000000000046}")
+
 	// IID_IProvideClassInfo is for IProvideClassInfo interfaces.
 	IID_IProvideClassInfo = NewGUID("{B196B283-BAB4-101A-B69C-00AA00341D07}")
+
+	// IID_IProvideClassInfo2 is for IProvideClassInfo2 interfaces.
+	IID_IProvideClassInfo2 = NewGUID("{A6BC3AC0-DBAA-11CE-9DE3-00AA004BB851}")
 )
 
 // These are for testing and not part of any library.
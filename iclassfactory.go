@@ -0,0 +1,20 @@
+package ole
+
+import "unsafe"
+
+// IClassFactory creates the objects of a COM class. It is the class object
+// returned by CoGetClassObject and by the DllGetClassObject export of
+// in-process servers.
+type IClassFactory struct {
+	IUnknown
+}
+
+type IClassFactoryVtbl struct {
+	IUnknownVtbl
+	CreateInstance uintptr
+	LockServer     uintptr
+}
+
+func (v *IClassFactory) VTable() *IClassFactoryVtbl {
+	return (*IClassFactoryVtbl)(unsafe.Pointer(v.RawVTable))
+}
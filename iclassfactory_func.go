@@ -0,0 +1,14 @@
+// +build !windows
+
+package ole
+
+// CreateInstance creates an uninitialized object of the class and returns
+// its interface iid.
+func (v *IClassFactory) CreateInstance(outer *IUnknown, iid *GUID) (*IUnknown, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+// LockServer keeps the server of the class loaded while locked.
+func (v *IClassFactory) LockServer(lock bool) error {
+	return NewError(E_NOTIMPL)
+}
@@ -0,0 +1,46 @@
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// CreateInstance creates an uninitialized object of the class and returns
+// its interface iid. outer is the controlling unknown when the object is
+// aggregated, nil otherwise.
+func (v *IClassFactory) CreateInstance(outer *IUnknown, iid *GUID) (unk *IUnknown, err error) {
+	hr, _, _ := syscall.Syscall6(
+		v.VTable().CreateInstance,
+		4,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(outer)),
+		uintptr(unsafe.Pointer(iid)),
+		uintptr(unsafe.Pointer(&unk)),
+		0,
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// LockServer keeps the server of the class loaded while locked, so objects
+// can be created quickly.
+func (v *IClassFactory) LockServer(lock bool) (err error) {
+	var flag uintptr
+	if lock {
+		flag = 1
+	}
+	hr, _, _ := syscall.Syscall(
+		v.VTable().LockServer,
+		2,
+		uintptr(unsafe.Pointer(v)),
+		flag,
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
@@ -2,10 +2,12 @@
 
 package ole
 
-import "unsafe"
+func (v *IConnectionPoint) GetConnectionInterface() (*GUID, error) {
+	return nil, NewError(E_NOTIMPL)
+}
 
-func (v *IConnectionPoint) GetConnectionInterface(piid **GUID) int32 {
-	return int32(0)
+func (v *IConnectionPoint) GetConnectionPointContainer() (*IConnectionPointContainer, error) {
+	return nil, NewError(E_NOTIMPL)
 }
 
 func (v *IConnectionPoint) Advise(unknown *IUnknown) (uint32, error) {
@@ -16,6 +18,6 @@ func (v *IConnectionPoint) Unadvise(cookie uint32) error {
 	return NewError(E_NOTIMPL)
 }
 
-func (v *IConnectionPoint) EnumConnections(p *unsafe.Pointer) (err error) {
-	return NewError(E_NOTIMPL)
+func (v *IConnectionPoint) EnumConnections() ([]CONNECTDATA, error) {
+	return nil, NewError(E_NOTIMPL)
 }
@@ -7,9 +7,37 @@ import (
 	"unsafe"
 )
 
-func (v *IConnectionPoint) GetConnectionInterface(piid **GUID) int32 {
-	// XXX: This doesn't look like it does what it's supposed to
-	return release((*IUnknown)(unsafe.Pointer(v)))
+// GetConnectionInterface retrieves the IID of the outgoing interface managed
+// by the connection point.
+func (v *IConnectionPoint) GetConnectionInterface() (iid *GUID, err error) {
+	var guid GUID
+	hr, _, _ := syscall.Syscall(
+		v.VTable().GetConnectionInterface,
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&guid)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	iid = &guid
+	return
+}
+
+// GetConnectionPointContainer retrieves the container the connection point
+// belongs to.
+func (v *IConnectionPoint) GetConnectionPointContainer() (container *IConnectionPointContainer, err error) {
+	hr, _, _ := syscall.Syscall(
+		v.VTable().GetConnectionPointContainer,
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&container)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
 }
 
 func (v *IConnectionPoint) Advise(unknown *IUnknown) (cookie uint32, err error) {
@@ -38,6 +66,41 @@ func (v *IConnectionPoint) Unadvise(cookie uint32) (err error) {
 	return
 }
 
-func (v *IConnectionPoint) EnumConnections(p *unsafe.Pointer) error {
-	return NewError(E_NOTIMPL)
+// enumBatchSize is the number of elements requested per Next call when an
+// enumerator is drained into a slice, by EnumConnections and
+// IConnectionPointContainer.EnumConnectionPoints.
+const enumBatchSize = 16
+
+// EnumConnections lists the current connections on the connection point.
+//
+// Each returned CONNECTDATA holds a reference to its sink, which the caller
+// must release.
+func (v *IConnectionPoint) EnumConnections() (connections []CONNECTDATA, err error) {
+	var enum *IEnumConnections
+	hr, _, _ := syscall.Syscall(
+		v.VTable().EnumConnections,
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&enum)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	defer enum.Release()
+
+	for {
+		var batch []CONNECTDATA
+		batch, err = enum.Next(enumBatchSize)
+		if err != nil {
+			for _, connection := range connections {
+				connection.PUnk.Release()
+			}
+			return nil, err
+		}
+		connections = append(connections, batch...)
+		if len(batch) < enumBatchSize {
+			return
+		}
+	}
 }
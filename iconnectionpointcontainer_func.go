@@ -2,8 +2,8 @@
 
 package ole
 
-func (v *IConnectionPointContainer) EnumConnectionPoints(points interface{}) error {
-	return NewError(E_NOTIMPL)
+func (v *IConnectionPointContainer) EnumConnectionPoints() ([]*IConnectionPoint, error) {
+	return nil, NewError(E_NOTIMPL)
 }
 
 func (v *IConnectionPointContainer) FindConnectionPoint(iid *GUID, point **IConnectionPoint) error {
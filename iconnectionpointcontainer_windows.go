@@ -7,8 +7,37 @@ import (
 	"unsafe"
 )
 
-func (v *IConnectionPointContainer) EnumConnectionPoints(points interface{}) error {
-	return NewError(E_NOTIMPL)
+// EnumConnectionPoints lists the connection points of the container.
+//
+// The caller must release every returned connection point.
+func (v *IConnectionPointContainer) EnumConnectionPoints() (points []*IConnectionPoint, err error) {
+	var enum *IEnumConnectionPoints
+	hr, _, _ := syscall.Syscall(
+		v.VTable().EnumConnectionPoints,
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&enum)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	defer enum.Release()
+
+	for {
+		var batch []*IConnectionPoint
+		batch, err = enum.Next(enumBatchSize)
+		if err != nil {
+			for _, point := range points {
+				point.Release()
+			}
+			return nil, err
+		}
+		points = append(points, batch...)
+		if len(batch) < enumBatchSize {
+			return
+		}
+	}
 }
 
 func (v *IConnectionPointContainer) FindConnectionPoint(iid *GUID, point **IConnectionPoint) (err error) {
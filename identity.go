@@ -0,0 +1,122 @@
+package ole
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// IsSameObject reports whether a and b are interfaces of the same COM
+// object. Interfaces of an object may be different pointers, but the object
+// returns the same one to every query of IID_IUnknown, which is compared.
+// Nil interfaces and objects failing the query are the same as none.
+func IsSameObject(a, b UnknownLike) bool {
+	ida, err := identityOf(a)
+	if err != nil {
+		return false
+	}
+	idb, err := identityOf(b)
+	return err == nil && ida == idb
+}
+
+// identityOf returns the address of the canonical IUnknown of obj, which
+// stays the same while obj is alive.
+func identityOf(obj UnknownLike) (uintptr, error) {
+	if obj == nil {
+		return 0, NewError(E_POINTER)
+	}
+	if rv := reflect.ValueOf(obj); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return 0, NewError(E_POINTER)
+	}
+	unk, err := obj.QueryInterface(IID_IUnknown)
+	if err != nil {
+		return 0, err
+	}
+	if unk == nil {
+		return 0, NewError(E_NOINTERFACE)
+	}
+	defer unk.Release()
+	return uintptr(unsafe.Pointer(unk)), nil
+}
+
+// InternTable holds one interface of each COM object it is given, by the
+// identity IsSameObject compares, so that the interfaces it returns stand
+// for their object: equal for the same object, they can be used as map
+// keys. Walking an object graph, such as the folder tree of a mail store,
+// a table tells the objects already visited.
+//
+// The table holds a reference to the interfaces it holds, until Remove or
+// Clear. The zero value is an empty table, safe for concurrent use.
+type InternTable struct {
+	mu      sync.Mutex
+	objects map[uintptr]UnknownLike
+}
+
+// Intern returns the interface the table holds for the object of obj. When
+// it holds none, obj becomes it, with a reference of its own, and added is
+// true. The caller keeps its own reference to obj either way.
+func (t *InternTable) Intern(obj UnknownLike) (interned UnknownLike, added bool, err error) {
+	id, err := identityOf(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if interned, ok := t.objects[id]; ok {
+		return interned, false, nil
+	}
+	if t.objects == nil {
+		t.objects = make(map[uintptr]UnknownLike)
+	}
+	obj.AddRef()
+	t.objects[id] = obj
+	return obj, true, nil
+}
+
+// Lookup returns the interface the table holds for the object of obj, if
+// any.
+func (t *InternTable) Lookup(obj UnknownLike) (UnknownLike, bool) {
+	id, err := identityOf(obj)
+	if err != nil {
+		return nil, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	interned, ok := t.objects[id]
+	return interned, ok
+}
+
+// Remove releases the interface the table holds for the object of obj, and
+// tells whether it held one.
+func (t *InternTable) Remove(obj UnknownLike) bool {
+	id, err := identityOf(obj)
+	if err != nil {
+		return false
+	}
+	t.mu.Lock()
+	interned, ok := t.objects[id]
+	delete(t.objects, id)
+	t.mu.Unlock()
+	if ok {
+		interned.Release()
+	}
+	return ok
+}
+
+// Len returns the number of objects in the table.
+func (t *InternTable) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.objects)
+}
+
+// Clear releases the interfaces of the table and empties it.
+func (t *InternTable) Clear() {
+	t.mu.Lock()
+	objects := t.objects
+	t.objects = nil
+	t.mu.Unlock()
+	for _, obj := range objects {
+		obj.Release()
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package ole
+
+// newTestIdentity returns an IUnknown for a testObject. Off Windows, its
+// methods are not called.
+func newTestIdentity() *IDispatch {
+	return new(IDispatch)
+}
@@ -0,0 +1,89 @@
+package ole
+
+import "testing"
+
+// testObject is a COM object whose interfaces are implemented in Go, with
+// identity as its IUnknown.
+type testObject struct {
+	identity *IDispatch
+	refs     int32
+}
+
+// testInterface is an interface of a testObject.
+type testInterface struct {
+	obj *testObject
+}
+
+func (i *testInterface) QueryInterface(iid *GUID) (*IDispatch, error) {
+	if !IsEqualGUID(iid, IID_IUnknown) {
+		return nil, NewError(E_NOINTERFACE)
+	}
+	i.obj.identity.AddRef()
+	return i.obj.identity, nil
+}
+
+func (i *testInterface) AddRef() int32 {
+	i.obj.refs++
+	return i.obj.refs
+}
+
+func (i *testInterface) Release() int32 {
+	i.obj.refs--
+	return i.obj.refs
+}
+
+func TestIsSameObject(t *testing.T) {
+	a := &testObject{identity: newTestIdentity()}
+	b := &testObject{identity: newTestIdentity()}
+	a1, a2, b1 := &testInterface{a}, &testInterface{a}, &testInterface{b}
+
+	if !IsSameObject(a1, a2) || !IsSameObject(a1, a1) {
+		t.Error("interfaces of the same object are not the same object")
+	}
+	if IsSameObject(a1, b1) {
+		t.Error("interfaces of different objects are the same object")
+	}
+	var none *IDispatch
+	if IsSameObject(none, none) || IsSameObject(nil, a1) {
+		t.Error("nil interfaces are the same object")
+	}
+}
+
+func TestInternTable(t *testing.T) {
+	a := &testObject{identity: newTestIdentity()}
+	b := &testObject{identity: newTestIdentity()}
+	a1, a2, b1 := &testInterface{a}, &testInterface{a}, &testInterface{b}
+
+	var table InternTable
+	interned, added, err := table.Intern(a1)
+	if err != nil || interned != a1 || !added || a.refs != 1 {
+		t.Fatalf("Intern(a1) = %v, %v, %v with %d references", interned, added, err, a.refs)
+	}
+	interned, added, err = table.Intern(a2)
+	if err != nil || interned != a1 || added || a.refs != 1 {
+		t.Errorf("Intern(a2) = %v, %v, %v with %d references, want a1", interned, added, err, a.refs)
+	}
+	if _, _, err := table.Intern(b1); err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", table.Len())
+	}
+	if interned, ok := table.Lookup(a2); !ok || interned != a1 {
+		t.Errorf("Lookup(a2) = %v, %v", interned, ok)
+	}
+	if _, _, err := table.Intern(nil); err == nil {
+		t.Error("interned nil")
+	}
+
+	if !table.Remove(a2) || a.refs != 0 || table.Remove(a1) {
+		t.Errorf("Remove(a2) left %d references", a.refs)
+	}
+	if _, ok := table.Lookup(a1); ok {
+		t.Error("removed object is still in the table")
+	}
+	table.Clear()
+	if table.Len() != 0 || b.refs != 0 {
+		t.Errorf("Clear() left %d objects, %d references", table.Len(), b.refs)
+	}
+}
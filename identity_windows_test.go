@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// testIdentityVtbl is the vtable of the IUnknowns of testObjects, which do
+// not count references.
+var testIdentityVtbl = []uintptr{
+	syscall.NewCallback(func(this, iid, out uintptr) uintptr { return E_NOINTERFACE }),
+	syscall.NewCallback(func(this uintptr) uintptr { return 1 }),
+	syscall.NewCallback(func(this uintptr) uintptr { return 1 }),
+}
+
+// newTestIdentity returns an IUnknown for a testObject.
+func newTestIdentity() *IDispatch {
+	obj := &struct{ vtbl *uintptr }{&testIdentityVtbl[0]}
+	return (*IDispatch)(unsafe.Pointer(obj))
+}
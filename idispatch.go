@@ -19,22 +19,43 @@ func (v *IDispatch) VTable() *IDispatchVtbl {
 }
 
 func (v *IDispatch) GetIDsOfName(names []string) (dispid []int32, err error) {
+	refUse(unsafe.Pointer(v), "GetIDsOfNames")
 	dispid, err = getIDsOfName(v, names)
 	return
 }
 
 func (v *IDispatch) Invoke(dispid int32, dispatch int16, params ...interface{}) (result *VARIANT, err error) {
+	refUse(unsafe.Pointer(v), "Invoke")
 	result, err = invoke(v, dispid, dispatch, params...)
+	if err == nil {
+		refVariantAcquired(result)
+	}
+	return
+}
+
+// InvokeDispParams calls the member dispid with arguments already converted
+// to DISPPARAMS, which remain owned by the caller.
+func (v *IDispatch) InvokeDispParams(dispid int32, dispatch int16, params *DISPPARAMS) (result *VARIANT, err error) {
+	refUse(unsafe.Pointer(v), "Invoke")
+	result, err = invokeDispParams(v, dispid, dispatch, params)
+	if err == nil {
+		refVariantAcquired(result)
+	}
 	return
 }
 
 func (v *IDispatch) GetTypeInfoCount() (c uint32, err error) {
+	refUse(unsafe.Pointer(v), "GetTypeInfoCount")
 	c, err = getTypeInfoCount(v)
 	return
 }
 
 func (v *IDispatch) GetTypeInfo() (tinfo *ITypeInfo, err error) {
+	refUse(unsafe.Pointer(v), "GetTypeInfo")
 	tinfo, err = getTypeInfo(v)
+	if err == nil {
+		refAcquired(unsafe.Pointer(tinfo), "ITypeInfo")
+	}
 	return
 }
 
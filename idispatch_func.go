@@ -17,3 +17,7 @@ func getTypeInfo(disp *IDispatch) (*ITypeInfo, error) {
 func invoke(disp *IDispatch, dispid int32, dispatch int16, params ...interface{}) (*VARIANT, error) {
 	return nil, NewError(E_NOTIMPL)
 }
+
+func invokeDispParams(disp *IDispatch, dispid int32, dispatch int16, params *DISPPARAMS) (*VARIANT, error) {
+	return nil, NewError(E_NOTIMPL)
+}
@@ -65,11 +65,11 @@ func invoke(disp *IDispatch, dispid int32, dispatch int16, params ...interface{}
 
 	if dispatch&DISPATCH_PROPERTYPUT != 0 {
 		dispnames := [1]int32{DISPID_PROPERTYPUT}
-		dispparams.rgdispidNamedArgs = uintptr(unsafe.Pointer(&dispnames[0]))
+		dispparams.rgdispidNamedArgs = unsafe.Pointer(&dispnames[0])
 		dispparams.cNamedArgs = 1
 	} else if dispatch&DISPATCH_PROPERTYPUTREF != 0 {
 		dispnames := [1]int32{DISPID_PROPERTYPUT}
-		dispparams.rgdispidNamedArgs = uintptr(unsafe.Pointer(&dispnames[0]))
+		dispparams.rgdispidNamedArgs = unsafe.Pointer(&dispnames[0])
 		dispparams.cNamedArgs = 1
 	}
 	var vargs []VARIANT
@@ -117,7 +117,7 @@ func invoke(disp *IDispatch, dispid int32, dispatch int16, params ...interface{}
 			case *int64:
 				vargs[n] = NewVariant(VT_I8|VT_BYREF, int64(uintptr(unsafe.Pointer(v.(*int64)))))
 			case uint64:
-				vargs[n] = NewVariant(VT_UI8, int64(uintptr(v.(uint64))))
+				vargs[n] = NewVariant(VT_UI8, int64(vv))
 			case *uint64:
 				vargs[n] = NewVariant(VT_UI8|VT_BYREF, int64(uintptr(unsafe.Pointer(v.(*uint64)))))
 			case int:
@@ -175,10 +175,24 @@ func invoke(disp *IDispatch, dispid int32, dispatch int16, params ...interface{}
 				panic("unknown type")
 			}
 		}
-		dispparams.rgvarg = uintptr(unsafe.Pointer(&vargs[0]))
+		dispparams.rgvarg = unsafe.Pointer(&vargs[0])
 		dispparams.cArgs = uint32(len(params))
 	}
 
+	result, err = invokeDispParams(disp, dispid, dispatch, &dispparams)
+	for i, varg := range vargs {
+		n := len(params) - i - 1
+		if varg.VT == VT_BSTR && varg.Val != 0 {
+			SysFreeString(((*int16)(unsafe.Pointer(uintptr(varg.Val)))))
+		}
+		if varg.VT == (VT_BSTR|VT_BYREF) && varg.Val != 0 {
+			*(params[n].(*string)) = LpOleStrToString(*(**uint16)(unsafe.Pointer(uintptr(varg.Val))))
+		}
+	}
+	return
+}
+
+func invokeDispParams(disp *IDispatch, dispid int32, dispatch int16, params *DISPPARAMS) (result *VARIANT, err error) {
 	result = new(VARIANT)
 	var excepInfo EXCEPINFO
 	VariantInit(result)
@@ -190,7 +204,7 @@ func invoke(disp *IDispatch, dispid int32, dispatch int16, params ...interface{}
 		uintptr(unsafe.Pointer(IID_NULL)),
 		uintptr(GetUserDefaultLCID()),
 		uintptr(dispatch),
-		uintptr(unsafe.Pointer(&dispparams)),
+		uintptr(unsafe.Pointer(params)),
 		uintptr(unsafe.Pointer(result)),
 		uintptr(unsafe.Pointer(&excepInfo)),
 		0)
@@ -199,14 +213,5 @@ func invoke(disp *IDispatch, dispid int32, dispatch int16, params ...interface{}
 		excepInfo.Clear()
 		err = NewErrorWithSubError(hr, excepInfo.description, excepInfo)
 	}
-	for i, varg := range vargs {
-		n := len(params) - i - 1
-		if varg.VT == VT_BSTR && varg.Val != 0 {
-			SysFreeString(((*int16)(unsafe.Pointer(uintptr(varg.Val)))))
-		}
-		if varg.VT == (VT_BSTR|VT_BYREF) && varg.Val != 0 {
-			*(params[n].(*string)) = LpOleStrToString(*(**uint16)(unsafe.Pointer(uintptr(varg.Val))))
-		}
-	}
 	return
 }
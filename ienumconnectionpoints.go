@@ -0,0 +1,19 @@
+package ole
+
+import "unsafe"
+
+type IEnumConnectionPoints struct {
+	IUnknown
+}
+
+type IEnumConnectionPointsVtbl struct {
+	IUnknownVtbl
+	Next  uintptr
+	Skip  uintptr
+	Reset uintptr
+	Clone uintptr
+}
+
+func (v *IEnumConnectionPoints) VTable() *IEnumConnectionPointsVtbl {
+	return (*IEnumConnectionPointsVtbl)(unsafe.Pointer(v.RawVTable))
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package ole
+
+func (enum *IEnumConnectionPoints) Clone() (*IEnumConnectionPoints, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (enum *IEnumConnectionPoints) Reset() error {
+	return NewError(E_NOTIMPL)
+}
+
+func (enum *IEnumConnectionPoints) Skip(celt uint32) error {
+	return NewError(E_NOTIMPL)
+}
+
+func (enum *IEnumConnectionPoints) Next(celt uint32) ([]*IConnectionPoint, error) {
+	return nil, NewError(E_NOTIMPL)
+}
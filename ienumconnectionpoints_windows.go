@@ -0,0 +1,72 @@
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func (enum *IEnumConnectionPoints) Clone() (cloned *IEnumConnectionPoints, err error) {
+	hr, _, _ := syscall.Syscall(
+		enum.VTable().Clone,
+		2,
+		uintptr(unsafe.Pointer(enum)),
+		uintptr(unsafe.Pointer(&cloned)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+func (enum *IEnumConnectionPoints) Reset() (err error) {
+	hr, _, _ := syscall.Syscall(
+		enum.VTable().Reset,
+		1,
+		uintptr(unsafe.Pointer(enum)),
+		0,
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+func (enum *IEnumConnectionPoints) Skip(celt uint32) (err error) {
+	hr, _, _ := syscall.Syscall(
+		enum.VTable().Skip,
+		2,
+		uintptr(unsafe.Pointer(enum)),
+		uintptr(celt),
+		0)
+	if hr != 0 && hr != S_FALSE {
+		err = NewError(hr)
+	}
+	return
+}
+
+// Next retrieves up to celt connection points. Fewer are returned at the end of the
+// enumeration.
+func (enum *IEnumConnectionPoints) Next(celt uint32) (points []*IConnectionPoint, err error) {
+	if celt == 0 {
+		return
+	}
+	buffer := make([]*IConnectionPoint, celt)
+	var fetched uint32
+	hr, _, _ := syscall.Syscall6(
+		enum.VTable().Next,
+		4,
+		uintptr(unsafe.Pointer(enum)),
+		uintptr(celt),
+		uintptr(unsafe.Pointer(&buffer[0])),
+		uintptr(unsafe.Pointer(&fetched)),
+		0,
+		0)
+	if hr != 0 && hr != S_FALSE {
+		err = NewError(hr)
+		return
+	}
+	points = buffer[:fetched]
+	return
+}
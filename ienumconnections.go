@@ -0,0 +1,27 @@
+package ole
+
+import "unsafe"
+
+// CONNECTDATA describes a connection on a connection point.
+//
+// PUnk holds a reference which the receiver must release.
+type CONNECTDATA struct {
+	PUnk     *IUnknown
+	DwCookie uint32
+}
+
+type IEnumConnections struct {
+	IUnknown
+}
+
+type IEnumConnectionsVtbl struct {
+	IUnknownVtbl
+	Next  uintptr
+	Skip  uintptr
+	Reset uintptr
+	Clone uintptr
+}
+
+func (v *IEnumConnections) VTable() *IEnumConnectionsVtbl {
+	return (*IEnumConnectionsVtbl)(unsafe.Pointer(v.RawVTable))
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package ole
+
+func (enum *IEnumConnections) Clone() (*IEnumConnections, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (enum *IEnumConnections) Reset() error {
+	return NewError(E_NOTIMPL)
+}
+
+func (enum *IEnumConnections) Skip(celt uint32) error {
+	return NewError(E_NOTIMPL)
+}
+
+func (enum *IEnumConnections) Next(celt uint32) ([]CONNECTDATA, error) {
+	return nil, NewError(E_NOTIMPL)
+}
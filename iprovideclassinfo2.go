@@ -0,0 +1,26 @@
+package ole
+
+import "unsafe"
+
+// IProvideClassInfo2 extends IProvideClassInfo with direct access to the
+// IID of the object's default outgoing interface.
+type IProvideClassInfo2 struct {
+	IProvideClassInfo
+}
+
+type IProvideClassInfo2Vtbl struct {
+	IProvideClassInfoVtbl
+	GetGUID uintptr
+}
+
+func (v *IProvideClassInfo2) VTable() *IProvideClassInfo2Vtbl {
+	return (*IProvideClassInfo2Vtbl)(unsafe.Pointer(v.RawVTable))
+}
+
+// GetGUID retrieves the GUID of the given kind for the object.
+//
+// The only kind defined by COM is GUIDKIND_DEFAULT_SOURCE_DISP_IID.
+func (v *IProvideClassInfo2) GetGUID(guidKind uint32) (guid *GUID, err error) {
+	guid, err = getGUID(v, guidKind)
+	return
+}
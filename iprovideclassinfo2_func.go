@@ -0,0 +1,7 @@
+// +build !windows
+
+package ole
+
+func getGUID(info *IProvideClassInfo2, guidKind uint32) (*GUID, error) {
+	return nil, NewError(E_NOTIMPL)
+}
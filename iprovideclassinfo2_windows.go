@@ -0,0 +1,24 @@
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func getGUID(info *IProvideClassInfo2, guidKind uint32) (guid *GUID, err error) {
+	var g GUID
+	hr, _, _ := syscall.Syscall(
+		info.VTable().GetGUID,
+		3,
+		uintptr(unsafe.Pointer(info)),
+		uintptr(guidKind),
+		uintptr(unsafe.Pointer(&g)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	guid = &g
+	return
+}
@@ -5,3 +5,47 @@ package ole
 func (v *ITypeInfo) GetTypeAttr() (*TYPEATTR, error) {
 	return nil, NewError(E_NOTIMPL)
 }
+
+func (v *ITypeInfo) GetFuncDesc(index uint32) (*FUNCDESC, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetVarDesc(index uint32) (*VARDESC, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetNames(memid int32) ([]string, error) {
+	return []string{}, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetContainingTypeLib() (*ITypeLib, uint32, error) {
+	return nil, uint32(0), NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetMops(memid int32) (string, error) {
+	return "", NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetDllEntry(memid int32, invkind int32) (string, string, uint16, error) {
+	return "", "", uint16(0), NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetRefTypeOfImplType(index uint32) (uint32, error) {
+	return uint32(0), NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetImplTypeFlags(index uint32) (int32, error) {
+	return int32(0), NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetRefTypeInfo(hreftype uint32) (*ITypeInfo, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetDocumentation(memid int32) (string, string, uint32, string, error) {
+	return "", "", uint32(0), "", NewError(E_NOTIMPL)
+}
+
+func (v *ITypeInfo) GetIDsOfNames(names []string) ([]int32, error) {
+	return []int32{}, NewError(E_NOTIMPL)
+}
@@ -5,14 +5,246 @@ package ole
 import (
 	"syscall"
 	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
+// GetTypeAttr retrieves the attributes of the type.
 func (v *ITypeInfo) GetTypeAttr() (tattr *TYPEATTR, err error) {
+	var native *nativeTypeAttr
 	hr, _, _ := syscall.Syscall(
 		uintptr(v.VTable().GetTypeAttr),
 		2,
 		uintptr(unsafe.Pointer(v)),
-		uintptr(unsafe.Pointer(&tattr)),
+		uintptr(unsafe.Pointer(&native)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	tattr = copyTypeAttr(native)
+	syscall.Syscall(
+		uintptr(v.VTable().ReleaseTypeAttr),
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(native)),
+		0)
+	return
+}
+
+// GetFuncDesc retrieves the description of the function at index.
+func (v *ITypeInfo) GetFuncDesc(index uint32) (fdesc *FUNCDESC, err error) {
+	var native *nativeFuncDesc
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetFuncDesc),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&native)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	fdesc = copyFuncDesc(native)
+	syscall.Syscall(
+		uintptr(v.VTable().ReleaseFuncDesc),
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(native)),
+		0)
+	return
+}
+
+// GetVarDesc retrieves the description of the variable at index.
+func (v *ITypeInfo) GetVarDesc(index uint32) (vdesc *VARDESC, err error) {
+	var native *nativeVarDesc
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetVarDesc),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&native)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	vdesc = copyVarDesc(native)
+	syscall.Syscall(
+		uintptr(v.VTable().ReleaseVarDesc),
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(native)),
+		0)
+	return
+}
+
+// maxNames bounds the names GetNames retrieves: a member and its
+// parameters.
+const maxNames = 256
+
+// GetNames retrieves the name of the member memid followed by the names of
+// its parameters.
+func (v *ITypeInfo) GetNames(memid int32) (names []string, err error) {
+	var bstrs [maxNames]*uint16
+	var count uint32
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().GetNames),
+		5,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(memid),
+		uintptr(unsafe.Pointer(&bstrs[0])),
+		maxNames,
+		uintptr(unsafe.Pointer(&count)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	names = make([]string, count)
+	for i := range names {
+		names[i] = takeBSTR(bstrs[i])
+	}
+	return
+}
+
+// GetContainingTypeLib retrieves the type library holding the type and the
+// index of the type in it.
+func (v *ITypeInfo) GetContainingTypeLib() (tlib *ITypeLib, index uint32, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetContainingTypeLib),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&tlib)),
+		uintptr(unsafe.Pointer(&index)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetMops retrieves the marshaling information of the member memid.
+func (v *ITypeInfo) GetMops(memid int32) (mops string, err error) {
+	var bstrMops *uint16
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetMops),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(memid),
+		uintptr(unsafe.Pointer(&bstrMops)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	mops = takeBSTR(bstrMops)
+	return
+}
+
+// GetDllEntry retrieves the DLL exporting the function memid of a module,
+// with the name or, when exported by ordinal, the ordinal of its entry
+// point. invkind is one of the INVOKE_* values.
+func (v *ITypeInfo) GetDllEntry(memid int32, invkind int32) (dllName string, name string, ordinal uint16, err error) {
+	var bstrDllName, bstrName *uint16
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().GetDllEntry),
+		6,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(memid),
+		uintptr(invkind),
+		uintptr(unsafe.Pointer(&bstrDllName)),
+		uintptr(unsafe.Pointer(&bstrName)),
+		uintptr(unsafe.Pointer(&ordinal)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	dllName = takeBSTR(bstrDllName)
+	name = takeBSTR(bstrName)
+	return
+}
+
+// GetRefTypeOfImplType retrieves the type description handle of the
+// implemented or inherited interface at index.
+func (v *ITypeInfo) GetRefTypeOfImplType(index uint32) (hreftype uint32, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetRefTypeOfImplType),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&hreftype)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetImplTypeFlags retrieves the IMPLTYPEFLAG_* flags of the implemented
+// interface at index.
+func (v *ITypeInfo) GetImplTypeFlags(index uint32) (flags int32, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetImplTypeFlags),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&flags)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetRefTypeInfo retrieves the type description referenced by hreftype.
+func (v *ITypeInfo) GetRefTypeInfo(hreftype uint32) (tinfo *ITypeInfo, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetRefTypeInfo),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(hreftype),
+		uintptr(unsafe.Pointer(&tinfo)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetDocumentation retrieves the name, documentation string, help context and
+// help file of the member memid, or of the type itself for MEMBERID_NIL.
+func (v *ITypeInfo) GetDocumentation(memid int32) (name string, docString string, helpContext uint32, helpFile string, err error) {
+	var bstrName, bstrDocString, bstrHelpFile *uint16
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().GetDocumentation),
+		6,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(memid),
+		uintptr(unsafe.Pointer(&bstrName)),
+		uintptr(unsafe.Pointer(&bstrDocString)),
+		uintptr(unsafe.Pointer(&helpContext)),
+		uintptr(unsafe.Pointer(&bstrHelpFile)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	name = takeBSTR(bstrName)
+	docString = takeBSTR(bstrDocString)
+	helpFile = takeBSTR(bstrHelpFile)
+	return
+}
+
+// GetIDsOfNames maps a member name, followed by its parameter names, to member
+// and parameter IDs.
+func (v *ITypeInfo) GetIDsOfNames(names []string) (memids []int32, err error) {
+	wnames := make([]*uint16, len(names))
+	for i := 0; i < len(names); i++ {
+		wnames[i] = windows.StringToUTF16Ptr(names[i])
+	}
+	memids = make([]int32, len(names))
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().GetIDsOfNames),
+		4,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&wnames[0])),
+		uintptr(len(names)),
+		uintptr(unsafe.Pointer(&memids[0])),
+		0,
 		0)
 	if hr != 0 {
 		err = NewError(hr)
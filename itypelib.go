@@ -0,0 +1,37 @@
+package ole
+
+import "unsafe"
+
+// ITypeLib is a type library: the descriptions of the types of a component.
+type ITypeLib struct {
+	IUnknown
+}
+
+type ITypeLibVtbl struct {
+	IUnknownVtbl
+	GetTypeInfoCount  uintptr
+	GetTypeInfo       uintptr
+	GetTypeInfoType   uintptr
+	GetTypeInfoOfGuid uintptr
+	GetLibAttr        uintptr
+	GetTypeComp       uintptr
+	GetDocumentation  uintptr
+	IsName            uintptr
+	FindName          uintptr
+	ReleaseTLibAttr   uintptr
+}
+
+// TLIBATTR holds the attributes of a type library. It has the layout of the
+// native structure on every architecture.
+type TLIBATTR struct {
+	Guid         GUID
+	Lcid         uint32
+	Syskind      int32
+	WMajorVerNum uint16
+	WMinorVerNum uint16
+	WLibFlags    uint16
+}
+
+func (v *ITypeLib) VTable() *ITypeLibVtbl {
+	return (*ITypeLibVtbl)(unsafe.Pointer(v.RawVTable))
+}
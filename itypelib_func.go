@@ -0,0 +1,35 @@
+// +build !windows
+
+package ole
+
+func (v *ITypeLib) GetTypeInfoCount() uint32 {
+	return 0
+}
+
+func (v *ITypeLib) GetTypeInfo(index uint32) (*ITypeInfo, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeLib) GetTypeInfoType(index uint32) (int32, error) {
+	return 0, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeLib) GetTypeInfoOfGuid(guid *GUID) (*ITypeInfo, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeLib) GetLibAttr() (*TLIBATTR, error) {
+	return nil, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeLib) GetDocumentation(index int32) (string, string, uint32, string, error) {
+	return "", "", 0, "", NewError(E_NOTIMPL)
+}
+
+func (v *ITypeLib) IsName(name string) (string, bool, error) {
+	return "", false, NewError(E_NOTIMPL)
+}
+
+func (v *ITypeLib) FindName(name string, max int) ([]*ITypeInfo, []int32, error) {
+	return nil, nil, NewError(E_NOTIMPL)
+}
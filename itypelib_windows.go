@@ -0,0 +1,174 @@
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// GetTypeInfoCount returns the number of types in the library.
+func (v *ITypeLib) GetTypeInfoCount() uint32 {
+	count, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetTypeInfoCount),
+		1,
+		uintptr(unsafe.Pointer(v)),
+		0,
+		0)
+	return uint32(count)
+}
+
+// GetTypeInfo retrieves the type at index.
+func (v *ITypeLib) GetTypeInfo(index uint32) (tinfo *ITypeInfo, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetTypeInfo),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&tinfo)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetTypeInfoType retrieves the TKIND_* kind of the type at index without
+// loading it.
+func (v *ITypeLib) GetTypeInfoType(index uint32) (typekind int32, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetTypeInfoType),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&typekind)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetTypeInfoOfGuid retrieves the type identified by guid. It fails with
+// TYPE_E_ELEMENTNOTFOUND when the library has no such type.
+func (v *ITypeLib) GetTypeInfoOfGuid(guid *GUID) (tinfo *ITypeInfo, err error) {
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetTypeInfoOfGuid),
+		3,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(guid)),
+		uintptr(unsafe.Pointer(&tinfo)))
+	if hr != 0 {
+		err = NewError(hr)
+	}
+	return
+}
+
+// GetLibAttr retrieves the attributes of the library. The native structure
+// is copied and released.
+func (v *ITypeLib) GetLibAttr() (attr *TLIBATTR, err error) {
+	var native *TLIBATTR
+	hr, _, _ := syscall.Syscall(
+		uintptr(v.VTable().GetLibAttr),
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&native)),
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	attr = new(TLIBATTR)
+	*attr = *native
+	syscall.Syscall(
+		uintptr(v.VTable().ReleaseTLibAttr),
+		2,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(native)),
+		0)
+	return
+}
+
+// GetDocumentation retrieves the name, documentation string, help context and
+// help file of the type at index, or of the library itself for index -1.
+func (v *ITypeLib) GetDocumentation(index int32) (name string, docString string, helpContext uint32, helpFile string, err error) {
+	var bstrName, bstrDocString, bstrHelpFile *uint16
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().GetDocumentation),
+		6,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(unsafe.Pointer(&bstrName)),
+		uintptr(unsafe.Pointer(&bstrDocString)),
+		uintptr(unsafe.Pointer(&helpContext)),
+		uintptr(unsafe.Pointer(&bstrHelpFile)))
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	name = takeBSTR(bstrName)
+	docString = takeBSTR(bstrDocString)
+	helpFile = takeBSTR(bstrHelpFile)
+	return
+}
+
+// IsName reports whether the library describes a type or member called
+// name, compared without case. When it does, matched is the name as spelled
+// in the library.
+func (v *ITypeLib) IsName(name string) (matched string, found bool, err error) {
+	buf, err := windows.UTF16FromString(name)
+	if err != nil {
+		return
+	}
+	var result int32
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().IsName),
+		4,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&buf[0])),
+		0,
+		uintptr(unsafe.Pointer(&result)),
+		0,
+		0)
+	if hr != 0 {
+		err = NewError(hr)
+		return
+	}
+	if found = result != 0; found {
+		matched = windows.UTF16ToString(buf)
+	}
+	return
+}
+
+// FindName finds up to max types describing a type or member called name.
+// For each type found, the matching element of memids is the member ID of
+// the member, or MEMBERID_NIL when the type itself matched. The caller
+// releases the types.
+func (v *ITypeLib) FindName(name string, max int) (tinfos []*ITypeInfo, memids []int32, err error) {
+	if max <= 0 {
+		return
+	}
+	if max > 0xFFFF {
+		max = 0xFFFF
+	}
+	buf, err := windows.UTF16FromString(name)
+	if err != nil {
+		return
+	}
+	tinfos = make([]*ITypeInfo, max)
+	memids = make([]int32, max)
+	found := uint16(max)
+	hr, _, _ := syscall.Syscall6(
+		uintptr(v.VTable().FindName),
+		6,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(&buf[0])),
+		0,
+		uintptr(unsafe.Pointer(&tinfos[0])),
+		uintptr(unsafe.Pointer(&memids[0])),
+		uintptr(unsafe.Pointer(&found)))
+	if hr != 0 {
+		return nil, nil, NewError(hr)
+	}
+	return tinfos[:found], memids[:found], nil
+}
@@ -0,0 +1,74 @@
+// +build windows
+
+package ole
+
+import "testing"
+
+// libidStdOle is the LIBID of stdole2.tlb, which every Windows registers.
+var libidStdOle = NewGUID("{00020430-0000-0000-C000-000000000046}")
+
+func TestLoadRegTypeLib(t *testing.T) {
+	CoInitialize(0)
+	defer CoUninitialize()
+
+	tlib, err := LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadRegTypeLib = %v", err)
+	}
+	defer tlib.Release()
+
+	attr, err := tlib.GetLibAttr()
+	if err != nil {
+		t.Fatalf("GetLibAttr = %v", err)
+	}
+	if !IsEqualGUID(&attr.Guid, libidStdOle) || attr.WMajorVerNum != 2 {
+		t.Errorf("GetLibAttr = %+v", attr)
+	}
+	if name, _, _, _, err := tlib.GetDocumentation(-1); err != nil || name != "stdole" {
+		t.Errorf("GetDocumentation(-1) = %q, %v", name, err)
+	}
+	if tlib.GetTypeInfoCount() == 0 {
+		t.Error("stdole has no types")
+	}
+
+	matched, found, err := tlib.IsName("idispatch")
+	if err != nil || !found || matched != "IDispatch" {
+		t.Errorf("IsName = %q, %v, %v", matched, found, err)
+	}
+	tinfos, memids, err := tlib.FindName("IDispatch", 4)
+	if err != nil || len(tinfos) == 0 || memids[0] != MEMBERID_NIL {
+		t.Errorf("FindName = %v, %v, %v", tinfos, memids, err)
+	}
+	for _, tinfo := range tinfos {
+		tinfo.Release()
+	}
+}
+
+func TestITypeInfo_GetContainingTypeLib(t *testing.T) {
+	CoInitialize(0)
+	defer CoUninitialize()
+
+	tlib, err := LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadRegTypeLib = %v", err)
+	}
+	defer tlib.Release()
+
+	tinfo, err := tlib.GetTypeInfoOfGuid(IID_IDispatch)
+	if err != nil {
+		t.Fatalf("GetTypeInfoOfGuid = %v", err)
+	}
+	defer tinfo.Release()
+
+	container, index, err := tinfo.GetContainingTypeLib()
+	if err != nil {
+		t.Fatalf("GetContainingTypeLib = %v", err)
+	}
+	defer container.Release()
+	if kind, err := container.GetTypeInfoType(index); err != nil || kind != TKIND_INTERFACE {
+		t.Errorf("GetTypeInfoType(%d) = %d, %v, want TKIND_INTERFACE", index, kind, err)
+	}
+	if _, err := container.GetTypeInfoOfGuid(IID_NULL); err == nil || err.(*OleError).Code() != TYPE_E_ELEMENTNOTFOUND {
+		t.Errorf("GetTypeInfoOfGuid(IID_NULL) = %v, want TYPE_E_ELEMENTNOTFOUND", err)
+	}
+}
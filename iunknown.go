@@ -1,6 +1,9 @@
 package ole
 
-import "unsafe"
+import (
+	"reflect"
+	"unsafe"
+)
 
 type IUnknown struct {
 	RawVTable *interface{}
@@ -23,7 +26,14 @@ func (v *IUnknown) VTable() *IUnknownVtbl {
 }
 
 func (v *IUnknown) PutQueryInterface(interfaceID *GUID, obj interface{}) error {
-	return reflectQueryInterface(v, v.VTable().QueryInterface, interfaceID, obj)
+	refUse(unsafe.Pointer(v), "QueryInterface")
+	err := reflectQueryInterface(v, v.VTable().QueryInterface, interfaceID, obj)
+	if err == nil && RefDebugEnabled() {
+		if p := reflect.ValueOf(obj).Elem(); p.Kind() == reflect.Ptr {
+			refAcquired(unsafe.Pointer(p.Pointer()), interfaceNameOf(obj, interfaceID))
+		}
+	}
+	return err
 }
 
 func (v *IUnknown) IDispatch(interfaceID *GUID) (dispatch *IDispatch, err error) {
@@ -37,11 +47,16 @@ func (v *IUnknown) IEnumVARIANT(interfaceID *GUID) (enum *IEnumVARIANT, err erro
 }
 
 func (v *IUnknown) QueryInterface(iid *GUID) (*IDispatch, error) {
-	return queryInterface(v, iid)
+	refUse(unsafe.Pointer(v), "QueryInterface")
+	disp, err := queryInterface(v, iid)
+	if err == nil {
+		refAcquired(unsafe.Pointer(disp), interfaceName(iid))
+	}
+	return disp, err
 }
 
 func (v *IUnknown) MustQueryInterface(iid *GUID) (disp *IDispatch) {
-	unk, err := queryInterface(v, iid)
+	unk, err := v.QueryInterface(iid)
 	if err != nil {
 		panic(err)
 	}
@@ -49,9 +64,13 @@ func (v *IUnknown) MustQueryInterface(iid *GUID) (disp *IDispatch) {
 }
 
 func (v *IUnknown) AddRef() int32 {
+	refAddRef(unsafe.Pointer(v))
 	return addRef(v)
 }
 
 func (v *IUnknown) Release() int32 {
-	return release(v)
+	refUse(unsafe.Pointer(v), "Release")
+	remaining := release(v)
+	refReleased(unsafe.Pointer(v), remaining)
+	return remaining
 }
@@ -8,12 +8,46 @@ import (
 
 // DISPPARAMS are the arguments that passed to methods or property.
 type DISPPARAMS struct {
-	rgvarg            uintptr
-	rgdispidNamedArgs uintptr
+	rgvarg            unsafe.Pointer
+	rgdispidNamedArgs unsafe.Pointer
 	cArgs             uint32
 	cNamedArgs        uint32
 }
 
+// NewDISPPARAMS creates DISPPARAMS over the given arguments.
+//
+// Arguments are in the order IDispatch.Invoke expects them: named arguments
+// first, matching namedArgs, then positional arguments from last to first.
+// The slices are referenced, not copied.
+func NewDISPPARAMS(args []VARIANT, namedArgs []int32) (params DISPPARAMS) {
+	if len(args) > 0 {
+		params.rgvarg = unsafe.Pointer(&args[0])
+		params.cArgs = uint32(len(args))
+	}
+	if len(namedArgs) > 0 {
+		params.rgdispidNamedArgs = unsafe.Pointer(&namedArgs[0])
+		params.cNamedArgs = uint32(len(namedArgs))
+	}
+	return
+}
+
+// Args returns the arguments in the order they are stored, named arguments
+// first and positional arguments reversed.
+func (p *DISPPARAMS) Args() []VARIANT {
+	if p.cArgs == 0 || p.rgvarg == nil {
+		return nil
+	}
+	return (*[1 << 20]VARIANT)(p.rgvarg)[:p.cArgs:p.cArgs]
+}
+
+// NamedArgs returns the DISPIDs of the named arguments.
+func (p *DISPPARAMS) NamedArgs() []int32 {
+	if p.cNamedArgs == 0 || p.rgdispidNamedArgs == nil {
+		return nil
+	}
+	return (*[1 << 20]int32)(p.rgdispidNamedArgs)[:p.cNamedArgs:p.cNamedArgs]
+}
+
 // EXCEPINFO defines exception info.
 type EXCEPINFO struct {
 	wCode             uint16
@@ -77,6 +111,24 @@ func (e *EXCEPINFO) Clear() {
 	}
 }
 
+// SetError fills in the exception an IDispatch implementation reports along
+// with DISP_E_EXCEPTION.
+//
+// Only the native part of the structure is written, so it is safe to call on
+// an EXCEPINFO allocated by a COM client, which becomes the owner of the
+// strings.
+func (e *EXCEPINFO) SetError(scode uint32, source string, description string) {
+	e.wCode = 0
+	e.wReserved = 0
+	e.bstrSource = (*uint16)(unsafe.Pointer(SysAllocString(source)))
+	e.bstrDescription = (*uint16)(unsafe.Pointer(SysAllocString(description)))
+	e.bstrHelpFile = nil
+	e.dwHelpContext = 0
+	e.pvReserved = 0
+	e.pfnDeferredFillIn = 0
+	e.scode = scode
+}
+
 // WCode return wCode in EXCEPINFO.
 func (e EXCEPINFO) WCode() uint16 {
 	return e.wCode
@@ -130,7 +182,7 @@ type METHODDATA struct {
 	CC       int32
 	CArgs    uint32
 	Flags    uint16
-	VtReturn uint32
+	VtReturn uint16
 }
 
 // INTERFACEDATA defines interface info.
@@ -155,10 +207,22 @@ type Msg struct {
 	Pt      Point
 }
 
-// TYPEDESC defines data type.
+// TYPEDESC describes the type of a variable, parameter or result.
+//
+// Lptdesc is the pointed-to or element type for VT_PTR and VT_SAFEARRAY,
+// Lpadesc the array description for VT_CARRAY and Hreftype the type
+// description handle for VT_USERDEFINED, to pass to ITypeInfo.GetRefTypeInfo.
 type TYPEDESC struct {
 	Hreftype uint32
 	VT       uint16
+	Lptdesc  *TYPEDESC
+	Lpadesc  *ARRAYDESC
+}
+
+// ARRAYDESC describes a C-style array.
+type ARRAYDESC struct {
+	TdescElem TYPEDESC
+	Bounds    []SafeArrayBound
 }
 
 // IDLDESC defines IDL info.
@@ -168,6 +232,9 @@ type IDLDESC struct {
 }
 
 // TYPEATTR defines type info.
+//
+// It is a copy of the native structure, which GetTypeAttr releases. The
+// reserved LpstrSchema is always nil.
 type TYPEATTR struct {
 	Guid             GUID
 	Lcid             uint32
@@ -0,0 +1,74 @@
+package oleserver
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Command is what the command line asks of a local server.
+type Command int
+
+const (
+	// CommandRun serves the classes until Stop is called, as when the
+	// executable is started by a user.
+	CommandRun Command = iota
+
+	// CommandEmbedding serves the classes for COM, which passes /Embedding
+	// or /Automation when it launches the server to create an object. The
+	// server exits once idle.
+	CommandEmbedding
+
+	// CommandRegServer registers the server (/RegServer).
+	CommandRegServer
+
+	// CommandUnregServer unregisters the server (/UnregServer).
+	CommandUnregServer
+)
+
+func (c Command) String() string {
+	switch c {
+	case CommandRun:
+		return "Run"
+	case CommandEmbedding:
+		return "Embedding"
+	case CommandRegServer:
+		return "RegServer"
+	case CommandUnregServer:
+		return "UnregServer"
+	}
+	return "Command(" + strconv.Itoa(int(c)) + ")"
+}
+
+// ParseCommandLine returns the command given by args, the command line
+// without the program name, and the arguments it does not know. Switches
+// start with / or - and are not case-sensitive. When several are given,
+// the first wins.
+func ParseCommandLine(args []string) (command Command, rest []string) {
+	found := false
+	for _, arg := range args {
+		c, ok := parseSwitch(arg)
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if !found {
+			command, found = c, true
+		}
+	}
+	return
+}
+
+func parseSwitch(arg string) (Command, bool) {
+	if len(arg) < 2 || (arg[0] != '/' && arg[0] != '-') {
+		return 0, false
+	}
+	switch strings.ToLower(arg[1:]) {
+	case "embedding", "automation":
+		return CommandEmbedding, true
+	case "regserver":
+		return CommandRegServer, true
+	case "unregserver":
+		return CommandUnregServer, true
+	}
+	return 0, false
+}
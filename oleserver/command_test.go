@@ -0,0 +1,40 @@
+package oleserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command Command
+		rest    []string
+	}{
+		{nil, CommandRun, nil},
+		{[]string{"/Embedding"}, CommandEmbedding, nil},
+		{[]string{"-Embedding"}, CommandEmbedding, nil},
+		{[]string{"/automation"}, CommandEmbedding, nil},
+		{[]string{"/RegServer"}, CommandRegServer, nil},
+		{[]string{"-REGSERVER"}, CommandRegServer, nil},
+		{[]string{"/UnregServer"}, CommandUnregServer, nil},
+		{[]string{"/UnregServer", "/RegServer"}, CommandUnregServer, nil},
+		{[]string{"-v", "/Embedding", "file.txt"}, CommandEmbedding, []string{"-v", "file.txt"}},
+		{[]string{"/", "Embedding"}, CommandRun, []string{"/", "Embedding"}},
+	}
+	for _, test := range tests {
+		command, rest := ParseCommandLine(test.args)
+		if command != test.command || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("ParseCommandLine(%q) = %v, %q, want %v, %q", test.args, command, rest, test.command, test.rest)
+		}
+	}
+}
+
+func TestCommand_String(t *testing.T) {
+	if s := CommandEmbedding.String(); s != "Embedding" {
+		t.Errorf("CommandEmbedding.String() = %q", s)
+	}
+	if s := Command(9).String(); s != "Command(9)" {
+		t.Errorf("Command(9).String() = %q", s)
+	}
+}
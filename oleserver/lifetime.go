@@ -0,0 +1,230 @@
+package oleserver
+
+import (
+	"sync"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// stopper is the part of *time.Timer the lifetime uses.
+type stopper interface {
+	Stop() bool
+}
+
+func afterFunc(d time.Duration, f func()) stopper {
+	return time.AfterFunc(d, f)
+}
+
+// serverProcess is the reference count of the server process COM keeps,
+// which the lifetime mirrors the objects and locks in.
+type serverProcess interface {
+	addRef()
+	release() uint32 // returns the references left
+	suspend()        // suspends the class objects
+}
+
+// comServerProcess counts with CoAddRefServerProcess and
+// CoReleaseServerProcess.
+type comServerProcess struct{}
+
+func (comServerProcess) addRef()         { ole.CoAddRefServerProcess() }
+func (comServerProcess) release() uint32 { return ole.CoReleaseServerProcess() }
+func (comServerProcess) suspend()        { ole.CoSuspendClassObjects() }
+
+// lifetime decides when a local server exits: once it has been idle, with no
+// object alive and no LockServer lock, for the idle timeout. Idle shutdown
+// only applies to servers launched by COM; any server stops on stop.
+//
+// Each object and lock holds a reference to the server process, and so does
+// the lifetime while the idle timer runs, or until stop for servers not
+// launched by COM. When the last reference is released, COM suspends the
+// class objects, so no client can get a new object from the process, and
+// the server stops.
+type lifetime struct {
+	mu        sync.Mutex
+	idle      time.Duration
+	autoStop  bool
+	afterFunc func(time.Duration, func()) stopper
+	process   serverProcess
+
+	// callCOM runs the functions of the idle timer and of stop, which call
+	// COM from goroutines of their own, on a thread where COM is
+	// initialized.
+	callCOM func(f func())
+
+	started bool
+	stopped bool
+	locks   int
+	held    bool // whether the lifetime holds a reference to the process
+	timer   stopper
+	armed   int // identifies the armed timer, so a stale one is ignored
+	onStop  func()
+	done    chan struct{}
+}
+
+func newLifetime(idle time.Duration) *lifetime {
+	return &lifetime{
+		idle:      idle,
+		afterFunc: afterFunc,
+		process:   comServerProcess{},
+		callCOM:   func(f func()) { f() },
+		done:      make(chan struct{}),
+	}
+}
+
+// setCallCOM sets the function running the calls to COM of the idle timer
+// and of stop.
+func (l *lifetime) setCallCOM(callCOM func(f func())) {
+	l.mu.Lock()
+	l.callCOM = callCOM
+	l.mu.Unlock()
+}
+
+// start begins serving. The idle timer runs from the start, so a server no
+// client ends up using still exits.
+func (l *lifetime) start(autoStop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.started = true
+	l.autoStop = autoStop
+	for i := 0; i < l.locks; i++ {
+		l.process.addRef()
+	}
+	if !autoStop {
+		l.hold()
+	} else if l.locks == 0 {
+		l.arm()
+	}
+}
+
+// setLocks records the number of live objects and locks.
+func (l *lifetime) setLocks(locks int) {
+	l.mu.Lock()
+	delta := locks - l.locks
+	l.locks = locks
+	if !l.started || l.stopped {
+		l.mu.Unlock()
+		return
+	}
+
+	// The references are added before those of the lifetime are released,
+	// and the lifetime holds its own before the others are released, so
+	// the count only drops to zero when the server is done.
+	for ; delta > 0; delta-- {
+		l.process.addRef()
+	}
+	last := false
+	if locks > 0 {
+		l.disarm()
+		if l.autoStop {
+			last = l.unhold()
+		}
+	} else {
+		l.arm()
+	}
+	for ; delta < 0; delta++ {
+		if l.process.release() == 0 {
+			last = true
+		}
+	}
+	if last {
+		l.stopLocked()
+		return
+	}
+	l.mu.Unlock()
+}
+
+// hold takes the reference of the lifetime to the process.
+func (l *lifetime) hold() {
+	if !l.held {
+		l.held = true
+		l.process.addRef()
+	}
+}
+
+// unhold releases the reference of the lifetime, and tells whether it was
+// the last one.
+func (l *lifetime) unhold() bool {
+	if !l.held {
+		return false
+	}
+	l.held = false
+	return l.process.release() == 0
+}
+
+// setOnStop sets the function waking up the serving thread when the server
+// stops. It is called at once if the server has already stopped.
+func (l *lifetime) setOnStop(f func()) {
+	l.mu.Lock()
+	stopped := l.stopped
+	l.onStop = f
+	l.mu.Unlock()
+	if stopped {
+		f()
+	}
+}
+
+func (l *lifetime) arm() {
+	if !l.autoStop || l.stopped {
+		return
+	}
+	l.disarm()
+	l.hold()
+	armed := l.armed
+	callCOM := l.callCOM
+	l.timer = l.afterFunc(l.idle, func() { callCOM(func() { l.expire(armed) }) })
+}
+
+func (l *lifetime) disarm() {
+	l.armed++
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// expire releases the reference of the lifetime once the server has been
+// idle for the timeout, and stops the server unless references taken
+// elsewhere in the process keep it alive.
+func (l *lifetime) expire(armed int) {
+	l.mu.Lock()
+	if armed != l.armed || l.locks > 0 || !l.unhold() {
+		l.mu.Unlock()
+		return
+	}
+	l.stopLocked()
+}
+
+// stop ends serving.
+func (l *lifetime) stop() {
+	l.mu.Lock()
+	callCOM := l.callCOM
+	l.mu.Unlock()
+	callCOM(func() {
+		l.mu.Lock()
+		l.stopLocked()
+	})
+}
+
+// stopLocked stops the server and unlocks l.mu. The class objects are
+// suspended first, so that no client gets a new object while the server
+// shuts down.
+func (l *lifetime) stopLocked() {
+	if l.stopped {
+		l.mu.Unlock()
+		return
+	}
+	l.stopped = true
+	l.disarm()
+	if l.started {
+		l.process.suspend()
+		l.unhold()
+	}
+	close(l.done)
+	onStop := l.onStop
+	l.mu.Unlock()
+	if onStop != nil {
+		onStop()
+	}
+}
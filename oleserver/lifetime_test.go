@@ -0,0 +1,236 @@
+package oleserver
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeTimers replaces the timers of a lifetime by ones fired by the test.
+type fakeTimers struct {
+	armed []*fakeTimer
+}
+
+type fakeTimer struct {
+	d       time.Duration
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	wasRunning := !t.stopped
+	t.stopped = true
+	return wasRunning
+}
+
+func (timers *fakeTimers) afterFunc(d time.Duration, f func()) stopper {
+	timer := &fakeTimer{d: d, f: f}
+	timers.armed = append(timers.armed, timer)
+	return timer
+}
+
+// last returns the most recently armed timer.
+func (timers *fakeTimers) last(t *testing.T) *fakeTimer {
+	if len(timers.armed) == 0 {
+		t.Fatal("no timer armed")
+	}
+	return timers.armed[len(timers.armed)-1]
+}
+
+// fakeProcess counts the references to the server process as COM does.
+type fakeProcess struct {
+	refs      uint32
+	suspended bool
+}
+
+func (p *fakeProcess) addRef() { p.refs++ }
+
+func (p *fakeProcess) release() uint32 {
+	p.refs--
+	if p.refs == 0 {
+		p.suspended = true
+	}
+	return p.refs
+}
+
+func (p *fakeProcess) suspend() { p.suspended = true }
+
+func newTestLifetime() (*lifetime, *fakeTimers) {
+	life, timers, _ := newTestLifetimeProcess()
+	return life, timers
+}
+
+func newTestLifetimeProcess() (*lifetime, *fakeTimers, *fakeProcess) {
+	timers := &fakeTimers{}
+	process := &fakeProcess{}
+	life := newLifetime(time.Second)
+	life.afterFunc = timers.afterFunc
+	life.process = process
+	return life, timers, process
+}
+
+func isStopped(life *lifetime) bool {
+	select {
+	case <-life.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestLifetime_neverUsed(t *testing.T) {
+	life, timers := newTestLifetime()
+	life.start(true)
+
+	timer := timers.last(t)
+	if timer.d != time.Second {
+		t.Errorf("idle timer armed for %v, want 1s", timer.d)
+	}
+	timer.f()
+	if !isStopped(life) {
+		t.Error("server not stopped after idling since start")
+	}
+}
+
+func TestLifetime_lastRelease(t *testing.T) {
+	life, timers := newTestLifetime()
+	stops := 0
+	life.setOnStop(func() { stops++ })
+	life.start(true)
+
+	life.setLocks(1)
+	if !timers.last(t).stopped {
+		t.Error("idle timer still running with an object alive")
+	}
+	life.setLocks(2)
+	life.setLocks(1)
+	if len(timers.armed) != 1 {
+		t.Errorf("%d timers armed while objects are alive, want 1", len(timers.armed))
+	}
+
+	life.setLocks(0)
+	if isStopped(life) {
+		t.Fatal("server stopped before the idle timeout")
+	}
+	timers.last(t).f()
+	if !isStopped(life) || stops != 1 {
+		t.Errorf("after idle timeout: stopped %v, onStop called %d times", isStopped(life), stops)
+	}
+
+	life.stop()
+	if stops != 1 {
+		t.Errorf("onStop called %d times after second stop, want 1", stops)
+	}
+}
+
+func TestLifetime_reusedWhileIdle(t *testing.T) {
+	life, timers := newTestLifetime()
+	life.start(true)
+	life.setLocks(1)
+	life.setLocks(0)
+	stale := timers.last(t)
+
+	// A client creates an object before the timeout: the armed timer must
+	// not stop the server even if it fires anyway.
+	life.setLocks(1)
+	stale.f()
+	if isStopped(life) {
+		t.Fatal("stale idle timer stopped the server")
+	}
+
+	life.setLocks(0)
+	life.setLocks(1)
+	life.setLocks(0)
+	stale = timers.armed[len(timers.armed)-2]
+	stale.f()
+	if isStopped(life) {
+		t.Fatal("timer armed for an earlier idle period stopped the server")
+	}
+	timers.last(t).f()
+	if !isStopped(life) {
+		t.Error("server not stopped after idle timeout")
+	}
+}
+
+func TestLifetime_notEmbedded(t *testing.T) {
+	life, timers := newTestLifetime()
+	life.start(false)
+	life.setLocks(1)
+	life.setLocks(0)
+	if len(timers.armed) != 0 {
+		t.Errorf("%d idle timers armed for a server started by a user", len(timers.armed))
+	}
+
+	life.stop()
+	if !isStopped(life) {
+		t.Error("stop did not stop the server")
+	}
+}
+
+func TestLifetime_locksBeforeStart(t *testing.T) {
+	life, timers := newTestLifetime()
+	life.setLocks(1)
+	life.setLocks(0)
+	if len(timers.armed) != 0 {
+		t.Fatal("idle timer armed before start")
+	}
+	life.setLocks(1)
+	life.start(true)
+	if len(timers.armed) != 0 {
+		t.Error("idle timer armed at start with an object alive")
+	}
+}
+
+func TestLifetime_stoppedBeforeOnStop(t *testing.T) {
+	life, _ := newTestLifetime()
+	life.stop()
+	called := false
+	life.setOnStop(func() { called = true })
+	if !called {
+		t.Error("onStop not called when set after stop")
+	}
+}
+
+func TestLifetime_serverProcess(t *testing.T) {
+	life, timers, process := newTestLifetimeProcess()
+	life.start(true)
+	if process.refs != 1 {
+		t.Errorf("%d references to the process while idle, want 1", process.refs)
+	}
+	life.setLocks(1)
+	life.setLocks(2)
+	if process.refs != 2 {
+		t.Errorf("%d references to the process with two objects alive, want 2", process.refs)
+	}
+	life.setLocks(0)
+	if process.refs != 1 || process.suspended || isStopped(life) {
+		t.Fatalf("after the last release: %d references, suspended %v, stopped %v", process.refs, process.suspended, isStopped(life))
+	}
+	timers.last(t).f()
+	if process.refs != 0 || !process.suspended || !isStopped(life) {
+		t.Errorf("after idle timeout: %d references, suspended %v, stopped %v", process.refs, process.suspended, isStopped(life))
+	}
+}
+
+func TestLifetime_serverProcessHeldElsewhere(t *testing.T) {
+	life, timers, process := newTestLifetimeProcess()
+	process.refs = 1
+	life.start(true)
+	timers.last(t).f()
+	if process.refs != 1 || process.suspended || isStopped(life) {
+		t.Errorf("with another reference to the process: %d references, suspended %v, stopped %v", process.refs, process.suspended, isStopped(life))
+	}
+}
+
+func TestLifetime_serverProcessNotEmbedded(t *testing.T) {
+	life, _, process := newTestLifetimeProcess()
+	life.start(false)
+	life.setLocks(1)
+	life.setLocks(0)
+	if process.refs != 1 || process.suspended {
+		t.Fatalf("server started by a user: %d references, suspended %v", process.refs, process.suspended)
+	}
+	life.stop()
+	if process.refs != 0 || !process.suspended {
+		t.Errorf("after stop: %d references, suspended %v", process.refs, process.suspended)
+	}
+}
@@ -0,0 +1,258 @@
+package oleserver
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// Threading models of in-process servers.
+const (
+	ThreadingApartment = "Apartment"
+	ThreadingFree      = "Free"
+	ThreadingBoth      = "Both"
+	ThreadingNeutral   = "Neutral"
+)
+
+// Registration describes the registry entries of a COM class.
+type Registration struct {
+	CLSID       *ole.GUID
+	Description string
+
+	// ProgID is the versioned programmatic identifier, such as
+	// "Vendor.Component.1". VersionIndependentProgID, such as
+	// "Vendor.Component", points to it through CurVer.
+	ProgID                   string
+	VersionIndependentProgID string
+
+	// InprocServer is the path of the DLL serving the class and
+	// ThreadingModel the apartments its objects can live in.
+	InprocServer   string
+	ThreadingModel string
+
+	// LocalServer is the command line of the executable serving the class.
+	LocalServer string
+
+	// TypeLib is the LIBID of the type library describing the class.
+	TypeLib *ole.GUID
+
+	// AppID groups the security and activation settings of a local
+	// server.
+	AppID *ole.GUID
+
+	// PerUser registers the class for the current user only, under
+	// HKEY_CURRENT_USER\Software\Classes, which needs no administrator
+	// rights.
+	PerUser bool
+}
+
+// registryValue is a string value; the default value has an empty name.
+type registryValue struct {
+	name string
+	data string
+}
+
+// registryKey is a key with its values, relative to the classes root.
+type registryKey struct {
+	path   string
+	values []registryValue
+}
+
+// rootName returns the name of the key the entries are written under.
+func (r Registration) rootName() string {
+	if r.PerUser {
+		return `HKEY_CURRENT_USER\Software\Classes`
+	}
+	return "HKEY_CLASSES_ROOT"
+}
+
+func (r Registration) validate() error {
+	if r.CLSID == nil {
+		return ole.NewErrorWithDescription(ole.E_INVALIDARG, "registration without CLSID")
+	}
+	switch r.ThreadingModel {
+	case "", ThreadingApartment, ThreadingFree, ThreadingBoth, ThreadingNeutral:
+	default:
+		return ole.NewErrorWithDescription(ole.E_INVALIDARG, "unknown threading model "+r.ThreadingModel)
+	}
+	if r.ThreadingModel != "" && r.InprocServer == "" {
+		return ole.NewErrorWithDescription(ole.E_INVALIDARG, "threading model without in-process server")
+	}
+	if r.VersionIndependentProgID != "" && r.ProgID == "" {
+		return ole.NewErrorWithDescription(ole.E_INVALIDARG, "version-independent ProgID without ProgID")
+	}
+	return nil
+}
+
+// keys returns the keys to create, parents first.
+func (r Registration) keys() ([]registryKey, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	clsid := r.CLSID.String()
+	class := `CLSID\` + clsid
+
+	main := registryKey{path: class, values: []registryValue{{"", r.Description}}}
+	if r.AppID != nil {
+		main.values = append(main.values, registryValue{"AppID", r.AppID.String()})
+	}
+	keys := []registryKey{main}
+	add := func(path string, values ...registryValue) {
+		keys = append(keys, registryKey{path, values})
+	}
+
+	if r.InprocServer != "" {
+		values := []registryValue{{"", r.InprocServer}}
+		if r.ThreadingModel != "" {
+			values = append(values, registryValue{"ThreadingModel", r.ThreadingModel})
+		}
+		add(class+`\InprocServer32`, values...)
+	}
+	if r.LocalServer != "" {
+		add(class+`\LocalServer32`, registryValue{"", quoteCommand(r.LocalServer)})
+	}
+	if r.ProgID != "" {
+		add(class+`\ProgID`, registryValue{"", r.ProgID})
+	}
+	if r.VersionIndependentProgID != "" {
+		add(class+`\VersionIndependentProgID`, registryValue{"", r.VersionIndependentProgID})
+	}
+	if r.TypeLib != nil {
+		add(class+`\TypeLib`, registryValue{"", r.TypeLib.String()})
+	}
+
+	if r.ProgID != "" {
+		add(r.ProgID, registryValue{"", r.Description})
+		add(r.ProgID+`\CLSID`, registryValue{"", clsid})
+	}
+	if r.VersionIndependentProgID != "" {
+		add(r.VersionIndependentProgID, registryValue{"", r.Description})
+		add(r.VersionIndependentProgID+`\CLSID`, registryValue{"", clsid})
+		add(r.VersionIndependentProgID+`\CurVer`, registryValue{"", r.ProgID})
+	}
+
+	if r.AppID != nil {
+		add(`AppID\`+r.AppID.String(), registryValue{"", r.Description})
+		if exe := executableName(r.LocalServer); exe != "" {
+			add(`AppID\`+exe, registryValue{"AppID", r.AppID.String()})
+		}
+	}
+	return keys, nil
+}
+
+// ownedKeys returns the top-level keys removed when unregistering. The
+// AppID of the executable is left alone, as other classes may share it.
+func (r Registration) ownedKeys() ([]string, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	keys := []string{`CLSID\` + r.CLSID.String()}
+	if r.ProgID != "" {
+		keys = append(keys, r.ProgID)
+	}
+	if r.VersionIndependentProgID != "" {
+		keys = append(keys, r.VersionIndependentProgID)
+	}
+	return keys, nil
+}
+
+// quoteCommand quotes the executable of a command line when its path holds
+// spaces, as COM runs LocalServer32 through CreateProcess.
+func quoteCommand(command string) string {
+	if strings.HasPrefix(command, `"`) || !strings.Contains(command, " ") {
+		return command
+	}
+	for _, switchStart := range []string{" /", " -"} {
+		if i := strings.Index(command, switchStart); i > 0 {
+			return `"` + command[:i] + `"` + command[i:]
+		}
+	}
+	return `"` + command + `"`
+}
+
+// executableName returns the file name of the executable of a command line.
+func executableName(command string) string {
+	if command == "" {
+		return ""
+	}
+	if strings.HasPrefix(command, `"`) {
+		if end := strings.Index(command[1:], `"`); end >= 0 {
+			command = command[1 : end+1]
+		}
+	} else if i := strings.Index(command, " /"); i > 0 {
+		command = command[:i]
+	} else if i := strings.Index(command, " -"); i > 0 {
+		command = command[:i]
+	}
+	// Command lines use Windows separators wherever the script is made.
+	return filepath.Base(strings.Replace(command, `\`, "/", -1))
+}
+
+// regFileHeader starts the files written by RegScript and UnregScript.
+const regFileHeader = "Windows Registry Editor Version 5.00\r\n"
+
+// RegScript returns the text of a .reg file creating the entries of the
+// registrations.
+func RegScript(registrations ...Registration) (string, error) {
+	var b strings.Builder
+	b.WriteString(regFileHeader)
+	for _, r := range registrations {
+		keys, err := r.keys()
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			b.WriteString("\r\n[" + r.rootName() + `\` + key.path + "]\r\n")
+			for _, value := range key.values {
+				if value.name == "" {
+					b.WriteString("@")
+				} else {
+					b.WriteString(regString(value.name))
+				}
+				b.WriteString("=" + regString(value.data) + "\r\n")
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// UnregScript returns the text of a .reg file deleting the entries of the
+// registrations.
+func UnregScript(registrations ...Registration) (string, error) {
+	var b strings.Builder
+	b.WriteString(regFileHeader)
+	for _, r := range registrations {
+		keys, err := r.ownedKeys()
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			b.WriteString("\r\n[-" + r.rootName() + `\` + key + "]\r\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// WriteRegFile writes script, as returned by RegScript or UnregScript, in
+// the UTF-16 encoding regedit reads and writes.
+func WriteRegFile(w io.Writer, script string) error {
+	var b bytes.Buffer
+	b.Grow(2 + 2*len(script))
+	b.Write([]byte{0xFF, 0xFE})
+	for _, unit := range utf16.Encode([]rune(script)) {
+		b.WriteByte(byte(unit))
+		b.WriteByte(byte(unit >> 8))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func regString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
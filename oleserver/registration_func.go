@@ -0,0 +1,15 @@
+// +build !windows
+
+package oleserver
+
+import ole "github.com/go-ole/go-ole"
+
+// Register writes the entries of the registrations to the registry.
+func Register(registrations ...Registration) error {
+	return ole.NewError(ole.E_NOTIMPL)
+}
+
+// Unregister deletes the entries of the registrations from the registry.
+func Unregister(registrations ...Registration) error {
+	return ole.NewError(ole.E_NOTIMPL)
+}
@@ -0,0 +1,134 @@
+package oleserver
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+var (
+	clsidCalculator = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A31}")
+	libidCalculator = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A30}")
+	appidCalculator = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A3F}")
+)
+
+var inprocCalculator = Registration{
+	CLSID:                    clsidCalculator,
+	Description:              "Go Calculator",
+	ProgID:                   "GoOle.Calculator.1",
+	VersionIndependentProgID: "GoOle.Calculator",
+	InprocServer:             `C:\Program Files\GoOle\calculator.dll`,
+	ThreadingModel:           ThreadingBoth,
+	TypeLib:                  libidCalculator,
+}
+
+var localCalculator = Registration{
+	CLSID:       clsidCalculator,
+	Description: `Go "Calculator" server`,
+	ProgID:      "GoOle.Calculator.1",
+	LocalServer: `C:\Program Files\GoOle\calculator.exe /Embedding`,
+	AppID:       appidCalculator,
+	PerUser:     true,
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from golden file:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestRegScript(t *testing.T) {
+	tests := []struct {
+		golden        string
+		registrations []Registration
+	}{
+		{"inproc.reg", []Registration{inprocCalculator}},
+		{"local.reg", []Registration{localCalculator}},
+	}
+	for _, test := range tests {
+		script, err := RegScript(test.registrations...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.golden, err)
+		}
+		checkGolden(t, test.golden, script)
+	}
+}
+
+func TestUnregScript(t *testing.T) {
+	script, err := UnregScript(inprocCalculator, localCalculator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "unregister.reg", script)
+}
+
+func TestRegScript_invalid(t *testing.T) {
+	tests := []Registration{
+		{Description: "no CLSID"},
+		{CLSID: clsidCalculator, InprocServer: "a.dll", ThreadingModel: "Single"},
+		{CLSID: clsidCalculator, ThreadingModel: ThreadingApartment},
+		{CLSID: clsidCalculator, VersionIndependentProgID: "GoOle.Calculator"},
+	}
+	for _, r := range tests {
+		if _, err := RegScript(r); err == nil {
+			t.Errorf("RegScript(%+v) succeeded", r)
+		}
+	}
+}
+
+func TestWriteRegFile(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteRegFile(&b, "é\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xFF, 0xFE, 0xE9, 0x00, '\r', 0x00, '\n', 0x00}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("WriteRegFile wrote % x, want % x", b.Bytes(), want)
+	}
+
+	script, _ := RegScript(inprocCalculator)
+	b.Reset()
+	WriteRegFile(&b, script)
+	data := b.Bytes()[2:]
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+	if decoded := string(utf16.Decode(units)); decoded != script {
+		t.Error("UTF-16 file does not decode to the script")
+	}
+}
+
+func TestQuoteCommand(t *testing.T) {
+	tests := []struct{ command, quoted, exe string }{
+		{`C:\server.exe`, `C:\server.exe`, "server.exe"},
+		{`C:\My Server\server.exe`, `"C:\My Server\server.exe"`, "server.exe"},
+		{`C:\My Server\server.exe /Embedding`, `"C:\My Server\server.exe" /Embedding`, "server.exe"},
+		{`"C:\My Server\server.exe" -x`, `"C:\My Server\server.exe" -x`, "server.exe"},
+	}
+	for _, test := range tests {
+		if quoted := quoteCommand(test.command); quoted != test.quoted {
+			t.Errorf("quoteCommand(%q) = %q, want %q", test.command, quoted, test.quoted)
+		}
+		if exe := executableName(test.quoted); exe != test.exe {
+			t.Errorf("executableName(%q) = %q, want %q", test.quoted, exe, test.exe)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//go:build windows
+// +build windows
+
+package oleserver
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// Register writes the entries of the registrations to the registry.
+func Register(registrations ...Registration) error {
+	for _, r := range registrations {
+		keys, err := r.keys()
+		if err != nil {
+			return err
+		}
+		root, err := r.openRoot()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err = writeKey(root, key); err != nil {
+				break
+			}
+		}
+		root.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unregister deletes the entries of the registrations from the registry.
+// Entries already missing are ignored.
+func Unregister(registrations ...Registration) error {
+	for _, r := range registrations {
+		keys, err := r.ownedKeys()
+		if err != nil {
+			return err
+		}
+		root, err := r.openRoot()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err = deleteTree(root, key); err != nil {
+				break
+			}
+		}
+		root.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r Registration) openRoot() (registry.Key, error) {
+	if r.PerUser {
+		key, _, err := registry.CreateKey(registry.CURRENT_USER, `Software\Classes`, registry.ALL_ACCESS)
+		return key, err
+	}
+	return registry.OpenKey(registry.CLASSES_ROOT, "", registry.ALL_ACCESS)
+}
+
+func writeKey(root registry.Key, key registryKey) error {
+	k, _, err := registry.CreateKey(root, key.path, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	for _, value := range key.values {
+		if err := k.SetStringValue(value.name, value.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTree deletes path and its subkeys.
+func deleteTree(root registry.Key, path string) error {
+	k, err := registry.OpenKey(root, path, registry.ENUMERATE_SUB_KEYS)
+	if err == registry.ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	subkeys, err := k.ReadSubKeyNames(-1)
+	k.Close()
+	if err != nil {
+		return err
+	}
+	for _, subkey := range subkeys {
+		if err := deleteTree(root, path+`\`+subkey); err != nil {
+			return err
+		}
+	}
+	if err := registry.DeleteKey(root, path); err != nil && err != registry.ErrNotExist {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package oleserver runs Go executables as local COM servers: it parses the
+// command line COM and installers pass, registers the class objects with
+// COM and keeps the process alive while clients use its objects.
+//
+// A typical server main is:
+//
+//	func main() {
+//		server := &oleserver.Server{
+//			Classes: []oleserver.Class{{CLSID: clsidCalculator, New: newCalculator}},
+//		}
+//		if err := server.Run(os.Args[1:]); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+package oleserver
+
+import (
+	"sync"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// DefaultIdleTimeout is how long a server launched by COM waits, once its
+// last object is released, before exiting.
+const DefaultIdleTimeout = 5 * time.Second
+
+// Class is a class served by a local server.
+type Class struct {
+	CLSID *ole.GUID
+
+	// New creates the objects of the class. When nil, the class must have
+	// been added with oleutil.RegisterClass.
+	New oleutil.ClassConstructor
+
+	// SingleUse registers the class object with REGCLS_SINGLEUSE, so COM
+	// starts a new process for each object. Otherwise one process serves
+	// all clients (REGCLS_MULTIPLEUSE).
+	SingleUse bool
+}
+
+// Server is a local server executable.
+type Server struct {
+	Classes []Class
+
+	// Multithreaded serves the objects from the multithreaded apartment
+	// instead of running a message loop in a single-threaded apartment.
+	Multithreaded bool
+
+	// IdleTimeout overrides DefaultIdleTimeout.
+	IdleTimeout time.Duration
+
+	// Registrations are the registry entries written by /RegServer and
+	// removed by /UnregServer.
+	Registrations []Registration
+
+	// Register and Unregister replace the handling of /RegServer and
+	// /UnregServer.
+	Register   func() error
+	Unregister func() error
+
+	once sync.Once
+	life *lifetime
+}
+
+func (s *Server) lifetime() *lifetime {
+	s.once.Do(func() {
+		idle := s.IdleTimeout
+		if idle <= 0 {
+			idle = DefaultIdleTimeout
+		}
+		s.life = newLifetime(idle)
+	})
+	return s.life
+}
+
+// Run carries out the command line args, without the program name. For
+// /RegServer and /UnregServer it updates the registry. Otherwise it
+// serves the classes, returning once the server stops: after Stop, or when
+// launched by COM with /Embedding, once no object has been alive for the
+// idle timeout.
+func (s *Server) Run(args []string) error {
+	command, _ := ParseCommandLine(args)
+	switch command {
+	case CommandRegServer:
+		if s.Register != nil {
+			return s.Register()
+		}
+		if len(s.Registrations) == 0 {
+			return ole.NewErrorWithDescription(ole.E_NOTIMPL, "server cannot register itself")
+		}
+		return Register(s.Registrations...)
+	case CommandUnregServer:
+		if s.Unregister != nil {
+			return s.Unregister()
+		}
+		if len(s.Registrations) == 0 {
+			return ole.NewErrorWithDescription(ole.E_NOTIMPL, "server cannot unregister itself")
+		}
+		return Unregister(s.Registrations...)
+	}
+
+	unregister, err := s.registerClasses()
+	if err != nil {
+		return err
+	}
+	defer unregister()
+	return s.serve(s.lifetime(), command == CommandEmbedding)
+}
+
+// Stop makes Run return. It may be called from any goroutine.
+func (s *Server) Stop() {
+	s.lifetime().stop()
+}
+
+// registerClasses adds the constructors of the classes to the oleutil class
+// registry. unregister removes them again.
+func (s *Server) registerClasses() (unregister func(), err error) {
+	var registered []*ole.GUID
+	unregister = func() {
+		for _, clsid := range registered {
+			oleutil.UnregisterClass(clsid)
+		}
+	}
+	for _, class := range s.Classes {
+		if class.New == nil {
+			continue
+		}
+		if err = oleutil.RegisterClass(class.CLSID, class.New); err != nil {
+			unregister()
+			return nil, err
+		}
+		registered = append(registered, class.CLSID)
+	}
+	return unregister, nil
+}
+
+// registrationFlags returns the REGCLS flags of the class object.
+func (c Class) registrationFlags() uint32 {
+	if c.SingleUse {
+		return ole.REGCLS_SINGLEUSE
+	}
+	return ole.REGCLS_MULTIPLEUSE
+}
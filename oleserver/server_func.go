@@ -0,0 +1,9 @@
+// +build !windows
+
+package oleserver
+
+import ole "github.com/go-ole/go-ole"
+
+func (s *Server) serve(life *lifetime, embedding bool) error {
+	return ole.NewError(ole.E_NOTIMPL)
+}
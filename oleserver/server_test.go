@@ -0,0 +1,88 @@
+package oleserver
+
+import (
+	"errors"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+var (
+	clsidFirst  = ole.NewGUID("{5C2D7A10-8E4B-4B0F-9D36-1F7E2A9C4B01}")
+	clsidSecond = ole.NewGUID("{5C2D7A10-8E4B-4B0F-9D36-1F7E2A9C4B02}")
+)
+
+type counter struct{ Value int32 }
+
+func newCounter() (interface{}, error) { return &counter{}, nil }
+
+func TestServer_registration(t *testing.T) {
+	var calls []string
+	server := &Server{
+		Register:   func() error { calls = append(calls, "register"); return nil },
+		Unregister: func() error { calls = append(calls, "unregister"); return errors.New("denied") },
+	}
+	if err := server.Run([]string{"/RegServer"}); err != nil {
+		t.Errorf("Run(/RegServer) = %v", err)
+	}
+	if err := server.Run([]string{"-unregserver"}); err == nil || err.Error() != "denied" {
+		t.Errorf("Run(-unregserver) = %v, want the Unregister error", err)
+	}
+	if len(calls) != 2 || calls[0] != "register" || calls[1] != "unregister" {
+		t.Errorf("calls = %v", calls)
+	}
+
+	err := (&Server{}).Run([]string{"/RegServer"})
+	if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != ole.E_NOTIMPL {
+		t.Errorf("Run(/RegServer) without Register = %v, want E_NOTIMPL", err)
+	}
+}
+
+func TestServer_registerClasses(t *testing.T) {
+	server := &Server{Classes: []Class{
+		{CLSID: clsidFirst, New: newCounter},
+		{CLSID: clsidSecond, New: newCounter, SingleUse: true},
+	}}
+	unregister, err := server.registerClasses()
+	if err != nil {
+		t.Fatalf("registerClasses = %v", err)
+	}
+	// Registered classes are taken: registering them again fails.
+	if oleutil.RegisterClass(clsidSecond, newCounter) == nil {
+		t.Error("class not registered")
+	}
+	unregister()
+	if err := oleutil.RegisterClass(clsidSecond, newCounter); err != nil {
+		t.Errorf("class still registered after unregister: %v", err)
+	}
+
+	// A conflict with a class registered elsewhere undoes the registration.
+	if _, err := server.registerClasses(); err == nil {
+		t.Fatal("registerClasses succeeded with a class registered twice")
+	}
+	if err := oleutil.RegisterClass(clsidFirst, newCounter); err != nil {
+		t.Errorf("failed registerClasses left a class registered: %v", err)
+	}
+	oleutil.UnregisterClass(clsidFirst)
+	oleutil.UnregisterClass(clsidSecond)
+}
+
+func TestClass_registrationFlags(t *testing.T) {
+	if flags := (Class{}).registrationFlags(); flags != ole.REGCLS_MULTIPLEUSE {
+		t.Errorf("default flags = %d, want REGCLS_MULTIPLEUSE", flags)
+	}
+	if flags := (Class{SingleUse: true}).registrationFlags(); flags != ole.REGCLS_SINGLEUSE {
+		t.Errorf("SingleUse flags = %d, want REGCLS_SINGLEUSE", flags)
+	}
+}
+
+func TestServer_idleTimeout(t *testing.T) {
+	if idle := (&Server{}).lifetime().idle; idle != DefaultIdleTimeout {
+		t.Errorf("default idle timeout = %v", idle)
+	}
+	server := &Server{IdleTimeout: DefaultIdleTimeout * 2}
+	if idle := server.lifetime().idle; idle != DefaultIdleTimeout*2 {
+		t.Errorf("idle timeout = %v", idle)
+	}
+}
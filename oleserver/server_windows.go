@@ -0,0 +1,128 @@
+//go:build windows
+// +build windows
+
+package oleserver
+
+import (
+	"runtime"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	moduser32              = windows.NewLazySystemDLL("user32.dll")
+	procGetMessageW        = moduser32.NewProc("GetMessageW")
+	procTranslateMessage   = moduser32.NewProc("TranslateMessage")
+	procDispatchMessageW   = moduser32.NewProc("DispatchMessageW")
+	procPostThreadMessageW = moduser32.NewProc("PostThreadMessageW")
+)
+
+const wmQuit = 0x0012
+
+// msg is the native MSG structure.
+type msg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+	private uint32
+}
+
+func (s *Server) serve(life *lifetime, embedding bool) error {
+	// COM calls into a single-threaded apartment arrive through the message
+	// loop of the thread that registered the class objects.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	coinit := uint32(ole.COINIT_APARTMENTTHREADED)
+	if s.Multithreaded {
+		coinit = ole.COINIT_MULTITHREADED
+	}
+	if err := ole.CoInitializeEx(0, coinit); err != nil {
+		if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != ole.S_FALSE {
+			return err
+		}
+	}
+	defer ole.CoUninitialize()
+
+	life.setCallCOM(callInMTA)
+	oleutil.NotifyModuleLocks(life.setLocks)
+	defer oleutil.NotifyModuleLocks(nil)
+
+	cookies, err := s.registerClassObjects()
+	defer func() {
+		for _, cookie := range cookies {
+			ole.CoRevokeClassObject(cookie)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	if err := ole.CoResumeClassObjects(); err != nil {
+		return err
+	}
+
+	if s.Multithreaded {
+		life.start(embedding)
+		<-life.done
+		return nil
+	}
+
+	threadID := windows.GetCurrentThreadId()
+	life.setOnStop(func() {
+		procPostThreadMessageW.Call(uintptr(threadID), wmQuit, 0, 0)
+	})
+	life.start(embedding)
+	return messageLoop()
+}
+
+// registerClassObjects registers the class objects, suspended until
+// CoResumeClassObjects makes them all available to clients at once. COM
+// keeps its own reference to them until they are revoked.
+func (s *Server) registerClassObjects() (cookies []uint32, err error) {
+	for _, class := range s.Classes {
+		factory, err := oleutil.NewClassObject(class.CLSID)
+		if err != nil {
+			return cookies, err
+		}
+		cookie, err := ole.CoRegisterClassObject(class.CLSID, &factory.IUnknown, ole.CLSCTX_LOCAL_SERVER, class.registrationFlags()|ole.REGCLS_SUSPENDED)
+		factory.Release()
+		if err != nil {
+			return cookies, err
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+// callInMTA calls f on a thread of the multithreaded apartment.
+func callInMTA(f func()) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
+	if oleErr, ok := err.(*ole.OleError); err == nil || ok && oleErr.Code() == ole.S_FALSE {
+		defer ole.CoUninitialize()
+	}
+	f()
+}
+
+// messageLoop dispatches the messages of the thread until WM_QUIT.
+func messageLoop() error {
+	var m msg
+	for {
+		ret, _, err := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		switch int32(ret) {
+		case 0:
+			return nil
+		case -1:
+			return err
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
@@ -0,0 +1,195 @@
+package oleutil
+
+import (
+	"sync"
+	"sync/atomic"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// ClassConstructor creates a new object of a registered class. The returned
+// value is served through NewDispatchServer, unless it is already a COM
+// object (*ole.IUnknown or *ole.IDispatch) whose reference passes to the
+// caller.
+type ClassConstructor func() (interface{}, error)
+
+var (
+	classes   = map[ole.GUID]ClassConstructor{}
+	classesMu sync.RWMutex
+
+	// moduleLocks counts the live objects of the module and the LockServer
+	// locks held by clients. A DLL may only be unloaded at zero.
+	moduleLocks int32
+
+	moduleLocksHook   func(locks int)
+	moduleLocksHookMu sync.Mutex
+)
+
+func init() {
+	// Enumerators served for _NewEnum are objects of the module too.
+	ole.NotifyEnumVARIANTServers(func(delta int) {
+		addModuleLocks(int32(delta))
+	})
+}
+
+// RegisterClass makes the class clsid available to DllGetClassObject, with
+// ctor creating its objects. Classes are usually registered from an init
+// function of the server. Registering a class twice fails with
+// E_INVALIDARG.
+func RegisterClass(clsid *ole.GUID, ctor ClassConstructor) error {
+	if clsid == nil || ctor == nil {
+		return ole.NewError(ole.E_INVALIDARG)
+	}
+	classesMu.Lock()
+	defer classesMu.Unlock()
+	if _, ok := classes[*clsid]; ok {
+		return ole.NewErrorWithDescription(ole.E_INVALIDARG, "class "+clsid.String()+" is already registered")
+	}
+	classes[*clsid] = ctor
+	return nil
+}
+
+// UnregisterClass removes the class clsid. Class objects already handed out
+// keep creating objects.
+func UnregisterClass(clsid *ole.GUID) {
+	classesMu.Lock()
+	delete(classes, *clsid)
+	classesMu.Unlock()
+}
+
+func lookupClass(clsid *ole.GUID) (ClassConstructor, bool) {
+	classesMu.RLock()
+	defer classesMu.RUnlock()
+	ctor, ok := classes[*clsid]
+	return ctor, ok
+}
+
+// NotifyModuleLocks sets a function called with the number of module locks
+// whenever it changes, replacing any previous one; nil removes it. Local
+// servers use it to exit once the last object has been released.
+//
+// Calls are made in order, one at a time; f must not create or release
+// objects of the module.
+func NotifyModuleLocks(f func(locks int)) {
+	moduleLocksHookMu.Lock()
+	moduleLocksHook = f
+	moduleLocksHookMu.Unlock()
+}
+
+func lockModule() {
+	addModuleLocks(1)
+}
+
+func unlockModule() {
+	addModuleLocks(-1)
+}
+
+// addModuleLocks changes the number of module locks by delta. The count never
+// drops below zero, so that an unbalanced release cannot keep the module
+// loaded for good.
+func addModuleLocks(delta int32) {
+	moduleLocksHookMu.Lock()
+	defer moduleLocksHookMu.Unlock()
+	locks := atomic.LoadInt32(&moduleLocks) + delta
+	if locks < 0 {
+		return
+	}
+	atomic.StoreInt32(&moduleLocks, locks)
+	if moduleLocksHook != nil {
+		moduleLocksHook(int(locks))
+	}
+}
+
+// DllCanUnloadNow implements the DllCanUnloadNow export of an in-process
+// server: it returns S_OK when no object of the module is alive and no
+// client holds a LockServer lock, S_FALSE otherwise.
+func DllCanUnloadNow() uintptr {
+	if atomic.LoadInt32(&moduleLocks) == 0 {
+		return ole.S_OK
+	}
+	return ole.S_FALSE
+}
+
+// classFactoryCore implements IClassFactory for one class independently of
+// the COM plumbing.
+type classFactoryCore struct {
+	clsid ole.GUID
+	ctor  ClassConstructor
+
+	// locks counts the LockServer locks taken through this class object,
+	// so that unlocking it more often than it was locked is ignored.
+	locks int32
+}
+
+// classFactoryFor returns the class object of clsid requested as iid, as in
+// DllGetClassObject.
+func classFactoryFor(clsid *ole.GUID, iid *ole.GUID) (*classFactoryCore, uintptr) {
+	if clsid == nil || iid == nil {
+		return nil, ole.E_POINTER
+	}
+	ctor, ok := lookupClass(clsid)
+	if !ok {
+		return nil, ole.CLASS_E_CLASSNOTAVAILABLE
+	}
+	if !ole.IsEqualGUID(iid, ole.IID_IUnknown) && !ole.IsEqualGUID(iid, ole.IID_IClassFactory) {
+		return nil, ole.E_NOINTERFACE
+	}
+	return &classFactoryCore{clsid: *clsid, ctor: ctor}, ole.S_OK
+}
+
+// createInstance creates an object of the class. The object is returned with
+// a reference the caller must release, or nil with the failure HRESULT.
+// Objects served by this package cannot be aggregated.
+func (f *classFactoryCore) createInstance(outer *ole.IUnknown) (unk *ole.IUnknown, hr uintptr) {
+	if outer != nil {
+		return nil, ole.CLASS_E_NOAGGREGATION
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			unk, hr = nil, ole.E_UNEXPECTED
+		}
+	}()
+	value, err := f.ctor()
+	if err != nil {
+		if oleErr, ok := err.(*ole.OleError); ok && oleErr.Code() != 0 {
+			return nil, oleErr.Code()
+		}
+		return nil, ole.E_FAIL
+	}
+
+	switch object := value.(type) {
+	case *ole.IUnknown:
+		return object, ole.S_OK
+	case *ole.IDispatch:
+		return &object.IUnknown, ole.S_OK
+	}
+	if newObjectServer == nil {
+		return nil, ole.E_NOTIMPL
+	}
+	core, err := newDispatchCore(value)
+	if err != nil {
+		return nil, ole.E_INVALIDARG
+	}
+	return &newObjectServer(core).IUnknown, ole.S_OK
+}
+
+// lockServer implements IClassFactory.LockServer. Unlocking releases only
+// locks taken through the same class object.
+func (f *classFactoryCore) lockServer(lock bool) {
+	if lock {
+		atomic.AddInt32(&f.locks, 1)
+		lockModule()
+		return
+	}
+	for {
+		locks := atomic.LoadInt32(&f.locks)
+		if locks == 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&f.locks, locks, locks-1) {
+			unlockModule()
+			return
+		}
+	}
+}
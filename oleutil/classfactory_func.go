@@ -0,0 +1,21 @@
+// +build !windows
+
+package oleutil
+
+import (
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// DllGetClassObject implements the DllGetClassObject export of an
+// in-process server.
+func DllGetClassObject(clsid *ole.GUID, iid *ole.GUID, ppv *unsafe.Pointer) uintptr {
+	return ole.E_NOTIMPL
+}
+
+// NewClassObject returns the class object of a class added with
+// RegisterClass.
+func NewClassObject(clsid *ole.GUID) (*ole.IClassFactory, error) {
+	return nil, ole.NewError(ole.E_NOTIMPL)
+}
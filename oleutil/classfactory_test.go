@@ -0,0 +1,180 @@
+package oleutil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var clsidTestCalculator = ole.NewGUID("{0B0E7D3A-6F4C-4F1E-8C3B-5A2D9E7F1C40}")
+
+func registerTestClass(t *testing.T, ctor ClassConstructor) (unregister func()) {
+	if err := RegisterClass(clsidTestCalculator, ctor); err != nil {
+		t.Fatalf("RegisterClass = %v", err)
+	}
+	return func() { UnregisterClass(clsidTestCalculator) }
+}
+
+func TestRegisterClass(t *testing.T) {
+	unregister := registerTestClass(t, func() (interface{}, error) { return &calculator{}, nil })
+	if err := RegisterClass(clsidTestCalculator, func() (interface{}, error) { return nil, nil }); err == nil {
+		t.Error("registering a class twice succeeded")
+	}
+	if err := RegisterClass(ole.IID_NULL, nil); err == nil {
+		t.Error("registering a nil constructor succeeded")
+	}
+	if _, ok := lookupClass(clsidTestCalculator); !ok {
+		t.Error("registered class not found")
+	}
+	unregister()
+	if _, ok := lookupClass(clsidTestCalculator); ok {
+		t.Error("unregistered class still found")
+	}
+}
+
+func TestClassFactoryFor(t *testing.T) {
+	defer registerTestClass(t, func() (interface{}, error) { return &calculator{}, nil })()
+
+	tests := []struct {
+		clsid *ole.GUID
+		iid   *ole.GUID
+		hr    uintptr
+	}{
+		{clsidTestCalculator, ole.IID_IClassFactory, ole.S_OK},
+		{clsidTestCalculator, ole.IID_IUnknown, ole.S_OK},
+		{clsidTestCalculator, ole.IID_IDispatch, ole.E_NOINTERFACE},
+		{ole.IID_NULL, ole.IID_IClassFactory, ole.CLASS_E_CLASSNOTAVAILABLE},
+		{nil, ole.IID_IClassFactory, ole.E_POINTER},
+	}
+	for _, test := range tests {
+		factory, hr := classFactoryFor(test.clsid, test.iid)
+		if hr != test.hr {
+			t.Errorf("classFactoryFor(%v, %v) = %#x, want %#x", test.clsid, test.iid, hr, test.hr)
+		}
+		if (factory != nil) != (test.hr == ole.S_OK) {
+			t.Errorf("classFactoryFor(%v, %v) returned factory %v", test.clsid, test.iid, factory)
+		}
+	}
+}
+
+func TestClassFactory_createInstance(t *testing.T) {
+	objects, _, restore := fakeObjectServers()
+	defer restore()
+
+	created := 0
+	defer registerTestClass(t, func() (interface{}, error) {
+		created++
+		return &calculator{}, nil
+	})()
+	factory, _ := classFactoryFor(clsidTestCalculator, ole.IID_IClassFactory)
+
+	if _, hr := factory.createInstance(&ole.IUnknown{}); hr != ole.CLASS_E_NOAGGREGATION {
+		t.Errorf("aggregated createInstance = %#x, want CLASS_E_NOAGGREGATION", hr)
+	}
+	if created != 0 {
+		t.Errorf("constructor called %d times for an aggregated instance", created)
+	}
+
+	unk, hr := factory.createInstance(nil)
+	if hr != ole.S_OK || unk == nil {
+		t.Fatalf("createInstance = %v, %#x", unk, hr)
+	}
+	if len(*objects) != 1 {
+		t.Fatalf("%d objects served, want 1", len(*objects))
+	}
+	if _, ok := (*objects)[0].value.Interface().(*calculator); !ok {
+		t.Errorf("served %v, want the constructed calculator", (*objects)[0].value.Type())
+	}
+}
+
+func TestClassFactory_createInstanceErrors(t *testing.T) {
+	_, _, restore := fakeObjectServers()
+	defer restore()
+
+	com := &ole.IUnknown{}
+	tests := []struct {
+		name string
+		ctor ClassConstructor
+		unk  *ole.IUnknown
+		hr   uintptr
+	}{
+		{"ole error", func() (interface{}, error) { return nil, ole.NewError(ole.E_OUTOFMEMORY) }, nil, ole.E_OUTOFMEMORY},
+		{"error", func() (interface{}, error) { return nil, errors.New("failed") }, nil, ole.E_FAIL},
+		{"panic", func() (interface{}, error) { panic("boom") }, nil, ole.E_UNEXPECTED},
+		{"nil value", func() (interface{}, error) { return nil, nil }, nil, ole.E_INVALIDARG},
+		{"com object", func() (interface{}, error) { return com, nil }, com, ole.S_OK},
+	}
+	for _, test := range tests {
+		factory := &classFactoryCore{clsid: *clsidTestCalculator, ctor: test.ctor}
+		unk, hr := factory.createInstance(nil)
+		if unk != test.unk || hr != test.hr {
+			t.Errorf("%s: createInstance = %v, %#x, want %v, %#x", test.name, unk, hr, test.unk, test.hr)
+		}
+	}
+}
+
+func TestDllCanUnloadNow(t *testing.T) {
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Fatalf("DllCanUnloadNow without locks = %#x", hr)
+	}
+
+	factory := &classFactoryCore{clsid: *clsidTestCalculator}
+	factory.lockServer(true)
+	lockModule()
+	if hr := DllCanUnloadNow(); hr != ole.S_FALSE {
+		t.Errorf("DllCanUnloadNow with locks = %#x, want S_FALSE", hr)
+	}
+	unlockModule()
+	if hr := DllCanUnloadNow(); hr != ole.S_FALSE {
+		t.Errorf("DllCanUnloadNow while locked = %#x, want S_FALSE", hr)
+	}
+	factory.lockServer(false)
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Errorf("DllCanUnloadNow after unlocking = %#x, want S_OK", hr)
+	}
+}
+
+func TestLockServerUnbalanced(t *testing.T) {
+	factory := &classFactoryCore{clsid: *clsidTestCalculator}
+	other := &classFactoryCore{clsid: *clsidTestCalculator}
+	factory.lockServer(false)
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Fatalf("DllCanUnloadNow after an unbalanced unlock = %#x, want S_OK", hr)
+	}
+
+	factory.lockServer(true)
+	other.lockServer(false)
+	if hr := DllCanUnloadNow(); hr != ole.S_FALSE {
+		t.Errorf("DllCanUnloadNow unlocked through another class object = %#x, want S_FALSE", hr)
+	}
+	factory.lockServer(false)
+	factory.lockServer(false)
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Errorf("DllCanUnloadNow after unlocking = %#x, want S_OK", hr)
+	}
+	lockModule()
+	if hr := DllCanUnloadNow(); hr != ole.S_FALSE {
+		t.Errorf("DllCanUnloadNow with a lock = %#x, want S_FALSE", hr)
+	}
+	unlockModule()
+	unlockModule()
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Errorf("DllCanUnloadNow after an extra unlock = %#x, want S_OK", hr)
+	}
+}
+
+func TestNotifyModuleLocks(t *testing.T) {
+	var counts []int
+	NotifyModuleLocks(func(locks int) { counts = append(counts, locks) })
+	lockModule()
+	lockModule()
+	unlockModule()
+	NotifyModuleLocks(nil)
+	unlockModule()
+
+	if want := []int{1, 2, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("notified %v, want %v", counts, want)
+	}
+}
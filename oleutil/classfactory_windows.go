@@ -0,0 +1,144 @@
+//go:build windows
+// +build windows
+
+package oleutil
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// classFactory is the class object handed out by DllGetClassObject. The
+// vtable pointer must stay the first field.
+type classFactory struct {
+	lpVtbl *ole.IClassFactoryVtbl
+	ref    int32
+	core   *classFactoryCore
+}
+
+var (
+	classFactoryVtbl     *ole.IClassFactoryVtbl
+	classFactoryVtblOnce sync.Once
+
+	liveClassFactories   = map[*classFactory]struct{}{}
+	liveClassFactoriesMu sync.Mutex
+)
+
+// DllGetClassObject implements the DllGetClassObject export of an
+// in-process server built with -buildmode=c-shared, returning the class
+// object of a class added with RegisterClass. The package main of the server
+// forwards the exports:
+//
+//	//export DllGetClassObject
+//	func DllGetClassObject(clsid, iid, ppv unsafe.Pointer) uintptr {
+//		return oleutil.DllGetClassObject((*ole.GUID)(clsid), (*ole.GUID)(iid), (*unsafe.Pointer)(ppv))
+//	}
+//
+//	//export DllCanUnloadNow
+//	func DllCanUnloadNow() uintptr {
+//		return oleutil.DllCanUnloadNow()
+//	}
+//
+// Objects served by the class object count as module locks until released,
+// as do LockServer calls, so that DllCanUnloadNow keeps the DLL loaded while
+// they are in use.
+func DllGetClassObject(clsid *ole.GUID, iid *ole.GUID, ppv *unsafe.Pointer) uintptr {
+	if ppv == nil {
+		return ole.E_POINTER
+	}
+	*ppv = nil
+	core, hr := classFactoryFor(clsid, iid)
+	if hr != ole.S_OK {
+		return hr
+	}
+	*ppv = unsafe.Pointer(newClassFactory(core))
+	return ole.S_OK
+}
+
+// NewClassObject returns the class object of a class added with
+// RegisterClass, for local servers to pass to ole.CoRegisterClassObject.
+func NewClassObject(clsid *ole.GUID) (*ole.IClassFactory, error) {
+	core, hr := classFactoryFor(clsid, ole.IID_IClassFactory)
+	if hr != ole.S_OK {
+		return nil, ole.NewError(hr)
+	}
+	return newClassFactory(core), nil
+}
+
+func newClassFactory(core *classFactoryCore) *ole.IClassFactory {
+	classFactoryVtblOnce.Do(func() {
+		classFactoryVtbl = &ole.IClassFactoryVtbl{}
+		classFactoryVtbl.QueryInterface = syscall.NewCallback(classFactoryQueryInterface)
+		classFactoryVtbl.AddRef = syscall.NewCallback(classFactoryAddRef)
+		classFactoryVtbl.Release = syscall.NewCallback(classFactoryRelease)
+		classFactoryVtbl.CreateInstance = syscall.NewCallback(classFactoryCreateInstance)
+		classFactoryVtbl.LockServer = syscall.NewCallback(classFactoryLockServer)
+	})
+
+	factory := &classFactory{
+		lpVtbl: classFactoryVtbl,
+		ref:    1,
+		core:   core,
+	}
+	liveClassFactoriesMu.Lock()
+	liveClassFactories[factory] = struct{}{}
+	liveClassFactoriesMu.Unlock()
+	return (*ole.IClassFactory)(unsafe.Pointer(factory))
+}
+
+func classFactoryQueryInterface(this *classFactory, iid *ole.GUID, punk **ole.IUnknown) uintptr {
+	if punk == nil {
+		return ole.E_POINTER
+	}
+	*punk = nil
+	if !ole.IsEqualGUID(iid, ole.IID_IUnknown) && !ole.IsEqualGUID(iid, ole.IID_IClassFactory) {
+		return ole.E_NOINTERFACE
+	}
+	atomic.AddInt32(&this.ref, 1)
+	*punk = (*ole.IUnknown)(unsafe.Pointer(this))
+	return ole.S_OK
+}
+
+func classFactoryAddRef(this *classFactory) uintptr {
+	return uintptr(atomic.AddInt32(&this.ref, 1))
+}
+
+func classFactoryRelease(this *classFactory) uintptr {
+	ref := atomic.AddInt32(&this.ref, -1)
+	if ref == 0 {
+		liveClassFactoriesMu.Lock()
+		delete(liveClassFactories, this)
+		liveClassFactoriesMu.Unlock()
+	}
+	return uintptr(ref)
+}
+
+func classFactoryCreateInstance(this *classFactory, outer *ole.IUnknown, iid *ole.GUID, ppv *unsafe.Pointer) uintptr {
+	if ppv == nil || iid == nil {
+		return ole.E_POINTER
+	}
+	*ppv = nil
+	unk, hr := this.core.createInstance(outer)
+	if hr != ole.S_OK {
+		return hr
+	}
+	defer unk.Release()
+	object, err := unk.QueryInterface(iid)
+	if err != nil {
+		if oleErr, ok := err.(*ole.OleError); ok {
+			return oleErr.Code()
+		}
+		return ole.E_NOINTERFACE
+	}
+	*ppv = unsafe.Pointer(object)
+	return ole.S_OK
+}
+
+func classFactoryLockServer(this *classFactory, lock int32) uintptr {
+	this.core.lockServer(lock != 0)
+	return ole.S_OK
+}
@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package oleutil
+
+import (
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestDllCanUnloadNowEnumerators(t *testing.T) {
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Fatalf("DllCanUnloadNow without locks = %#x", hr)
+	}
+
+	enum, err := ole.NewEnumVARIANT([]int32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hr := DllCanUnloadNow(); hr != ole.S_FALSE {
+		t.Errorf("DllCanUnloadNow with an enumerator = %#x, want S_FALSE", hr)
+	}
+	clone, err := enum.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enum.Release()
+	if hr := DllCanUnloadNow(); hr != ole.S_FALSE {
+		t.Errorf("DllCanUnloadNow with a clone = %#x, want S_FALSE", hr)
+	}
+	clone.Release()
+	if hr := DllCanUnloadNow(); hr != ole.S_OK {
+		t.Errorf("DllCanUnloadNow after releasing the enumerators = %#x, want S_OK", hr)
+	}
+}
@@ -0,0 +1,243 @@
+package oleutil
+
+import ole "github.com/go-ole/go-ole"
+
+// ConnectionPointInfo describes an outgoing interface an object fires events
+// through.
+type ConnectionPointInfo struct {
+	IID     *ole.GUID
+	Name    string
+	Default bool
+}
+
+// DefaultSourceInterface returns the IID of the default outgoing interface of
+// the object, the one scripting hosts connect to for its events.
+//
+// IProvideClassInfo2 is asked first. When the object does not implement it,
+// the coclass type information from IProvideClassInfo is searched for the
+// interface marked [default, source].
+func DefaultSourceInterface(disp *ole.IDispatch) (iid *ole.GUID, err error) {
+	var info2 *ole.IProvideClassInfo2
+	if ole.QueryInterfaceAs(&disp.IUnknown, &info2) == nil && info2 != nil {
+		iid, err = info2.GetGUID(ole.GUIDKIND_DEFAULT_SOURCE_DISP_IID)
+		info2.Release()
+		if err == nil {
+			return
+		}
+	}
+
+	sources, err := classSourceInterfaces(disp)
+	if err != nil {
+		return
+	}
+	for _, source := range sources {
+		if source.Default {
+			return source.IID, nil
+		}
+	}
+	return nil, ole.NewError(ole.E_NOINTERFACE)
+}
+
+// ConnectionPoints lists every connection point of the object.
+//
+// Interface names and the default flag come from the coclass type
+// information, when the object provides it.
+func ConnectionPoints(disp *ole.IDispatch) (points []ConnectionPointInfo, err error) {
+	var container *ole.IConnectionPointContainer
+	err = ole.QueryInterfaceAs(&disp.IUnknown, &container)
+	if err != nil {
+		return
+	}
+	defer container.Release()
+
+	enumerated, err := container.EnumConnectionPoints()
+	if err != nil {
+		return
+	}
+	defer func() {
+		for _, point := range enumerated {
+			point.Release()
+		}
+	}()
+
+	sources, _ := classSourceInterfaces(disp)
+	for _, point := range enumerated {
+		var iid *ole.GUID
+		iid, err = point.GetConnectionInterface()
+		if err != nil {
+			return nil, err
+		}
+		info := ConnectionPointInfo{IID: iid}
+		for _, source := range sources {
+			if ole.IsEqualGUID(source.IID, iid) {
+				info = source
+				break
+			}
+		}
+		points = append(points, info)
+	}
+	return
+}
+
+// ConnectDefaultEvents connects sink to the default outgoing interface of the
+// object without the caller having to know its IID.
+func ConnectDefaultEvents(disp *ole.IDispatch, sink interface{}) (cookie uint32, err error) {
+	iid, err := DefaultSourceInterface(disp)
+	if err != nil {
+		return
+	}
+	return ConnectObject(disp, iid, sink)
+}
+
+// classSourceInterfaces walks the coclass type information of the object for
+// the interfaces it declares as [source].
+func classSourceInterfaces(disp *ole.IDispatch) (sources []ConnectionPointInfo, err error) {
+	class, err := classInfoOf(disp)
+	if err != nil {
+		return
+	}
+	defer class.Release()
+	return coclassSources(class)
+}
+
+// classInfoOf returns the coclass type information of the object, from
+// IProvideClassInfo.
+func classInfoOf(disp *ole.IDispatch) (class *ole.ITypeInfo, err error) {
+	var provider *ole.IProvideClassInfo
+	err = ole.QueryInterfaceAs(&disp.IUnknown, &provider)
+	if err != nil {
+		return
+	}
+	defer provider.Release()
+	return provider.GetClassInfo()
+}
+
+// coclassSources lists the interfaces the coclass class declares as
+// [source].
+func coclassSources(class *ole.ITypeInfo) (sources []ConnectionPointInfo, err error) {
+	impls, err := coclassSourceImpls(class)
+	if err != nil {
+		return
+	}
+	for _, impl := range impls {
+		var source ConnectionPointInfo
+		source, err = implementedInterface(class, impl.index)
+		if err != nil {
+			return
+		}
+		source.Default = impl.flags&ole.IMPLTYPEFLAG_FDEFAULT != 0
+		sources = append(sources, source)
+	}
+	return
+}
+
+// sourceImpl is an implemented interface of a coclass declared as [source].
+type sourceImpl struct {
+	index uint32 // among the implemented interfaces of the coclass
+	flags int32  // IMPLTYPEFLAG_*
+}
+
+// coclassSourceImpls returns the implemented interfaces the coclass class
+// declares as [source].
+func coclassSourceImpls(class *ole.ITypeInfo) (impls []sourceImpl, err error) {
+	attr, err := class.GetTypeAttr()
+	if err != nil {
+		return
+	}
+	if attr.Typekind != ole.TKIND_COCLASS {
+		return nil, ole.NewError(ole.E_INVALIDARG)
+	}
+
+	for i := uint32(0); i < uint32(attr.CImplTypes); i++ {
+		var flags int32
+		flags, err = class.GetImplTypeFlags(i)
+		if err != nil {
+			return
+		}
+		if flags&ole.IMPLTYPEFLAG_FSOURCE != 0 {
+			impls = append(impls, sourceImpl{index: i, flags: flags})
+		}
+	}
+	return
+}
+
+// implementedInterface resolves the IID and name of the interface at index in
+// the implemented interfaces of a coclass.
+func implementedInterface(class *ole.ITypeInfo, index uint32) (info ConnectionPointInfo, err error) {
+	ref, err := implementedTypeInfo(class, index)
+	if err != nil {
+		return
+	}
+	defer ref.Release()
+
+	attr, err := ref.GetTypeAttr()
+	if err != nil {
+		return
+	}
+	info.IID = &attr.Guid
+	info.Name, _, _, _, err = ref.GetDocumentation(ole.MEMBERID_NIL)
+	return
+}
+
+// implementedTypeInfo returns the type information of the interface at index
+// in the implemented interfaces of a coclass.
+func implementedTypeInfo(class *ole.ITypeInfo, index uint32) (*ole.ITypeInfo, error) {
+	hreftype, err := class.GetRefTypeOfImplType(index)
+	if err != nil {
+		return nil, err
+	}
+	return class.GetRefTypeInfo(hreftype)
+}
+
+// sourceTypeInfo returns the type information of the outgoing interface iid
+// declared by the coclass of the object.
+func sourceTypeInfo(disp *ole.IDispatch, iid *ole.GUID) (info *ole.ITypeInfo, err error) {
+	class, err := classInfoOf(disp)
+	if err != nil {
+		return
+	}
+	defer class.Release()
+
+	impls, err := coclassSourceImpls(class)
+	if err != nil {
+		return
+	}
+	for _, impl := range impls {
+		var ref *ole.ITypeInfo
+		ref, err = implementedTypeInfo(class, impl.index)
+		if err != nil {
+			return
+		}
+		var attr *ole.TYPEATTR
+		attr, err = ref.GetTypeAttr()
+		if err != nil {
+			ref.Release()
+			return
+		}
+		if ole.IsEqualGUID(&attr.Guid, iid) {
+			return ref, nil
+		}
+		ref.Release()
+	}
+	return nil, ole.NewError(ole.E_NOINTERFACE)
+}
+
+// withSourceDispIDs renumbers the members of table with the DISPIDs of the
+// outgoing interface iid, so that the events of the object reach the methods
+// of the same name. The table is returned unchanged when the object does not
+// describe the interface.
+func withSourceDispIDs(table *dispatchTable, disp *ole.IDispatch, iid *ole.GUID) *dispatchTable {
+	info, err := sourceTypeInfo(disp, iid)
+	if err != nil {
+		return table
+	}
+	defer info.Release()
+
+	return table.withDispIDs(func(name string) (int32, bool) {
+		ids, err := info.GetIDsOfNames([]string{name})
+		if err != nil || len(ids) == 0 {
+			return 0, false
+		}
+		return ids[0], true
+	})
+}
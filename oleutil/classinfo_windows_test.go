@@ -0,0 +1,53 @@
+//go:build windows
+// +build windows
+
+package oleutil
+
+import (
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var (
+	// stdole2.tlb, which every Windows registers, declares the coclass
+	// StdFont with FontEvents as its [default, source] interface.
+	libidStdOle  = ole.NewGUID("{00020430-0000-0000-C000-000000000046}")
+	clsidStdFont = ole.NewGUID("{0BE35203-8F91-11CE-9DE3-00AA004BB851}")
+	iidFont      = ole.NewGUID("{BEF6E003-A874-101A-8BBA-00AA00300CAB}")
+)
+
+func TestCoclassSources(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	tlib, err := ole.LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Skipf("LoadRegTypeLib = %v", err)
+	}
+	defer tlib.Release()
+
+	class, err := tlib.GetTypeInfoOfGuid(clsidStdFont)
+	if err != nil {
+		t.Fatalf("GetTypeInfoOfGuid(StdFont) = %v", err)
+	}
+	defer class.Release()
+
+	sources, err := coclassSources(class)
+	if err != nil {
+		t.Fatalf("coclassSources(StdFont) = %v", err)
+	}
+	if len(sources) != 1 || sources[0].IID == nil || sources[0].Name != "FontEvents" || !sources[0].Default {
+		t.Errorf("coclassSources(StdFont) = %+v", sources)
+	}
+
+	// Only coclasses declare sources.
+	font, err := tlib.GetTypeInfoOfGuid(iidFont)
+	if err != nil {
+		t.Fatalf("GetTypeInfoOfGuid(Font) = %v", err)
+	}
+	defer font.Release()
+	if _, err := coclassSources(font); err == nil {
+		t.Error("coclassSources accepted the interface Font")
+	}
+}
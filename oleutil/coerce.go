@@ -0,0 +1,405 @@
+package oleutil
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// paramSignature is the declared type of a parameter callers pass.
+type paramSignature struct {
+	// vt is the type of the parameter, with VT_BYREF for pointers to
+	// values, or VT_EMPTY when arguments are passed as they are.
+	vt         ole.VT
+	optional   bool
+	hasDefault bool
+	def        interface{} // default value, as variantScalar returns it
+}
+
+// signature is the parameters of a member that callers pass: those of its
+// type information without the [retval] and [lcid] ones.
+type signature struct {
+	params []paramSignature
+	vararg bool // the last parameter takes the remaining arguments
+	put    bool // the last argument is the value of a property put
+}
+
+// typeResolver returns the VT a user-defined type is passed as.
+type typeResolver func(hreftype uint32) (ole.VT, error)
+
+// newSignature returns the signature fdesc describes.
+func newSignature(fdesc *ole.FUNCDESC, resolve typeResolver) *signature {
+	sig := &signature{
+		vararg: fdesc.CParamsOpt == -1,
+		put:    fdesc.InvKind&(ole.INVOKE_PROPERTYPUT|ole.INVOKE_PROPERTYPUTREF) != 0,
+	}
+	for i := range fdesc.Params {
+		p := &fdesc.Params[i]
+		if p.Flags&(ole.PARAMFLAG_FRETVAL|ole.PARAMFLAG_FLCID) != 0 {
+			continue
+		}
+		param := paramSignature{
+			vt:       paramVT(&p.Tdesc, resolve),
+			optional: p.Flags&(ole.PARAMFLAG_FOPT|ole.PARAMFLAG_FHASDEFAULT) != 0,
+		}
+		if p.Flags&ole.PARAMFLAG_FHASDEFAULT != 0 && p.Default != nil {
+			if def, err := variantScalar(p.Default); err == nil {
+				param.hasDefault, param.def = true, def
+			}
+		}
+		sig.params = append(sig.params, param)
+	}
+
+	optional := int(fdesc.CParamsOpt)
+	if sig.vararg {
+		optional = 1
+	}
+	for i := len(sig.params) - optional; i < len(sig.params); i++ {
+		if i >= 0 {
+			sig.params[i].optional = true
+		}
+	}
+	return sig
+}
+
+// newVarSignature returns the signature of the accessor of the property
+// vdesc describes that flags invoke.
+func newVarSignature(vdesc *ole.VARDESC, flags uint16, resolve typeResolver) *signature {
+	if flags&(ole.DISPATCH_PROPERTYPUT|ole.DISPATCH_PROPERTYPUTREF) == 0 {
+		return &signature{}
+	}
+	return &signature{
+		params: []paramSignature{{vt: paramVT(&vdesc.ElemdescVar.Tdesc, resolve)}},
+		put:    true,
+	}
+}
+
+// paramVT returns the VT of arguments of type t, or VT_EMPTY for the types
+// arguments cannot be coerced to, such as structures.
+func paramVT(t *ole.TYPEDESC, resolve typeResolver) ole.VT {
+	switch ole.VT(t.VT) {
+	case ole.VT_PTR:
+		if t.Lptdesc == nil {
+			return ole.VT_EMPTY
+		}
+		vt := paramVT(t.Lptdesc, resolve)
+		// A pointer to an interface is the interface itself.
+		if ole.VT(t.Lptdesc.VT) == ole.VT_USERDEFINED && (vt == ole.VT_UNKNOWN || vt == ole.VT_DISPATCH) {
+			return vt
+		}
+		if vt == ole.VT_EMPTY || vt&ole.VT_BYREF != 0 {
+			return ole.VT_EMPTY
+		}
+		return vt | ole.VT_BYREF
+	case ole.VT_USERDEFINED:
+		vt, err := resolve(t.Hreftype)
+		if err != nil {
+			return ole.VT_EMPTY
+		}
+		return vt
+	case ole.VT_SAFEARRAY:
+		if t.Lptdesc == nil {
+			return ole.VT_ARRAY | ole.VT_VARIANT
+		}
+		elem := paramVT(t.Lptdesc, resolve)
+		if elem == ole.VT_EMPTY || elem&ole.VT_BYREF != 0 {
+			return ole.VT_EMPTY
+		}
+		return ole.VT_ARRAY | elem
+	case ole.VT_CARRAY, ole.VT_VOID, ole.VT_HRESULT:
+		return ole.VT_EMPTY
+	}
+	return ole.VT(t.VT)
+}
+
+// required returns the number of arguments that must be passed.
+func (s *signature) required() int {
+	n := len(s.params)
+	for n > 0 && s.params[n-1].optional {
+		n--
+	}
+	return n
+}
+
+// check reports the calls of n arguments the member does not take, before
+// they reach the server.
+func (s *signature) check(n int) error {
+	if required := s.required(); n < required {
+		return ole.NewErrorWithDescription(ole.DISP_E_PARAMNOTOPTIONAL,
+			fmt.Sprintf("%d arguments passed, %d required", n, required))
+	}
+	if !s.vararg && n > len(s.params) {
+		return ole.NewErrorWithDescription(ole.DISP_E_BADPARAMCOUNT,
+			fmt.Sprintf("%d arguments passed, at most %d taken", n, len(s.params)))
+	}
+	return nil
+}
+
+// fill appends to args the defaults of the optional parameters args omit:
+// their default values, or Missing. Property puts, whose value comes last,
+// and the parameter of variable arguments are not filled.
+func (s *signature) fill(args []interface{}) []interface{} {
+	if s.put {
+		return args
+	}
+	end := len(s.params)
+	if s.vararg {
+		end--
+	}
+	args = args[:len(args):len(args)]
+	for i := len(args); i < end; i++ {
+		switch {
+		case s.params[i].hasDefault && s.params[i].def == nil:
+			args = append(args, ole.NewVariant(ole.VT_EMPTY, 0))
+		case s.params[i].hasDefault:
+			args = append(args, s.params[i].def)
+		default:
+			args = append(args, Missing)
+		}
+	}
+	return args
+}
+
+// param returns the parameter argument i is passed to, or nil for the
+// arguments passed as they are.
+func (s *signature) param(i int) *paramSignature {
+	if s == nil || i >= len(s.params) || s.vararg && i == len(s.params)-1 {
+		return nil
+	}
+	return &s.params[i]
+}
+
+// Go types of the VTs arguments are coerced to.
+var coercedTypes = map[ole.VT]reflect.Type{
+	ole.VT_I1:    reflect.TypeOf(int8(0)),
+	ole.VT_I2:    reflect.TypeOf(int16(0)),
+	ole.VT_I4:    reflect.TypeOf(int32(0)),
+	ole.VT_INT:   reflect.TypeOf(int32(0)),
+	ole.VT_ERROR: reflect.TypeOf(int32(0)),
+	ole.VT_I8:    reflect.TypeOf(int64(0)),
+	ole.VT_UI1:   reflect.TypeOf(uint8(0)),
+	ole.VT_UI2:   reflect.TypeOf(uint16(0)),
+	ole.VT_UI4:   reflect.TypeOf(uint32(0)),
+	ole.VT_UINT:  reflect.TypeOf(uint32(0)),
+	ole.VT_UI8:   reflect.TypeOf(uint64(0)),
+	ole.VT_R4:    reflect.TypeOf(float32(0)),
+	ole.VT_R8:    reflect.TypeOf(float64(0)),
+	ole.VT_CY:    reflect.TypeOf(float64(0)),
+	ole.VT_BOOL:  reflect.TypeOf(false),
+	ole.VT_BSTR:  reflect.TypeOf(""),
+	ole.VT_DATE:  timeType,
+}
+
+// coerceVariant converts v, which it takes over, to vt. VARIANTs of types
+// without a Go counterpart, arrays, interfaces and Missing are returned as
+// they are, for the server to convert or reject; so is v with an error when
+// its value does not fit vt.
+func coerceVariant(v ole.VARIANT, vt ole.VT) (ole.VARIANT, error) {
+	if v.VT == vt || isMissing(&v) || v.VT&(ole.VT_ARRAY|ole.VT_BYREF) != 0 {
+		return v, nil
+	}
+	if vt == ole.VT_UNKNOWN && v.VT == ole.VT_DISPATCH {
+		v.VT = ole.VT_UNKNOWN
+		return v, nil
+	}
+	t, ok := coercedTypes[vt]
+	if !ok {
+		return v, nil
+	}
+	scalar, err := variantScalar(&v)
+	if err != nil {
+		return v, nil
+	}
+	switch scalar.(type) {
+	case *ole.IDispatch, *ole.IUnknown:
+		return v, nil
+	}
+
+	converted, err := convertScalar(scalar, t)
+	if err != nil {
+		return v, err
+	}
+	var out ole.VARIANT
+	if vt == ole.VT_CY {
+		cy := math.RoundToEven(converted.Float() * 10000)
+		if cy < math.MinInt64 || cy >= math.MaxInt64 {
+			return v, ole.NewError(ole.DISP_E_OVERFLOW)
+		}
+		out = ole.NewVariant(ole.VT_CY, int64(cy))
+	} else {
+		out, err = ole.NewVariantFromValue(converted.Interface())
+		if err != nil {
+			return v, err
+		}
+		out.VT = vt
+	}
+	v.Clear()
+	return out, nil
+}
+
+// invokeKindMatches tells whether Invoke flags call a function of kind
+// invkind.
+func invokeKindMatches(invkind int32, flags uint16) bool {
+	switch invkind {
+	case ole.INVOKE_FUNC:
+		return flags&ole.DISPATCH_METHOD != 0
+	case ole.INVOKE_PROPERTYGET:
+		return flags&ole.DISPATCH_PROPERTYGET != 0
+	case ole.INVOKE_PROPERTYPUT:
+		return flags&ole.DISPATCH_PROPERTYPUT != 0
+	case ole.INVOKE_PROPERTYPUTREF:
+		return flags&ole.DISPATCH_PROPERTYPUTREF != 0
+	}
+	return false
+}
+
+// memberSignatures is the signatures of the members of an interface, in
+// the order of its type information.
+type memberSignatures struct {
+	funcs []funcSignature
+	puts  map[int32]*signature // of the properties declared as variables
+}
+
+// funcSignature is the signature of a function of an interface.
+type funcSignature struct {
+	memid   int32
+	invkind int32
+	sig     *signature
+}
+
+func (m *memberSignatures) addFunc(fdesc *ole.FUNCDESC, resolve typeResolver) {
+	m.funcs = append(m.funcs, funcSignature{
+		memid:   fdesc.Memid,
+		invkind: fdesc.InvKind,
+		sig:     newSignature(fdesc, resolve),
+	})
+}
+
+func (m *memberSignatures) addVar(vdesc *ole.VARDESC, resolve typeResolver) {
+	if vdesc.VarKind != ole.VAR_DISPATCH {
+		return
+	}
+	if m.puts == nil {
+		m.puts = make(map[int32]*signature)
+	}
+	if _, ok := m.puts[vdesc.Memid]; !ok {
+		m.puts[vdesc.Memid] = newVarSignature(vdesc, ole.DISPATCH_PROPERTYPUT, resolve)
+	}
+}
+
+// lookup returns the signature of the member dispid that flags invoke, or
+// nil when the interface does not describe it.
+func (m *memberSignatures) lookup(dispid int32, flags uint16) *signature {
+	for i := range m.funcs {
+		if m.funcs[i].memid == dispid && invokeKindMatches(m.funcs[i].invkind, flags) {
+			return m.funcs[i].sig
+		}
+	}
+	if put, ok := m.puts[dispid]; ok {
+		if flags&(ole.DISPATCH_PROPERTYPUT|ole.DISPATCH_PROPERTYPUTREF) != 0 {
+			return put
+		}
+		return &signature{}
+	}
+	return nil
+}
+
+// signatureCache holds the member signatures of the interfaces Invoke went
+// through, by IID. A published interface does not change, so the members
+// of its type information, each a call to out-of-process servers, are read
+// once.
+var signatureCache = struct {
+	sync.Mutex
+	interfaces map[ole.GUID]*memberSignatures
+}{interfaces: make(map[ole.GUID]*memberSignatures)}
+
+// lookupSignature returns the signature of the member dispid of disp that
+// flags invoke, or nil when disp has no type information describing it.
+func lookupSignature(disp *ole.IDispatch, dispid int32, flags uint16) *signature {
+	tinfo, err := disp.GetTypeInfo()
+	if err != nil || tinfo == nil {
+		return nil
+	}
+	defer tinfo.Release()
+	attr, err := tinfo.GetTypeAttr()
+	if err != nil {
+		return nil
+	}
+	members, err := interfaceSignatures(tinfo, attr)
+	if err != nil {
+		return nil
+	}
+	return members.lookup(dispid, flags)
+}
+
+// interfaceSignatures returns the member signatures of the interface tinfo
+// describes, from the cache when its IID was seen before. Interfaces without
+// an IID are not cached.
+func interfaceSignatures(tinfo *ole.ITypeInfo, attr *ole.TYPEATTR) (*memberSignatures, error) {
+	cached := !ole.IsEqualGUID(&attr.Guid, ole.IID_NULL)
+	if cached {
+		signatureCache.Lock()
+		members, ok := signatureCache.interfaces[attr.Guid]
+		signatureCache.Unlock()
+		if ok {
+			return members, nil
+		}
+	}
+
+	members := new(memberSignatures)
+	resolve := typeInfoResolver(tinfo)
+	for i := 0; i < int(attr.CFuncs); i++ {
+		fdesc, err := tinfo.GetFuncDesc(uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		members.addFunc(fdesc, resolve)
+		fdesc.Clear()
+	}
+	for i := 0; i < int(attr.CVars); i++ {
+		vdesc, err := tinfo.GetVarDesc(uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		members.addVar(vdesc, resolve)
+		vdesc.Clear()
+	}
+
+	if cached {
+		signatureCache.Lock()
+		signatureCache.interfaces[attr.Guid] = members
+		signatureCache.Unlock()
+	}
+	return members, nil
+}
+
+// typeInfoResolver returns the resolver of the user-defined types of tinfo:
+// enumerations are passed as VT_I4, interfaces as VT_UNKNOWN or VT_DISPATCH
+// and aliases as the type they stand for.
+func typeInfoResolver(tinfo *ole.ITypeInfo) typeResolver {
+	return func(hreftype uint32) (ole.VT, error) {
+		ref, err := tinfo.GetRefTypeInfo(hreftype)
+		if err != nil {
+			return ole.VT_EMPTY, err
+		}
+		defer ref.Release()
+		attr, err := ref.GetTypeAttr()
+		if err != nil {
+			return ole.VT_EMPTY, err
+		}
+		switch attr.Typekind {
+		case ole.TKIND_ENUM:
+			return ole.VT_I4, nil
+		case ole.TKIND_INTERFACE, ole.TKIND_COCLASS:
+			return ole.VT_UNKNOWN, nil
+		case ole.TKIND_DISPATCH:
+			return ole.VT_DISPATCH, nil
+		case ole.TKIND_ALIAS:
+			return paramVT(&attr.TdescAlias, typeInfoResolver(ref)), nil
+		}
+		return ole.VT_EMPTY, ole.NewError(ole.DISP_E_TYPEMISMATCH)
+	}
+}
@@ -0,0 +1,211 @@
+package oleutil
+
+import (
+	"math"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestNewInvokeArgs_signature(t *testing.T) {
+	i4 := ole.TYPEDESC{VT: uint16(ole.VT_I4)}
+	def := ole.NewVariant(ole.VT_I4, 7)
+	fdesc := &ole.FUNCDESC{
+		InvKind: ole.INVOKE_FUNC,
+		Params: []ole.ELEMDESC{
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_I2)}, Flags: ole.PARAMFLAG_FIN},
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_DATE)}, Flags: ole.PARAMFLAG_FIN},
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &i4}, Flags: ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOUT},
+			{Tdesc: i4, Flags: ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOPT | ole.PARAMFLAG_FHASDEFAULT, Default: &def},
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_VARIANT)}, Flags: ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOPT},
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &i4}, Flags: ole.PARAMFLAG_FOUT | ole.PARAMFLAG_FRETVAL},
+		},
+	}
+	sig := newSignature(fdesc, nil)
+
+	args, err := newInvokeArgs([]interface{}{3, 45000.5, int64(5)}, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer args.clear()
+	if len(args.vargs) != 5 {
+		t.Fatalf("%d arguments, expected the optional ones filled in", len(args.vargs))
+	}
+	if v := args.vargs[4]; v.VT != ole.VT_I2 || v.Val != 3 {
+		t.Errorf("VT_I2 argument = %+v", v)
+	}
+	if v := args.vargs[3]; v.VT != ole.VT_DATE || math.Abs(math.Float64frombits(uint64(v.Val))-45000.5) > 1e-6 {
+		t.Errorf("VT_DATE argument = %+v", v)
+	}
+	if v := args.vargs[2]; v.VT != ole.VT_I4|ole.VT_BYREF || *(*int32)(variantPointer(&v)) != 5 {
+		t.Errorf("by reference argument = %+v", v)
+	}
+	if v := args.vargs[1]; v.VT != ole.VT_I4 || v.Val != 7 {
+		t.Errorf("default argument = %+v", v)
+	}
+	if v := args.vargs[0]; !isMissing(&v) {
+		t.Errorf("omitted argument = %+v", v)
+	}
+
+	value := 9
+	args, err = newInvokeArgs([]interface{}{int8(1), 0, &value, Missing, Missing}, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer args.clear()
+	ref := args.vargs[2]
+	if ref.VT != ole.VT_I4|ole.VT_BYREF {
+		t.Fatalf("by reference argument = %+v", ref)
+	}
+	*(*int32)(variantPointer(&ref)) = 11
+	if err := args.storeRefs(); err != nil {
+		t.Fatal(err)
+	}
+	if value != 11 {
+		t.Errorf("by reference argument = %d, expected 11", value)
+	}
+
+	for _, bad := range [][]interface{}{
+		{1, 2},
+		{1, 2, 3, 4, 5, 6},
+		{40000, 2, 3},
+	} {
+		if args, err := newInvokeArgs(bad, sig); err == nil {
+			args.clear()
+			t.Errorf("converted arguments %v", bad)
+		}
+	}
+}
+
+func TestNewSignature(t *testing.T) {
+	i4 := ole.TYPEDESC{VT: uint16(ole.VT_I4)}
+	ptr := ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &i4}
+	enum := ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: 1}
+	iface := ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: 2}
+	resolve := func(hreftype uint32) (ole.VT, error) {
+		if hreftype == 1 {
+			return ole.VT_I4, nil
+		}
+		return ole.VT_DISPATCH, nil
+	}
+	tests := []struct {
+		t  ole.TYPEDESC
+		vt ole.VT
+	}{
+		{i4, ole.VT_I4},
+		{ptr, ole.VT_I4 | ole.VT_BYREF},
+		{ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &ptr}, ole.VT_EMPTY},
+		{enum, ole.VT_I4},
+		{ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &iface}, ole.VT_DISPATCH},
+		{ole.TYPEDESC{VT: uint16(ole.VT_SAFEARRAY), Lptdesc: &enum}, ole.VT_ARRAY | ole.VT_I4},
+		{ole.TYPEDESC{VT: uint16(ole.VT_CARRAY)}, ole.VT_EMPTY},
+	}
+	for _, test := range tests {
+		if vt := paramVT(&test.t, resolve); vt != test.vt {
+			t.Errorf("paramVT(%+v) = %v, want %v", test.t, vt, test.vt)
+		}
+	}
+
+	vararg := newSignature(&ole.FUNCDESC{
+		InvKind:    ole.INVOKE_FUNC,
+		CParamsOpt: -1,
+		Params: []ole.ELEMDESC{
+			{Tdesc: i4},
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_SAFEARRAY), Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_VARIANT)}}},
+		},
+	}, resolve)
+	if vararg.required() != 1 || vararg.check(5) != nil || len(vararg.fill([]interface{}{1})) != 1 || vararg.param(3) != nil {
+		t.Errorf("signature of variable arguments = %+v", vararg)
+	}
+
+	put := newSignature(&ole.FUNCDESC{
+		InvKind: ole.INVOKE_PROPERTYPUT,
+		Params: []ole.ELEMDESC{
+			{Tdesc: i4, Flags: ole.PARAMFLAG_FOPT},
+			{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_R4)}},
+		},
+	}, resolve)
+	args, err := newInvokeArgs([]interface{}{1, 2}, put)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args.vargs) != 2 || args.vargs[0].VT != ole.VT_R4 || math.Float32frombits(uint32(args.vargs[0].Val)) != 2 {
+		t.Errorf("property put arguments = %+v", args.vargs)
+	}
+	if err := put.check(0); err == nil {
+		t.Error("checked a property put without its value")
+	}
+}
+
+func TestCoerceVariant(t *testing.T) {
+	tests := []struct {
+		in  ole.VARIANT
+		vt  ole.VT
+		out ole.VARIANT
+	}{
+		{ole.NewVariant(ole.VT_R8, int64(math.Float64bits(1.5))), ole.VT_CY, ole.NewVariant(ole.VT_CY, 15000)},
+		{ole.NewVariant(ole.VT_I4, 1), ole.VT_BOOL, ole.NewVariant(ole.VT_BOOL, 0xffff)},
+		{ole.NewVariant(ole.VT_I4, -1), ole.VT_ERROR, ole.NewVariant(ole.VT_ERROR, -1)},
+		{ole.NewVariant(ole.VT_UI8, 1<<40), ole.VT_I8, ole.NewVariant(ole.VT_I8, 1<<40)},
+		{ole.NewVariant(ole.VT_I4, 3), ole.VT_VARIANT, ole.NewVariant(ole.VT_I4, 3)},
+		{ole.NewVariant(ole.VT_I4, 3), ole.VT_DECIMAL, ole.NewVariant(ole.VT_I4, 3)},
+		{Missing, ole.VT_I2, Missing},
+		{ole.NewVariant(ole.VT_DISPATCH, 0), ole.VT_UNKNOWN, ole.NewVariant(ole.VT_UNKNOWN, 0)},
+	}
+	for _, test := range tests {
+		out, err := coerceVariant(test.in, test.vt)
+		if err != nil || out.VT != test.out.VT || out.Val != test.out.Val {
+			t.Errorf("coerceVariant(%+v, %v) = %+v, %v; want %+v", test.in, test.vt, out, err, test.out)
+		}
+	}
+	if _, err := coerceVariant(ole.NewVariant(ole.VT_I4, -1), ole.VT_UI2); err == nil {
+		t.Error("coerced -1 to VT_UI2")
+	}
+
+	// Arrays are left to the server.
+	array := ole.NewVariant(ole.VT_ARRAY|ole.VT_I4, 0x1000)
+	if out, err := coerceVariant(array, ole.VT_ARRAY|ole.VT_I2); err != nil || out != array {
+		t.Errorf("coerced an array to %+v", out)
+	}
+}
+
+func TestMemberSignatures(t *testing.T) {
+	i4 := ole.TYPEDESC{VT: uint16(ole.VT_I4)}
+	var members memberSignatures
+	members.addFunc(&ole.FUNCDESC{Memid: 1, InvKind: ole.INVOKE_PROPERTYGET}, nil)
+	members.addFunc(&ole.FUNCDESC{Memid: 1, InvKind: ole.INVOKE_PROPERTYPUT, Params: []ole.ELEMDESC{{Tdesc: i4}}}, nil)
+	members.addFunc(&ole.FUNCDESC{Memid: 2, InvKind: ole.INVOKE_FUNC, Params: []ole.ELEMDESC{{Tdesc: i4}, {Tdesc: i4}}}, nil)
+	members.addVar(&ole.VARDESC{Memid: 3, VarKind: ole.VAR_DISPATCH, ElemdescVar: ole.ELEMDESC{Tdesc: ole.TYPEDESC{VT: uint16(ole.VT_BSTR)}}}, nil)
+	members.addVar(&ole.VARDESC{Memid: 4, VarKind: ole.VAR_CONST}, nil)
+
+	tests := []struct {
+		dispid int32
+		flags  uint16
+		params int
+		put    bool
+	}{
+		{1, ole.DISPATCH_PROPERTYGET, 0, false},
+		{1, ole.DISPATCH_PROPERTYPUT, 1, true},
+		{2, ole.DISPATCH_METHOD, 2, false},
+		{2, ole.DISPATCH_METHOD | ole.DISPATCH_PROPERTYGET, 2, false},
+		{3, ole.DISPATCH_PROPERTYGET, 0, false},
+		{3, ole.DISPATCH_PROPERTYPUT, 1, true},
+	}
+	for _, test := range tests {
+		sig := members.lookup(test.dispid, test.flags)
+		if sig == nil || len(sig.params) != test.params || sig.put != test.put {
+			t.Errorf("lookup(%d, %d) = %+v", test.dispid, test.flags, sig)
+		}
+	}
+	if sig := members.lookup(3, ole.DISPATCH_PROPERTYPUT); sig != nil && len(sig.params) == 1 && sig.params[0].vt != ole.VT_BSTR {
+		t.Errorf("property put of a variable takes %v", sig.params[0].vt)
+	}
+	for _, missing := range []struct {
+		dispid int32
+		flags  uint16
+	}{{2, ole.DISPATCH_PROPERTYPUT}, {4, ole.DISPATCH_PROPERTYGET}, {5, ole.DISPATCH_METHOD}} {
+		if sig := members.lookup(missing.dispid, missing.flags); sig != nil {
+			t.Errorf("lookup(%d, %d) = %+v, want none", missing.dispid, missing.flags, sig)
+		}
+	}
+}
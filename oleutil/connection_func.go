@@ -8,3 +8,8 @@ import ole "github.com/go-ole/go-ole"
 func ConnectObject(disp *ole.IDispatch, iid *ole.GUID, idisp interface{}) (uint32, error) {
 	return 0, ole.NewError(ole.E_NOTIMPL)
 }
+
+// DisconnectObject ends a connection made by ConnectObject.
+func DisconnectObject(disp *ole.IDispatch, iid *ole.GUID, cookie uint32) error {
+	return ole.NewError(ole.E_NOTIMPL)
+}
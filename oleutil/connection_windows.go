@@ -1,58 +1,61 @@
+//go:build windows
 // +build windows
 
 package oleutil
 
 import (
-	"reflect"
-	"syscall"
-	"unsafe"
-
 	ole "github.com/go-ole/go-ole"
 )
 
 // ConnectObject creates a connection point between two services for communication.
+//
+// idisp is either an *ole.IUnknown implementing the outgoing interface, or a
+// Go value whose methods are called for the events of the same name, as with
+// NewDispatchServer.
 func ConnectObject(disp *ole.IDispatch, iid *ole.GUID, idisp interface{}) (cookie uint32, err error) {
-	unknown, err := disp.QueryInterface(ole.IID_IConnectionPointContainer)
+	var container *ole.IConnectionPointContainer
+	err = ole.QueryInterfaceAs(&disp.IUnknown, &container)
 	if err != nil {
 		return
 	}
+	defer container.Release()
 
-	container := (*ole.IConnectionPointContainer)(unsafe.Pointer(unknown))
 	var point *ole.IConnectionPoint
 	err = container.FindConnectionPoint(iid, &point)
 	if err != nil {
 		return
 	}
-	if edisp, ok := idisp.(*ole.IUnknown); ok {
-		cookie, err = point.Advise(edisp)
-		container.Release()
-		if err != nil {
-			return
-		}
+	defer point.Release()
+
+	if sink, ok := idisp.(*ole.IUnknown); ok {
+		return point.Advise(sink)
 	}
-	rv := reflect.ValueOf(disp).Elem()
-	if rv.Type().Kind() == reflect.Struct {
-		dest := &stdDispatch{}
-		dest.lpVtbl = &stdDispatchVtbl{}
-		dest.lpVtbl.pQueryInterface = syscall.NewCallback(dispQueryInterface)
-		dest.lpVtbl.pAddRef = syscall.NewCallback(dispAddRef)
-		dest.lpVtbl.pRelease = syscall.NewCallback(dispRelease)
-		dest.lpVtbl.pGetTypeInfoCount = syscall.NewCallback(dispGetTypeInfoCount)
-		dest.lpVtbl.pGetTypeInfo = syscall.NewCallback(dispGetTypeInfo)
-		dest.lpVtbl.pGetIDsOfNames = syscall.NewCallback(dispGetIDsOfNames)
-		dest.lpVtbl.pInvoke = syscall.NewCallback(dispInvoke)
-		dest.iface = disp
-		dest.iid = iid
-		cookie, err = point.Advise((*ole.IUnknown)(unsafe.Pointer(dest)))
-		container.Release()
-		if err != nil {
-			point.Release()
-			return
-		}
+
+	core, err := newDispatchCore(idisp)
+	if err != nil {
 		return
 	}
+	core.table = withSourceDispIDs(core.table, disp, iid)
+	sink := newDispatchServer(core, iid)
+	defer sink.Release()
+	return point.Advise(&sink.IUnknown)
+}
 
-	container.Release()
+// DisconnectObject ends a connection made by ConnectObject, given the cookie
+// it returned.
+func DisconnectObject(disp *ole.IDispatch, iid *ole.GUID, cookie uint32) error {
+	var container *ole.IConnectionPointContainer
+	err := ole.QueryInterfaceAs(&disp.IUnknown, &container)
+	if err != nil {
+		return err
+	}
+	defer container.Release()
 
-	return 0, ole.NewError(ole.E_INVALIDARG)
+	var point *ole.IConnectionPoint
+	err = container.FindConnectionPoint(iid, &point)
+	if err != nil {
+		return err
+	}
+	defer point.Release()
+	return point.Unadvise(cookie)
 }
@@ -0,0 +1,398 @@
+package oleutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/typelib"
+)
+
+// ObjectInfo describes the members of an automation object, as Describe
+// finds them. It marshals to JSON, and String lists it in an IDL-like form.
+type ObjectInfo struct {
+	Name    string `json:"name,omitempty"`
+	GUID    string `json:"guid,omitempty"`
+	Doc     string `json:"doc,omitempty"`
+	Library string `json:"library,omitempty"`
+
+	// Source is "typeinfo" when the members come from the type information
+	// of the object, "dispatchex" when they were enumerated with
+	// IDispatchEx.
+	Source  string       `json:"source"`
+	Members []MemberInfo `json:"members"`
+}
+
+// MemberInfo is a method or property accessor of an object. Properties have
+// a member for each of their accessors.
+type MemberInfo struct {
+	Name   string `json:"name"`
+	DispID int32  `json:"dispid"`
+
+	// Kind is "method", "propget", "propput" or "propputref" for the
+	// functions of an interface, "property" for the properties of a
+	// dispinterface, and "member" for the members of an IDispatchEx object
+	// whose properties are unknown.
+	Kind string `json:"kind"`
+
+	// Type is the type of the value the member returns to automation
+	// clients: that of its [retval] parameter for methods returning an
+	// HRESULT. It is empty for members enumerated with IDispatchEx.
+	Type   string      `json:"type,omitempty"`
+	Params []ParamInfo `json:"params,omitempty"`
+	Doc    string      `json:"doc,omitempty"`
+	Flags  []string    `json:"flags,omitempty"`
+}
+
+// ParamInfo is a parameter of a member. Default is the default value of an
+// optional parameter, when it has one.
+type ParamInfo struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Flags    []string    `json:"flags,omitempty"`
+	Optional bool        `json:"optional,omitempty"`
+	Default  interface{} `json:"default,omitempty"`
+}
+
+// Describe lists the members of disp: its methods and property accessors
+// with their parameters, dispids and help strings.
+//
+// They are read from the type information of the object. Objects without,
+// such as those of scripting engines, are enumerated with IDispatchEx, which
+// only knows the names, dispids and kinds of the members.
+func Describe(disp *ole.IDispatch) (*ObjectInfo, error) {
+	info, typeErr := describeTypeInfoOf(disp)
+	if typeErr == nil {
+		return info, nil
+	}
+	info, err := describeDispatchEx(disp)
+	if err != nil {
+		if typeErr != errNoTypeInfo {
+			return nil, typeErr
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+var errNoTypeInfo = ole.NewErrorWithDescription(ole.TYPE_E_ELEMENTNOTFOUND, "no type information")
+
+// describeTypeInfoOf describes disp from the type information it provides.
+// Only that interface and its bases are read, not the rest of their library.
+func describeTypeInfoOf(disp *ole.IDispatch) (*ObjectInfo, error) {
+	if n, err := disp.GetTypeInfoCount(); err != nil || n == 0 {
+		return nil, errNoTypeInfo
+	}
+	tinfo, err := disp.GetTypeInfo()
+	if err != nil {
+		return nil, err
+	}
+	defer tinfo.Release()
+	lib, t, err := typelib.LoadType(tinfo)
+	if err != nil {
+		return nil, err
+	}
+	return describeTypeInfo(lib, t), nil
+}
+
+// describeTypeInfo describes the interface or dispinterface t of lib,
+// members of its base interfaces first.
+func describeTypeInfo(lib *typelib.Library, t *typelib.TypeInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Name:    t.Name,
+		GUID:    t.Attr.Guid.String(),
+		Doc:     t.DocString,
+		Library: lib.Name,
+		Source:  "typeinfo",
+		Members: describeMembers(lib, t, nil),
+	}
+}
+
+func describeMembers(lib *typelib.Library, t *typelib.TypeInfo, members []MemberInfo) []MemberInfo {
+	// Bases in other libraries are IUnknown and IDispatch, whose members
+	// automation clients do not call.
+	if len(t.ImplTypes) > 0 {
+		if ref := lib.Ref(t.ImplTypes[0].Hreftype); ref != nil && ref.Type != nil && ref.Type != t &&
+			ref.Type.Attr.Typekind == t.Attr.Typekind {
+			members = describeMembers(lib, ref.Type, members)
+		}
+	}
+
+	for _, f := range t.Funcs {
+		if t.Attr.Typekind == ole.TKIND_DISPATCH && typelib.IsInheritedDispatchMember(f.Memid) {
+			continue
+		}
+		members = append(members, describeFunc(lib, f))
+	}
+	for _, v := range t.Vars {
+		member := MemberInfo{
+			Name:   v.Name,
+			DispID: v.Memid,
+			Kind:   "property",
+			Type:   lib.TypeName(&v.Type),
+			Doc:    v.DocString,
+			Flags:  flagNames(uint32(v.Flags), varFlagNames),
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+func describeFunc(lib *typelib.Library, f *typelib.Func) MemberInfo {
+	member := MemberInfo{
+		Name:   f.Name,
+		DispID: f.Memid,
+		Kind:   invokeKinds[f.InvKind],
+		Doc:    f.DocString,
+		Flags:  flagNames(uint32(f.Flags), funcFlagNames),
+	}
+	if member.Kind == "" {
+		member.Kind = "method"
+	}
+	if f.CParamsOpt == -1 {
+		member.Flags = append(member.Flags, "vararg")
+	}
+
+	member.Type = lib.TypeName(&f.Result)
+	if ole.VT(f.Result.VT) == ole.VT_HRESULT {
+		member.Type = "void"
+	}
+	for _, p := range f.Params {
+		param := ParamInfo{
+			Name:     p.Name,
+			Type:     lib.TypeName(&p.Type),
+			Flags:    flagNames(uint32(p.Flags), paramFlagNames),
+			Optional: p.Flags&(ole.PARAMFLAG_FOPT|ole.PARAMFLAG_FHASDEFAULT) != 0,
+		}
+		if p.Default != nil && p.Flags&ole.PARAMFLAG_FHASDEFAULT != 0 {
+			param.Default = p.Default.Val
+		}
+		if p.Flags&ole.PARAMFLAG_FRETVAL != 0 && ole.VT(p.Type.VT) == ole.VT_PTR && p.Type.Lptdesc != nil {
+			member.Type = lib.TypeName(p.Type.Lptdesc)
+		}
+		member.Params = append(member.Params, param)
+	}
+	return member
+}
+
+var invokeKinds = map[int32]string{
+	ole.INVOKE_FUNC:           "method",
+	ole.INVOKE_PROPERTYGET:    "propget",
+	ole.INVOKE_PROPERTYPUT:    "propput",
+	ole.INVOKE_PROPERTYPUTREF: "propputref",
+}
+
+type flagName struct {
+	flag uint32
+	name string
+}
+
+var paramFlagNames = []flagName{
+	{ole.PARAMFLAG_FIN, "in"},
+	{ole.PARAMFLAG_FOUT, "out"},
+	{ole.PARAMFLAG_FLCID, "lcid"},
+	{ole.PARAMFLAG_FRETVAL, "retval"},
+}
+
+var funcFlagNames = []flagName{
+	{ole.FUNCFLAG_FRESTRICTED, "restricted"},
+	{ole.FUNCFLAG_FSOURCE, "source"},
+	{ole.FUNCFLAG_FBINDABLE, "bindable"},
+	{ole.FUNCFLAG_FREQUESTEDIT, "requestedit"},
+	{ole.FUNCFLAG_FDISPLAYBIND, "displaybind"},
+	{ole.FUNCFLAG_FDEFAULTBIND, "defaultbind"},
+	{ole.FUNCFLAG_FHIDDEN, "hidden"},
+	{ole.FUNCFLAG_FUSESGETLASTERROR, "usesgetlasterror"},
+	{ole.FUNCFLAG_FDEFAULTCOLLELEM, "defaultcollelem"},
+	{ole.FUNCFLAG_FUIDEFAULT, "uidefault"},
+	{ole.FUNCFLAG_FNONBROWSABLE, "nonbrowsable"},
+	{ole.FUNCFLAG_FREPLACEABLE, "replaceable"},
+	{ole.FUNCFLAG_FIMMEDIATEBIND, "immediatebind"},
+}
+
+var varFlagNames = []flagName{
+	{ole.VARFLAG_FREADONLY, "readonly"},
+	{ole.VARFLAG_FSOURCE, "source"},
+	{ole.VARFLAG_FBINDABLE, "bindable"},
+	{ole.VARFLAG_FREQUESTEDIT, "requestedit"},
+	{ole.VARFLAG_FDISPLAYBIND, "displaybind"},
+	{ole.VARFLAG_FDEFAULTBIND, "defaultbind"},
+	{ole.VARFLAG_FHIDDEN, "hidden"},
+	{ole.VARFLAG_FRESTRICTED, "restricted"},
+	{ole.VARFLAG_FDEFAULTCOLLELEM, "defaultcollelem"},
+	{ole.VARFLAG_FUIDEFAULT, "uidefault"},
+	{ole.VARFLAG_FNONBROWSABLE, "nonbrowsable"},
+	{ole.VARFLAG_FREPLACEABLE, "replaceable"},
+	{ole.VARFLAG_FIMMEDIATEBIND, "immediatebind"},
+}
+
+func flagNames(flags uint32, names []flagName) (set []string) {
+	for _, name := range names {
+		if flags&name.flag != 0 {
+			set = append(set, name.name)
+		}
+	}
+	return
+}
+
+// Methods of IDispatchEx, after those of IDispatch.
+const (
+	dispexGetMemberProperties = 11
+	dispexGetMemberName       = 12
+	dispexGetNextDispID       = 13
+)
+
+// Flags of IDispatchEx.
+const (
+	fdexEnumAll       = 0x2
+	fdexPropCanGet    = 0x1
+	fdexPropCanPut    = 0x4
+	fdexPropCanPutRef = 0x10
+	fdexPropCanCall   = 0x100
+	grfdexPropCanAll  = 0x1515
+	dispidStartEnum   = -1
+)
+
+// describeDispatchEx enumerates the members of disp with IDispatchEx.
+func describeDispatchEx(disp *ole.IDispatch) (*ObjectInfo, error) {
+	var ex *ole.IUnknown
+	if err := disp.PutQueryInterface(ole.IID_IDispatchEx, &ex); err != nil {
+		return nil, err
+	}
+	defer ex.Release()
+	obj := unsafe.Pointer(ex)
+
+	info := &ObjectInfo{Source: "dispatchex", Members: []MemberInfo{}}
+	id := int32(dispidStartEnum)
+	for {
+		hr, err := ole.CallMethod(obj, dispexGetNextDispID, ole.Uint32Arg(fdexEnumAll), ole.Int32Arg(id), ole.PointerArg(unsafe.Pointer(&id)))
+		if err != nil {
+			return nil, err
+		}
+		if hr == ole.S_FALSE {
+			break
+		}
+
+		member := MemberInfo{DispID: id}
+		var name *uint16
+		if _, err := ole.CallMethod(obj, dispexGetMemberName, ole.Int32Arg(id), ole.PointerArg(unsafe.Pointer(&name))); err == nil && name != nil {
+			member.Name = ole.BstrToString(name)
+			ole.SysFreeString((*int16)(unsafe.Pointer(name)))
+		}
+		var props uint32
+		if _, err := ole.CallMethod(obj, dispexGetMemberProperties, ole.Int32Arg(id), ole.Uint32Arg(grfdexPropCanAll), ole.PointerArg(unsafe.Pointer(&props))); err != nil {
+			props = 0
+		}
+		info.Members = append(info.Members, dispatchExMembers(member, props)...)
+	}
+	return info, nil
+}
+
+// dispatchExMembers returns the accessors of member its fdexProp* props
+// tell.
+func dispatchExMembers(member MemberInfo, props uint32) (members []MemberInfo) {
+	for _, kind := range []flagName{
+		{fdexPropCanCall, "method"},
+		{fdexPropCanGet, "propget"},
+		{fdexPropCanPut, "propput"},
+		{fdexPropCanPutRef, "propputref"},
+	} {
+		if props&kind.flag != 0 {
+			m := member
+			m.Kind = kind.name
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		member.Kind = "member"
+		members = append(members, member)
+	}
+	return
+}
+
+// String lists the members of o, one per line, with their attributes in
+// the form of IDL.
+func (o *ObjectInfo) String() string {
+	var b strings.Builder
+	name := o.Name
+	if name == "" {
+		name = "object"
+	}
+	b.WriteString(name)
+	if o.GUID != "" {
+		b.WriteString(" " + o.GUID)
+	}
+	if o.Library != "" {
+		b.WriteString(" in " + o.Library)
+	}
+	b.WriteString("\n")
+	if o.Doc != "" {
+		fmt.Fprintf(&b, "\t// %s\n", o.Doc)
+	}
+	for i := range o.Members {
+		b.WriteString("\t" + o.Members[i].declaration(o.Source == "typeinfo") + "\n")
+	}
+	return b.String()
+}
+
+// declaration returns m as a line of IDL; typed tells whether the types and
+// parameters of m are known.
+func (m *MemberInfo) declaration(typed bool) string {
+	attrs := []string{fmt.Sprintf("id(0x%08x)", uint32(m.DispID))}
+	if m.Kind != "method" && m.Kind != "property" {
+		attrs = append(attrs, m.Kind)
+	}
+	attrs = append(attrs, m.Flags...)
+	s := "[" + strings.Join(attrs, ", ") + "] "
+	if !typed {
+		return s + m.Name
+	}
+	s += m.Type + " " + m.Name
+	if m.Kind != "property" {
+		s += "(" + strings.Join(m.paramDeclarations(), ", ") + ")"
+	}
+	if m.Doc != "" {
+		s += " // " + m.Doc
+	}
+	return s
+}
+
+// paramDeclarations returns the parameters of m callers pass, in IDL.
+func (m *MemberInfo) paramDeclarations() (params []string) {
+	for _, p := range m.Params {
+		if hasFlag(p.Flags, "retval") || hasFlag(p.Flags, "lcid") {
+			continue
+		}
+		var attrs []string
+		if hasFlag(p.Flags, "out") {
+			attrs = append(attrs, p.Flags...)
+		}
+		if p.Optional {
+			attrs = append(attrs, "optional")
+		}
+		param := p.Type + " " + p.Name
+		if len(attrs) > 0 {
+			param = "[" + strings.Join(attrs, ", ") + "] " + param
+		}
+		if p.Default != nil {
+			if str, ok := p.Default.(string); ok {
+				param += " = " + strconv.Quote(str)
+			} else {
+				param += fmt.Sprintf(" = %v", p.Default)
+			}
+		}
+		params = append(params, param)
+	}
+	return
+}
+
+func hasFlag(flags []string, name string) bool {
+	for _, flag := range flags {
+		if flag == name {
+			return true
+		}
+	}
+	return false
+}
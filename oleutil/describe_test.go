@@ -0,0 +1,111 @@
+package oleutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-ole/go-ole/typelib"
+)
+
+func TestDescribeTypeInfo(t *testing.T) {
+	lib, err := typelib.ReadFile("../typelib/testdata/test.tlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := describeTypeInfo(lib, lib.Type("ICalculator"))
+	if info.Name != "ICalculator" || info.GUID != "{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E02}" ||
+		info.Doc != "Calculator interface" || info.Library != "GoOleTest" || info.Source != "typeinfo" {
+		t.Errorf("info = %+v", info)
+	}
+	want := []MemberInfo{
+		{Name: "Add", DispID: 0x60020000, Kind: "method", Type: "long", Doc: "Adds two numbers", Params: []ParamInfo{
+			{Name: "a", Type: "long", Flags: []string{"in"}},
+			{Name: "b", Type: "long", Flags: []string{"in"}},
+			{Name: "result", Type: "long*", Flags: []string{"out", "retval"}},
+		}},
+		{Name: "Total", DispID: 1, Kind: "propget", Type: "double", Params: []ParamInfo{
+			{Name: "value", Type: "double*", Flags: []string{"out", "retval"}},
+		}},
+		{Name: "Total", DispID: 1, Kind: "propput", Type: "void", Params: []ParamInfo{
+			{Name: "value", Type: "double", Flags: []string{"in"}},
+		}},
+		{Name: "Move", DispID: 0x60020003, Kind: "method", Type: "void", Params: []ParamInfo{
+			{Name: "to", Type: "Point*", Flags: []string{"in"}},
+			{Name: "steps", Type: "long", Flags: []string{"in"}, Optional: true, Default: int32(1)},
+			{Name: "mode", Type: "BSTR", Flags: []string{"in"}, Optional: true, Default: "fast"},
+		}},
+		{Name: "Draw", DispID: 0x60020004, Kind: "method", Type: "IDispatch*", Params: []ParamInfo{
+			{Name: "shapes", Type: "SAFEARRAY(VARIANT)", Flags: []string{"in"}},
+			{Name: "canvas", Type: "IDispatch**", Flags: []string{"out", "retval"}},
+		}},
+	}
+	if !reflect.DeepEqual(info.Members, want) {
+		t.Errorf("members = %+v, want %+v", info.Members, want)
+	}
+
+	lines := strings.Split(info.String(), "\n")
+	wantLines := []string{
+		"ICalculator {5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E02} in GoOleTest",
+		"\t// Calculator interface",
+		"\t[id(0x60020000)] long Add(long a, long b) // Adds two numbers",
+		"\t[id(0x00000001), propget] double Total()",
+		"\t[id(0x00000001), propput] void Total(double value)",
+		`	[id(0x60020003)] void Move(Point* to, [optional] long steps = 1, [optional] BSTR mode = "fast")`,
+		"\t[id(0x60020004)] IDispatch* Draw(SAFEARRAY(VARIANT) shapes)",
+		"",
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("String() = %q, want %q", lines, wantLines)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ObjectInfo
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Members) != len(want) || decoded.Members[3].Params[2].Default != "fast" {
+		t.Errorf("JSON round trip = %s", data)
+	}
+	if !strings.Contains(string(data), `{"name":"steps","type":"long","flags":["in"],"optional":true,"default":1}`) {
+		t.Errorf("JSON = %s", data)
+	}
+
+	events := describeTypeInfo(lib, lib.Type("_CalculatorEvents"))
+	wantEvents := []MemberInfo{
+		{Name: "Done", DispID: 1, Kind: "method", Type: "void", Params: []ParamInfo{
+			{Name: "result", Type: "long", Flags: []string{"in"}},
+		}},
+		{Name: "Busy", DispID: 2, Kind: "property", Type: "VARIANT_BOOL", Flags: []string{"readonly"}},
+	}
+	if !reflect.DeepEqual(events.Members, wantEvents) {
+		t.Errorf("members = %+v, want %+v", events.Members, wantEvents)
+	}
+	if s := events.String(); !strings.Contains(s, "\t[id(0x00000002), readonly] VARIANT_BOOL Busy\n") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestDispatchExMembers(t *testing.T) {
+	member := MemberInfo{Name: "x", DispID: 5}
+	var kinds []string
+	for _, m := range dispatchExMembers(member, fdexPropCanGet|fdexPropCanPut|0x2) {
+		kinds = append(kinds, m.Kind)
+	}
+	if !reflect.DeepEqual(kinds, []string{"propget", "propput"}) {
+		t.Errorf("kinds = %v", kinds)
+	}
+	if m := dispatchExMembers(member, 0); len(m) != 1 || m[0].Kind != "member" {
+		t.Errorf("members = %+v", m)
+	}
+
+	info := &ObjectInfo{Source: "dispatchex", Members: []MemberInfo{{Name: "go", DispID: 7, Kind: "method"}}}
+	if s := info.String(); s != "object\n\t[id(0x00000007)] go\n" {
+		t.Errorf("String() = %q", s)
+	}
+}
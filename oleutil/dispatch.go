@@ -0,0 +1,427 @@
+package oleutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// ParamNamer can be implemented by values served through NewDispatchServer to
+// name the parameters of their methods. Named parameters let clients pass
+// arguments by name, e.g. `obj.Open FileName:="x"` in VBA.
+type ParamNamer interface {
+	ParamNames(method string) []string
+}
+
+// DispIDMapper can be implemented by values served through NewDispatchServer
+// to choose the DISPIDs of their members, as event sinks must when the
+// outgoing interface fixes them. Members missing from the map keep the
+// DISPID assigned by name.
+type DispIDMapper interface {
+	DispIDs() map[string]int32
+}
+
+// memberKind tells how a member of a Go value is reached.
+type memberKind int
+
+const (
+	memberMethod memberKind = iota
+	memberField
+	memberCount
+	memberItem
+	memberEnum
+)
+
+// dispatchMember is a member of a Go value exposed through IDispatch.
+type dispatchMember struct {
+	name     string
+	dispid   int32
+	kind     memberKind
+	index    int
+	field    []int
+	readonly bool
+	params   []string
+}
+
+// dispatchTable maps the members of a Go type to DISPIDs.
+type dispatchTable struct {
+	members []*dispatchMember
+	byID    map[int32]*dispatchMember
+	byName  map[string]*dispatchMember
+}
+
+var dispatchTables sync.Map
+
+// dispatchTableOf returns the member table of the type, building it on first
+// use.
+//
+// Exported methods are members, numbered from 1 in name order together with
+// the exported fields of structs. Methods may return nothing, a value, an
+// error, or a value and an error; methods with other results are not exposed.
+// Slices and maps are collections, with the Count, Item and _NewEnum members
+// automation clients expect.
+func dispatchTableOf(t reflect.Type) *dispatchTable {
+	if table, ok := dispatchTables.Load(t); ok {
+		return table.(*dispatchTable)
+	}
+
+	table := &dispatchTable{}
+	seen := map[string]bool{}
+	add := func(member *dispatchMember) {
+		key := strings.ToLower(member.name)
+		if !seen[key] {
+			seen[key] = true
+			table.members = append(table.members, member)
+		}
+	}
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if method.PkgPath != "" || isHookMethod(t, method.Name) || !dispatchableResults(method.Type) {
+			continue
+		}
+		add(&dispatchMember{name: method.Name, kind: memberMethod, index: i})
+	}
+
+	elem := t
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	collection := isCollection(elem)
+	switch {
+	case elem.Kind() == reflect.Struct && elem != timeType && elem != variantType:
+		for _, field := range structFields(elem, nil) {
+			add(field)
+		}
+	case collection:
+		add(&dispatchMember{name: "Count", kind: memberCount, readonly: true})
+	}
+
+	sort.SliceStable(table.members, func(i, j int) bool {
+		return table.members[i].name < table.members[j].name
+	})
+	for i, member := range table.members {
+		member.dispid = int32(i + 1)
+	}
+	if collection {
+		add(&dispatchMember{name: "Item", kind: memberItem, dispid: ole.DISPID_VALUE})
+		add(&dispatchMember{name: "_NewEnum", kind: memberEnum, dispid: ole.DISPID_NEWENUM, readonly: true})
+	}
+	table.index()
+
+	actual, _ := dispatchTables.LoadOrStore(t, table)
+	return actual.(*dispatchTable)
+}
+
+// index builds the lookup maps. Names are case-insensitive, as in Visual
+// Basic; the first member wins when two names only differ in case.
+func (table *dispatchTable) index() {
+	table.byID = make(map[int32]*dispatchMember, len(table.members))
+	table.byName = make(map[string]*dispatchMember, len(table.members))
+	for _, member := range table.members {
+		table.byID[member.dispid] = member
+		key := strings.ToLower(member.name)
+		if _, ok := table.byName[key]; !ok {
+			table.byName[key] = member
+		}
+	}
+}
+
+// withDispIDs returns a copy of the table with DISPIDs assigned by lookup,
+// used when the DISPIDs are dictated by an existing interface such as an
+// event interface. Members lookup does not know keep their DISPID.
+func (table *dispatchTable) withDispIDs(lookup func(name string) (int32, bool)) *dispatchTable {
+	remapped := &dispatchTable{}
+	for _, member := range table.members {
+		copied := *member
+		if dispid, ok := lookup(member.name); ok {
+			copied.dispid = dispid
+		}
+		remapped.members = append(remapped.members, &copied)
+	}
+	remapped.index()
+	return remapped
+}
+
+var (
+	paramNamerType   = reflect.TypeOf((*ParamNamer)(nil)).Elem()
+	dispIDMapperType = reflect.TypeOf((*DispIDMapper)(nil)).Elem()
+	eventSourcerType = reflect.TypeOf((*EventSourcer)(nil)).Elem()
+)
+
+// isHookMethod reports whether the method implements one of the interfaces
+// through which served values configure their server, rather than being a
+// member for clients.
+func isHookMethod(t reflect.Type, name string) bool {
+	switch name {
+	case "ParamNames":
+		return t.Implements(paramNamerType)
+	case "DispIDs":
+		return t.Implements(dispIDMapperType)
+	case "Events":
+		return t.Implements(eventSourcerType)
+	}
+	return false
+}
+
+// dispatchableResults reports whether a method's results can be returned
+// through IDispatch.Invoke.
+func dispatchableResults(method reflect.Type) bool {
+	switch method.NumOut() {
+	case 0, 1:
+		return true
+	case 2:
+		return method.Out(1) == errorType
+	}
+	return false
+}
+
+// dispatchCore implements IDispatch for a Go value independently of the COM
+// plumbing, so that it can be exercised with synthetic DISPPARAMS.
+type dispatchCore struct {
+	value  reflect.Value
+	table  *dispatchTable
+	source string
+}
+
+func newDispatchCore(value interface{}) (*dispatchCore, error) {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		return nil, ole.NewError(ole.E_INVALIDARG)
+	}
+	return newValueCore(rv), nil
+}
+
+// newValueCore returns the core serving rv.
+func newValueCore(rv reflect.Value) *dispatchCore {
+	core := &dispatchCore{
+		value:  rv,
+		table:  dispatchTableOf(rv.Type()),
+		source: rv.Type().String(),
+	}
+	if rv.CanInterface() {
+		if namer, ok := rv.Interface().(ParamNamer); ok {
+			core.table = core.table.withParamNames(namer)
+		}
+		if mapper, ok := rv.Interface().(DispIDMapper); ok {
+			dispids := mapper.DispIDs()
+			core.table = core.table.withDispIDs(func(name string) (int32, bool) {
+				dispid, ok := dispids[name]
+				return dispid, ok
+			})
+		}
+	}
+	return core
+}
+
+// withParamNames returns a copy of the table with parameter names supplied by
+// namer.
+func (table *dispatchTable) withParamNames(namer ParamNamer) *dispatchTable {
+	named := &dispatchTable{}
+	for _, member := range table.members {
+		copied := *member
+		copied.params = namer.ParamNames(member.name)
+		named.members = append(named.members, &copied)
+	}
+	named.index()
+	return named
+}
+
+// getIDsOfNames maps a member name and its parameter names to DISPIDs.
+// Parameters are numbered by position from 0.
+func (d *dispatchCore) getIDsOfNames(names []string, dispids []int32) uintptr {
+	for i := range dispids {
+		dispids[i] = ole.DISPID_UNKNOWN
+	}
+	if len(names) == 0 {
+		return ole.S_OK
+	}
+	member, ok := d.table.byName[strings.ToLower(names[0])]
+	if !ok {
+		return ole.DISP_E_UNKNOWNNAME
+	}
+	dispids[0] = member.dispid
+
+	hr := uintptr(ole.S_OK)
+	for i, name := range names[1:] {
+		found := false
+		for position, param := range member.params {
+			if strings.EqualFold(param, name) {
+				dispids[i+1] = int32(position)
+				found = true
+				break
+			}
+		}
+		if !found {
+			hr = ole.DISP_E_UNKNOWNNAME
+		}
+	}
+	return hr
+}
+
+// invoke calls the member dispid with the arguments in params, storing the
+// return value in result and a failure in excepInfo. Both may be nil.
+func (d *dispatchCore) invoke(dispid int32, flags uint16, params *ole.DISPPARAMS, result *ole.VARIANT, excepInfo *ole.EXCEPINFO, argErr *uint32) (hr uintptr) {
+	member, ok := d.table.byID[dispid]
+	if !ok {
+		return ole.DISP_E_MEMBERNOTFOUND
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			hr = d.exception(ole.NewErrorWithDescription(ole.E_UNEXPECTED, fmt.Sprint(r)), excepInfo)
+		}
+	}()
+	switch member.kind {
+	case memberField:
+		return d.property(member, flags, params, result, excepInfo, argErr)
+	case memberCount, memberItem, memberEnum:
+		return d.collection(member, flags, params, result, excepInfo, argErr)
+	}
+	if flags&(ole.DISPATCH_METHOD|ole.DISPATCH_PROPERTYGET) == 0 {
+		return ole.DISP_E_MEMBERNOTFOUND
+	}
+	return d.call(member, params, result, excepInfo, argErr)
+}
+
+// call calls a method member.
+func (d *dispatchCore) call(member *dispatchMember, params *ole.DISPPARAMS, result *ole.VARIANT, excepInfo *ole.EXCEPINFO, argErr *uint32) uintptr {
+	method := d.value.Method(member.index)
+	args, byRefs, hr := bindArguments(method.Type(), params, argErr)
+	if hr != ole.S_OK {
+		return hr
+	}
+	out := method.Call(args)
+
+	if n := len(out); n > 0 && method.Type().Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			return d.exception(err, excepInfo)
+		}
+		out = out[:n-1]
+	}
+	for _, byRef := range byRefs {
+		if err := storeByRef(byRef.variant, byRef.value.Elem()); err != nil {
+			return d.exception(err, excepInfo)
+		}
+	}
+	if len(out) == 0 {
+		return ole.S_OK
+	}
+	return d.result(out[0], result, excepInfo)
+}
+
+// exception reports err to the client. The HRESULT of an *ole.OleError, or of
+// any error with a Code method, is used as the SCODE; E_FAIL otherwise.
+func (d *dispatchCore) exception(err error, excepInfo *ole.EXCEPINFO) uintptr {
+	scode := uint32(ole.E_FAIL)
+	if coded, ok := err.(interface{ Code() uintptr }); ok {
+		scode = uint32(coded.Code())
+	}
+	if excepInfo == nil {
+		return uintptr(scode)
+	}
+	description := err.Error()
+	if oleErr, ok := err.(*ole.OleError); ok && oleErr.Description() != "" {
+		description = oleErr.Description()
+	}
+	excepInfo.SetError(scode, d.source, description)
+	return ole.DISP_E_EXCEPTION
+}
+
+// byRefArgument is a pointer parameter whose value is written back to a
+// VT_BYREF argument once the call returns.
+type byRefArgument struct {
+	variant *ole.VARIANT
+	value   reflect.Value
+}
+
+// bindArguments converts the arguments in params to the parameters of a
+// function of type fn.
+//
+// Positional arguments fill parameters from the first; named arguments are
+// identified by parameter position. Parameters of pointer and interface type
+// are optional. On failure argErr receives the index of the offending
+// argument in params.
+func bindArguments(fn reflect.Type, params *ole.DISPPARAMS, argErr *uint32) ([]reflect.Value, []byRefArgument, uintptr) {
+	var vargs []ole.VARIANT
+	var named []int32
+	if params != nil {
+		vargs = params.Args()
+		named = params.NamedArgs()
+	}
+	positional := len(vargs) - len(named)
+	if positional < 0 {
+		return nil, nil, ole.E_INVALIDARG
+	}
+
+	count := fn.NumIn()
+	if fn.IsVariadic() {
+		count--
+		if positional > count {
+			count = positional
+		}
+	}
+	if positional > count {
+		return nil, nil, ole.DISP_E_BADPARAMCOUNT
+	}
+
+	// slots holds the rgvarg index of the argument of each parameter, or -1.
+	slots := make([]int, count)
+	for i := range slots {
+		slots[i] = -1
+	}
+	for i := 0; i < positional; i++ {
+		slots[i] = len(vargs) - 1 - i
+	}
+	for i, dispid := range named {
+		if dispid < 0 || int(dispid) >= count || slots[dispid] != -1 {
+			setArgErr(argErr, i)
+			return nil, nil, ole.DISP_E_PARAMNOTFOUND
+		}
+		slots[dispid] = i
+	}
+
+	args := make([]reflect.Value, count)
+	var byRefs []byRefArgument
+	for i, slot := range slots {
+		t := parameterType(fn, i)
+		if slot == -1 || isMissing(&vargs[slot]) {
+			if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+				return nil, nil, ole.DISP_E_PARAMNOTOPTIONAL
+			}
+			args[i] = reflect.Zero(t)
+			continue
+		}
+		value, err := valueFromVariant(&vargs[slot], t)
+		if err != nil {
+			setArgErr(argErr, slot)
+			if oleErr, ok := err.(*ole.OleError); ok {
+				return nil, nil, oleErr.Code()
+			}
+			return nil, nil, ole.DISP_E_TYPEMISMATCH
+		}
+		args[i] = value
+		if vargs[slot].VT&ole.VT_BYREF != 0 && t.Kind() == reflect.Ptr && !value.IsNil() {
+			byRefs = append(byRefs, byRefArgument{variant: &vargs[slot], value: value})
+		}
+	}
+	return args, byRefs, ole.S_OK
+}
+
+// parameterType returns the type of parameter i, which for variadic
+// functions may be one of the variadic arguments.
+func parameterType(fn reflect.Type, i int) reflect.Type {
+	if fn.IsVariadic() && i >= fn.NumIn()-1 {
+		return fn.In(fn.NumIn() - 1).Elem()
+	}
+	return fn.In(i)
+}
+
+func setArgErr(argErr *uint32, index int) {
+	if argErr != nil {
+		*argErr = uint32(index)
+	}
+}
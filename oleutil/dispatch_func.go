@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package oleutil
+
+import ole "github.com/go-ole/go-ole"
+
+// NewDispatchServer exposes the exported methods of value to COM clients
+// through IDispatch.
+func NewDispatchServer(value interface{}) (*ole.IDispatch, error) {
+	return nil, ole.NewError(ole.E_NOTIMPL)
+}
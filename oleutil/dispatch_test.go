@@ -0,0 +1,251 @@
+package oleutil
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+type calculator struct {
+	total int32
+}
+
+func (c *calculator) Add(a, b int32) int32 { return a + b }
+
+func (c *calculator) Divide(a, b int32) (int32, error) {
+	if b == 0 {
+		return 0, ole.NewErrorWithDescription(ole.DISP_E_DIVBYZERO, "division by zero")
+	}
+	return a / b, nil
+}
+
+func (c *calculator) Fail() error { return errors.New("failed") }
+
+func (c *calculator) Panic() { panic("boom") }
+
+func (c *calculator) Accumulate(value int32, scale *int32) {
+	if scale != nil {
+		value *= *scale
+	}
+	c.total += value
+}
+
+func (c *calculator) Double(value *int32) { *value *= 2 }
+
+func (c *calculator) Sum(values ...int32) (sum int32) {
+	for _, value := range values {
+		sum += value
+	}
+	return
+}
+
+func (c *calculator) Total() int32 { return c.total }
+
+func (c *calculator) ParamNames(method string) []string {
+	if method == "Accumulate" {
+		return []string{"Value", "Scale"}
+	}
+	return nil
+}
+
+func i4(value int32) ole.VARIANT { return ole.NewVariant(ole.VT_I4, int64(value)) }
+
+// invokeCore calls member name of core with positional arguments in call
+// order.
+func invokeCore(t *testing.T, core *dispatchCore, name string, args ...ole.VARIANT) (ole.VARIANT, uintptr, ole.EXCEPINFO) {
+	dispids := make([]int32, 1)
+	if hr := core.getIDsOfNames([]string{name}, dispids); hr != ole.S_OK {
+		t.Fatalf("getIDsOfNames(%q) = %#x", name, hr)
+	}
+	reversed := make([]ole.VARIANT, len(args))
+	for i := range args {
+		reversed[len(args)-1-i] = args[i]
+	}
+	params := ole.NewDISPPARAMS(reversed, nil)
+	var result ole.VARIANT
+	var excepInfo ole.EXCEPINFO
+	hr := core.invoke(dispids[0], ole.DISPATCH_METHOD, &params, &result, &excepInfo, nil)
+	return result, hr, excepInfo
+}
+
+func TestDispatchCore_positional(t *testing.T) {
+	core, err := newDispatchCore(&calculator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, hr, _ := invokeCore(t, core, "add", i4(2), i4(40))
+	if hr != ole.S_OK || result.VT != ole.VT_I4 || result.Val != 42 {
+		t.Errorf("Add(2, 40) = %v %d, hr %#x", result.VT, result.Val, hr)
+	}
+
+	result, hr, _ = invokeCore(t, core, "Divide", i4(9), ole.NewVariant(ole.VT_R8, 0x4008000000000000))
+	if hr != ole.S_OK || result.Val != 3 {
+		t.Errorf("Divide(9, 3.0) = %d, hr %#x", result.Val, hr)
+	}
+
+	result, hr, _ = invokeCore(t, core, "Sum", i4(1), i4(2), i4(3))
+	if hr != ole.S_OK || result.Val != 6 {
+		t.Errorf("Sum(1, 2, 3) = %d, hr %#x", result.Val, hr)
+	}
+}
+
+func TestDispatchCore_named(t *testing.T) {
+	c := &calculator{}
+	core, err := newDispatchCore(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispids := make([]int32, 3)
+	if hr := core.getIDsOfNames([]string{"Accumulate", "scale", "value"}, dispids); hr != ole.S_OK {
+		t.Fatalf("getIDsOfNames = %#x", hr)
+	}
+	if dispids[1] != 1 || dispids[2] != 0 {
+		t.Fatalf("parameter DISPIDs = %v", dispids[1:])
+	}
+
+	args := []ole.VARIANT{i4(3), i4(5)}
+	params := ole.NewDISPPARAMS(args, dispids[1:])
+	if hr := core.invoke(dispids[0], ole.DISPATCH_METHOD, &params, nil, nil, nil); hr != ole.S_OK {
+		t.Fatalf("Accumulate(Scale:=3, Value:=5) = %#x", hr)
+	}
+	if c.total != 15 {
+		t.Errorf("total = %d, expected 15", c.total)
+	}
+
+	// The optional Scale is omitted.
+	if _, hr, _ := invokeCore(t, core, "Accumulate", i4(1)); hr != ole.S_OK || c.total != 16 {
+		t.Errorf("Accumulate(1) = %#x, total %d", hr, c.total)
+	}
+
+	if hr := core.getIDsOfNames([]string{"Accumulate", "Factor"}, dispids[:2]); hr != ole.DISP_E_UNKNOWNNAME || dispids[1] != ole.DISPID_UNKNOWN {
+		t.Errorf("getIDsOfNames with unknown parameter = %#x, %d", hr, dispids[1])
+	}
+}
+
+func TestDispatchCore_errors(t *testing.T) {
+	core, err := newDispatchCore(&calculator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures := []struct {
+		Name  string
+		Args  []ole.VARIANT
+		HR    uintptr
+		SCODE uint32
+	}{
+		{"Divide", []ole.VARIANT{i4(1), i4(0)}, ole.DISP_E_EXCEPTION, ole.DISP_E_DIVBYZERO},
+		{"Fail", nil, ole.DISP_E_EXCEPTION, ole.E_FAIL},
+		{"Panic", nil, ole.DISP_E_EXCEPTION, ole.E_UNEXPECTED},
+		{"Add", []ole.VARIANT{i4(1)}, ole.DISP_E_PARAMNOTOPTIONAL, 0},
+		{"Add", []ole.VARIANT{i4(1), i4(2), i4(3)}, ole.DISP_E_BADPARAMCOUNT, 0},
+		{"Add", []ole.VARIANT{i4(1), ole.NewVariant(ole.VT_I8, 1<<40)}, ole.DISP_E_OVERFLOW, 0},
+		{"Add", []ole.VARIANT{i4(1), ole.NewVariant(ole.VT_RECORD, 0)}, ole.DISP_E_BADVARTYPE, 0},
+	}
+	for _, f := range fixtures {
+		_, hr, excepInfo := invokeCore(t, core, f.Name, f.Args...)
+		if hr != f.HR {
+			t.Errorf("%s%v returned %#x, expected %#x", f.Name, f.Args, hr, f.HR)
+		}
+		if excepInfo.SCODE() != f.SCODE {
+			t.Errorf("%s%v reported SCODE %#x, expected %#x", f.Name, f.Args, excepInfo.SCODE(), f.SCODE)
+		}
+	}
+
+	dispids := []int32{0}
+	if hr := core.getIDsOfNames([]string{"Missing"}, dispids); hr != ole.DISP_E_UNKNOWNNAME || dispids[0] != ole.DISPID_UNKNOWN {
+		t.Errorf("getIDsOfNames(Missing) = %#x, %d", hr, dispids[0])
+	}
+	if hr := core.invoke(1000, ole.DISPATCH_METHOD, nil, nil, nil, nil); hr != ole.DISP_E_MEMBERNOTFOUND {
+		t.Errorf("invoke of unknown DISPID = %#x", hr)
+	}
+
+	// Without EXCEPINFO the SCODE is returned directly.
+	core.getIDsOfNames([]string{"Fail"}, dispids)
+	if hr := core.invoke(dispids[0], ole.DISPATCH_METHOD, nil, nil, nil, nil); hr != ole.E_FAIL {
+		t.Errorf("Fail without EXCEPINFO = %#x", hr)
+	}
+}
+
+func TestDispatchCore_argErr(t *testing.T) {
+	core, err := newDispatchCore(&calculator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispids := []int32{0}
+	core.getIDsOfNames([]string{"Add"}, dispids)
+
+	// rgvarg is in reverse order: the second argument is at index 0.
+	args := []ole.VARIANT{ole.NewVariant(ole.VT_RECORD, 0), i4(1)}
+	params := ole.NewDISPPARAMS(args, nil)
+	argErr := uint32(99)
+	core.invoke(dispids[0], ole.DISPATCH_METHOD, &params, nil, nil, &argErr)
+	if argErr != 0 {
+		t.Errorf("argErr = %d, expected 0", argErr)
+	}
+}
+
+func TestDispatchCore_byRef(t *testing.T) {
+	core, err := newDispatchCore(&calculator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := int32(21)
+	var arg ole.VARIANT
+	arg.VT = ole.VT_BYREF | ole.VT_I4
+	*(**int32)(unsafe.Pointer(&arg.Val)) = &value
+
+	if _, hr, _ := invokeCore(t, core, "Double", arg); hr != ole.S_OK {
+		t.Fatalf("Double = %#x", hr)
+	}
+	if value != 42 {
+		t.Errorf("by reference argument = %d, expected 42", value)
+	}
+}
+
+func TestDispatchTable_withDispIDs(t *testing.T) {
+	core, err := newDispatchCore(&calculator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := core.table.withDispIDs(func(name string) (int32, bool) {
+		if name == "Total" {
+			return 7, true
+		}
+		return 0, false
+	})
+
+	if table.byID[7] == nil || table.byID[7].name != "Total" {
+		t.Error("remapped DISPID not found")
+	}
+	if core.table.byName["total"].dispid == 7 {
+		t.Error("remapping changed the original table")
+	}
+}
+
+type mappedSink struct{}
+
+func (mappedSink) Done(result int32) {}
+
+func (mappedSink) Busy() bool { return false }
+
+func (mappedSink) DispIDs() map[string]int32 { return map[string]int32{"Done": 1, "Busy": 2} }
+
+func TestDispatchCore_dispIDMapper(t *testing.T) {
+	core, err := newDispatchCore(mappedSink{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.table.byName["dispids"] != nil {
+		t.Error("DispIDs served as a member")
+	}
+	if core.table.byID[1] == nil || core.table.byID[1].name != "Done" ||
+		core.table.byID[2] == nil || core.table.byID[2].name != "Busy" {
+		t.Errorf("DISPIDs not mapped: %+v", core.table.byID)
+	}
+}
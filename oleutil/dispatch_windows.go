@@ -0,0 +1,203 @@
+//go:build windows
+// +build windows
+
+package oleutil
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// dispatchServer is the COM object handed out by NewDispatchServer. The
+// vtable pointer must stay the first field.
+type dispatchServer struct {
+	lpVtbl *ole.IDispatchVtbl
+	ref    int32
+	core   *dispatchCore
+	iids   []*ole.GUID
+
+	typeInfo     *ole.ITypeInfo
+	typeInfoOnce sync.Once
+
+	container containerTearOff
+	classInfo classInfoTearOff
+}
+
+var (
+	dispatchServerVtbl     *ole.IDispatchVtbl
+	dispatchServerVtblOnce sync.Once
+
+	// liveDispatchServers keeps servers reachable by the garbage collector
+	// while COM clients hold references to them.
+	liveDispatchServers   = map[*dispatchServer]struct{}{}
+	liveDispatchServersMu sync.Mutex
+)
+
+func init() {
+	newObjectServer = func(core *dispatchCore) *ole.IDispatch {
+		return newDispatchServer(core)
+	}
+}
+
+// NewDispatchServer exposes the exported methods of value to COM clients
+// through IDispatch.
+//
+// Exported struct fields are properties, named and made read-only by the
+// `ole:"Name,readonly"` struct tag. Structs, slices and maps returned by
+// members are served as sub-objects and collections in turn.
+//
+// Type information describing the members is synthesized from the Go type,
+// for clients such as VBA and PowerShell that rely on GetTypeInfo.
+//
+// Methods are called with DISPATCH_METHOD or DISPATCH_PROPERTYGET. Arguments
+// may be passed by position or, when value implements ParamNamer, by name.
+// A returned error, or a panic, is reported to the client as
+// DISP_E_EXCEPTION with the error text in EXCEPINFO.
+//
+// When value implements EventSourcer, the object is also an
+// IConnectionPointContainer with a connection point for each outgoing
+// interface, and sinks advised there are called by EventSource.Fire. If
+// class information is set on the EventSource, the object implements
+// IProvideClassInfo2 as well.
+//
+// The returned object has a reference count of one; call Release when done.
+func NewDispatchServer(value interface{}) (*ole.IDispatch, error) {
+	core, err := newDispatchCore(value)
+	if err != nil {
+		return nil, err
+	}
+	return newDispatchServer(core), nil
+}
+
+// newDispatchServer wraps core in a COM object answering QueryInterface for
+// IUnknown, IDispatch and iids.
+func newDispatchServer(core *dispatchCore, iids ...*ole.GUID) *ole.IDispatch {
+	dispatchServerVtblOnce.Do(func() {
+		dispatchServerVtbl = &ole.IDispatchVtbl{}
+		dispatchServerVtbl.QueryInterface = syscall.NewCallback(dispatchServerQueryInterface)
+		dispatchServerVtbl.AddRef = syscall.NewCallback(dispatchServerAddRef)
+		dispatchServerVtbl.Release = syscall.NewCallback(dispatchServerRelease)
+		dispatchServerVtbl.GetTypeInfoCount = syscall.NewCallback(dispatchServerGetTypeInfoCount)
+		dispatchServerVtbl.GetTypeInfo = syscall.NewCallback(dispatchServerGetTypeInfo)
+		dispatchServerVtbl.GetIDsOfNames = syscall.NewCallback(dispatchServerGetIDsOfNames)
+		dispatchServerVtbl.Invoke = syscall.NewCallback(dispatchServerInvoke)
+	})
+
+	server := &dispatchServer{
+		lpVtbl: dispatchServerVtbl,
+		ref:    1,
+		core:   core,
+		iids:   iids,
+	}
+	liveDispatchServersMu.Lock()
+	liveDispatchServers[server] = struct{}{}
+	liveDispatchServersMu.Unlock()
+	lockModule()
+	return (*ole.IDispatch)(unsafe.Pointer(server))
+}
+
+func dispatchServerQueryInterface(this *dispatchServer, iid *ole.GUID, punk **ole.IUnknown) uintptr {
+	if punk == nil {
+		return ole.E_POINTER
+	}
+	*punk = nil
+	if ole.IsEqualGUID(iid, ole.IID_IUnknown) || ole.IsEqualGUID(iid, ole.IID_IDispatch) || this.implements(iid) {
+		atomic.AddInt32(&this.ref, 1)
+		*punk = (*ole.IUnknown)(unsafe.Pointer(this))
+		return ole.S_OK
+	}
+	if tearOff := this.eventInterface(iid); tearOff != nil {
+		atomic.AddInt32(&this.ref, 1)
+		*punk = (*ole.IUnknown)(tearOff)
+		return ole.S_OK
+	}
+	return ole.E_NOINTERFACE
+}
+
+func (server *dispatchServer) implements(iid *ole.GUID) bool {
+	for _, candidate := range server.iids {
+		if ole.IsEqualGUID(iid, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func dispatchServerAddRef(this *dispatchServer) uintptr {
+	return uintptr(atomic.AddInt32(&this.ref, 1))
+}
+
+func dispatchServerRelease(this *dispatchServer) uintptr {
+	ref := atomic.AddInt32(&this.ref, -1)
+	if ref == 0 {
+		if this.typeInfo != nil {
+			this.typeInfo.Release()
+		}
+		liveDispatchServersMu.Lock()
+		delete(liveDispatchServers, this)
+		liveDispatchServersMu.Unlock()
+		unlockModule()
+	}
+	return uintptr(ref)
+}
+
+// getTypeInfo returns the type information synthesized from the served Go
+// type, or nil when it could not be created.
+func (server *dispatchServer) getTypeInfo() *ole.ITypeInfo {
+	server.typeInfoOnce.Do(func() {
+		data := newInterfaceData(describe(server.core.value.Type(), server.core.table))
+		unknown, err := ole.CreateDispTypeInfo(&data.data)
+		if err == nil {
+			server.typeInfo = (*ole.ITypeInfo)(unsafe.Pointer(unknown))
+		}
+	})
+	return server.typeInfo
+}
+
+func dispatchServerGetTypeInfoCount(this *dispatchServer, count *uint32) uintptr {
+	if count == nil {
+		return ole.E_POINTER
+	}
+	*count = 0
+	if this.getTypeInfo() != nil {
+		*count = 1
+	}
+	return ole.S_OK
+}
+
+func dispatchServerGetTypeInfo(this *dispatchServer, index uint32, lcid uint32, info **ole.ITypeInfo) uintptr {
+	if info == nil {
+		return ole.E_POINTER
+	}
+	*info = nil
+	typeInfo := this.getTypeInfo()
+	if index != 0 || typeInfo == nil {
+		return ole.DISP_E_BADINDEX
+	}
+	typeInfo.AddRef()
+	*info = typeInfo
+	return ole.S_OK
+}
+
+func dispatchServerGetIDsOfNames(this *dispatchServer, iid *ole.GUID, names **uint16, count uint32, lcid uint32, dispids *int32) uintptr {
+	if count == 0 {
+		return ole.S_OK
+	}
+	if names == nil || dispids == nil {
+		return ole.E_POINTER
+	}
+	wnames := (*[1 << 20]*uint16)(unsafe.Pointer(names))[:count:count]
+	goNames := make([]string, count)
+	for i, name := range wnames {
+		goNames[i] = ole.LpOleStrToString(name)
+	}
+	return this.core.getIDsOfNames(goNames, (*[1 << 20]int32)(unsafe.Pointer(dispids))[:count:count])
+}
+
+func dispatchServerInvoke(this *dispatchServer, dispid int32, iid *ole.GUID, lcid uint32, flags uint16, params *ole.DISPPARAMS, result *ole.VARIANT, excepInfo *ole.EXCEPINFO, argErr *uint32) uintptr {
+	return this.core.invoke(dispid, flags, params, result, excepInfo, argErr)
+}
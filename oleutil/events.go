@@ -0,0 +1,267 @@
+package oleutil
+
+import (
+	"strings"
+	"sync"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// EventInterface describes an outgoing interface of a Go object: its IID and
+// the DISPIDs of its events, as declared in the type library clients
+// compile their sinks against.
+type EventInterface struct {
+	IID    *ole.GUID
+	Name   string
+	Events map[string]int32
+
+	// Default marks the interface scripting hosts connect to.
+	Default bool
+}
+
+// EventSourcer is implemented by values served through NewDispatchServer
+// that raise events. Their servers implement IConnectionPointContainer with
+// a connection point for each interface of the EventSource.
+type EventSourcer interface {
+	Events() *EventSource
+}
+
+// EventSource keeps the sinks connected to the outgoing interfaces of a Go
+// object and calls them when events are fired.
+type EventSource struct {
+	interfaces []EventInterface
+	classInfo  *ole.ITypeInfo
+
+	mu          sync.Mutex
+	connections [][]*eventConnection
+	nextCookie  uint32
+}
+
+// eventSink is a connected sink. On Windows it is the IDispatch a client
+// passed to Advise.
+type eventSink interface {
+	invoke(dispid int32, params *ole.DISPPARAMS) error
+	unknown() *ole.IUnknown
+	release()
+}
+
+// eventConnection is an advised sink. The sink is released once the
+// connection is removed and no Fire is still calling it.
+type eventConnection struct {
+	cookie    uint32
+	sink      eventSink
+	refs      int
+	connected bool
+}
+
+// NewEventSource returns an EventSource for the outgoing interfaces.
+func NewEventSource(interfaces ...EventInterface) *EventSource {
+	return &EventSource{
+		interfaces:  interfaces,
+		connections: make([][]*eventConnection, len(interfaces)),
+	}
+}
+
+// SetClassInfo sets the coclass type information served through
+// IProvideClassInfo2. Hosts that bind events by name, such as
+// WScript.ConnectObject, find the outgoing interface through it, so it
+// usually comes from the type library describing the events.
+//
+// The EventSource keeps its own reference to info.
+func (s *EventSource) SetClassInfo(info *ole.ITypeInfo) {
+	if info != nil {
+		info.AddRef()
+	}
+	s.mu.Lock()
+	old := s.classInfo
+	s.classInfo = info
+	s.mu.Unlock()
+	if old != nil {
+		old.Release()
+	}
+}
+
+// getClassInfo returns the class information set by SetClassInfo.
+func (s *EventSource) getClassInfo() *ole.ITypeInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.classInfo
+}
+
+// interfaceIndex returns the index of the outgoing interface iid, or -1.
+func (s *EventSource) interfaceIndex(iid *ole.GUID) int {
+	for i, iface := range s.interfaces {
+		if ole.IsEqualGUID(iface.IID, iid) {
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultInterface returns the index of the default outgoing interface, the
+// first one unless another is marked Default.
+func (s *EventSource) defaultInterface() int {
+	for i, iface := range s.interfaces {
+		if iface.Default {
+			return i
+		}
+	}
+	if len(s.interfaces) == 0 {
+		return -1
+	}
+	return 0
+}
+
+// advise connects sink to the interface at index and returns the cookie
+// identifying the connection.
+func (s *EventSource) advise(index int, sink eventSink) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextCookie++
+	s.connections[index] = append(s.connections[index], &eventConnection{
+		cookie:    s.nextCookie,
+		sink:      sink,
+		refs:      1,
+		connected: true,
+	})
+	return s.nextCookie
+}
+
+// unadvise disconnects the sink with the cookie from the interface at index.
+// A Fire in progress does not call it any more.
+func (s *EventSource) unadvise(index int, cookie uint32) error {
+	s.mu.Lock()
+	connections := s.connections[index]
+	for i, connection := range connections {
+		if connection.cookie != cookie {
+			continue
+		}
+		s.connections[index] = append(connections[:i:i], connections[i+1:]...)
+		connection.connected = false
+		s.mu.Unlock()
+		s.unref(connection)
+		return nil
+	}
+	s.mu.Unlock()
+	return ole.NewError(ole.CONNECT_E_NOCONNECTION)
+}
+
+// sinks returns the connections of the interface at index, each referenced
+// until passed to unref.
+func (s *EventSource) sinks(index int) []*eventConnection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	connections := append([]*eventConnection(nil), s.connections[index]...)
+	for _, connection := range connections {
+		connection.refs++
+	}
+	return connections
+}
+
+func (s *EventSource) unref(connection *eventConnection) {
+	s.mu.Lock()
+	connection.refs--
+	release := connection.refs == 0
+	s.mu.Unlock()
+	if release {
+		connection.sink.release()
+	}
+}
+
+// Fire calls the event on every sink connected to the outgoing interfaces
+// declaring it. Arguments are converted with ole.NewVariantFromValue.
+//
+// Every sink is called even when some fail; the first failure is returned.
+// Sinks whose client has gone away are disconnected. Sinks may connect and
+// disconnect while the event is fired, including from the event handler:
+// sinks disconnected meanwhile are skipped, new sinks receive the next event.
+func (s *EventSource) Fire(event string, args ...interface{}) error {
+	vargs := make([]ole.VARIANT, len(args))
+	defer func() {
+		for i := range vargs {
+			ole.VariantClear(&vargs[i])
+		}
+	}()
+	for i, arg := range args {
+		v, err := ole.NewVariantFromValue(arg)
+		if err != nil {
+			return err
+		}
+		vargs[len(args)-1-i] = v
+	}
+
+	found := false
+	var firstErr error
+	for index, iface := range s.interfaces {
+		dispid, ok := eventDispID(iface, event)
+		if !ok {
+			continue
+		}
+		found = true
+		for _, connection := range s.sinks(index) {
+			if err := s.call(index, connection, dispid, vargs); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if !found {
+		return ole.NewErrorWithDescription(ole.DISP_E_UNKNOWNNAME, "unknown event "+event)
+	}
+	return firstErr
+}
+
+// call invokes one sink, unless it was disconnected since the sinks were
+// collected, and disconnects it when its client is gone.
+func (s *EventSource) call(index int, connection *eventConnection, dispid int32, vargs []ole.VARIANT) error {
+	defer s.unref(connection)
+
+	s.mu.Lock()
+	connected := connection.connected
+	s.mu.Unlock()
+	if !connected {
+		return nil
+	}
+
+	// Sinks get their own copy of the VARIANTs, in case they change them.
+	// The copy is shallow: strings and interfaces, which sinks must not
+	// free, are shared, and so are the targets of arguments passed by
+	// reference, whose values sinks return to the raiser and the next
+	// sinks.
+	params := ole.NewDISPPARAMS(append([]ole.VARIANT(nil), vargs...), nil)
+	err := connection.sink.invoke(dispid, &params)
+	if oleErr, ok := err.(*ole.OleError); ok && isDisconnected(oleErr.Code()) {
+		s.unadvise(index, connection.cookie)
+	}
+	return err
+}
+
+// rpcServerUnavailable is HRESULT_FROM_WIN32(RPC_S_SERVER_UNAVAILABLE).
+const rpcServerUnavailable = 0x800706BA
+
+func isDisconnected(hr uintptr) bool {
+	return hr == ole.RPC_E_DISCONNECTED || hr == rpcServerUnavailable
+}
+
+func eventDispID(iface EventInterface, event string) (int32, bool) {
+	if dispid, ok := iface.Events[event]; ok {
+		return dispid, true
+	}
+	for name, dispid := range iface.Events {
+		if strings.EqualFold(name, event) {
+			return dispid, true
+		}
+	}
+	return 0, false
+}
+
+// events returns the EventSource of the served value, or nil when it raises
+// no events.
+func (d *dispatchCore) events() *EventSource {
+	if !d.value.CanInterface() {
+		return nil
+	}
+	if sourcer, ok := d.value.Interface().(EventSourcer); ok {
+		return sourcer.Events()
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package oleutil
+
+import (
+	"reflect"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var (
+	iidProgressEvents = ole.NewGUID("{6E1F4A52-3C0B-4D8E-9A71-2B5D0C8E4F10}")
+	iidStatusEvents   = ole.NewGUID("{6E1F4A52-3C0B-4D8E-9A71-2B5D0C8E4F11}")
+)
+
+type recordedEvent struct {
+	dispid int32
+	args   []interface{}
+}
+
+// fakeSink records the events it receives. onInvoke, when set, runs before
+// the event is recorded and its error is returned to Fire.
+type fakeSink struct {
+	events   []recordedEvent
+	released int
+	onInvoke func() error
+}
+
+func (s *fakeSink) invoke(dispid int32, params *ole.DISPPARAMS) error {
+	if s.onInvoke != nil {
+		if err := s.onInvoke(); err != nil {
+			return err
+		}
+	}
+	stored := params.Args()
+	args := make([]interface{}, len(stored))
+	for i := range stored {
+		args[len(stored)-1-i] = stored[i].Value()
+	}
+	s.events = append(s.events, recordedEvent{dispid, args})
+	return nil
+}
+
+func (s *fakeSink) unknown() *ole.IUnknown { return nil }
+
+func (s *fakeSink) release() { s.released++ }
+
+func newTestEventSource() *EventSource {
+	return NewEventSource(
+		EventInterface{
+			IID:    iidProgressEvents,
+			Name:   "ProgressEvents",
+			Events: map[string]int32{"Progress": 1, "Done": 2},
+		},
+		EventInterface{
+			IID:     iidStatusEvents,
+			Name:    "StatusEvents",
+			Events:  map[string]int32{"Done": 7},
+			Default: true,
+		},
+	)
+}
+
+type worker struct {
+	source *EventSource
+}
+
+func (w *worker) Run() {}
+
+func (w *worker) Events() *EventSource { return w.source }
+
+func TestEventSource_fire(t *testing.T) {
+	source := newTestEventSource()
+	progress, status := &fakeSink{}, &fakeSink{}
+	source.advise(0, progress)
+	source.advise(1, status)
+
+	if err := source.Fire("Progress", int32(50), true); err != nil {
+		t.Fatalf("Fire(Progress) = %v", err)
+	}
+	if err := source.Fire("done"); err != nil {
+		t.Fatalf("Fire(done) = %v", err)
+	}
+
+	want := []recordedEvent{{1, []interface{}{int32(50), true}}, {2, []interface{}{}}}
+	if !reflect.DeepEqual(progress.events, want) {
+		t.Errorf("progress sink got %v, want %v", progress.events, want)
+	}
+	want = []recordedEvent{{7, []interface{}{}}}
+	if !reflect.DeepEqual(status.events, want) {
+		t.Errorf("status sink got %v, want %v", status.events, want)
+	}
+}
+
+func TestEventSource_fireUnknownEvent(t *testing.T) {
+	source := newTestEventSource()
+	err := source.Fire("Missing")
+	if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != ole.DISP_E_UNKNOWNNAME {
+		t.Errorf("Fire(Missing) = %v, want DISP_E_UNKNOWNNAME", err)
+	}
+}
+
+func TestEventSource_sinkFailure(t *testing.T) {
+	source := newTestEventSource()
+	failing := &fakeSink{onInvoke: func() error { return ole.NewError(ole.E_FAIL) }}
+	healthy := &fakeSink{}
+	source.advise(0, failing)
+	source.advise(0, healthy)
+
+	err := source.Fire("Progress", int32(10))
+	if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != ole.E_FAIL {
+		t.Errorf("Fire = %v, want E_FAIL", err)
+	}
+	if len(healthy.events) != 1 {
+		t.Errorf("healthy sink got %d events, want 1", len(healthy.events))
+	}
+
+	// A failing sink stays connected.
+	source.Fire("Progress", int32(20))
+	if len(healthy.events) != 2 || failing.released != 0 {
+		t.Errorf("after second Fire: healthy got %d events, failing released %d times", len(healthy.events), failing.released)
+	}
+}
+
+func TestEventSource_disconnectedSink(t *testing.T) {
+	source := newTestEventSource()
+	gone := &fakeSink{onInvoke: func() error { return ole.NewError(ole.RPC_E_DISCONNECTED) }}
+	source.advise(0, gone)
+
+	source.Fire("Progress", int32(10))
+	if gone.released != 1 {
+		t.Fatalf("disconnected sink released %d times, want 1", gone.released)
+	}
+	if err := source.Fire("Progress", int32(20)); err != nil {
+		t.Errorf("Fire after disconnection = %v", err)
+	}
+}
+
+func TestEventSource_unadviseDuringFire(t *testing.T) {
+	source := newTestEventSource()
+	first, second, third := &fakeSink{}, &fakeSink{}, &fakeSink{}
+	source.advise(0, first)
+	secondCookie := source.advise(0, second)
+	var thirdCookie uint32
+	first.onInvoke = func() error {
+		// Disconnect the next sink, then connect a new one: neither is
+		// called for this event.
+		if err := source.unadvise(0, secondCookie); err != nil {
+			t.Errorf("unadvise(second) = %v", err)
+		}
+		if thirdCookie == 0 {
+			thirdCookie = source.advise(0, third)
+		}
+		return nil
+	}
+
+	source.Fire("Progress", int32(1))
+	if len(second.events) != 0 || len(third.events) != 0 {
+		t.Errorf("second got %d events, third got %d, want none", len(second.events), len(third.events))
+	}
+	if second.released != 1 {
+		t.Errorf("second released %d times, want 1", second.released)
+	}
+
+	first.onInvoke = nil
+	source.Fire("Progress", int32(2))
+	if len(third.events) != 1 {
+		t.Errorf("third got %d events on next Fire, want 1", len(third.events))
+	}
+}
+
+func TestEventSource_unadviseSelfDuringFire(t *testing.T) {
+	source := newTestEventSource()
+	sink := &fakeSink{}
+	cookie := source.advise(0, sink)
+	sink.onInvoke = func() error {
+		if sink.released != 0 {
+			t.Error("sink released while handling the event")
+		}
+		return source.unadvise(0, cookie)
+	}
+
+	if err := source.Fire("Progress", int32(1)); err != nil {
+		t.Fatalf("Fire = %v", err)
+	}
+	if sink.released != 1 {
+		t.Errorf("sink released %d times, want 1", sink.released)
+	}
+}
+
+func TestEventSource_cookies(t *testing.T) {
+	source := newTestEventSource()
+	seen := map[uint32]bool{}
+	for i := 0; i < 4; i++ {
+		cookie := source.advise(i%2, &fakeSink{})
+		if cookie == 0 || seen[cookie] {
+			t.Fatalf("advise returned cookie %d, seen before: %v", cookie, seen[cookie])
+		}
+		seen[cookie] = true
+	}
+
+	err := source.unadvise(0, 99)
+	if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != ole.CONNECT_E_NOCONNECTION {
+		t.Errorf("unadvise(99) = %v, want CONNECT_E_NOCONNECTION", err)
+	}
+	// Cookies are only valid on the connection point that issued them.
+	if err := source.unadvise(1, 1); err == nil {
+		t.Error("unadvise on another interface succeeded")
+	}
+}
+
+func TestEventSource_interfaces(t *testing.T) {
+	source := newTestEventSource()
+	if index := source.interfaceIndex(iidStatusEvents); index != 1 {
+		t.Errorf("interfaceIndex(status) = %d, want 1", index)
+	}
+	if index := source.interfaceIndex(ole.IID_IDispatch); index != -1 {
+		t.Errorf("interfaceIndex(IDispatch) = %d, want -1", index)
+	}
+	if index := source.defaultInterface(); index != 1 {
+		t.Errorf("defaultInterface() = %d, want 1", index)
+	}
+	if index := NewEventSource().defaultInterface(); index != -1 {
+		t.Errorf("defaultInterface() without interfaces = %d, want -1", index)
+	}
+}
+
+func TestDispatchCore_events(t *testing.T) {
+	source := newTestEventSource()
+	core, err := newDispatchCore(&worker{source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.events() != source {
+		t.Error("events() did not return the value's EventSource")
+	}
+	if _, ok := core.table.byName["events"]; ok {
+		t.Error("Events is served as a member")
+	}
+	if _, ok := core.table.byName["run"]; !ok {
+		t.Error("Run is not served")
+	}
+
+	core, _ = newDispatchCore(&calculator{})
+	if core.events() != nil {
+		t.Error("events() of a value without events is not nil")
+	}
+}
@@ -0,0 +1,431 @@
+//go:build windows
+// +build windows
+
+package oleutil
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// containerTearOff is the IConnectionPointContainer of a dispatch server
+// whose value raises events. It shares the reference count of the server.
+type containerTearOff struct {
+	lpVtbl *ole.IConnectionPointContainerVtbl
+	server *dispatchServer
+}
+
+// classInfoTearOff is the IProvideClassInfo2 of a dispatch server whose
+// EventSource has class information.
+type classInfoTearOff struct {
+	lpVtbl *ole.IProvideClassInfo2Vtbl
+	server *dispatchServer
+}
+
+// connectionPoint is the IConnectionPoint for one outgoing interface. It
+// keeps a reference to its container.
+type connectionPoint struct {
+	lpVtbl *ole.IConnectionPointVtbl
+	ref    int32
+	server *dispatchServer
+	source *EventSource
+	index  int
+}
+
+// connectionEnum implements IEnumConnectionPoints over connection points and,
+// when cookies is set, IEnumConnections over sinks. It holds a reference to
+// every element.
+type connectionEnum struct {
+	lpVtbl   *ole.IEnumConnectionsVtbl
+	ref      int32
+	iid      *ole.GUID
+	mu       sync.Mutex
+	elements []*ole.IUnknown
+	cookies  []uint32
+	pos      int
+}
+
+// dispatchSink is a sink advised by a COM client.
+type dispatchSink struct {
+	disp *ole.IDispatch
+}
+
+var (
+	containerVtbl       *ole.IConnectionPointContainerVtbl
+	classInfoVtbl       *ole.IProvideClassInfo2Vtbl
+	connectionPointVtbl *ole.IConnectionPointVtbl
+	connectionEnumVtbl  *ole.IEnumConnectionsVtbl
+	eventVtblsOnce      sync.Once
+
+	liveConnectionPoints   = map[*connectionPoint]struct{}{}
+	liveConnectionEnums    = map[*connectionEnum]struct{}{}
+	liveConnectionObjectMu sync.Mutex
+)
+
+func initEventVtbls() {
+	eventVtblsOnce.Do(func() {
+		containerVtbl = &ole.IConnectionPointContainerVtbl{}
+		containerVtbl.QueryInterface = syscall.NewCallback(containerQueryInterface)
+		containerVtbl.AddRef = syscall.NewCallback(containerAddRef)
+		containerVtbl.Release = syscall.NewCallback(containerRelease)
+		containerVtbl.EnumConnectionPoints = syscall.NewCallback(containerEnumConnectionPoints)
+		containerVtbl.FindConnectionPoint = syscall.NewCallback(containerFindConnectionPoint)
+
+		classInfoVtbl = &ole.IProvideClassInfo2Vtbl{}
+		classInfoVtbl.QueryInterface = syscall.NewCallback(classInfoQueryInterface)
+		classInfoVtbl.AddRef = syscall.NewCallback(classInfoAddRef)
+		classInfoVtbl.Release = syscall.NewCallback(classInfoRelease)
+		classInfoVtbl.GetClassInfo = syscall.NewCallback(classInfoGetClassInfo)
+		classInfoVtbl.GetGUID = syscall.NewCallback(classInfoGetGUID)
+
+		connectionPointVtbl = &ole.IConnectionPointVtbl{}
+		connectionPointVtbl.QueryInterface = syscall.NewCallback(connectionPointQueryInterface)
+		connectionPointVtbl.AddRef = syscall.NewCallback(connectionPointAddRef)
+		connectionPointVtbl.Release = syscall.NewCallback(connectionPointRelease)
+		connectionPointVtbl.GetConnectionInterface = syscall.NewCallback(connectionPointGetConnectionInterface)
+		connectionPointVtbl.GetConnectionPointContainer = syscall.NewCallback(connectionPointGetConnectionPointContainer)
+		connectionPointVtbl.Advise = syscall.NewCallback(connectionPointAdvise)
+		connectionPointVtbl.Unadvise = syscall.NewCallback(connectionPointUnadvise)
+		connectionPointVtbl.EnumConnections = syscall.NewCallback(connectionPointEnumConnections)
+
+		connectionEnumVtbl = &ole.IEnumConnectionsVtbl{}
+		connectionEnumVtbl.QueryInterface = syscall.NewCallback(connectionEnumQueryInterface)
+		connectionEnumVtbl.AddRef = syscall.NewCallback(connectionEnumAddRef)
+		connectionEnumVtbl.Release = syscall.NewCallback(connectionEnumRelease)
+		connectionEnumVtbl.Next = syscall.NewCallback(connectionEnumNext)
+		connectionEnumVtbl.Skip = syscall.NewCallback(connectionEnumSkip)
+		connectionEnumVtbl.Reset = syscall.NewCallback(connectionEnumReset)
+		connectionEnumVtbl.Clone = syscall.NewCallback(connectionEnumClone)
+	})
+}
+
+// eventInterface returns the tear-off implementing iid when the served value
+// raises events, or nil.
+func (server *dispatchServer) eventInterface(iid *ole.GUID) unsafe.Pointer {
+	source := server.core.events()
+	if source == nil {
+		return nil
+	}
+	initEventVtbls()
+	switch {
+	case ole.IsEqualGUID(iid, ole.IID_IConnectionPointContainer):
+		server.container.lpVtbl = containerVtbl
+		server.container.server = server
+		return unsafe.Pointer(&server.container)
+	case ole.IsEqualGUID(iid, ole.IID_IProvideClassInfo), ole.IsEqualGUID(iid, ole.IID_IProvideClassInfo2):
+		if source.getClassInfo() == nil {
+			return nil
+		}
+		server.classInfo.lpVtbl = classInfoVtbl
+		server.classInfo.server = server
+		return unsafe.Pointer(&server.classInfo)
+	}
+	return nil
+}
+
+func containerQueryInterface(this *containerTearOff, iid *ole.GUID, punk **ole.IUnknown) uintptr {
+	return dispatchServerQueryInterface(this.server, iid, punk)
+}
+
+func containerAddRef(this *containerTearOff) uintptr {
+	return dispatchServerAddRef(this.server)
+}
+
+func containerRelease(this *containerTearOff) uintptr {
+	return dispatchServerRelease(this.server)
+}
+
+func containerEnumConnectionPoints(this *containerTearOff, enum **ole.IEnumConnectionPoints) uintptr {
+	if enum == nil {
+		return ole.E_POINTER
+	}
+	source := this.server.core.events()
+	points := make([]*ole.IUnknown, len(source.interfaces))
+	for i := range source.interfaces {
+		points[i] = newConnectionPoint(this.server, source, i)
+	}
+	*enum = (*ole.IEnumConnectionPoints)(newConnectionEnum(ole.IID_IEnumConnectionPoints, points, nil))
+	return ole.S_OK
+}
+
+func containerFindConnectionPoint(this *containerTearOff, iid *ole.GUID, point **ole.IConnectionPoint) uintptr {
+	if point == nil {
+		return ole.E_POINTER
+	}
+	*point = nil
+	source := this.server.core.events()
+	index := source.interfaceIndex(iid)
+	if index < 0 {
+		return ole.CONNECT_E_NOCONNECTION
+	}
+	*point = (*ole.IConnectionPoint)(unsafe.Pointer(newConnectionPoint(this.server, source, index)))
+	return ole.S_OK
+}
+
+func classInfoQueryInterface(this *classInfoTearOff, iid *ole.GUID, punk **ole.IUnknown) uintptr {
+	return dispatchServerQueryInterface(this.server, iid, punk)
+}
+
+func classInfoAddRef(this *classInfoTearOff) uintptr {
+	return dispatchServerAddRef(this.server)
+}
+
+func classInfoRelease(this *classInfoTearOff) uintptr {
+	return dispatchServerRelease(this.server)
+}
+
+func classInfoGetClassInfo(this *classInfoTearOff, info **ole.ITypeInfo) uintptr {
+	if info == nil {
+		return ole.E_POINTER
+	}
+	classInfo := this.server.core.events().getClassInfo()
+	if classInfo == nil {
+		// SetClassInfo(nil) was called after the tear-off was handed out.
+		*info = nil
+		return ole.E_UNEXPECTED
+	}
+	classInfo.AddRef()
+	*info = classInfo
+	return ole.S_OK
+}
+
+func classInfoGetGUID(this *classInfoTearOff, kind uint32, guid *ole.GUID) uintptr {
+	if guid == nil {
+		return ole.E_POINTER
+	}
+	source := this.server.core.events()
+	index := source.defaultInterface()
+	if kind != ole.GUIDKIND_DEFAULT_SOURCE_DISP_IID || index < 0 {
+		*guid = ole.GUID{}
+		return ole.E_INVALIDARG
+	}
+	*guid = *source.interfaces[index].IID
+	return ole.S_OK
+}
+
+// newConnectionPoint returns the connection point for the outgoing interface
+// at index, with a reference count of one.
+func newConnectionPoint(server *dispatchServer, source *EventSource, index int) *ole.IUnknown {
+	dispatchServerAddRef(server)
+	point := &connectionPoint{
+		lpVtbl: connectionPointVtbl,
+		ref:    1,
+		server: server,
+		source: source,
+		index:  index,
+	}
+	liveConnectionObjectMu.Lock()
+	liveConnectionPoints[point] = struct{}{}
+	liveConnectionObjectMu.Unlock()
+	return (*ole.IUnknown)(unsafe.Pointer(point))
+}
+
+func connectionPointQueryInterface(this *connectionPoint, iid *ole.GUID, punk **ole.IUnknown) uintptr {
+	if punk == nil {
+		return ole.E_POINTER
+	}
+	*punk = nil
+	if !ole.IsEqualGUID(iid, ole.IID_IUnknown) && !ole.IsEqualGUID(iid, ole.IID_IConnectionPoint) {
+		return ole.E_NOINTERFACE
+	}
+	atomic.AddInt32(&this.ref, 1)
+	*punk = (*ole.IUnknown)(unsafe.Pointer(this))
+	return ole.S_OK
+}
+
+func connectionPointAddRef(this *connectionPoint) uintptr {
+	return uintptr(atomic.AddInt32(&this.ref, 1))
+}
+
+func connectionPointRelease(this *connectionPoint) uintptr {
+	ref := atomic.AddInt32(&this.ref, -1)
+	if ref == 0 {
+		liveConnectionObjectMu.Lock()
+		delete(liveConnectionPoints, this)
+		liveConnectionObjectMu.Unlock()
+		dispatchServerRelease(this.server)
+	}
+	return uintptr(ref)
+}
+
+func connectionPointGetConnectionInterface(this *connectionPoint, iid *ole.GUID) uintptr {
+	if iid == nil {
+		return ole.E_POINTER
+	}
+	*iid = *this.source.interfaces[this.index].IID
+	return ole.S_OK
+}
+
+func connectionPointGetConnectionPointContainer(this *connectionPoint, container **ole.IConnectionPointContainer) uintptr {
+	if container == nil {
+		return ole.E_POINTER
+	}
+	return dispatchServerQueryInterface(this.server, ole.IID_IConnectionPointContainer, (**ole.IUnknown)(unsafe.Pointer(container)))
+}
+
+func connectionPointAdvise(this *connectionPoint, sink *ole.IUnknown, cookie *uint32) uintptr {
+	if sink == nil || cookie == nil {
+		return ole.E_POINTER
+	}
+	*cookie = 0
+	disp, err := sink.QueryInterface(this.source.interfaces[this.index].IID)
+	if err != nil {
+		disp, err = sink.QueryInterface(ole.IID_IDispatch)
+	}
+	if err != nil {
+		return ole.CONNECT_E_CANNOTCONNECT
+	}
+	*cookie = this.source.advise(this.index, dispatchSink{disp})
+	return ole.S_OK
+}
+
+func connectionPointUnadvise(this *connectionPoint, cookie uint32) uintptr {
+	if err := this.source.unadvise(this.index, cookie); err != nil {
+		return ole.CONNECT_E_NOCONNECTION
+	}
+	return ole.S_OK
+}
+
+func connectionPointEnumConnections(this *connectionPoint, enum **ole.IEnumConnections) uintptr {
+	if enum == nil {
+		return ole.E_POINTER
+	}
+	connections := this.source.sinks(this.index)
+	sinks := make([]*ole.IUnknown, len(connections))
+	cookies := make([]uint32, len(connections))
+	for i, connection := range connections {
+		sinks[i] = connection.sink.unknown()
+		sinks[i].AddRef()
+		cookies[i] = connection.cookie
+		this.source.unref(connection)
+	}
+	*enum = (*ole.IEnumConnections)(newConnectionEnum(ole.IID_IEnumConnections, sinks, cookies))
+	return ole.S_OK
+}
+
+// newConnectionEnum returns an enumerator over elements, taking over their
+// references. The enumerator has a reference count of one.
+func newConnectionEnum(iid *ole.GUID, elements []*ole.IUnknown, cookies []uint32) unsafe.Pointer {
+	initEventVtbls()
+	enum := &connectionEnum{
+		lpVtbl:   connectionEnumVtbl,
+		ref:      1,
+		iid:      iid,
+		elements: elements,
+		cookies:  cookies,
+	}
+	liveConnectionObjectMu.Lock()
+	liveConnectionEnums[enum] = struct{}{}
+	liveConnectionObjectMu.Unlock()
+	return unsafe.Pointer(enum)
+}
+
+func connectionEnumQueryInterface(this *connectionEnum, iid *ole.GUID, punk **ole.IUnknown) uintptr {
+	if punk == nil {
+		return ole.E_POINTER
+	}
+	*punk = nil
+	if !ole.IsEqualGUID(iid, ole.IID_IUnknown) && !ole.IsEqualGUID(iid, this.iid) {
+		return ole.E_NOINTERFACE
+	}
+	atomic.AddInt32(&this.ref, 1)
+	*punk = (*ole.IUnknown)(unsafe.Pointer(this))
+	return ole.S_OK
+}
+
+func connectionEnumAddRef(this *connectionEnum) uintptr {
+	return uintptr(atomic.AddInt32(&this.ref, 1))
+}
+
+func connectionEnumRelease(this *connectionEnum) uintptr {
+	ref := atomic.AddInt32(&this.ref, -1)
+	if ref == 0 {
+		for _, element := range this.elements {
+			element.Release()
+		}
+		liveConnectionObjectMu.Lock()
+		delete(liveConnectionEnums, this)
+		liveConnectionObjectMu.Unlock()
+	}
+	return uintptr(ref)
+}
+
+func connectionEnumNext(this *connectionEnum, count uint32, out unsafe.Pointer, fetched *uint32) uintptr {
+	if out == nil || (count > 1 && fetched == nil) {
+		return ole.E_POINTER
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	n := 0
+	for ; n < int(count) && this.pos < len(this.elements); n++ {
+		element := this.elements[this.pos]
+		element.AddRef()
+		if this.cookies == nil {
+			(*[1 << 20]*ole.IUnknown)(out)[n] = element
+		} else {
+			(*[1 << 20]ole.CONNECTDATA)(out)[n] = ole.CONNECTDATA{PUnk: element, DwCookie: this.cookies[this.pos]}
+		}
+		this.pos++
+	}
+	if fetched != nil {
+		*fetched = uint32(n)
+	}
+	if n < int(count) {
+		return ole.S_FALSE
+	}
+	return ole.S_OK
+}
+
+func connectionEnumSkip(this *connectionEnum, count uint32) uintptr {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if left := len(this.elements) - this.pos; int(count) > left {
+		this.pos = len(this.elements)
+		return ole.S_FALSE
+	}
+	this.pos += int(count)
+	return ole.S_OK
+}
+
+func connectionEnumReset(this *connectionEnum) uintptr {
+	this.mu.Lock()
+	this.pos = 0
+	this.mu.Unlock()
+	return ole.S_OK
+}
+
+func connectionEnumClone(this *connectionEnum, clone *unsafe.Pointer) uintptr {
+	if clone == nil {
+		return ole.E_POINTER
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, element := range this.elements {
+		element.AddRef()
+	}
+	enum := newConnectionEnum(this.iid, this.elements, this.cookies)
+	(*connectionEnum)(enum).pos = this.pos
+	*clone = enum
+	return ole.S_OK
+}
+
+func (sink dispatchSink) invoke(dispid int32, params *ole.DISPPARAMS) error {
+	result, err := sink.disp.InvokeDispParams(dispid, ole.DISPATCH_METHOD, params)
+	if result != nil {
+		ole.VariantClear(result)
+	}
+	return err
+}
+
+func (sink dispatchSink) unknown() *ole.IUnknown {
+	return &sink.disp.IUnknown
+}
+
+func (sink dispatchSink) release() {
+	sink.disp.Release()
+}
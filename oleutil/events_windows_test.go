@@ -0,0 +1,149 @@
+//go:build windows
+// +build windows
+
+package oleutil
+
+import (
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// manyEnumerated is more than the elements ole asks an enumerator for in one
+// call to Next, so that draining it takes several.
+const manyEnumerated = 20
+
+func TestEnumConnectionPoints(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	interfaces := make([]EventInterface, manyEnumerated)
+	for i := range interfaces {
+		interfaces[i] = EventInterface{
+			IID:    &ole.GUID{Data1: 0x6E1F4A60 + uint32(i), Data4: [8]byte{0xC0, 0, 0, 0, 0, 0, 0, 0x46}},
+			Events: map[string]int32{"Done": 1},
+		}
+	}
+	disp, err := NewDispatchServer(&worker{source: NewEventSource(interfaces...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disp.Release()
+	var container *ole.IConnectionPointContainer
+	if err := ole.QueryInterfaceAs(&disp.IUnknown, &container); err != nil {
+		t.Fatal(err)
+	}
+	defer container.Release()
+
+	points, err := container.EnumConnectionPoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, point := range points {
+			point.Release()
+		}
+	}()
+	if len(points) != manyEnumerated {
+		t.Fatalf("%d connection points, want %d", len(points), manyEnumerated)
+	}
+	for i, point := range points {
+		iid, err := point.GetConnectionInterface()
+		if err != nil || !ole.IsEqualGUID(iid, interfaces[i].IID) {
+			t.Errorf("connection point %d: interface %v, %v, want %v", i, iid, err, interfaces[i].IID)
+		}
+	}
+}
+
+func TestEnumConnections(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	disp, err := NewDispatchServer(&worker{source: newTestEventSource()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disp.Release()
+	var container *ole.IConnectionPointContainer
+	if err := ole.QueryInterfaceAs(&disp.IUnknown, &container); err != nil {
+		t.Fatal(err)
+	}
+	defer container.Release()
+	var point *ole.IConnectionPoint
+	if err := container.FindConnectionPoint(iidProgressEvents, &point); err != nil {
+		t.Fatal(err)
+	}
+	defer point.Release()
+
+	cookies := map[uint32]bool{}
+	for i := 0; i < manyEnumerated; i++ {
+		sink, err := NewDispatchServer(&calculator{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookie, err := point.Advise(&sink.IUnknown)
+		sink.Release()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookies[cookie] = true
+		defer point.Unadvise(cookie)
+	}
+
+	connections, err := point.EnumConnections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connections) != manyEnumerated {
+		t.Errorf("%d connections, want %d", len(connections), manyEnumerated)
+	}
+	for _, connection := range connections {
+		if !cookies[connection.DwCookie] || connection.PUnk == nil {
+			t.Errorf("connection %+v was not advised", connection)
+		}
+		delete(cookies, connection.DwCookie)
+		connection.PUnk.Release()
+	}
+}
+
+func TestProvideClassInfo_cleared(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	tlib, err := ole.LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Skipf("LoadRegTypeLib = %v", err)
+	}
+	defer tlib.Release()
+	class, err := tlib.GetTypeInfoOfGuid(clsidStdFont)
+	if err != nil {
+		t.Fatalf("GetTypeInfoOfGuid(StdFont) = %v", err)
+	}
+	defer class.Release()
+
+	source := newTestEventSource()
+	source.SetClassInfo(class)
+	defer source.SetClassInfo(nil)
+	disp, err := NewDispatchServer(&worker{source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disp.Release()
+	var provider *ole.IProvideClassInfo
+	if err := ole.QueryInterfaceAs(&disp.IUnknown, &provider); err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Release()
+
+	info, err := provider.GetClassInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.Release()
+
+	source.SetClassInfo(nil)
+	info, err = provider.GetClassInfo()
+	if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != ole.E_UNEXPECTED {
+		t.Errorf("GetClassInfo after SetClassInfo(nil) = %v, %v, want E_UNEXPECTED", info, err)
+	}
+}
@@ -0,0 +1,270 @@
+package oleutil
+
+import (
+	"reflect"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// Missing is passed in place of an omitted optional argument, for which the
+// server uses the parameter's default value.
+var Missing = ole.NewVariant(ole.VT_ERROR, int64(ole.DISP_E_PARAMNOTFOUND))
+
+// Optional returns the value ptr points to, or Missing when ptr is nil or a
+// nil pointer, for passing optional arguments held in pointers to Invoke.
+// Values other than pointers are returned as they are.
+func Optional(ptr interface{}) interface{} {
+	rv := reflect.ValueOf(ptr)
+	if !rv.IsValid() {
+		return Missing
+	}
+	if rv.Kind() != reflect.Ptr {
+		return ptr
+	}
+	if rv.IsNil() {
+		return Missing
+	}
+	return rv.Elem().Interface()
+}
+
+// invokeRef is an argument passed by reference: the VARIANT the server
+// updates, and the Go pointer its value is stored back into, if any.
+type invokeRef struct {
+	holder *ole.VARIANT
+	target reflect.Value
+}
+
+// invokeArgs holds Go arguments converted for IDispatch.Invoke.
+type invokeArgs struct {
+	vargs []ole.VARIANT // in reverse order, as DISPPARAMS expects
+	refs  []invokeRef
+}
+
+// newInvokeArgs converts args, in call order. Trailing Missing arguments are
+// dropped, as Visual Basic does. With the signature of the member, the
+// number of arguments is checked, omitted optional arguments are filled in
+// and arguments are coerced to the types of their parameters.
+func newInvokeArgs(args []interface{}, sig *signature) (*invokeArgs, error) {
+	for len(args) > 0 {
+		if v, ok := args[len(args)-1].(ole.VARIANT); !ok || !isMissing(&v) {
+			break
+		}
+		args = args[:len(args)-1]
+	}
+	if sig != nil {
+		if err := sig.check(len(args)); err != nil {
+			return nil, err
+		}
+		args = sig.fill(args)
+	}
+
+	converted := &invokeArgs{vargs: make([]ole.VARIANT, len(args))}
+	for i, arg := range args {
+		v, err := converted.convert(arg, sig.param(i))
+		if err != nil {
+			converted.clear()
+			return nil, err
+		}
+		converted.vargs[len(args)-1-i] = v
+	}
+	return converted, nil
+}
+
+// convert returns the VARIANT passing arg to param, which is nil for
+// arguments passed as they are. Pointers other than interface pointers pass
+// the value they point to by reference; nil ones are Missing. VARIANTs are
+// never coerced.
+func (a *invokeArgs) convert(arg interface{}, param *paramSignature) (ole.VARIANT, error) {
+	switch v := arg.(type) {
+	case ole.VARIANT:
+		return v, nil
+	case *ole.VARIANT:
+		if v == nil {
+			return Missing, nil
+		}
+		return byRef(ole.VT_VARIANT, unsafe.Pointer(v)), nil
+	}
+
+	rv := reflect.ValueOf(arg)
+	byPointer := rv.IsValid() && rv.Kind() == reflect.Ptr && rv.Type() != dispatchPtrType && rv.Type() != unknownPtrType
+	if byPointer && rv.IsNil() {
+		return Missing, nil
+	}
+	if byPointer {
+		arg = rv.Elem().Interface()
+	}
+	value, err := ole.NewVariantFromValue(arg)
+	if err != nil {
+		return ole.VARIANT{}, err
+	}
+	if param != nil {
+		value, err = coerceVariant(value, param.vt&^ole.VT_BYREF)
+		if err != nil {
+			value.Clear()
+			return ole.VARIANT{}, err
+		}
+	}
+	if !byPointer && (param == nil || param.vt&ole.VT_BYREF == 0) {
+		return value, nil
+	}
+
+	// Arguments of by reference parameters passed by value are held for
+	// the call only.
+	holder := new(ole.VARIANT)
+	*holder = value
+	ref := invokeRef{holder: holder}
+	if byPointer {
+		ref.target = rv
+	}
+	a.refs = append(a.refs, ref)
+	if param != nil && param.vt&^ole.VT_BYREF == ole.VT_VARIANT {
+		return byRef(ole.VT_VARIANT, unsafe.Pointer(holder)), nil
+	}
+	return byRef(holder.VT, unsafe.Pointer(&holder.Val)), nil
+}
+
+// byRef returns a VT_BYREF VARIANT pointing to ptr.
+func byRef(vt ole.VT, ptr unsafe.Pointer) ole.VARIANT {
+	v := ole.VARIANT{VT: vt | ole.VT_BYREF}
+	*(*unsafe.Pointer)(unsafe.Pointer(&v.Val)) = ptr
+	return v
+}
+
+// storeRefs stores the values the server left in arguments passed by
+// reference back into the caller's pointers.
+func (a *invokeArgs) storeRefs() error {
+	for _, ref := range a.refs {
+		if !ref.target.IsValid() {
+			continue
+		}
+		value, err := valueFromVariant(ref.holder, ref.target.Type().Elem())
+		if err != nil {
+			return err
+		}
+		retain(value)
+		ref.target.Elem().Set(value)
+	}
+	return nil
+}
+
+// clear frees the converted arguments. Arguments passed by reference do not
+// own what they point to, but their holders do.
+func (a *invokeArgs) clear() {
+	for i := range a.vargs {
+		if a.vargs[i].VT&ole.VT_BYREF == 0 {
+			a.vargs[i].Clear()
+		}
+	}
+	for _, ref := range a.refs {
+		ref.holder.Clear()
+	}
+}
+
+// retain adds a reference to an interface pointer converted from a VARIANT
+// about to be cleared.
+func retain(value reflect.Value) {
+	if !value.IsValid() || !value.CanInterface() {
+		return
+	}
+	switch v := value.Interface().(type) {
+	case *ole.IDispatch:
+		if v != nil {
+			v.AddRef()
+		}
+	case *ole.IUnknown:
+		if v != nil {
+			v.AddRef()
+		}
+	}
+}
+
+// Invoke calls the member dispid of disp with Go arguments in call order.
+// flags is one of the DISPATCH_* flags; property puts take the value as the
+// last argument.
+//
+// Arguments are converted with ole.NewVariantFromValue, except pointers other
+// than *ole.IDispatch and *ole.IUnknown, which pass the value they point to
+// by reference and receive the value the server leaves there. Nil pointers
+// and Missing omit optional arguments. Unlike InvokeByName, Invoke does not
+// look the member up by name, for callers, such as generated code, that know
+// the DISPIDs.
+//
+// When disp provides type information describing the member, arguments are
+// coerced to the declared types of the parameters, by reference for pointer
+// parameters, omitted trailing optional arguments are passed as their
+// default values or Missing, and calls with too few or too many arguments
+// fail before reaching the server. VARIANT arguments are passed as they are.
+// The type information of an interface is read once, at its first call, but
+// each call still asks disp for it. InvokeUntyped skips all of this; so do
+// CallMethod, GetProperty, PutProperty and PutPropertyRef, and the methods
+// of ole.IDispatch.
+func Invoke(disp *ole.IDispatch, dispid int32, flags uint16, args ...interface{}) (*ole.VARIANT, error) {
+	return invoke(disp, dispid, flags, lookupSignature(disp, dispid, flags), args)
+}
+
+// InvokeByName is Invoke for the member name, looked up with
+// GetIDsOfNames at each call.
+func InvokeByName(disp *ole.IDispatch, name string, flags uint16, args ...interface{}) (*ole.VARIANT, error) {
+	dispid, err := disp.GetSingleIDOfName(name)
+	if err != nil {
+		return nil, err
+	}
+	return Invoke(disp, dispid, flags, args...)
+}
+
+// InvokeUntyped is Invoke without the type information of disp: arguments
+// are passed with the types of their Go values, for servers whose type
+// information is wrong.
+func InvokeUntyped(disp *ole.IDispatch, dispid int32, flags uint16, args ...interface{}) (*ole.VARIANT, error) {
+	return invoke(disp, dispid, flags, nil, args)
+}
+
+func invoke(disp *ole.IDispatch, dispid int32, flags uint16, sig *signature, args []interface{}) (*ole.VARIANT, error) {
+	converted, err := newInvokeArgs(args, sig)
+	if err != nil {
+		return nil, err
+	}
+	defer converted.clear()
+
+	var named []int32
+	if flags&(ole.DISPATCH_PROPERTYPUT|ole.DISPATCH_PROPERTYPUTREF) != 0 {
+		named = []int32{ole.DISPID_PROPERTYPUT}
+	}
+	params := ole.NewDISPPARAMS(converted.vargs, named)
+	result, err := disp.InvokeDispParams(dispid, int16(flags), &params)
+	if err != nil {
+		return nil, err
+	}
+	if err := converted.storeRefs(); err != nil {
+		result.Clear()
+		return nil, err
+	}
+	return result, nil
+}
+
+// ResultAs stores the value of result in the variable target points to,
+// converting it as NewDispatchServer converts arguments, then clears result.
+// Interface pointers stored in target hold their own reference. An
+// *ole.VARIANT target takes result over, without conversion or clearing.
+func ResultAs(result *ole.VARIANT, target interface{}) error {
+	if result == nil {
+		return ole.NewError(ole.E_POINTER)
+	}
+	if v, ok := target.(*ole.VARIANT); ok && v != nil {
+		*v = *result
+		return nil
+	}
+	rv := reflect.ValueOf(target)
+	if !rv.IsValid() || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ole.NewError(ole.E_INVALIDARG)
+	}
+	defer result.Clear()
+	value, err := valueFromVariant(result, rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+	retain(value)
+	rv.Elem().Set(value)
+	return nil
+}
@@ -0,0 +1,95 @@
+package oleutil
+
+import (
+	"testing"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestNewInvokeArgs(t *testing.T) {
+	value := int32(5)
+	var omitted *int32
+	args, err := newInvokeArgs([]interface{}{int32(1), &value, Missing, int16(2), Optional(omitted), Missing}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer args.clear()
+
+	if len(args.vargs) != 4 {
+		t.Fatalf("%d arguments, expected trailing Missing dropped", len(args.vargs))
+	}
+	if v := args.vargs[3]; v.VT != ole.VT_I4 || v.Val != 1 {
+		t.Errorf("first argument = %+v", v)
+	}
+	if v := args.vargs[0]; v.VT != ole.VT_I2 || v.Val != 2 {
+		t.Errorf("last argument = %+v", v)
+	}
+	if v := args.vargs[1]; !isMissing(&v) {
+		t.Errorf("omitted argument = %+v", v)
+	}
+
+	ref := args.vargs[2]
+	if ref.VT != ole.VT_I4|ole.VT_BYREF || len(args.refs) != 1 {
+		t.Fatalf("by reference argument = %+v", ref)
+	}
+	*(*int32)(variantPointer(&ref)) = 42
+	if err := args.storeRefs(); err != nil {
+		t.Fatal(err)
+	}
+	if value != 42 {
+		t.Errorf("by reference argument = %d, expected 42", value)
+	}
+}
+
+func TestNewInvokeArgs_variant(t *testing.T) {
+	v := ole.NewVariant(ole.VT_I4, 3)
+	args, err := newInvokeArgs([]interface{}{&v}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arg := args.vargs[0]; arg.VT != ole.VT_VARIANT|ole.VT_BYREF || variantPointer(&arg) != unsafe.Pointer(&v) {
+		t.Errorf("argument = %+v", arg)
+	}
+	if len(args.refs) != 0 {
+		t.Error("VARIANT pointer recorded as a reference")
+	}
+}
+
+func TestNewInvokeArgs_error(t *testing.T) {
+	if _, err := newInvokeArgs([]interface{}{struct{}{}}, nil); err == nil {
+		t.Error("no error for an unconvertible argument")
+	}
+}
+
+func TestOptional(t *testing.T) {
+	value := "x"
+	if got := Optional(&value); got != "x" {
+		t.Errorf("Optional(&value) = %v", got)
+	}
+	var omitted *string
+	if got, ok := Optional(omitted).(ole.VARIANT); !ok || !isMissing(&got) {
+		t.Errorf("Optional(nil) = %v", got)
+	}
+	if got, ok := Optional(nil).(ole.VARIANT); !ok || !isMissing(&got) {
+		t.Errorf("Optional(nil) = %v", got)
+	}
+}
+
+func TestResultAs(t *testing.T) {
+	result := ole.NewVariant(ole.VT_I2, 7)
+	var total int64
+	if err := ResultAs(&result, &total); err != nil || total != 7 {
+		t.Errorf("ResultAs = %d, %v", total, err)
+	}
+
+	result = ole.NewVariant(ole.VT_I4, 8)
+	var v ole.VARIANT
+	if err := ResultAs(&result, &v); err != nil || v.VT != ole.VT_I4 || v.Val != 8 {
+		t.Errorf("ResultAs = %+v, %v", v, err)
+	}
+
+	if err := ResultAs(&result, total); err == nil {
+		t.Error("no error for a target that is not a pointer")
+	}
+}
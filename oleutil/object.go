@@ -0,0 +1,476 @@
+package oleutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// newObjectServer and newEnumServer create the COM objects handed out for
+// sub-objects and enumerators. newObjectServer is nil where COM objects cannot
+// be created, which makes members returning sub-objects fail with E_NOTIMPL.
+var (
+	newObjectServer func(core *dispatchCore) *ole.IDispatch
+	newEnumServer   = ole.NewEnumVARIANT
+)
+
+var intType = reflect.TypeOf(int(0))
+
+// structFields lists the exported fields of a struct type as members.
+//
+// The `ole` struct tag renames a field and can mark it readonly, as in
+// `ole:"Name,readonly"`; `ole:"-"` hides it. The fields of embedded structs
+// are promoted unless a field of the same name hides them.
+func structFields(t reflect.Type, index []int) (fields []*dispatchMember) {
+	var embedded []*dispatchMember
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		tag := field.Tag.Get("ole")
+		if tag == "-" {
+			continue
+		}
+		path := append(index[:len(index):len(index)], i)
+
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+		// The exported fields of embedded structs are promoted even when
+		// the struct type itself is unexported.
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, structFields(fieldType, path)...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, &dispatchMember{
+			name:     name,
+			kind:     memberField,
+			field:    path,
+			readonly: hasOption(options, "readonly"),
+		})
+	}
+
+	for _, promoted := range embedded {
+		hidden := false
+		for _, field := range fields {
+			if strings.EqualFold(field.name, promoted.name) {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			fields = append(fields, promoted)
+		}
+	}
+	return
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isCollection reports whether values of the type are served as collections.
+// Byte slices are binary data rather than collections.
+func isCollection(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Map, reflect.Array:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// target returns the value the fields or elements of the object are read
+// from, following pointers. It is invalid for nil pointers.
+func (d *dispatchCore) target() reflect.Value {
+	v := d.value
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex returning an invalid value
+// instead of panicking on nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// property gets or sets a field member.
+func (d *dispatchCore) property(member *dispatchMember, flags uint16, params *ole.DISPPARAMS, result *ole.VARIANT, excepInfo *ole.EXCEPINFO, argErr *uint32) uintptr {
+	target := d.target()
+	if !target.IsValid() {
+		return ole.E_POINTER
+	}
+	field := fieldByIndex(target, member.field)
+
+	if flags&(ole.DISPATCH_PROPERTYPUT|ole.DISPATCH_PROPERTYPUTREF) != 0 {
+		if member.readonly || !field.CanSet() {
+			return ole.DISP_E_MEMBERNOTFOUND
+		}
+		value, args, hr := putArguments(params)
+		if hr != ole.S_OK {
+			return hr
+		}
+		if len(args) != 0 {
+			return ole.DISP_E_BADPARAMCOUNT
+		}
+		return setValue(field, value, argErr)
+	}
+
+	if flags&(ole.DISPATCH_METHOD|ole.DISPATCH_PROPERTYGET) == 0 {
+		return ole.DISP_E_MEMBERNOTFOUND
+	}
+	if args, hr := callArguments(params); hr != ole.S_OK {
+		return hr
+	} else if len(args) != 0 {
+		return ole.DISP_E_BADPARAMCOUNT
+	}
+	return d.result(field, result, excepInfo)
+}
+
+// collection serves the Count, Item and _NewEnum members of slices, arrays
+// and maps.
+//
+// Items of slices and arrays are indexed from 1, as in automation collections,
+// and items of maps by key. Enumerating a map yields its keys, in order.
+func (d *dispatchCore) collection(member *dispatchMember, flags uint16, params *ole.DISPPARAMS, result *ole.VARIANT, excepInfo *ole.EXCEPINFO, argErr *uint32) uintptr {
+	target := d.target()
+	if !target.IsValid() {
+		return ole.E_POINTER
+	}
+
+	if member.kind == memberItem && flags&(ole.DISPATCH_PROPERTYPUT|ole.DISPATCH_PROPERTYPUTREF) != 0 {
+		value, args, hr := putArguments(params)
+		if hr != ole.S_OK {
+			return hr
+		}
+		if len(args) != 1 {
+			return ole.DISP_E_BADPARAMCOUNT
+		}
+		if target.Kind() == reflect.Map {
+			key, hr := mapKey(target, &args[0], argErr)
+			if hr != ole.S_OK {
+				return hr
+			}
+			element := reflect.New(target.Type().Elem()).Elem()
+			if hr = setValue(element, value, argErr); hr != ole.S_OK {
+				return hr
+			}
+			if target.IsNil() {
+				return ole.DISP_E_MEMBERNOTFOUND
+			}
+			target.SetMapIndex(key, element)
+			return ole.S_OK
+		}
+		element, hr := sliceElement(target, &args[0], argErr)
+		if hr != ole.S_OK {
+			return hr
+		}
+		if !element.CanSet() {
+			return ole.DISP_E_MEMBERNOTFOUND
+		}
+		return setValue(element, value, argErr)
+	}
+
+	if flags&(ole.DISPATCH_METHOD|ole.DISPATCH_PROPERTYGET) == 0 {
+		return ole.DISP_E_MEMBERNOTFOUND
+	}
+	args, hr := callArguments(params)
+	if hr != ole.S_OK {
+		return hr
+	}
+
+	switch member.kind {
+	case memberCount:
+		if len(args) != 0 {
+			return ole.DISP_E_BADPARAMCOUNT
+		}
+		return d.result(reflect.ValueOf(target.Len()), result, excepInfo)
+	case memberEnum:
+		if len(args) != 0 {
+			return ole.DISP_E_BADPARAMCOUNT
+		}
+		return d.enumerate(target, result)
+	}
+
+	if len(args) != 1 {
+		return ole.DISP_E_BADPARAMCOUNT
+	}
+	if target.Kind() == reflect.Map {
+		key, hr := mapKey(target, &args[0], argErr)
+		if hr != ole.S_OK {
+			return hr
+		}
+		element := target.MapIndex(key)
+		if !element.IsValid() {
+			return ole.DISP_E_BADINDEX
+		}
+		return d.result(element, result, excepInfo)
+	}
+	element, hr := sliceElement(target, &args[0], argErr)
+	if hr != ole.S_OK {
+		return hr
+	}
+	return d.result(element, result, excepInfo)
+}
+
+// enumerate returns an IEnumVARIANT over the elements of a slice or array, or
+// the keys of a map. Elements are converted as they are fetched; an element
+// that cannot be converted is returned as a VT_ERROR VARIANT holding the
+// failure.
+func (d *dispatchCore) enumerate(target reflect.Value, result *ole.VARIANT) uintptr {
+	if result == nil {
+		return ole.S_OK
+	}
+
+	count, element := target.Len(), target.Index
+	if target.Kind() == reflect.Map {
+		keys := sortedKeys(target)
+		count, element = len(keys), func(i int) reflect.Value { return keys[i] }
+	}
+	i := 0
+	pull := func() (interface{}, bool) {
+		if i >= count {
+			return nil, false
+		}
+		v, err := variantOf(element(i))
+		i++
+		if err != nil {
+			scode := int64(ole.E_FAIL)
+			if oleErr, ok := err.(*ole.OleError); ok {
+				scode = int64(oleErr.Code())
+			}
+			return ole.NewVariant(ole.VT_ERROR, scode), true
+		}
+		return v, true
+	}
+
+	enum, err := newEnumServer(pull)
+	if err != nil {
+		if oleErr, ok := err.(*ole.OleError); ok {
+			return oleErr.Code()
+		}
+		return ole.E_FAIL
+	}
+	*result = ole.NewVariant(ole.VT_UNKNOWN, int64(uintptr(unsafe.Pointer(enum))))
+	return ole.S_OK
+}
+
+// sliceElement returns the element of a slice or array at the 1-based index
+// held by arg.
+func sliceElement(target reflect.Value, arg *ole.VARIANT, argErr *uint32) (reflect.Value, uintptr) {
+	index, err := valueFromVariant(arg, intType)
+	if err != nil {
+		setArgErr(argErr, 0)
+		return reflect.Value{}, ole.DISP_E_TYPEMISMATCH
+	}
+	i := int(index.Int()) - 1
+	if i < 0 || i >= target.Len() {
+		return reflect.Value{}, ole.DISP_E_BADINDEX
+	}
+	return target.Index(i), ole.S_OK
+}
+
+// mapKey converts arg to a key of the map.
+func mapKey(target reflect.Value, arg *ole.VARIANT, argErr *uint32) (reflect.Value, uintptr) {
+	key, err := valueFromVariant(arg, target.Type().Key())
+	if err != nil {
+		setArgErr(argErr, 0)
+		return reflect.Value{}, ole.DISP_E_TYPEMISMATCH
+	}
+	return key, ole.S_OK
+}
+
+// sortedKeys returns the keys of a map in order, so that enumerations are
+// repeatable.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		}
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	})
+	return keys
+}
+
+// callArguments returns the arguments of a method or property get call in
+// call order. Named arguments are not supported.
+func callArguments(params *ole.DISPPARAMS) ([]ole.VARIANT, uintptr) {
+	if params == nil {
+		return nil, ole.S_OK
+	}
+	if len(params.NamedArgs()) != 0 {
+		return nil, ole.DISP_E_NONAMEDARGS
+	}
+	return reversed(params.Args()), ole.S_OK
+}
+
+// putArguments splits the arguments of a property put call into the value
+// being assigned, passed first and named DISPID_PROPERTYPUT, and the indexes
+// in call order.
+func putArguments(params *ole.DISPPARAMS) (*ole.VARIANT, []ole.VARIANT, uintptr) {
+	if params == nil || len(params.Args()) == 0 {
+		return nil, nil, ole.DISP_E_PARAMNOTOPTIONAL
+	}
+	named := params.NamedArgs()
+	if len(named) > 1 || (len(named) == 1 && named[0] != ole.DISPID_PROPERTYPUT) {
+		return nil, nil, ole.DISP_E_NONAMEDARGS
+	}
+	args := params.Args()
+	return &args[0], reversed(args[1:]), ole.S_OK
+}
+
+func reversed(args []ole.VARIANT) []ole.VARIANT {
+	out := make([]ole.VARIANT, len(args))
+	for i := range args {
+		out[len(args)-1-i] = args[i]
+	}
+	return out
+}
+
+// setValue assigns the VARIANT to dst. Interface pointers stored in Go values
+// are AddRef'd, and the ones they replace released.
+func setValue(dst reflect.Value, v *ole.VARIANT, argErr *uint32) uintptr {
+	value, err := valueFromVariant(v, dst.Type())
+	if err != nil {
+		setArgErr(argErr, 0)
+		if oleErr, ok := err.(*ole.OleError); ok {
+			return oleErr.Code()
+		}
+		return ole.DISP_E_TYPEMISMATCH
+	}
+	if dst.Type() == dispatchPtrType || dst.Type() == unknownPtrType {
+		if !value.IsNil() {
+			value.Interface().(interface{ AddRef() int32 }).AddRef()
+		}
+		if !dst.IsNil() {
+			dst.Interface().(interface{ Release() int32 }).Release()
+		}
+	}
+	dst.Set(value)
+	return ole.S_OK
+}
+
+// result stores value in result, unless the caller does not want it.
+func (d *dispatchCore) result(value reflect.Value, result *ole.VARIANT, excepInfo *ole.EXCEPINFO) uintptr {
+	if result == nil {
+		return ole.S_OK
+	}
+	v, err := variantOf(value)
+	if err != nil {
+		if oleErr, ok := err.(*ole.OleError); ok && oleErr.Code() == ole.E_NOTIMPL {
+			return ole.E_NOTIMPL
+		}
+		return d.exception(err, excepInfo)
+	}
+	*result = v
+	return ole.S_OK
+}
+
+// variantOf converts a Go value to a VARIANT the way a server returns it:
+// structs, maps, slices and arrays become automation objects serving the
+// value, everything else is converted by ole.NewVariantFromValue.
+//
+// Structs and arrays reached through pointers or fields are served in place,
+// so that changes made by clients are visible to Go.
+func variantOf(value reflect.Value) (ole.VARIANT, error) {
+	if !value.IsValid() {
+		return ole.NewVariant(ole.VT_EMPTY, 0), nil
+	}
+	t := value.Type()
+	switch t.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		return variantOf(value.Elem())
+	case reflect.Ptr:
+		if t == dispatchPtrType || t == unknownPtrType {
+			break
+		}
+		if isObject(t.Elem()) {
+			if value.IsNil() {
+				return ole.NewVariant(ole.VT_DISPATCH, 0), nil
+			}
+			return objectVariant(value)
+		}
+		if value.IsNil() {
+			return ole.NewVariant(ole.VT_NULL, 0), nil
+		}
+		return variantOf(value.Elem())
+	case reflect.Struct, reflect.Array:
+		if !isObject(t) {
+			break
+		}
+		if value.CanAddr() {
+			return objectVariant(value.Addr())
+		}
+		copied := reflect.New(t)
+		copied.Elem().Set(value)
+		return objectVariant(copied)
+	case reflect.Slice, reflect.Map:
+		if isObject(t) {
+			return objectVariant(value)
+		}
+	}
+	return ole.NewVariantFromValue(value.Interface())
+}
+
+// isObject reports whether values of the type are served as automation
+// objects.
+func isObject(t reflect.Type) bool {
+	if t.Kind() == reflect.Struct {
+		return t != timeType && t != variantType
+	}
+	return isCollection(t)
+}
+
+// objectVariant serves value as a sub-object.
+func objectVariant(value reflect.Value) (ole.VARIANT, error) {
+	if newObjectServer == nil {
+		return ole.VARIANT{}, ole.NewError(ole.E_NOTIMPL)
+	}
+	disp := newObjectServer(newValueCore(value))
+	return ole.NewVariant(ole.VT_DISPATCH, int64(uintptr(unsafe.Pointer(disp)))), nil
+}
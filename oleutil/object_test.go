@@ -0,0 +1,251 @@
+package oleutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+type address struct {
+	City string
+}
+
+type audit struct {
+	Revision int32 `ole:",readonly"`
+}
+
+type customer struct {
+	audit
+	Name     string
+	Age      int32  `ole:"Years"`
+	ID       string `ole:"ID,readonly"`
+	Secret   string `ole:"-"`
+	Home     address
+	Tags     []string
+	Scores   map[string]int32
+	internal int
+}
+
+func (c *customer) Greet() string { return "Hello " + c.Name }
+
+// fakeObjectServers replaces the COM object hooks, recording the cores of the
+// objects created, until restore is called.
+func fakeObjectServers() (objects *[]*dispatchCore, enums *[]func() (interface{}, bool), restore func()) {
+	objects = &[]*dispatchCore{}
+	enums = &[]func() (interface{}, bool){}
+	savedObject, savedEnum := newObjectServer, newEnumServer
+	newObjectServer = func(core *dispatchCore) *ole.IDispatch {
+		*objects = append(*objects, core)
+		return &ole.IDispatch{}
+	}
+	newEnumServer = func(source interface{}) (*ole.IEnumVARIANT, error) {
+		*enums = append(*enums, source.(func() (interface{}, bool)))
+		return &ole.IEnumVARIANT{}, nil
+	}
+	restore = func() {
+		newObjectServer, newEnumServer = savedObject, savedEnum
+	}
+	return
+}
+
+func dispatchCall(core *dispatchCore, name string, flags uint16, args ...ole.VARIANT) (ole.VARIANT, uintptr) {
+	dispids := []int32{0}
+	if hr := core.getIDsOfNames([]string{name}, dispids); hr != ole.S_OK {
+		return ole.VARIANT{}, hr
+	}
+	var named []int32
+	rgvarg := reversed(args)
+	if flags&ole.DISPATCH_PROPERTYPUT != 0 {
+		// The assigned value comes last, so first in rgvarg.
+		named = []int32{ole.DISPID_PROPERTYPUT}
+	}
+	params := ole.NewDISPPARAMS(rgvarg, named)
+	var result ole.VARIANT
+	hr := core.invoke(dispids[0], flags, &params, &result, nil, nil)
+	return result, hr
+}
+
+func TestDispatchTable_fields(t *testing.T) {
+	table := dispatchTableOf(reflect.TypeOf(&customer{}))
+
+	fixtures := []struct {
+		Name     string
+		Kind     memberKind
+		Readonly bool
+	}{
+		{"Greet", memberMethod, false},
+		{"Name", memberField, false},
+		{"years", memberField, false},
+		{"ID", memberField, true},
+		{"Home", memberField, false},
+		{"Tags", memberField, false},
+		{"Scores", memberField, false},
+		{"Revision", memberField, true},
+	}
+	for _, f := range fixtures {
+		member, ok := table.byName[strings.ToLower(f.Name)]
+		if !ok {
+			t.Errorf("member %s not found", f.Name)
+			continue
+		}
+		if member.kind != f.Kind || member.readonly != f.Readonly {
+			t.Errorf("member %s: kind %d readonly %v, expected %d %v", f.Name, member.kind, member.readonly, f.Kind, f.Readonly)
+		}
+	}
+	for _, hidden := range []string{"Age", "Secret", "internal", "audit"} {
+		if _, ok := table.byName[strings.ToLower(hidden)]; ok {
+			t.Errorf("member %s should not be exposed", hidden)
+		}
+	}
+	for i, member := range table.members {
+		if member.dispid != int32(i+1) {
+			t.Errorf("member %s has DISPID %d, expected %d", member.name, member.dispid, i+1)
+		}
+	}
+}
+
+func TestDispatchTable_collections(t *testing.T) {
+	for _, value := range []interface{}{[]int{}, [2]string{}, map[string]int{}, &[]int{}} {
+		table := dispatchTableOf(reflect.TypeOf(value))
+		if member := table.byName["count"]; member == nil || member.kind != memberCount {
+			t.Errorf("%T has no Count member", value)
+		}
+		if member := table.byID[ole.DISPID_VALUE]; member == nil || member.name != "Item" {
+			t.Errorf("%T has no default Item member", value)
+		}
+		if member := table.byID[ole.DISPID_NEWENUM]; member == nil || member.name != "_NewEnum" {
+			t.Errorf("%T has no _NewEnum member", value)
+		}
+	}
+	if _, ok := dispatchTableOf(reflect.TypeOf([]byte{})).byName["count"]; ok {
+		t.Error("[]byte should not be a collection")
+	}
+}
+
+func TestDispatchCore_properties(t *testing.T) {
+	objects, _, restore := fakeObjectServers()
+	defer restore()
+	c := &customer{Name: "Ann", Age: 30, ID: "c1"}
+	c.Revision = 4
+	core, err := newDispatchCore(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result, hr := dispatchCall(core, "Years", ole.DISPATCH_PROPERTYGET); hr != ole.S_OK || result.VT != ole.VT_I4 || result.Val != 30 {
+		t.Errorf("Years = %v %d, hr %#x", result.VT, result.Val, hr)
+	}
+	if result, hr := dispatchCall(core, "Revision", ole.DISPATCH_METHOD|ole.DISPATCH_PROPERTYGET); hr != ole.S_OK || result.Val != 4 {
+		t.Errorf("Revision = %d, hr %#x", result.Val, hr)
+	}
+
+	if _, hr := dispatchCall(core, "Years", ole.DISPATCH_PROPERTYPUT, ole.NewVariant(ole.VT_R8, 0x4045000000000000)); hr != ole.S_OK || c.Age != 42 {
+		t.Errorf("Years = 42.0 returned %#x, Age %d", hr, c.Age)
+	}
+	if _, hr := dispatchCall(core, "ID", ole.DISPATCH_PROPERTYPUT, i4(2)); hr != ole.DISP_E_MEMBERNOTFOUND {
+		t.Errorf("assigning readonly ID returned %#x", hr)
+	}
+	if _, hr := dispatchCall(core, "Revision", ole.DISPATCH_PROPERTYPUT, i4(2)); hr != ole.DISP_E_MEMBERNOTFOUND {
+		t.Errorf("assigning readonly Revision returned %#x", hr)
+	}
+	if _, hr := dispatchCall(core, "Years", ole.DISPATCH_PROPERTYPUT, ole.NewVariant(ole.VT_I8, 1<<40)); hr != ole.DISP_E_OVERFLOW {
+		t.Errorf("assigning out of range Years returned %#x", hr)
+	}
+
+	// Nested structs are sub-objects sharing the Go value.
+	result, hr := dispatchCall(core, "Home", ole.DISPATCH_PROPERTYGET)
+	if hr != ole.S_OK || result.VT != ole.VT_DISPATCH || len(*objects) != 1 {
+		t.Fatalf("Home = %v, hr %#x", result.VT, hr)
+	}
+	home := (*objects)[0]
+	if _, hr := dispatchCall(home, "City", ole.DISPATCH_PROPERTYPUT, ole.NewVariant(ole.VT_I4, 5)); hr != ole.S_OK {
+		t.Fatalf("Home.City = 5 returned %#x", hr)
+	}
+	if c.Home.City != "5" {
+		t.Errorf("Home.City = %q, expected the sub-object to update the struct", c.Home.City)
+	}
+}
+
+func TestDispatchCore_sliceCollection(t *testing.T) {
+	objects, enums, restore := fakeObjectServers()
+	defer restore()
+	c := &customer{Tags: []string{"a", "b", "c"}}
+	core, err := newDispatchCore(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, hr := dispatchCall(core, "Tags", ole.DISPATCH_PROPERTYGET); hr != ole.S_OK || len(*objects) != 1 {
+		t.Fatalf("Tags returned %#x", hr)
+	}
+	tags := (*objects)[0]
+
+	if result, hr := dispatchCall(tags, "Count", ole.DISPATCH_PROPERTYGET); hr != ole.S_OK || result.Val != 3 {
+		t.Errorf("Count = %d, hr %#x", result.Val, hr)
+	}
+	// Item is the default member, indexed from 1.
+	params := ole.NewDISPPARAMS([]ole.VARIANT{i4(4)}, nil)
+	if hr := tags.invoke(ole.DISPID_VALUE, ole.DISPATCH_METHOD|ole.DISPATCH_PROPERTYGET, &params, &ole.VARIANT{}, nil, nil); hr != ole.DISP_E_BADINDEX {
+		t.Errorf("Item(4) returned %#x", hr)
+	}
+	if _, hr := dispatchCall(tags, "Item", ole.DISPATCH_PROPERTYPUT, i4(2), i4(7)); hr != ole.S_OK || c.Tags[1] != "7" {
+		t.Errorf("Item(2) = 7 returned %#x, Tags %v", hr, c.Tags)
+	}
+
+	if result, hr := dispatchCall(tags, "_NewEnum", ole.DISPATCH_PROPERTYGET); hr != ole.S_OK || result.VT != ole.VT_UNKNOWN || len(*enums) != 1 {
+		t.Fatalf("_NewEnum = %v, hr %#x", result.VT, hr)
+	}
+	pull := (*enums)[0]
+	for i := 0; i < 3; i++ {
+		if v, ok := pull(); !ok || v.(ole.VARIANT).VT != ole.VT_BSTR {
+			t.Errorf("element %d = %v, %v", i, v, ok)
+		}
+	}
+	if _, ok := pull(); ok {
+		t.Error("enumerator returned an element past the end")
+	}
+}
+
+func TestDispatchCore_mapCollection(t *testing.T) {
+	_, enums, restore := fakeObjectServers()
+	defer restore()
+	scores := map[string]int32{"b": 2, "a": 1}
+	core, err := newDispatchCore(scores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result, hr := dispatchCall(core, "Item", ole.DISPATCH_PROPERTYGET, i4(1)); hr != ole.DISP_E_BADINDEX {
+		t.Errorf("Item(1) = %d, hr %#x", result.Val, hr)
+	}
+	if _, hr := dispatchCall(core, "Item", ole.DISPATCH_PROPERTYPUT, i4(3), i4(9)); hr != ole.S_OK || scores["3"] != 9 {
+		t.Errorf("Item(3) = 9 returned %#x, map %v", hr, scores)
+	}
+	if result, hr := dispatchCall(core, "Count", ole.DISPATCH_PROPERTYGET); hr != ole.S_OK || result.Val != 3 {
+		t.Errorf("Count = %d, hr %#x", result.Val, hr)
+	}
+
+	// Keys are enumerated in order.
+	if _, hr := dispatchCall(core, "_NewEnum", ole.DISPATCH_METHOD); hr != ole.S_OK || len(*enums) != 1 {
+		t.Fatalf("_NewEnum returned %#x", hr)
+	}
+	if first, ok := (*enums)[0](); !ok || first.(ole.VARIANT).VT != ole.VT_BSTR {
+		t.Errorf("first key = %v, %v", first, ok)
+	}
+}
+
+func TestDispatchCore_noObjectServer(t *testing.T) {
+	saved := newObjectServer
+	newObjectServer = nil
+	defer func() { newObjectServer = saved }()
+
+	core, err := newDispatchCore(&customer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, hr := dispatchCall(core, "Home", ole.DISPATCH_PROPERTYGET); hr != ole.E_NOTIMPL {
+		t.Errorf("Home without object server returned %#x", hr)
+	}
+}
@@ -47,6 +47,9 @@ func GetActiveObject(programID string) (unknown *ole.IUnknown, err error) {
 }
 
 // CallMethod calls method on IDispatch with parameters.
+//
+// The parameters are passed with the types of their Go values; InvokeByName
+// coerces them to the types the type information of disp declares.
 func CallMethod(disp *ole.IDispatch, name string, params ...interface{}) (result *ole.VARIANT, err error) {
 	return disp.InvokeWithOptionalArgs(name, ole.DISPATCH_METHOD, params)
 }
@@ -0,0 +1,201 @@
+package oleutil
+
+import (
+	"fmt"
+	"reflect"
+	"unicode/utf16"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// methodDescription describes one entry of the type information of a Go
+// value served through IDispatch. Properties have an entry for each of their
+// get and put accessors.
+type methodDescription struct {
+	Name   string
+	DispID int32
+	Flags  uint16
+	Params []paramDescription
+	Return ole.VT
+}
+
+// paramDescription describes a parameter of a methodDescription.
+type paramDescription struct {
+	Name string
+	VT   ole.VT
+}
+
+// describe derives the type information of the members in table from t, the
+// type of the served value.
+func describe(t reflect.Type, table *dispatchTable) (methods []methodDescription) {
+	elem := t
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	for _, member := range table.members {
+		switch member.kind {
+		case memberMethod:
+			methods = append(methods, describeMethod(member, t.Method(member.index).Type))
+		case memberField:
+			fieldType := elem.FieldByIndex(member.field).Type
+			methods = append(methods, describeProperty(member, ole.DISPATCH_PROPERTYGET, nil, typeVT(fieldType)))
+			if !member.readonly {
+				methods = append(methods, describeProperty(member, ole.DISPATCH_PROPERTYPUT, []paramDescription{{"Value", typeVT(fieldType)}}, ole.VT_EMPTY))
+			}
+		case memberCount:
+			methods = append(methods, describeProperty(member, ole.DISPATCH_PROPERTYGET, nil, ole.VT_I4))
+		case memberItem:
+			index := paramDescription{"Index", ole.VT_I4}
+			if elem.Kind() == reflect.Map {
+				index.VT = typeVT(elem.Key())
+			}
+			methods = append(methods, describeProperty(member, ole.DISPATCH_PROPERTYGET, []paramDescription{index}, typeVT(elem.Elem())))
+		case memberEnum:
+			methods = append(methods, describeProperty(member, ole.DISPATCH_PROPERTYGET, nil, ole.VT_UNKNOWN))
+		}
+	}
+	return
+}
+
+func describeProperty(member *dispatchMember, flags uint16, params []paramDescription, vt ole.VT) methodDescription {
+	return methodDescription{
+		Name:   member.name,
+		DispID: member.dispid,
+		Flags:  flags,
+		Params: params,
+		Return: vt,
+	}
+}
+
+// describeMethod describes a method of type fn, whose first parameter is the
+// receiver. Parameters are named after the ParamNamer names, or numbered.
+func describeMethod(member *dispatchMember, fn reflect.Type) methodDescription {
+	method := methodDescription{
+		Name:   member.name,
+		DispID: member.dispid,
+		Flags:  ole.DISPATCH_METHOD,
+		Return: ole.VT_EMPTY,
+	}
+	for i := 1; i < fn.NumIn(); i++ {
+		param := paramDescription{VT: typeVT(fn.In(i))}
+		if fn.IsVariadic() && i == fn.NumIn()-1 {
+			param.VT = ole.VT_VARIANT
+		}
+		if i-1 < len(member.params) {
+			param.Name = member.params[i-1]
+		} else {
+			param.Name = fmt.Sprintf("Param%d", i)
+		}
+		method.Params = append(method.Params, param)
+	}
+	if fn.NumOut() > 0 && fn.Out(0) != errorType {
+		method.Return = typeVT(fn.Out(0))
+	}
+	return method
+}
+
+// typeVT returns the VARTYPE values of type t are exchanged as.
+func typeVT(t reflect.Type) ole.VT {
+	switch t {
+	case timeType:
+		return ole.VT_DATE
+	case dispatchPtrType:
+		return ole.VT_DISPATCH
+	case unknownPtrType:
+		return ole.VT_UNKNOWN
+	case variantType, variantPtrType:
+		return ole.VT_VARIANT
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return ole.VT_BOOL
+	case reflect.Int8:
+		return ole.VT_I1
+	case reflect.Int16:
+		return ole.VT_I2
+	case reflect.Int32, reflect.Int:
+		return ole.VT_I4
+	case reflect.Int64:
+		return ole.VT_I8
+	case reflect.Uint8:
+		return ole.VT_UI1
+	case reflect.Uint16:
+		return ole.VT_UI2
+	case reflect.Uint32, reflect.Uint, reflect.Uintptr:
+		return ole.VT_UI4
+	case reflect.Uint64:
+		return ole.VT_UI8
+	case reflect.Float32:
+		return ole.VT_R4
+	case reflect.Float64:
+		return ole.VT_R8
+	case reflect.String:
+		return ole.VT_BSTR
+	case reflect.Ptr:
+		if isObject(t.Elem()) {
+			return ole.VT_DISPATCH
+		}
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		if isObject(t) {
+			return ole.VT_DISPATCH
+		}
+	}
+	return ole.VT_VARIANT
+}
+
+// interfaceData holds the native INTERFACEDATA for a description together
+// with the memory it points to.
+type interfaceData struct {
+	data    ole.INTERFACEDATA
+	methods []ole.METHODDATA
+	params  [][]ole.PARAMDATA
+}
+
+// dispatchVtblSize is the number of IDispatch methods preceding the methods of
+// a dispatch interface in its vtable.
+const dispatchVtblSize = 7
+
+// newInterfaceData converts a description to the INTERFACEDATA
+// CreateDispTypeInfo takes.
+func newInterfaceData(methods []methodDescription) *interfaceData {
+	data := &interfaceData{
+		methods: make([]ole.METHODDATA, len(methods)),
+		params:  make([][]ole.PARAMDATA, len(methods)),
+	}
+	for i, method := range methods {
+		params := make([]ole.PARAMDATA, len(method.Params))
+		for j, param := range method.Params {
+			params[j] = ole.PARAMDATA{
+				Name: (*int16)(unsafe.Pointer(utf16Ptr(param.Name))),
+				Vt:   uint16(param.VT),
+			}
+		}
+		data.params[i] = params
+
+		data.methods[i] = ole.METHODDATA{
+			Name:     utf16Ptr(method.Name),
+			Dispid:   method.DispID,
+			Meth:     uint32(dispatchVtblSize + i),
+			CC:       ole.CC_STDCALL,
+			CArgs:    uint32(len(params)),
+			Flags:    method.Flags,
+			VtReturn: uint16(method.Return),
+		}
+		if len(params) > 0 {
+			data.methods[i].Data = &params[0]
+		}
+	}
+	if len(methods) > 0 {
+		data.data.MethodData = &data.methods[0]
+	}
+	data.data.CMembers = uint32(len(methods))
+	return data
+}
+
+// utf16Ptr returns s as a NUL-terminated UTF-16 string.
+func utf16Ptr(s string) *uint16 {
+	return &utf16.Encode([]rune(s + "\x00"))[0]
+}
@@ -0,0 +1,128 @@
+package oleutil
+
+import (
+	"reflect"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func findDescription(methods []methodDescription, name string, flags uint16) *methodDescription {
+	for i := range methods {
+		if methods[i].Name == name && methods[i].Flags == flags {
+			return &methods[i]
+		}
+	}
+	return nil
+}
+
+func TestDescribe_methods(t *testing.T) {
+	core, err := newDispatchCore(&calculator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := describe(core.value.Type(), core.table)
+
+	add := findDescription(methods, "Add", ole.DISPATCH_METHOD)
+	if add == nil {
+		t.Fatal("Add not described")
+	}
+	expected := []paramDescription{{"Param1", ole.VT_I4}, {"Param2", ole.VT_I4}}
+	if !reflect.DeepEqual(add.Params, expected) || add.Return != ole.VT_I4 || add.DispID != core.table.byName["add"].dispid {
+		t.Errorf("Add described as %+v", *add)
+	}
+
+	accumulate := findDescription(methods, "Accumulate", ole.DISPATCH_METHOD)
+	expected = []paramDescription{{"Value", ole.VT_I4}, {"Scale", ole.VT_VARIANT}}
+	if accumulate == nil || !reflect.DeepEqual(accumulate.Params, expected) || accumulate.Return != ole.VT_EMPTY {
+		t.Errorf("Accumulate described as %+v", accumulate)
+	}
+
+	// The error result is reported through EXCEPINFO, not returned.
+	if divide := findDescription(methods, "Divide", ole.DISPATCH_METHOD); divide == nil || divide.Return != ole.VT_I4 {
+		t.Errorf("Divide described as %+v", divide)
+	}
+	if fail := findDescription(methods, "Fail", ole.DISPATCH_METHOD); fail == nil || fail.Return != ole.VT_EMPTY {
+		t.Errorf("Fail described as %+v", fail)
+	}
+}
+
+func TestDescribe_properties(t *testing.T) {
+	core, err := newDispatchCore(&customer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := describe(core.value.Type(), core.table)
+
+	fixtures := []struct {
+		Name   string
+		Flags  uint16
+		Return ole.VT
+		Params []paramDescription
+	}{
+		{"Name", ole.DISPATCH_PROPERTYGET, ole.VT_BSTR, nil},
+		{"Name", ole.DISPATCH_PROPERTYPUT, ole.VT_EMPTY, []paramDescription{{"Value", ole.VT_BSTR}}},
+		{"Years", ole.DISPATCH_PROPERTYGET, ole.VT_I4, nil},
+		{"ID", ole.DISPATCH_PROPERTYGET, ole.VT_BSTR, nil},
+		{"Home", ole.DISPATCH_PROPERTYGET, ole.VT_DISPATCH, nil},
+		{"Tags", ole.DISPATCH_PROPERTYGET, ole.VT_DISPATCH, nil},
+		{"Revision", ole.DISPATCH_PROPERTYGET, ole.VT_I4, nil},
+	}
+	for _, f := range fixtures {
+		method := findDescription(methods, f.Name, f.Flags)
+		if method == nil {
+			t.Errorf("%s (flags %d) not described", f.Name, f.Flags)
+			continue
+		}
+		if method.Return != f.Return || !reflect.DeepEqual(method.Params, f.Params) {
+			t.Errorf("%s (flags %d) described as %+v", f.Name, f.Flags, *method)
+		}
+	}
+	for _, readonly := range []string{"ID", "Revision"} {
+		if findDescription(methods, readonly, ole.DISPATCH_PROPERTYPUT) != nil {
+			t.Errorf("readonly %s has a put accessor", readonly)
+		}
+	}
+}
+
+func TestDescribe_collection(t *testing.T) {
+	core, err := newDispatchCore(map[string]float64{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := describe(core.value.Type(), core.table)
+
+	item := findDescription(methods, "Item", ole.DISPATCH_PROPERTYGET)
+	if item == nil || item.DispID != ole.DISPID_VALUE || item.Return != ole.VT_R8 ||
+		!reflect.DeepEqual(item.Params, []paramDescription{{"Index", ole.VT_BSTR}}) {
+		t.Errorf("Item described as %+v", item)
+	}
+	if enum := findDescription(methods, "_NewEnum", ole.DISPATCH_PROPERTYGET); enum == nil || enum.DispID != ole.DISPID_NEWENUM || enum.Return != ole.VT_UNKNOWN {
+		t.Errorf("_NewEnum described as %+v", enum)
+	}
+}
+
+func TestNewInterfaceData(t *testing.T) {
+	methods := []methodDescription{
+		{Name: "Add", DispID: 1, Flags: ole.DISPATCH_METHOD, Return: ole.VT_I4,
+			Params: []paramDescription{{"A", ole.VT_I4}, {"B", ole.VT_I4}}},
+		{Name: "Count", DispID: 2, Flags: ole.DISPATCH_PROPERTYGET, Return: ole.VT_I4},
+	}
+	data := newInterfaceData(methods)
+
+	if data.data.CMembers != 2 || data.data.MethodData != &data.methods[0] {
+		t.Fatalf("INTERFACEDATA = %+v", data.data)
+	}
+	add := data.methods[0]
+	if ole.LpOleStrToString(add.Name) != "Add" || add.Dispid != 1 || add.Meth != dispatchVtblSize ||
+		add.CArgs != 2 || add.VtReturn != uint16(ole.VT_I4) || add.CC != ole.CC_STDCALL || add.Data != &data.params[0][0] {
+		t.Errorf("METHODDATA for Add = %+v", add)
+	}
+	if data.params[0][1].Vt != uint16(ole.VT_I4) {
+		t.Errorf("PARAMDATA for B = %+v", data.params[0][1])
+	}
+	count := data.methods[1]
+	if count.Meth != dispatchVtblSize+1 || count.CArgs != 0 || count.Data != nil || count.Flags != ole.DISPATCH_PROPERTYGET {
+		t.Errorf("METHODDATA for Count = %+v", count)
+	}
+}
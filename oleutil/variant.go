@@ -0,0 +1,484 @@
+package oleutil
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var (
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+	variantType     = reflect.TypeOf(ole.VARIANT{})
+	variantPtrType  = reflect.TypeOf((*ole.VARIANT)(nil))
+	dispatchPtrType = reflect.TypeOf((*ole.IDispatch)(nil))
+	unknownPtrType  = reflect.TypeOf((*ole.IUnknown)(nil))
+)
+
+// variantPointer returns the pointer held by a VARIANT, such as the target of
+// a VT_BYREF VARIANT.
+func variantPointer(v *ole.VARIANT) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&v.Val))
+}
+
+// isMissing reports whether v is the marker for an omitted optional argument.
+func isMissing(v *ole.VARIANT) bool {
+	return v.VT == ole.VT_ERROR && uint32(v.Val) == ole.DISP_E_PARAMNOTFOUND
+}
+
+// derefVariant returns the value a VT_BYREF VARIANT points to as a plain
+// VARIANT. Other VARIANTs are returned as they are.
+func derefVariant(v *ole.VARIANT) *ole.VARIANT {
+	if v.VT&ole.VT_BYREF == 0 {
+		return v
+	}
+	vt := v.VT &^ ole.VT_BYREF
+	ptr := variantPointer(v)
+	if ptr == nil {
+		return &ole.VARIANT{VT: ole.VT_EMPTY}
+	}
+	if vt == ole.VT_VARIANT {
+		return derefVariant((*ole.VARIANT)(ptr))
+	}
+
+	out := &ole.VARIANT{VT: vt}
+	switch vt {
+	case ole.VT_I1:
+		out.Val = int64(*(*int8)(ptr))
+	case ole.VT_UI1:
+		out.Val = int64(*(*uint8)(ptr))
+	case ole.VT_I2, ole.VT_BOOL:
+		out.Val = int64(*(*int16)(ptr))
+	case ole.VT_UI2:
+		out.Val = int64(*(*uint16)(ptr))
+	case ole.VT_I4, ole.VT_INT, ole.VT_ERROR:
+		out.Val = int64(*(*int32)(ptr))
+	case ole.VT_UI4, ole.VT_UINT, ole.VT_R4:
+		out.Val = int64(*(*uint32)(ptr))
+	case ole.VT_I8, ole.VT_UI8, ole.VT_R8, ole.VT_DATE, ole.VT_CY:
+		out.Val = *(*int64)(ptr)
+	default:
+		*(*unsafe.Pointer)(unsafe.Pointer(&out.Val)) = *(*unsafe.Pointer)(ptr)
+	}
+	return out
+}
+
+// variantScalar reads a VARIANT as one of nil, bool, int64, uint64, float64,
+// string, time.Time, *ole.IDispatch or *ole.IUnknown.
+func variantScalar(v *ole.VARIANT) (interface{}, error) {
+	switch v.VT {
+	case ole.VT_EMPTY, ole.VT_NULL:
+		return nil, nil
+	case ole.VT_BOOL:
+		return v.Val&0xffff != 0, nil
+	case ole.VT_I1:
+		return int64(int8(v.Val)), nil
+	case ole.VT_I2:
+		return int64(int16(v.Val)), nil
+	case ole.VT_I4, ole.VT_INT, ole.VT_ERROR:
+		return int64(int32(v.Val)), nil
+	case ole.VT_I8:
+		return v.Val, nil
+	case ole.VT_UI1:
+		return uint64(uint8(v.Val)), nil
+	case ole.VT_UI2:
+		return uint64(uint16(v.Val)), nil
+	case ole.VT_UI4, ole.VT_UINT:
+		return uint64(uint32(v.Val)), nil
+	case ole.VT_UI8:
+		return uint64(v.Val), nil
+	case ole.VT_R4:
+		return float64(math.Float32frombits(uint32(v.Val))), nil
+	case ole.VT_R8:
+		return math.Float64frombits(uint64(v.Val)), nil
+	case ole.VT_CY:
+		return float64(v.Val) / 10000, nil
+	case ole.VT_DATE:
+		return ole.VariantDateToTime(math.Float64frombits(uint64(v.Val))), nil
+	case ole.VT_BSTR:
+		return v.ToString(), nil
+	case ole.VT_DISPATCH:
+		return v.ToIDispatch(), nil
+	case ole.VT_UNKNOWN:
+		return v.ToIUnknown(), nil
+	}
+	return nil, ole.NewError(ole.DISP_E_BADVARTYPE)
+}
+
+// variantInterface reads a VARIANT as the Go value VARIANT.Value returns,
+// with arrays converted to []interface{}.
+func variantInterface(v *ole.VARIANT) interface{} {
+	if v.VT&ole.VT_ARRAY != 0 {
+		return v.ToArray().ToValueArray()
+	}
+	if v.VT == ole.VT_DATE {
+		return ole.VariantDateToTime(math.Float64frombits(uint64(v.Val)))
+	}
+	return v.Value()
+}
+
+// valueFromVariant converts a VARIANT argument to a value of type t.
+//
+// Interface pointers are borrowed from the VARIANT; they must be AddRef'd to
+// be kept after the call returns.
+func valueFromVariant(v *ole.VARIANT, t reflect.Type) (reflect.Value, error) {
+	src := derefVariant(v)
+	switch t {
+	case variantType:
+		return reflect.ValueOf(*src), nil
+	case variantPtrType:
+		return reflect.ValueOf(src), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		value := variantInterface(src)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		if !reflect.TypeOf(value).Implements(t) {
+			return reflect.Value{}, ole.NewError(ole.DISP_E_TYPEMISMATCH)
+		}
+		out := reflect.New(t).Elem()
+		out.Set(reflect.ValueOf(value))
+		return out, nil
+	case reflect.Ptr:
+		if t == dispatchPtrType || t == unknownPtrType {
+			break
+		}
+		if src.VT == ole.VT_EMPTY || src.VT == ole.VT_NULL {
+			return reflect.Zero(t), nil
+		}
+		elem, err := valueFromVariant(src, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t.Elem())
+		out.Elem().Set(elem)
+		return out, nil
+	case reflect.Slice:
+		if src.VT&ole.VT_ARRAY == 0 {
+			break
+		}
+		values := src.ToArray().ToValueArray()
+		out := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			elem, err := convertScalar(scalarFromValue(value), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+	}
+
+	scalar, err := variantScalar(src)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return convertScalar(scalar, t)
+}
+
+// scalarFromValue normalizes a Go value to the types variantScalar returns.
+func scalarFromValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, time.Time, *ole.IDispatch, *ole.IUnknown:
+		return v
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	}
+	return value
+}
+
+// convertScalar coerces a value returned by variantScalar to type t the way
+// automation clients expect, reporting DISP_E_OVERFLOW for values out of range
+// and DISP_E_TYPEMISMATCH for values that cannot be converted.
+func convertScalar(scalar interface{}, t reflect.Type) (reflect.Value, error) {
+	out := reflect.New(t).Elem()
+	if scalar != nil && reflect.TypeOf(scalar).AssignableTo(t) {
+		out.Set(reflect.ValueOf(scalar))
+		return out, nil
+	}
+
+	mismatch := ole.NewError(ole.DISP_E_TYPEMISMATCH)
+	overflow := ole.NewError(ole.DISP_E_OVERFLOW)
+	switch t.Kind() {
+	case reflect.Bool:
+		switch v := scalar.(type) {
+		case nil:
+		case int64:
+			out.SetBool(v != 0)
+		case uint64:
+			out.SetBool(v != 0)
+		case float64:
+			out.SetBool(v != 0)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return reflect.Value{}, mismatch
+			}
+			out.SetBool(b)
+		default:
+			return reflect.Value{}, mismatch
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch v := scalar.(type) {
+		case nil:
+		case bool:
+			if v {
+				i = -1
+			}
+		case int64:
+			i = v
+		case uint64:
+			if v > math.MaxInt64 {
+				return reflect.Value{}, overflow
+			}
+			i = int64(v)
+		case float64:
+			v = math.RoundToEven(v)
+			if v < math.MinInt64 || v >= math.MaxInt64 {
+				return reflect.Value{}, overflow
+			}
+			i = int64(v)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return reflect.Value{}, mismatch
+			}
+			return convertScalar(f, t)
+		default:
+			return reflect.Value{}, mismatch
+		}
+		if out.OverflowInt(i) {
+			return reflect.Value{}, overflow
+		}
+		out.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch v := scalar.(type) {
+		case nil:
+		case bool:
+			if v {
+				return reflect.Value{}, overflow
+			}
+		case int64:
+			if v < 0 {
+				return reflect.Value{}, overflow
+			}
+			u = uint64(v)
+		case uint64:
+			u = v
+		case float64:
+			v = math.RoundToEven(v)
+			if v < 0 || v >= math.MaxUint64 {
+				return reflect.Value{}, overflow
+			}
+			u = uint64(v)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return reflect.Value{}, mismatch
+			}
+			return convertScalar(f, t)
+		default:
+			return reflect.Value{}, mismatch
+		}
+		if out.OverflowUint(u) {
+			return reflect.Value{}, overflow
+		}
+		out.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch v := scalar.(type) {
+		case nil:
+		case bool:
+			if v {
+				f = -1
+			}
+		case int64:
+			f = float64(v)
+		case uint64:
+			f = float64(v)
+		case float64:
+			f = v
+		case time.Time:
+			f = ole.TimeToVariantDate(v)
+		case string:
+			var err error
+			f, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return reflect.Value{}, mismatch
+			}
+		default:
+			return reflect.Value{}, mismatch
+		}
+		if out.OverflowFloat(f) {
+			return reflect.Value{}, overflow
+		}
+		out.SetFloat(f)
+	case reflect.String:
+		switch v := scalar.(type) {
+		case nil:
+		case bool:
+			if v {
+				out.SetString("True")
+			} else {
+				out.SetString("False")
+			}
+		case int64:
+			out.SetString(strconv.FormatInt(v, 10))
+		case uint64:
+			out.SetString(strconv.FormatUint(v, 10))
+		case float64:
+			out.SetString(strconv.FormatFloat(v, 'g', -1, 64))
+		case string:
+			out.SetString(v)
+		case time.Time:
+			out.SetString(v.Format("2006-01-02 15:04:05"))
+		default:
+			return reflect.Value{}, mismatch
+		}
+	case reflect.Struct:
+		if t != timeType {
+			return reflect.Value{}, mismatch
+		}
+		switch v := scalar.(type) {
+		case nil:
+		case float64:
+			out.Set(reflect.ValueOf(ole.VariantDateToTime(v)))
+		case int64:
+			out.Set(reflect.ValueOf(ole.VariantDateToTime(float64(v))))
+		case string:
+			date, err := time.Parse("2006-01-02 15:04:05", strings.TrimSpace(v))
+			if err != nil {
+				return reflect.Value{}, mismatch
+			}
+			out.Set(reflect.ValueOf(date))
+		default:
+			return reflect.Value{}, mismatch
+		}
+	case reflect.Ptr:
+		switch v := scalar.(type) {
+		case nil:
+		case *ole.IDispatch:
+			if t != unknownPtrType {
+				return reflect.Value{}, mismatch
+			}
+			out.Set(reflect.ValueOf(&v.IUnknown))
+		default:
+			return reflect.Value{}, mismatch
+		}
+	default:
+		return reflect.Value{}, mismatch
+	}
+	return out, nil
+}
+
+// storeByRef writes value back through a VT_BYREF VARIANT, the way output
+// parameters are returned to automation clients.
+func storeByRef(v *ole.VARIANT, value reflect.Value) error {
+	if v.VT&ole.VT_BYREF == 0 {
+		return nil
+	}
+	vt := v.VT &^ ole.VT_BYREF
+	ptr := variantPointer(v)
+	if ptr == nil {
+		return ole.NewError(ole.E_POINTER)
+	}
+	if vt == ole.VT_VARIANT {
+		target := (*ole.VARIANT)(ptr)
+		if target.VT&ole.VT_BYREF != 0 {
+			return storeByRef(target, value)
+		}
+		replacement, err := ole.NewVariantFromValue(value.Interface())
+		if err != nil {
+			return err
+		}
+		ole.VariantClear(target)
+		*target = replacement
+		return nil
+	}
+
+	scalar := scalarFromValue(value.Interface())
+	switch vt {
+	case ole.VT_BOOL:
+		b, err := convertScalar(scalar, reflect.TypeOf(false))
+		if err != nil {
+			return err
+		}
+		if b.Bool() {
+			*(*int16)(ptr) = -1
+		} else {
+			*(*int16)(ptr) = 0
+		}
+		return nil
+	case ole.VT_DATE:
+		d, err := convertScalar(scalar, timeType)
+		if err != nil {
+			return err
+		}
+		*(*float64)(ptr) = ole.TimeToVariantDate(d.Interface().(time.Time))
+		return nil
+	case ole.VT_BSTR:
+		s, err := convertScalar(scalar, reflect.TypeOf(""))
+		if err != nil {
+			return err
+		}
+		old := (*int16)(*(*unsafe.Pointer)(ptr))
+		*(**int16)(ptr) = ole.SysAllocStringLen(s.String())
+		if old != nil {
+			ole.SysFreeString(old)
+		}
+		return nil
+	}
+
+	var target reflect.Type
+	switch vt {
+	case ole.VT_I1:
+		target = reflect.TypeOf(int8(0))
+	case ole.VT_UI1:
+		target = reflect.TypeOf(uint8(0))
+	case ole.VT_I2:
+		target = reflect.TypeOf(int16(0))
+	case ole.VT_UI2:
+		target = reflect.TypeOf(uint16(0))
+	case ole.VT_I4, ole.VT_INT:
+		target = reflect.TypeOf(int32(0))
+	case ole.VT_UI4, ole.VT_UINT:
+		target = reflect.TypeOf(uint32(0))
+	case ole.VT_I8:
+		target = reflect.TypeOf(int64(0))
+	case ole.VT_UI8:
+		target = reflect.TypeOf(uint64(0))
+	case ole.VT_R4:
+		target = reflect.TypeOf(float32(0))
+	case ole.VT_R8:
+		target = reflect.TypeOf(float64(0))
+	default:
+		return ole.NewError(ole.DISP_E_BADVARTYPE)
+	}
+	converted, err := convertScalar(scalar, target)
+	if err != nil {
+		return err
+	}
+	reflect.NewAt(target, ptr).Elem().Set(converted)
+	return nil
+}
@@ -0,0 +1,326 @@
+package ole
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Reference debugging records the interface pointers go-ole hands out, with
+// the stack of the call that obtained them, and counts the references taken
+// and given back through AddRef, Release and the clearing of VARIANTs:
+//
+//   - the pointers still held are reported by ReportLeaks, and by
+//     CoUninitialize, to standard error;
+//   - a Release of a pointer whose object released its last reference, or a
+//     call through it, panics with a *RefError instead of reaching the
+//     freed object.
+//
+// The pointers recorded are those returned by QueryInterface,
+// QueryInterfaceAs, CreateInstance, GetActiveObject and GetTypeInfo, and the
+// interfaces held by the results of Invoke. The references of other
+// pointers are not counted.
+//
+// The debugging is on when the package is built with the oledebug tag, or
+// when the GOOLE_REFDEBUG environment variable is set to a value other than
+// 0; EnableRefDebug turns it on and off.
+var refDebug struct {
+	sync.Mutex
+	live     map[uintptr]*refRecord
+	released map[uintptr]*refRecord
+	seq      uint64
+}
+
+// refDebugOn is 1 while reference debugging is on.
+var refDebugOn int32
+
+// maxReleasedRefs bounds the released pointers remembered to catch their
+// use; the memory of a released object may be reused anyway.
+const maxReleasedRefs = 4096
+
+// refRecord is a recorded interface pointer.
+type refRecord struct {
+	iface string
+	refs  int
+	seq   uint64
+	stack []uintptr // where the pointer was obtained, or released
+}
+
+func init() {
+	if env := os.Getenv("GOOLE_REFDEBUG"); refDebugTag || env != "" && env != "0" {
+		EnableRefDebug(true)
+	}
+}
+
+// EnableRefDebug turns reference debugging on or off. Either way the
+// pointers recorded so far are forgotten.
+func EnableRefDebug(on bool) {
+	refDebug.Lock()
+	defer refDebug.Unlock()
+	refDebug.live = make(map[uintptr]*refRecord)
+	refDebug.released = make(map[uintptr]*refRecord)
+	if on {
+		atomic.StoreInt32(&refDebugOn, 1)
+	} else {
+		atomic.StoreInt32(&refDebugOn, 0)
+	}
+}
+
+// RefDebugEnabled tells whether reference debugging is on.
+func RefDebugEnabled() bool {
+	return atomic.LoadInt32(&refDebugOn) != 0
+}
+
+// LiveRef is an interface pointer still held, as reference debugging
+// recorded it.
+type LiveRef struct {
+	Pointer   uintptr
+	Interface string // Go type of the interface, or its IID
+	Refs      int    // references held
+	Stack     string // where the pointer was obtained
+}
+
+// LiveRefs returns the interface pointers still held, in the order they
+// were obtained.
+func LiveRefs() []LiveRef {
+	refDebug.Lock()
+	defer refDebug.Unlock()
+	records := make([]*refRecord, 0, len(refDebug.live))
+	pointers := make(map[*refRecord]uintptr, len(refDebug.live))
+	for p, r := range refDebug.live {
+		records = append(records, r)
+		pointers[r] = p
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	refs := make([]LiveRef, len(records))
+	for i, r := range records {
+		refs[i] = LiveRef{
+			Pointer:   pointers[r],
+			Interface: r.iface,
+			Refs:      r.refs,
+			Stack:     formatStack(r.stack),
+		}
+	}
+	return refs
+}
+
+// LiveRefCounts returns the number of interface pointers still held, by
+// interface.
+func LiveRefCounts() map[string]int {
+	refDebug.Lock()
+	defer refDebug.Unlock()
+	counts := make(map[string]int)
+	for _, r := range refDebug.live {
+		counts[r.iface]++
+	}
+	return counts
+}
+
+// ReportLeaks writes the interface pointers still held to w, with the stack
+// where each was obtained, and returns their number.
+func ReportLeaks(w io.Writer) int {
+	refs := LiveRefs()
+	for _, ref := range refs {
+		fmt.Fprintf(w, "ole: %s %#x leaked with %d references, obtained at:\n%s", ref.Interface, ref.Pointer, ref.Refs, ref.Stack)
+	}
+	return len(refs)
+}
+
+// reportLeaksAtUninitialize reports the pointers still held when COM is
+// uninitialized.
+func reportLeaksAtUninitialize() {
+	if RefDebugEnabled() {
+		ReportLeaks(os.Stderr)
+	}
+}
+
+// RefError is the panic of reference debugging when a released interface
+// pointer is used.
+type RefError struct {
+	Op        string // what was done with the pointer, such as Release
+	Pointer   uintptr
+	Interface string
+	Stack     string // where the last reference was released
+}
+
+func (e *RefError) Error() string {
+	return fmt.Sprintf("ole: %s of %s %#x after its last reference was released at:\n%s", e.Op, e.Interface, e.Pointer, e.Stack)
+}
+
+// refAcquired records a reference to p, an interface pointer obtained as
+// iface.
+func refAcquired(p unsafe.Pointer, iface string) {
+	if p == nil || !RefDebugEnabled() {
+		return
+	}
+	key := uintptr(p)
+	refDebug.Lock()
+	defer refDebug.Unlock()
+	delete(refDebug.released, key)
+	if r, ok := refDebug.live[key]; ok {
+		r.refs++
+		return
+	}
+	refDebug.seq++
+	refDebug.live[key] = &refRecord{iface: iface, refs: 1, seq: refDebug.seq, stack: callers()}
+}
+
+// refAddRef counts a reference added to p, and panics when p has none
+// left.
+func refAddRef(p unsafe.Pointer) {
+	if p == nil || !RefDebugEnabled() {
+		return
+	}
+	refDebug.Lock()
+	err := releasedError(p, "AddRef")
+	if r, ok := refDebug.live[uintptr(p)]; ok {
+		r.refs++
+	}
+	refDebug.Unlock()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// refReleased counts a reference to p given back, after which the object
+// holds remaining references. p is released when none remain: references
+// from elsewhere, not counted, may keep it alive after those recorded are
+// given back.
+func refReleased(p unsafe.Pointer, remaining int32) {
+	if p == nil || !RefDebugEnabled() {
+		return
+	}
+	key := uintptr(p)
+	refDebug.Lock()
+	defer refDebug.Unlock()
+	r, ok := refDebug.live[key]
+	if !ok {
+		return
+	}
+	if r.refs--; r.refs == 0 || remaining <= 0 {
+		delete(refDebug.live, key)
+	}
+	if remaining > 0 {
+		return
+	}
+	if len(refDebug.released) >= maxReleasedRefs {
+		for old := range refDebug.released {
+			delete(refDebug.released, old)
+			break
+		}
+	}
+	r.stack = callers()
+	refDebug.released[key] = r
+}
+
+// refUse panics when p, about to be called for op, has been released.
+func refUse(p unsafe.Pointer, op string) {
+	if p == nil || !RefDebugEnabled() {
+		return
+	}
+	refDebug.Lock()
+	err := releasedError(p, op)
+	refDebug.Unlock()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// releasedError returns the error of op on p when the last reference to p
+// was released.
+func releasedError(p unsafe.Pointer, op string) *RefError {
+	r, ok := refDebug.released[uintptr(p)]
+	if !ok {
+		return nil
+	}
+	return &RefError{Op: op, Pointer: uintptr(p), Interface: r.iface, Stack: formatStack(r.stack)}
+}
+
+// refVariantAcquired records the reference to the interface v holds.
+func refVariantAcquired(v *VARIANT) {
+	if p, iface := variantInterface(v); p != nil {
+		refAcquired(p, iface)
+	}
+}
+
+// refVariantCleared counts the reference to the interface v holds, about
+// to be given back by clearing v. The references the object holds, which
+// VariantClear does not return, are those AddRef and Release return.
+func refVariantCleared(v *VARIANT) {
+	p, _ := variantInterface(v)
+	if p == nil {
+		return
+	}
+	refUse(p, "VariantClear")
+	refDebug.Lock()
+	_, tracked := refDebug.live[uintptr(p)]
+	refDebug.Unlock()
+	if tracked {
+		unk := (*IUnknown)(p)
+		addRef(unk)
+		refReleased(p, release(unk)-1)
+	}
+}
+
+// variantInterface returns the interface v holds, if any, and its name.
+func variantInterface(v *VARIANT) (unsafe.Pointer, string) {
+	if v == nil || v.Val == 0 || !RefDebugEnabled() {
+		return nil, ""
+	}
+	switch v.VT {
+	case VT_DISPATCH:
+		return *(*unsafe.Pointer)(unsafe.Pointer(&v.Val)), "IDispatch"
+	case VT_UNKNOWN:
+		return *(*unsafe.Pointer)(unsafe.Pointer(&v.Val)), "IUnknown"
+	}
+	return nil, ""
+}
+
+// interfaceName returns the name of the interface iid for reference
+// debugging: the Go type registered for it, or the IID.
+func interfaceName(iid *GUID) string {
+	if t := InterfaceType(iid); t != nil {
+		return t.Name()
+	}
+	if iid == nil {
+		return "IUnknown"
+	}
+	return iid.String()
+}
+
+// interfaceNameOf returns the name of the interface obj, a pointer to an
+// interface pointer, points to.
+func interfaceNameOf(obj interface{}, iid *GUID) string {
+	if t := reflect.TypeOf(obj); t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Ptr {
+		return t.Elem().Elem().Name()
+	}
+	return interfaceName(iid)
+}
+
+// callers returns the stack of the go-ole function recording a pointer.
+func callers() []uintptr {
+	pcs := make([]uintptr, 32)
+	return pcs[:runtime.Callers(3, pcs)]
+}
+
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build oledebug
+// +build oledebug
+
+package ole
+
+// refDebugTag turns reference debugging on in builds with the oledebug tag.
+const refDebugTag = true
@@ -0,0 +1,151 @@
+package ole
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// refTestObjects stand for interface pointers: their addresses, which
+// reference debugging records, do not move as those of stack variables may.
+var refTestObjects [4]IDispatch
+
+// expectRefError calls f and returns the *RefError it panics with.
+func expectRefError(t *testing.T, f func()) (refErr *RefError) {
+	t.Helper()
+	defer func() {
+		var ok bool
+		if refErr, ok = recover().(*RefError); !ok {
+			t.Errorf("no RefError panic, got %v", refErr)
+		}
+	}()
+	f()
+	return nil
+}
+
+func TestRefDebug_counts(t *testing.T) {
+	defer EnableRefDebug(RefDebugEnabled())
+	EnableRefDebug(true)
+
+	unk, disp := &refTestObjects[0].IUnknown, &refTestObjects[1]
+	refAcquired(unsafe.Pointer(unk), "IUnknown")
+	refAcquired(unsafe.Pointer(disp), "IDispatch")
+	refAddRef(unsafe.Pointer(disp))
+	refAddRef(unsafe.Pointer(&refTestObjects[2])) // not recorded, not counted
+
+	counts := LiveRefCounts()
+	if len(counts) != 2 || counts["IUnknown"] != 1 || counts["IDispatch"] != 1 {
+		t.Errorf("LiveRefCounts() = %v", counts)
+	}
+	refs := LiveRefs()
+	if len(refs) != 2 || refs[0].Pointer != uintptr(unsafe.Pointer(unk)) || refs[1].Refs != 2 {
+		t.Fatalf("LiveRefs() = %+v", refs)
+	}
+	if !strings.Contains(refs[0].Stack, "TestRefDebug_counts") {
+		t.Errorf("stack of the pointer obtained:\n%s", refs[0].Stack)
+	}
+
+	refReleased(unsafe.Pointer(disp), 1)
+	refReleased(unsafe.Pointer(unk), 0)
+	var report bytes.Buffer
+	if n := ReportLeaks(&report); n != 1 || !strings.Contains(report.String(), "IDispatch") {
+		t.Errorf("ReportLeaks() = %d:\n%s", n, report.String())
+	}
+	refReleased(unsafe.Pointer(disp), 0)
+	if counts := LiveRefCounts(); len(counts) != 0 {
+		t.Errorf("LiveRefCounts() = %v after the releases", counts)
+	}
+
+	// A pointer obtained again is alive again.
+	refAcquired(unsafe.Pointer(unk), "IUnknown")
+	refUse(unsafe.Pointer(unk), "QueryInterface")
+	if counts := LiveRefCounts(); counts["IUnknown"] != 1 {
+		t.Errorf("LiveRefCounts() = %v", counts)
+	}
+}
+
+func TestRefDebug_released(t *testing.T) {
+	defer EnableRefDebug(RefDebugEnabled())
+	EnableRefDebug(true)
+
+	unk := &refTestObjects[0].IUnknown
+	refAcquired(unsafe.Pointer(unk), "IUnknown")
+	refReleased(unsafe.Pointer(unk), 0)
+
+	// The panics come before the calls reach the object.
+	refErr := expectRefError(t, func() { unk.Release() })
+	if refErr != nil && (refErr.Op != "Release" || refErr.Interface != "IUnknown" || !strings.Contains(refErr.Stack, "TestRefDebug_released")) {
+		t.Errorf("double Release: %v", refErr)
+	}
+	if refErr := expectRefError(t, func() { unk.QueryInterface(IID_IDispatch) }); refErr != nil && refErr.Op != "QueryInterface" {
+		t.Errorf("QueryInterface after Release: %v", refErr)
+	}
+	disp := (*IDispatch)(unsafe.Pointer(unk))
+	if refErr := expectRefError(t, func() { disp.Invoke(0, DISPATCH_METHOD) }); refErr != nil && refErr.Op != "Invoke" {
+		t.Errorf("Invoke after Release: %v", refErr)
+	}
+	expectRefError(t, func() { refAddRef(unsafe.Pointer(unk)) })
+
+	EnableRefDebug(false)
+	refReleased(unsafe.Pointer(unk), 0)
+	if refs := LiveRefs(); len(refs) != 0 {
+		t.Errorf("LiveRefs() = %+v with the debugging off", refs)
+	}
+}
+
+func TestRefDebug_untracked(t *testing.T) {
+	defer EnableRefDebug(RefDebugEnabled())
+	EnableRefDebug(true)
+
+	// The object of disp holds a reference recorded, and one obtained
+	// elsewhere, such as from IEnumVARIANT.Next.
+	disp := &refTestObjects[1]
+	refAcquired(unsafe.Pointer(disp), "IDispatch")
+	refReleased(unsafe.Pointer(disp), 1)
+	if refs := LiveRefs(); len(refs) != 0 {
+		t.Errorf("LiveRefs() = %+v after the recorded reference was given back", refs)
+	}
+	refUse(unsafe.Pointer(disp), "Invoke")
+	refAddRef(unsafe.Pointer(disp))
+	refReleased(unsafe.Pointer(disp), 1)
+	refReleased(unsafe.Pointer(disp), 0)
+	refUse(unsafe.Pointer(disp), "Invoke")
+
+	// Obtained again, it is released with its last reference, whichever
+	// was given back.
+	refAcquired(unsafe.Pointer(disp), "IDispatch")
+	refAcquired(unsafe.Pointer(disp), "IDispatch")
+	refReleased(unsafe.Pointer(disp), 0)
+	if counts := LiveRefCounts(); len(counts) != 0 {
+		t.Errorf("LiveRefCounts() = %v after the object released its last reference", counts)
+	}
+	expectRefError(t, func() { refUse(unsafe.Pointer(disp), "Invoke") })
+}
+
+func TestRefDebug_variants(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("clearing asks the object, which the test objects are not, for its references")
+	}
+	defer EnableRefDebug(RefDebugEnabled())
+	EnableRefDebug(true)
+
+	disp := &refTestObjects[3]
+	v := NewVariant(VT_DISPATCH, int64(uintptr(unsafe.Pointer(disp))))
+	refVariantAcquired(&v)
+	if counts := LiveRefCounts(); counts["IDispatch"] != 1 {
+		t.Fatalf("LiveRefCounts() = %v", counts)
+	}
+	refVariantCleared(&v)
+	if counts := LiveRefCounts(); len(counts) != 0 {
+		t.Errorf("LiveRefCounts() = %v after clearing", counts)
+	}
+	expectRefError(t, func() { refVariantCleared(&v) })
+
+	byref := NewVariant(VT_DISPATCH|VT_BYREF, int64(uintptr(unsafe.Pointer(&disp))))
+	refVariantAcquired(&byref)
+	if counts := LiveRefCounts(); len(counts) != 0 {
+		t.Errorf("LiveRefCounts() = %v for a reference to an interface", counts)
+	}
+}
@@ -0,0 +1,139 @@
+package ole
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// interfaceRegistry maps the IIDs of interfaces to the Go types wrapping
+// them, and back.
+var interfaceRegistry = struct {
+	sync.RWMutex
+	types map[GUID]reflect.Type
+	iids  map[reflect.Type]*GUID
+}{
+	types: make(map[GUID]reflect.Type),
+	iids:  make(map[reflect.Type]*GUID),
+}
+
+var unknownType = reflect.TypeOf(IUnknown{})
+
+func init() {
+	for _, builtin := range []struct {
+		iid *GUID
+		ptr interface{}
+	}{
+		{IID_IUnknown, (*IUnknown)(nil)},
+		{IID_IDispatch, (*IDispatch)(nil)},
+		{IID_IClassFactory, (*IClassFactory)(nil)},
+		{IID_IEnumVariant, (*IEnumVARIANT)(nil)},
+		{IID_IConnectionPointContainer, (*IConnectionPointContainer)(nil)},
+		{IID_IConnectionPoint, (*IConnectionPoint)(nil)},
+		{IID_IEnumConnectionPoints, (*IEnumConnectionPoints)(nil)},
+		{IID_IEnumConnections, (*IEnumConnections)(nil)},
+		{IID_IInspectable, (*IInspectable)(nil)},
+		{IID_IProvideClassInfo, (*IProvideClassInfo)(nil)},
+		{IID_IProvideClassInfo2, (*IProvideClassInfo2)(nil)},
+		{IID_ITypeInfo, (*ITypeInfo)(nil)},
+		{IID_ITypeLib, (*ITypeLib)(nil)},
+	} {
+		RegisterInterface(builtin.iid, builtin.ptr)
+	}
+}
+
+// RegisterInterface records that the Go type ptr points to wraps the
+// interface iid, for QueryInterfaceAs. ptr is a nil pointer of the type,
+// such as (*IEnumVARIANT)(nil); the type must embed IUnknown, or another
+// such type, as its only field, so that its pointers are interface pointers.
+//
+// Bindings of other interfaces register theirs in an init function.
+// RegisterInterface panics when the type is not an interface wrapper, or
+// when the type or the IID is already registered with another counterpart.
+func RegisterInterface(iid *GUID, ptr interface{}) {
+	t := reflect.TypeOf(ptr)
+	if iid == nil || t == nil || t.Kind() != reflect.Ptr || !isInterfaceType(t.Elem()) {
+		panic(fmt.Sprintf("ole: RegisterInterface of %v: not a pointer to a type embedding IUnknown", t))
+	}
+	t = t.Elem()
+
+	interfaceRegistry.Lock()
+	defer interfaceRegistry.Unlock()
+	if registered, ok := interfaceRegistry.types[*iid]; ok && registered != t {
+		panic(fmt.Sprintf("ole: RegisterInterface of %v: %s is registered for %v", t, iid, registered))
+	}
+	if registered, ok := interfaceRegistry.iids[t]; ok && !IsEqualGUID(registered, iid) {
+		panic(fmt.Sprintf("ole: RegisterInterface of %s: %v is registered for %s", iid, t, registered))
+	}
+	interfaceRegistry.types[*iid] = t
+	interfaceRegistry.iids[t] = iid
+}
+
+// isInterfaceType tells whether the pointers to t are interface pointers:
+// whether t is IUnknown, or only embeds such a type.
+func isInterfaceType(t reflect.Type) bool {
+	for t != unknownType {
+		if t.Kind() != reflect.Struct || t.NumField() != 1 || !t.Field(0).Anonymous {
+			return false
+		}
+		t = t.Field(0).Type
+	}
+	return true
+}
+
+// InterfaceIDOf returns the IID registered for the Go type ptr points to,
+// or nil.
+func InterfaceIDOf(ptr interface{}) *GUID {
+	t := reflect.TypeOf(ptr)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil
+	}
+	interfaceRegistry.RLock()
+	defer interfaceRegistry.RUnlock()
+	return interfaceRegistry.iids[t.Elem()]
+}
+
+// InterfaceType returns the Go type registered for iid, or nil.
+func InterfaceType(iid *GUID) reflect.Type {
+	if iid == nil {
+		return nil
+	}
+	interfaceRegistry.RLock()
+	defer interfaceRegistry.RUnlock()
+	return interfaceRegistry.types[*iid]
+}
+
+// QueryInterfaceAs asks unk for the interface of the type target points to,
+// and stores it there. target is a pointer to a pointer of a type passed to
+// RegisterInterface, whose IID is asked for:
+//
+//	var enum *ole.IEnumVARIANT
+//	err := ole.QueryInterfaceAs(unk, &enum)
+//
+// The caller releases the interface.
+func QueryInterfaceAs(unk *IUnknown, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if !rv.IsValid() || rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return NewErrorWithDescription(E_INVALIDARG, fmt.Sprintf("QueryInterfaceAs target %T is not a pointer to an interface pointer", target))
+	}
+	t := rv.Elem().Type().Elem()
+	interfaceRegistry.RLock()
+	iid := interfaceRegistry.iids[t]
+	interfaceRegistry.RUnlock()
+	if iid == nil {
+		return NewErrorWithDescription(E_INVALIDARG, fmt.Sprintf("no interface registered for %v", t))
+	}
+	if unk == nil {
+		return NewError(E_POINTER)
+	}
+
+	refUse(unsafe.Pointer(unk), "QueryInterface")
+	p, err := queryInterface(unk, iid)
+	if err != nil {
+		return err
+	}
+	refAcquired(unsafe.Pointer(p), t.Name())
+	rv.Elem().Set(reflect.NewAt(t, unsafe.Pointer(p)))
+	return nil
+}
@@ -0,0 +1,67 @@
+package ole
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testRegistryInterface struct {
+	IDispatch
+}
+
+func TestRegisterInterface(t *testing.T) {
+	if iid := InterfaceIDOf((*IEnumVARIANT)(nil)); iid != IID_IEnumVariant {
+		t.Errorf("InterfaceIDOf(*IEnumVARIANT) = %v", iid)
+	}
+	if typ := InterfaceType(IID_ITypeInfo); typ != reflect.TypeOf(ITypeInfo{}) {
+		t.Errorf("InterfaceType(IID_ITypeInfo) = %v", typ)
+	}
+
+	iid := NewGUID("{C0DE0047-0000-4000-8000-000000000001}")
+	RegisterInterface(iid, (*testRegistryInterface)(nil))
+	RegisterInterface(NewGUID(iid.String()), (*testRegistryInterface)(nil))
+	if got := InterfaceIDOf((*testRegistryInterface)(nil)); got == nil || !IsEqualGUID(got, iid) {
+		t.Errorf("InterfaceIDOf = %v, want %v", got, iid)
+	}
+	if typ := InterfaceType(iid); typ != reflect.TypeOf(testRegistryInterface{}) {
+		t.Errorf("InterfaceType = %v", typ)
+	}
+
+	for _, bad := range []struct {
+		iid *GUID
+		ptr interface{}
+	}{
+		{iid, (*IEnumVARIANT)(nil)},
+		{IID_IDispatch, (*testRegistryInterface)(nil)},
+		{iid, testRegistryInterface{}},
+		{iid, (*struct{ p uintptr })(nil)},
+		{iid, (*struct {
+			IUnknown
+			n int
+		})(nil)},
+		{nil, (*testRegistryInterface)(nil)},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterInterface(%v, %T) did not panic", bad.iid, bad.ptr)
+				}
+			}()
+			RegisterInterface(bad.iid, bad.ptr)
+		}()
+	}
+}
+
+func TestQueryInterfaceAs_errors(t *testing.T) {
+	var unk IUnknown
+	var enum *IEnumVARIANT
+	var unregistered *struct{ IUnknown }
+	for _, target := range []interface{}{nil, enum, &unk, &unregistered} {
+		if err := QueryInterfaceAs(&unk, target); err == nil {
+			t.Errorf("QueryInterfaceAs(%T) succeeded", target)
+		}
+	}
+	if err := QueryInterfaceAs(nil, &enum); err == nil {
+		t.Error("QueryInterfaceAs(nil) succeeded")
+	}
+}
@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package ole
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestQueryInterfaceAs(t *testing.T) {
+	var refs int32
+	queryInterface := syscall.NewCallback(func(this uintptr, iid *GUID, out *uintptr) uintptr {
+		if !IsEqualGUID(iid, IID_IUnknown) && !IsEqualGUID(iid, IID_IEnumVariant) {
+			*out = 0
+			return E_NOINTERFACE
+		}
+		refs++
+		*out = this
+		return S_OK
+	})
+	release := syscall.NewCallback(func(this uintptr) uintptr {
+		refs--
+		return uintptr(refs)
+	})
+	vtbl := []uintptr{queryInterface, 0, release}
+	obj := &struct{ vtbl *uintptr }{&vtbl[0]}
+	unk := (*IUnknown)(unsafe.Pointer(obj))
+
+	var enum *IEnumVARIANT
+	if err := QueryInterfaceAs(unk, &enum); err != nil {
+		t.Fatal(err)
+	}
+	if unsafe.Pointer(enum) != unsafe.Pointer(obj) || refs != 1 {
+		t.Errorf("QueryInterfaceAs = %p with %d references, want %p with 1", enum, refs, obj)
+	}
+	enum.Release()
+
+	var point *IConnectionPoint
+	if err := QueryInterfaceAs(unk, &point); err == nil || point != nil {
+		t.Errorf("QueryInterfaceAs(*IConnectionPoint) = %p, %v", point, err)
+	}
+}
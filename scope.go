@@ -0,0 +1,168 @@
+package ole
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Scope releases the interfaces and clears the VARIANTs it tracks when it
+// is closed, last tracked first, so that intermediate objects of a chain of
+// calls are not leaked:
+//
+//	var scope ole.Scope
+//	defer scope.Close()
+//	workbooks := scope.MustGetProperty(excel, "Workbooks").ToIDispatch()
+//	book := scope.MustCallMethod(workbooks, "Add").ToIDispatch()
+//
+// The results of the calls made through the scope are tracked, and with
+// them the interfaces they hold. Detach takes back what must outlive the
+// scope. The zero value is an empty scope, safe for concurrent use; it can
+// be used again after Close.
+type Scope struct {
+	mu      sync.Mutex
+	entries []scopeEntry
+}
+
+// scopeEntry is an interface or a VARIANT tracked by a Scope.
+type scopeEntry struct {
+	obj     UnknownLike
+	variant *VARIANT
+}
+
+// Track adds obj to the interfaces the scope releases, and returns it.
+func (s *Scope) Track(obj UnknownLike) UnknownLike {
+	if obj != nil {
+		s.add(scopeEntry{obj: obj})
+	}
+	return obj
+}
+
+// TrackDispatch adds disp to the interfaces the scope releases, and returns
+// it.
+func (s *Scope) TrackDispatch(disp *IDispatch) *IDispatch {
+	if disp != nil {
+		s.add(scopeEntry{obj: disp})
+	}
+	return disp
+}
+
+// TrackVariant adds v to the VARIANTs the scope clears, and returns it.
+func (s *Scope) TrackVariant(v *VARIANT) *VARIANT {
+	if v != nil {
+		s.add(scopeEntry{variant: v})
+	}
+	return v
+}
+
+func (s *Scope) add(entry scopeEntry) {
+	s.mu.Lock()
+	s.entries = append(s.entries, entry)
+	s.mu.Unlock()
+}
+
+// Detach removes x, an interface or a *VARIANT, from the scope without
+// releasing it, and tells whether the scope tracked it. An interface held
+// by a tracked VARIANT, as ToIDispatch returns it, is detached with its
+// VARIANT; the reference of the VARIANT becomes the caller's.
+func (s *Scope) Detach(x interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].holds(x) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// holds tells whether x is the interface or VARIANT of e, or the interface
+// its VARIANT holds.
+func (e *scopeEntry) holds(x interface{}) bool {
+	if e.variant == nil {
+		return e.obj == x
+	}
+	if v, ok := x.(*VARIANT); ok {
+		return v == e.variant
+	}
+	if e.variant.VT != VT_DISPATCH && e.variant.VT != VT_UNKNOWN || e.variant.Val == 0 {
+		return false
+	}
+	held := *(*unsafe.Pointer)(unsafe.Pointer(&e.variant.Val))
+	switch obj := x.(type) {
+	case *IDispatch:
+		return unsafe.Pointer(obj) == held
+	case *IUnknown:
+		return unsafe.Pointer(obj) == held
+	}
+	return false
+}
+
+// Len returns the number of interfaces and VARIANTs the scope tracks.
+func (s *Scope) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Close releases the interfaces and clears the VARIANTs of the scope, in
+// the reverse order of their tracking, and empties it. The error is the
+// first of clearing the VARIANTs.
+func (s *Scope) Close() (err error) {
+	s.mu.Lock()
+	entries := s.entries
+	s.entries = nil
+	s.mu.Unlock()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].variant == nil {
+			entries[i].obj.Release()
+		} else if clearErr := entries[i].variant.Clear(); clearErr != nil && err == nil {
+			err = clearErr
+		}
+	}
+	return
+}
+
+// track adds the result of a call to the scope.
+func (s *Scope) track(result *VARIANT, err error) (*VARIANT, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s.TrackVariant(result), nil
+}
+
+// CallMethod calls the method name of disp, as IDispatch.CallMethod does,
+// and tracks its result.
+func (s *Scope) CallMethod(disp *IDispatch, name string, params ...interface{}) (*VARIANT, error) {
+	return s.track(disp.CallMethod(name, params...))
+}
+
+// GetProperty gets the property name of disp, as IDispatch.GetProperty
+// does, and tracks its result.
+func (s *Scope) GetProperty(disp *IDispatch, name string, params ...interface{}) (*VARIANT, error) {
+	return s.track(disp.GetProperty(name, params...))
+}
+
+// PutProperty puts the property name of disp, as IDispatch.PutProperty
+// does, and tracks its result.
+func (s *Scope) PutProperty(disp *IDispatch, name string, params ...interface{}) (*VARIANT, error) {
+	return s.track(disp.PutProperty(name, params...))
+}
+
+// MustCallMethod is CallMethod panicking on errors, for chains of calls.
+func (s *Scope) MustCallMethod(disp *IDispatch, name string, params ...interface{}) *VARIANT {
+	result, err := s.CallMethod(disp, name, params...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+// MustGetProperty is GetProperty panicking on errors, for chains of calls.
+func (s *Scope) MustGetProperty(disp *IDispatch, name string, params ...interface{}) *VARIANT {
+	result, err := s.GetProperty(disp, name, params...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
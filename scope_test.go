@@ -0,0 +1,99 @@
+package ole
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+// scopeTestObject records its releases in log.
+type scopeTestObject struct {
+	name string
+	log  *[]string
+}
+
+func (o *scopeTestObject) QueryInterface(iid *GUID) (*IDispatch, error) {
+	return nil, NewError(E_NOINTERFACE)
+}
+
+func (o *scopeTestObject) AddRef() int32 { return 1 }
+
+func (o *scopeTestObject) Release() int32 {
+	*o.log = append(*o.log, o.name)
+	return 0
+}
+
+func TestScope(t *testing.T) {
+	var log []string
+	a := &scopeTestObject{"a", &log}
+	b := &scopeTestObject{"b", &log}
+	c := &scopeTestObject{"c", &log}
+
+	var scope Scope
+	if scope.Track(a) != a {
+		t.Error("Track did not return its argument")
+	}
+	scope.Track(b)
+	scope.TrackDispatch(nil)
+	scope.Track(c)
+	if scope.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", scope.Len())
+	}
+	if !scope.Detach(b) || scope.Detach(b) {
+		t.Error("Detach(b) did not detach b once")
+	}
+	scope.Close()
+	if !reflect.DeepEqual(log, []string{"c", "a"}) {
+		t.Errorf("released %v, want [c a]", log)
+	}
+	if scope.Len() != 0 {
+		t.Errorf("Len() = %d after Close", scope.Len())
+	}
+
+	// The scope is used again.
+	log = nil
+	scope.Track(b)
+	scope.Close()
+	if !reflect.DeepEqual(log, []string{"b"}) {
+		t.Errorf("released %v, want [b]", log)
+	}
+}
+
+func TestScope_variants(t *testing.T) {
+	disp := new(IDispatch)
+	held := NewVariant(VT_DISPATCH, int64(uintptr(unsafe.Pointer(disp))))
+	other := NewVariant(VT_I4, 1)
+
+	var scope Scope
+	if scope.TrackVariant(&held) != &held {
+		t.Error("TrackVariant did not return its argument")
+	}
+	scope.TrackVariant(&other)
+	copied := other
+	if scope.Detach(new(IDispatch)) || scope.Detach(&copied) {
+		t.Error("detached what the scope does not track")
+	}
+	if !scope.Detach(disp) || scope.Len() != 1 {
+		t.Error("did not detach the VARIANT of an interface")
+	}
+	if !scope.Detach(&other) || scope.Len() != 0 {
+		t.Error("did not detach a VARIANT")
+	}
+}
+
+func TestScope_calls(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a failing IDispatch")
+	}
+	var scope Scope
+	if _, err := scope.CallMethod(new(IDispatch), "Method"); err == nil || scope.Len() != 0 {
+		t.Errorf("failed call = %v, tracking %d", err, scope.Len())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGetProperty did not panic")
+		}
+	}()
+	scope.MustGetProperty(new(IDispatch), "Property")
+}
@@ -0,0 +1,57 @@
+package sxs
+
+import (
+	"runtime"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// ActCtx is an activation context: the COM classes of the assemblies a
+// manifest depends on, visible to the threads it is activated on.
+//
+// The context is only needed while objects are created; the objects keep
+// working after it is deactivated.
+type ActCtx struct {
+	handle uintptr
+}
+
+// Do runs f with the context activated on the current OS thread. The
+// goroutine stays on the thread until f returns.
+func (c *ActCtx) Do(f func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cookie, err := activateActCtx(c.handle)
+	if err != nil {
+		return err
+	}
+	defer deactivateActCtx(cookie)
+	return f()
+}
+
+// CreateInstance calls ole.CreateInstance within the context.
+func (c *ActCtx) CreateInstance(clsid *ole.GUID, iid *ole.GUID) (unk *ole.IUnknown, err error) {
+	err = c.Do(func() (err error) {
+		unk, err = ole.CreateInstance(clsid, iid)
+		return
+	})
+	return
+}
+
+// CreateObject calls oleutil.CreateObject within the context.
+func (c *ActCtx) CreateObject(programID string) (unk *ole.IUnknown, err error) {
+	err = c.Do(func() (err error) {
+		unk, err = oleutil.CreateObject(programID)
+		return
+	})
+	return
+}
+
+// Release frees the context.
+func (c *ActCtx) Release() {
+	if c.handle != 0 {
+		releaseActCtx(c.handle)
+		c.handle = 0
+	}
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package sxs
+
+import ole "github.com/go-ole/go-ole"
+
+// CreateActCtx creates an activation context from an application manifest
+// file.
+func CreateActCtx(manifest string) (*ActCtx, error) {
+	return nil, ole.NewError(ole.E_NOTIMPL)
+}
+
+func activateActCtx(handle uintptr) (uintptr, error) {
+	return 0, ole.NewError(ole.E_NOTIMPL)
+}
+
+func deactivateActCtx(cookie uintptr) {}
+
+func releaseActCtx(handle uintptr) {}
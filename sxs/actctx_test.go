@@ -0,0 +1,16 @@
+// +build !windows
+
+package sxs
+
+import "testing"
+
+func TestActCtx_unsupported(t *testing.T) {
+	if _, err := CreateActCtx("app.manifest"); err == nil {
+		t.Error("CreateActCtx succeeded")
+	}
+	called := false
+	err := (&ActCtx{}).Do(func() error { called = true; return nil })
+	if err == nil || called {
+		t.Errorf("Do = %v, f called: %v", err, called)
+	}
+}
@@ -0,0 +1,79 @@
+//go:build windows
+// +build windows
+
+package sxs
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modkernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	procCreateActCtxW    = modkernel32.NewProc("CreateActCtxW")
+	procActivateActCtx   = modkernel32.NewProc("ActivateActCtx")
+	procDeactivateActCtx = modkernel32.NewProc("DeactivateActCtx")
+	procReleaseActCtx    = modkernel32.NewProc("ReleaseActCtx")
+)
+
+const actctxFlagAssemblyDirectoryValid = 0x004
+
+// actctx is the native ACTCTXW structure.
+type actctx struct {
+	size                  uint32
+	flags                 uint32
+	source                *uint16
+	processorArchitecture uint16
+	langID                uint16
+	assemblyDirectory     *uint16
+	resourceName          *uint16
+	applicationName       *uint16
+	module                uintptr
+}
+
+// CreateActCtx creates an activation context from an application manifest
+// file. Dependent assemblies are looked up in the directory of the manifest.
+func CreateActCtx(manifest string) (*ActCtx, error) {
+	manifest, err := filepath.Abs(manifest)
+	if err != nil {
+		return nil, err
+	}
+	source, err := syscall.UTF16PtrFromString(manifest)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := syscall.UTF16PtrFromString(filepath.Dir(manifest))
+	if err != nil {
+		return nil, err
+	}
+	ctx := actctx{
+		flags:             actctxFlagAssemblyDirectoryValid,
+		source:            source,
+		assemblyDirectory: dir,
+	}
+	ctx.size = uint32(unsafe.Sizeof(ctx))
+	handle, _, err := procCreateActCtxW.Call(uintptr(unsafe.Pointer(&ctx)))
+	if handle == uintptr(windows.InvalidHandle) {
+		return nil, err
+	}
+	return &ActCtx{handle: handle}, nil
+}
+
+func activateActCtx(handle uintptr) (cookie uintptr, err error) {
+	ret, _, err := procActivateActCtx.Call(handle, uintptr(unsafe.Pointer(&cookie)))
+	if ret == 0 {
+		return 0, err
+	}
+	return cookie, nil
+}
+
+func deactivateActCtx(cookie uintptr) {
+	procDeactivateActCtx.Call(0, cookie)
+}
+
+func releaseActCtx(handle uintptr) {
+	procReleaseActCtx.Call(handle)
+}
@@ -0,0 +1,165 @@
+// Package sxs supports registration-free COM: it reads and writes the
+// side-by-side manifests describing COM servers without registry entries,
+// and activates them around object creation on Windows.
+package sxs
+
+import (
+	"bytes"
+	"encoding/xml"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleserver"
+)
+
+// Manifest is an application or assembly manifest.
+type Manifest struct {
+	XMLName         xml.Name         `xml:"urn:schemas-microsoft-com:asm.v1 assembly"`
+	ManifestVersion string           `xml:"manifestVersion,attr"`
+	Identity        AssemblyIdentity `xml:"assemblyIdentity"`
+	Description     string           `xml:"description,omitempty"`
+	Files           []File           `xml:"file"`
+	ProxyStubs      []ProxyStub      `xml:"comInterfaceExternalProxyStub"`
+	Dependencies    []Dependency     `xml:"dependency"`
+}
+
+// AssemblyIdentity names an assembly, such as
+// {Type: "win32", Name: "GoOle.Calculator", Version: "1.0.0.0"}.
+type AssemblyIdentity struct {
+	Type                  string `xml:"type,attr,omitempty"`
+	Name                  string `xml:"name,attr"`
+	Version               string `xml:"version,attr,omitempty"`
+	ProcessorArchitecture string `xml:"processorArchitecture,attr,omitempty"`
+	PublicKeyToken        string `xml:"publicKeyToken,attr,omitempty"`
+	Language              string `xml:"language,attr,omitempty"`
+}
+
+// File is a file of an assembly with the COM classes and type libraries it
+// serves.
+type File struct {
+	Name       string      `xml:"name,attr"`
+	Classes    []ComClass  `xml:"comClass"`
+	TypeLibs   []TypeLib   `xml:"typelib"`
+	ProxyStubs []ProxyStub `xml:"comInterfaceProxyStub"`
+}
+
+// ComClass is a class served by a file, the manifest counterpart of the
+// CLSID registry key.
+type ComClass struct {
+	CLSID          *GUID  `xml:"clsid,attr"`
+	ThreadingModel string `xml:"threadingModel,attr,omitempty"`
+	ProgID         string `xml:"progid,attr,omitempty"`
+	TypeLib        *GUID  `xml:"tlbid,attr,omitempty"`
+	Description    string `xml:"description,attr,omitempty"`
+
+	// ProgIDs are additional ProgIDs of the class, usually the
+	// version-independent one.
+	ProgIDs []string `xml:"progid"`
+}
+
+// TypeLib is a type library embedded in a file.
+type TypeLib struct {
+	ID         *GUID  `xml:"tlbid,attr"`
+	Version    string `xml:"version,attr"`
+	HelpDir    string `xml:"helpdir,attr"`
+	ResourceID string `xml:"resourceid,attr,omitempty"`
+	Flags      string `xml:"flags,attr,omitempty"`
+}
+
+// ProxyStub describes how calls on an interface are marshaled: by the proxy
+// and stub of a file (comInterfaceProxyStub) or, for automation interfaces,
+// by the type library marshaler (comInterfaceExternalProxyStub).
+type ProxyStub struct {
+	Name             string `xml:"name,attr,omitempty"`
+	IID              *GUID  `xml:"iid,attr"`
+	ProxyStubCLSID32 *GUID  `xml:"proxyStubClsid32,attr,omitempty"`
+	TypeLib          *GUID  `xml:"tlbid,attr,omitempty"`
+	NumMethods       string `xml:"numMethods,attr,omitempty"`
+	BaseInterface    *GUID  `xml:"baseInterface,attr,omitempty"`
+}
+
+// GUID is a GUID in an attribute of a manifest, in the registry format:
+// {3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A31}.
+type GUID ole.GUID
+
+// MarshalText implements encoding.TextMarshaler.
+func (guid GUID) MarshalText() ([]byte, error) {
+	return []byte((*ole.GUID)(&guid).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting the formats
+// of ole.NewGUID.
+func (guid *GUID) UnmarshalText(text []byte) error {
+	parsed := ole.NewGUID(string(text))
+	if parsed == nil {
+		return ole.NewErrorWithDescription(ole.E_INVALIDARG, "invalid GUID "+string(text))
+	}
+	*guid = GUID(*parsed)
+	return nil
+}
+
+// Dependency is an assembly an application or assembly uses.
+type Dependency struct {
+	Assembly AssemblyIdentity `xml:"dependentAssembly>assemblyIdentity"`
+}
+
+// TypeLibMarshaler is the CLSID of the proxy and stub of automation
+// interfaces, marshaled from their type library.
+var TypeLibMarshaler = (*GUID)(ole.NewGUID("{00020424-0000-0000-C000-000000000046}"))
+
+// ParseManifest reads a manifest. Elements the model does not cover are
+// ignored.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Bytes returns the manifest as an XML document.
+func (m *Manifest) Bytes() ([]byte, error) {
+	if m.ManifestVersion == "" {
+		m.ManifestVersion = "1.0"
+	}
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		return nil, err
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// ApplicationManifest returns the manifest of an application using the
+// assemblies.
+func ApplicationManifest(application AssemblyIdentity, assemblies ...AssemblyIdentity) *Manifest {
+	m := &Manifest{ManifestVersion: "1.0", Identity: application}
+	for _, assembly := range assemblies {
+		m.Dependencies = append(m.Dependencies, Dependency{Assembly: assembly})
+	}
+	return m
+}
+
+// AssemblyManifest returns the manifest of an assembly whose file serves
+// the registered classes. Only the entries of a registration which have a
+// manifest counterpart are used: the location of the server is the file,
+// and AppID settings have no manifest equivalent.
+func AssemblyManifest(assembly AssemblyIdentity, file string, registrations ...oleserver.Registration) *Manifest {
+	f := File{Name: file}
+	for _, r := range registrations {
+		class := ComClass{
+			CLSID:          (*GUID)(r.CLSID),
+			ThreadingModel: r.ThreadingModel,
+			ProgID:         r.ProgID,
+			TypeLib:        (*GUID)(r.TypeLib),
+			Description:    r.Description,
+		}
+		if r.VersionIndependentProgID != "" {
+			class.ProgIDs = []string{r.VersionIndependentProgID}
+		}
+		f.Classes = append(f.Classes, class)
+	}
+	return &Manifest{ManifestVersion: "1.0", Identity: assembly, Files: []File{f}}
+}
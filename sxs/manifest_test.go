@@ -0,0 +1,173 @@
+package sxs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleserver"
+)
+
+var (
+	clsidCalculator = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A31}")
+	libidCalculator = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A30}")
+	iidCalculator   = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A32}")
+	iidCustom       = ole.NewGUID("{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A33}")
+)
+
+var calculatorIdentity = AssemblyIdentity{
+	Type:                  "win32",
+	Name:                  "GoOle.Calculator",
+	Version:               "1.2.0.0",
+	ProcessorArchitecture: "amd64",
+}
+
+func roundTrip(t *testing.T, m *Manifest) *Manifest {
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes = %v", err)
+	}
+	parsed, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("ParseManifest(Bytes()) = %v\n%s", err, data)
+	}
+	return parsed
+}
+
+func TestParseManifest(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "calculator.manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Manifest{
+		XMLName:         m.XMLName,
+		ManifestVersion: "1.0",
+		Identity:        calculatorIdentity,
+		Description:     "Go calculator component",
+		Files: []File{{
+			Name: "calculator.dll",
+			Classes: []ComClass{{
+				CLSID:          (*GUID)(clsidCalculator),
+				ThreadingModel: "Both",
+				ProgID:         "GoOle.Calculator.1",
+				TypeLib:        (*GUID)(libidCalculator),
+				Description:    "Go Calculator",
+				ProgIDs:        []string{"GoOle.Calculator"},
+			}},
+			TypeLibs: []TypeLib{{
+				ID:         (*GUID)(libidCalculator),
+				Version:    "1.0",
+				ResourceID: "1",
+				Flags:      "HASDISKIMAGE",
+			}},
+			ProxyStubs: []ProxyStub{{Name: "ICalculatorCustom", IID: (*GUID)(iidCustom), NumMethods: "5"}},
+		}},
+		ProxyStubs: []ProxyStub{{
+			Name:             "ICalculator",
+			IID:              (*GUID)(iidCalculator),
+			ProxyStubCLSID32: TypeLibMarshaler,
+			TypeLib:          (*GUID)(libidCalculator),
+			BaseInterface:    (*GUID)(ole.IID_IDispatch),
+		}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("ParseManifest =\n%+v\nwant\n%+v", m, want)
+	}
+	if got := roundTrip(t, m); !reflect.DeepEqual(got, m) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, m)
+	}
+}
+
+func TestParseManifest_invalid(t *testing.T) {
+	tests := []string{
+		`<assembly xmlns="urn:schemas-microsoft-com:asm.v1"><file name="a.dll"><comClass clsid="{nope}"/></file></assembly>`,
+		`<assembly xmlns="urn:other"/>`,
+		`<assembly`,
+	}
+	for _, test := range tests {
+		if _, err := ParseManifest([]byte(test)); err == nil {
+			t.Errorf("ParseManifest(%q) succeeded", test)
+		}
+	}
+}
+
+func TestAssemblyManifest(t *testing.T) {
+	m := AssemblyManifest(calculatorIdentity, "calculator.dll", oleserver.Registration{
+		CLSID:                    clsidCalculator,
+		Description:              "Go Calculator",
+		ProgID:                   "GoOle.Calculator.1",
+		VersionIndependentProgID: "GoOle.Calculator",
+		InprocServer:             `C:\ignored\calculator.dll`,
+		ThreadingModel:           oleserver.ThreadingApartment,
+		TypeLib:                  libidCalculator,
+	})
+	m.Files[0].TypeLibs = []TypeLib{{ID: (*GUID)(libidCalculator), Version: "1.0"}}
+
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`,
+		`<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">`,
+		`<comClass clsid="{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A31}" threadingModel="Apartment" progid="GoOle.Calculator.1" tlbid="{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A30}" description="Go Calculator">`,
+		`<progid>GoOle.Calculator</progid>`,
+		`<typelib tlbid="{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A30}" version="1.0" helpdir=""></typelib>`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("manifest lacks %s:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "ignored") {
+		t.Errorf("manifest holds the registry location of the server:\n%s", text)
+	}
+
+	if got := roundTrip(t, m); !reflect.DeepEqual(got.Files, m.Files) || got.Identity != m.Identity {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, m)
+	}
+}
+
+func TestApplicationManifest(t *testing.T) {
+	application := AssemblyIdentity{Type: "win32", Name: "GoOle.Client", Version: "1.0.0.0"}
+	m := ApplicationManifest(application, calculatorIdentity)
+
+	data, _ := m.Bytes()
+	if !strings.Contains(string(data), `<dependency>
+    <dependentAssembly>
+      <assemblyIdentity type="win32" name="GoOle.Calculator" version="1.2.0.0" processorArchitecture="amd64"></assemblyIdentity>
+    </dependentAssembly>
+  </dependency>`) {
+		t.Errorf("manifest lacks the dependency:\n%s", data)
+	}
+
+	got := roundTrip(t, m)
+	if len(got.Dependencies) != 1 || got.Dependencies[0].Assembly != calculatorIdentity || got.Identity != application {
+		t.Errorf("round trip = %+v", got)
+	}
+}
+
+func TestGUID_text(t *testing.T) {
+	for _, text := range []string{"{3F8A2C61-0D4E-4B7A-9E15-6C2B8D0F7A31}", "3f8a2c61-0d4e-4b7a-9e15-6c2b8d0f7a31"} {
+		var guid GUID
+		if err := guid.UnmarshalText([]byte(text)); err != nil {
+			t.Errorf("UnmarshalText(%q) = %v", text, err)
+			continue
+		}
+		if marshaled, _ := guid.MarshalText(); string(marshaled) != clsidCalculator.String() {
+			t.Errorf("MarshalText after UnmarshalText(%q) = %s", text, marshaled)
+		}
+	}
+	var guid GUID
+	if err := guid.UnmarshalText([]byte("{nope}")); err == nil {
+		t.Error("UnmarshalText accepted {nope}")
+	}
+}
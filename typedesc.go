@@ -0,0 +1,254 @@
+package ole
+
+import "unsafe"
+
+// ELEMDESC describes a parameter, result or variable.
+//
+// Flags holds the PARAMFLAG_* flags of a parameter, or the IDLFLAG_* flags
+// of a result or variable, whose values are the first PARAMFLAG_* ones.
+// Default is the default value of an optional parameter declared with
+// PARAMFLAG_FHASDEFAULT.
+type ELEMDESC struct {
+	Tdesc   TYPEDESC
+	Flags   uint16
+	Default *VARIANT
+}
+
+// FUNCDESC describes a function of a type.
+//
+// It is a copy of the native structure, which GetFuncDesc releases. Call
+// Clear to free the default values of the parameters.
+type FUNCDESC struct {
+	Memid        int32
+	Scodes       []int32
+	Params       []ELEMDESC
+	FuncKind     int32
+	InvKind      int32
+	CallConv     int32
+	CParamsOpt   int16
+	OVft         int16
+	ElemdescFunc ELEMDESC
+	WFuncFlags   uint16
+}
+
+// Clear frees the default values of the parameters.
+func (f *FUNCDESC) Clear() {
+	for i := range f.Params {
+		if f.Params[i].Default != nil {
+			VariantClear(f.Params[i].Default)
+			f.Params[i].Default = nil
+		}
+	}
+}
+
+// VARDESC describes a variable, constant or data member of a type.
+//
+// It is a copy of the native structure, which GetVarDesc releases. OInst is
+// the offset of a VAR_PERINSTANCE variable and Value the value of a
+// VAR_CONST one; call Clear to free it.
+type VARDESC struct {
+	Memid       int32
+	OInst       uint32
+	Value       *VARIANT
+	ElemdescVar ELEMDESC
+	WVarFlags   uint16
+	VarKind     int32
+}
+
+// Clear frees the value of a constant.
+func (v *VARDESC) Clear() {
+	if v.Value != nil {
+		VariantClear(v.Value)
+		v.Value = nil
+	}
+}
+
+// The native structures follow. Their pointer-sized unions are declared as
+// unsafe.Pointer, which gives them the C layout on every architecture.
+
+type nativeTypeDesc struct {
+	union unsafe.Pointer
+	vt    uint16
+}
+
+type nativeArrayDesc struct {
+	tdescElem nativeTypeDesc
+	cDims     uint16
+	bounds    [1]SafeArrayBound
+}
+
+// nativeElemDesc holds an IDLDESC or a PARAMDESC, which share their layout.
+type nativeElemDesc struct {
+	tdesc     nativeTypeDesc
+	paramDesc unsafe.Pointer // PARAMDESCEX, or reserved for IDLDESC
+	flags     uint16
+}
+
+type nativeTypeAttr struct {
+	guid             GUID
+	lcid             uint32
+	dwReserved       uint32
+	memidConstructor int32
+	memidDestructor  int32
+	lpstrSchema      unsafe.Pointer
+	cbSizeInstance   uint32
+	typekind         int32
+	cFuncs           uint16
+	cVars            uint16
+	cImplTypes       uint16
+	cbSizeVft        uint16
+	cbAlignment      uint16
+	wTypeFlags       uint16
+	wMajorVerNum     uint16
+	wMinorVerNum     uint16
+	tdescAlias       nativeTypeDesc
+	idldescType      struct {
+		dwReserved uintptr
+		wIDLFlags  uint16
+	}
+}
+
+type nativeFuncDesc struct {
+	memid        int32
+	lprgscode    unsafe.Pointer
+	lprgelemdesc unsafe.Pointer
+	funckind     int32
+	invkind      int32
+	callconv     int32
+	cParams      int16
+	cParamsOpt   int16
+	oVft         int16
+	cScodes      int16
+	elemdescFunc nativeElemDesc
+	wFuncFlags   uint16
+}
+
+type nativeVarDesc struct {
+	memid       int32
+	lpstrSchema unsafe.Pointer
+	union       unsafe.Pointer // oInst or the VARIANT of a constant
+	elemdescVar nativeElemDesc
+	wVarFlags   uint16
+	varkind     int32
+}
+
+// paramDescExValueOffset is the offset of the default value in PARAMDESCEX,
+// whose VARIANT is 8-byte aligned on every architecture.
+const paramDescExValueOffset = 8
+
+// copyVariant returns a copy of v owned by the caller.
+func copyVariant(v *VARIANT) *VARIANT {
+	copied := new(VARIANT)
+	VariantInit(copied)
+	if err := VariantCopy(copied, v); err != nil {
+		// VariantCopy is only missing off Windows, where no native
+		// descriptor can hold references.
+		*copied = *v
+	}
+	return copied
+}
+
+func copyTypeDesc(native *nativeTypeDesc) TYPEDESC {
+	desc := TYPEDESC{VT: native.vt}
+	switch VT(native.vt) {
+	case VT_PTR, VT_SAFEARRAY:
+		if native.union != nil {
+			elem := copyTypeDesc((*nativeTypeDesc)(native.union))
+			desc.Lptdesc = &elem
+		}
+	case VT_CARRAY:
+		if native.union != nil {
+			array := (*nativeArrayDesc)(native.union)
+			count := int(array.cDims)
+			bounds := (*[1 << 16]SafeArrayBound)(unsafe.Pointer(&array.bounds[0]))[:count:count]
+			desc.Lpadesc = &ARRAYDESC{
+				TdescElem: copyTypeDesc(&array.tdescElem),
+				Bounds:    append([]SafeArrayBound(nil), bounds...),
+			}
+		}
+	case VT_USERDEFINED:
+		desc.Hreftype = uint32(uintptr(native.union))
+	}
+	return desc
+}
+
+// copyElemDesc copies a parameter description. Only parameters have default
+// values; withDefault is false for results and variables, whose union holds
+// an IDLDESC.
+func copyElemDesc(native *nativeElemDesc, withDefault bool) ELEMDESC {
+	desc := ELEMDESC{
+		Tdesc: copyTypeDesc(&native.tdesc),
+		Flags: native.flags,
+	}
+	if withDefault && native.flags&PARAMFLAG_FHASDEFAULT != 0 && native.paramDesc != nil {
+		desc.Default = copyVariant((*VARIANT)(unsafe.Pointer(uintptr(native.paramDesc) + paramDescExValueOffset)))
+	}
+	return desc
+}
+
+func copyTypeAttr(native *nativeTypeAttr) *TYPEATTR {
+	return &TYPEATTR{
+		Guid:             native.guid,
+		Lcid:             native.lcid,
+		MemidConstructor: native.memidConstructor,
+		MemidDestructor:  native.memidDestructor,
+		CbSizeInstance:   native.cbSizeInstance,
+		Typekind:         native.typekind,
+		CFuncs:           native.cFuncs,
+		CVars:            native.cVars,
+		CImplTypes:       native.cImplTypes,
+		CbSizeVft:        native.cbSizeVft,
+		CbAlignment:      native.cbAlignment,
+		WTypeFlags:       native.wTypeFlags,
+		WMajorVerNum:     native.wMajorVerNum,
+		WMinorVerNum:     native.wMinorVerNum,
+		TdescAlias:       copyTypeDesc(&native.tdescAlias),
+		IdldescType: IDLDESC{
+			DwReserved: uint32(native.idldescType.dwReserved),
+			WIDLFlags:  native.idldescType.wIDLFlags,
+		},
+	}
+}
+
+func copyFuncDesc(native *nativeFuncDesc) *FUNCDESC {
+	desc := &FUNCDESC{
+		Memid:        native.memid,
+		FuncKind:     native.funckind,
+		InvKind:      native.invkind,
+		CallConv:     native.callconv,
+		CParamsOpt:   native.cParamsOpt,
+		OVft:         native.oVft,
+		ElemdescFunc: copyElemDesc(&native.elemdescFunc, false),
+		WFuncFlags:   native.wFuncFlags,
+	}
+	if count := int(native.cScodes); count > 0 && native.lprgscode != nil {
+		scodes := (*[1 << 16]int32)(native.lprgscode)[:count:count]
+		desc.Scodes = append([]int32(nil), scodes...)
+	}
+	if count := int(native.cParams); count > 0 && native.lprgelemdesc != nil {
+		params := (*[1 << 16]nativeElemDesc)(native.lprgelemdesc)[:count:count]
+		desc.Params = make([]ELEMDESC, count)
+		for i := range params {
+			desc.Params[i] = copyElemDesc(&params[i], true)
+		}
+	}
+	return desc
+}
+
+func copyVarDesc(native *nativeVarDesc) *VARDESC {
+	desc := &VARDESC{
+		Memid:       native.memid,
+		ElemdescVar: copyElemDesc(&native.elemdescVar, false),
+		WVarFlags:   native.wVarFlags,
+		VarKind:     native.varkind,
+	}
+	switch native.varkind {
+	case VAR_CONST:
+		if native.union != nil {
+			desc.Value = copyVariant((*VARIANT)(native.union))
+		}
+	default:
+		desc.OInst = uint32(uintptr(native.union))
+	}
+	return desc
+}
@@ -0,0 +1,210 @@
+package ole
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// setUnion stores an integer in a pointer-sized union.
+func setUnion(union *unsafe.Pointer, value uintptr) {
+	*(*uintptr)(unsafe.Pointer(union)) = value
+}
+
+func TestNativeDescriptorSizes(t *testing.T) {
+	sizes := []struct {
+		name    string
+		size    uintptr
+		on32bit uintptr
+		on64bit uintptr
+	}{
+		{"TYPEDESC", unsafe.Sizeof(nativeTypeDesc{}), 8, 16},
+		{"ELEMDESC", unsafe.Sizeof(nativeElemDesc{}), 16, 32},
+		{"TYPEATTR", unsafe.Sizeof(nativeTypeAttr{}), 76, 96},
+		{"FUNCDESC", unsafe.Sizeof(nativeFuncDesc{}), 52, 88},
+		{"FUNCDESC.elemdescFunc", unsafe.Offsetof(nativeFuncDesc{}.elemdescFunc), 32, 48},
+		{"VARDESC", unsafe.Sizeof(nativeVarDesc{}), 36, 64},
+		{"TLIBATTR", unsafe.Sizeof(TLIBATTR{}), 32, 32},
+		{"ARRAYDESC.rgbounds", unsafe.Offsetof(nativeArrayDesc{}.bounds), 12, 20},
+	}
+	is64bit := unsafe.Sizeof(uintptr(0)) == 8
+	for _, size := range sizes {
+		want := size.on32bit
+		if is64bit {
+			want = size.on64bit
+		}
+		if size.size != want {
+			t.Errorf("native %s is %d bytes, want %d", size.name, size.size, want)
+		}
+	}
+}
+
+func TestCallingConventions(t *testing.T) {
+	// Values of the CALLCONV enumeration in oaidl.h.
+	got := []int{CC_FASTCALL, CC_CDECL, CC_MSCPASCAL, CC_MACPASCAL, CC_STDCALL, CC_FPFASTCALL, CC_SYSCALL, CC_MPWCDECL, CC_MPWPASCAL, CC_MAX}
+	want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CC_* = %v, want %v", got, want)
+	}
+}
+
+func TestTypeKinds(t *testing.T) {
+	// Values of the TYPEKIND enumeration in oaidl.h.
+	got := []int{TKIND_ENUM, TKIND_RECORD, TKIND_MODULE, TKIND_INTERFACE, TKIND_DISPATCH, TKIND_COCLASS, TKIND_ALIAS, TKIND_UNION, TKIND_MAX}
+	want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TKIND_* = %v, want %v", got, want)
+	}
+}
+
+func TestCopyFuncDesc(t *testing.T) {
+	// HRESULT Item([in] VARIANT* index, [in, optional] long count[2][3],
+	//              [out, retval] IThing** result)
+	thing := nativeTypeDesc{vt: uint16(VT_USERDEFINED)}
+	setUnion(&thing.union, 0x42)
+	thingPtr := nativeTypeDesc{union: unsafe.Pointer(&thing), vt: uint16(VT_PTR)}
+	variant := nativeTypeDesc{vt: uint16(VT_VARIANT)}
+
+	// An ARRAYDESC with two dimensions.
+	array := struct {
+		tdescElem nativeTypeDesc
+		cDims     uint16
+		bounds    [2]SafeArrayBound
+	}{
+		tdescElem: nativeTypeDesc{vt: uint16(VT_I4)},
+		cDims:     2,
+		bounds:    [2]SafeArrayBound{{Elements: 2}, {Elements: 3, LowerBound: 1}},
+	}
+
+	params := []nativeElemDesc{
+		{tdesc: nativeTypeDesc{union: unsafe.Pointer(&variant), vt: uint16(VT_PTR)}, flags: PARAMFLAG_FIN},
+		{tdesc: nativeTypeDesc{union: unsafe.Pointer(&array), vt: uint16(VT_CARRAY)}, flags: PARAMFLAG_FIN | PARAMFLAG_FOPT},
+		{tdesc: nativeTypeDesc{union: unsafe.Pointer(&thingPtr), vt: uint16(VT_PTR)}, flags: PARAMFLAG_FOUT | PARAMFLAG_FRETVAL},
+	}
+	scodes := []int32{int32(-2147352567)}
+	native := nativeFuncDesc{
+		memid:        DISPID_VALUE,
+		lprgscode:    unsafe.Pointer(&scodes[0]),
+		lprgelemdesc: unsafe.Pointer(&params[0]),
+		funckind:     FUNC_PUREVIRTUAL,
+		invkind:      INVOKE_FUNC,
+		callconv:     CC_STDCALL,
+		cParams:      3,
+		cParamsOpt:   0,
+		oVft:         56,
+		cScodes:      1,
+		elemdescFunc: nativeElemDesc{tdesc: nativeTypeDesc{vt: uint16(VT_HRESULT)}},
+		wFuncFlags:   FUNCFLAG_FDEFAULTCOLLELEM,
+	}
+
+	desc := copyFuncDesc(&native)
+	want := &FUNCDESC{
+		Memid:    DISPID_VALUE,
+		Scodes:   scodes,
+		FuncKind: FUNC_PUREVIRTUAL,
+		InvKind:  INVOKE_FUNC,
+		CallConv: CC_STDCALL,
+		OVft:     56,
+		Params: []ELEMDESC{
+			{Tdesc: TYPEDESC{VT: uint16(VT_PTR), Lptdesc: &TYPEDESC{VT: uint16(VT_VARIANT)}}, Flags: PARAMFLAG_FIN},
+			{Tdesc: TYPEDESC{VT: uint16(VT_CARRAY), Lpadesc: &ARRAYDESC{
+				TdescElem: TYPEDESC{VT: uint16(VT_I4)},
+				Bounds:    []SafeArrayBound{{Elements: 2}, {Elements: 3, LowerBound: 1}},
+			}}, Flags: PARAMFLAG_FIN | PARAMFLAG_FOPT},
+			{Tdesc: TYPEDESC{VT: uint16(VT_PTR), Lptdesc: &TYPEDESC{
+				VT:      uint16(VT_PTR),
+				Lptdesc: &TYPEDESC{VT: uint16(VT_USERDEFINED), Hreftype: 0x42},
+			}}, Flags: PARAMFLAG_FOUT | PARAMFLAG_FRETVAL},
+		},
+		ElemdescFunc: ELEMDESC{Tdesc: TYPEDESC{VT: uint16(VT_HRESULT)}},
+		WFuncFlags:   FUNCFLAG_FDEFAULTCOLLELEM,
+	}
+	if !reflect.DeepEqual(desc, want) {
+		t.Errorf("copyFuncDesc =\n%+v\nwant\n%+v", desc, want)
+	}
+
+	// The copy must not share memory with the native descriptor.
+	scodes[0] = 0
+	array.bounds[1].Elements = 0
+	if desc.Scodes[0] == 0 || desc.Params[1].Tdesc.Lpadesc.Bounds[1].Elements == 0 {
+		t.Error("copy refers to the native descriptor")
+	}
+}
+
+func TestCopyElemDesc_default(t *testing.T) {
+	// PARAMDESCEX: ULONG cBytes, then an 8-byte aligned VARIANT.
+	ex := struct {
+		cBytes uint32
+		_      uint32
+		value  VARIANT
+	}{cBytes: 24, value: NewVariant(VT_I4, 7)}
+	native := nativeElemDesc{
+		tdesc:     nativeTypeDesc{vt: uint16(VT_I4)},
+		paramDesc: unsafe.Pointer(&ex),
+		flags:     PARAMFLAG_FIN | PARAMFLAG_FOPT | PARAMFLAG_FHASDEFAULT,
+	}
+
+	param := copyElemDesc(&native, true)
+	if param.Default == nil || param.Default.VT != VT_I4 || param.Default.Val != 7 {
+		t.Errorf("default = %+v, want VT_I4 7", param.Default)
+	}
+	if result := copyElemDesc(&native, false); result.Default != nil {
+		t.Error("IDLDESC read as a PARAMDESC")
+	}
+}
+
+func TestCopyVarDesc(t *testing.T) {
+	value := NewVariant(VT_I4, 3)
+	constant := nativeVarDesc{
+		memid:       0x400,
+		union:       unsafe.Pointer(&value),
+		elemdescVar: nativeElemDesc{tdesc: nativeTypeDesc{vt: uint16(VT_I4)}},
+		varkind:     VAR_CONST,
+	}
+	desc := copyVarDesc(&constant)
+	if desc.Value == nil || desc.Value.Val != 3 || desc.Value == &value || desc.OInst != 0 {
+		t.Errorf("constant = %+v", desc)
+	}
+
+	field := nativeVarDesc{
+		memid:       0x401,
+		elemdescVar: nativeElemDesc{tdesc: nativeTypeDesc{vt: uint16(VT_BSTR)}},
+		wVarFlags:   0x1,
+		varkind:     VAR_PERINSTANCE,
+	}
+	setUnion(&field.union, 16)
+	want := &VARDESC{
+		Memid:       0x401,
+		OInst:       16,
+		ElemdescVar: ELEMDESC{Tdesc: TYPEDESC{VT: uint16(VT_BSTR)}},
+		WVarFlags:   0x1,
+		VarKind:     VAR_PERINSTANCE,
+	}
+	if desc := copyVarDesc(&field); !reflect.DeepEqual(desc, want) {
+		t.Errorf("field = %+v, want %+v", desc, want)
+	}
+}
+
+func TestCopyTypeAttr(t *testing.T) {
+	target := nativeTypeDesc{vt: uint16(VT_BSTR)}
+	native := nativeTypeAttr{
+		guid:         *IID_IDispatch,
+		lcid:         0x409,
+		typekind:     TKIND_ALIAS,
+		cFuncs:       2,
+		wTypeFlags:   TYPEFLAG_FDUAL,
+		wMajorVerNum: 1,
+		tdescAlias:   nativeTypeDesc{union: unsafe.Pointer(&target), vt: uint16(VT_PTR)},
+	}
+	native.idldescType.wIDLFlags = PARAMFLAG_FIN
+
+	attr := copyTypeAttr(&native)
+	if !IsEqualGUID(&attr.Guid, IID_IDispatch) || attr.Lcid != 0x409 || attr.Typekind != TKIND_ALIAS ||
+		attr.CFuncs != 2 || attr.WTypeFlags != TYPEFLAG_FDUAL || attr.WMajorVerNum != 1 ||
+		attr.IdldescType.WIDLFlags != PARAMFLAG_FIN {
+		t.Errorf("copyTypeAttr = %+v", attr)
+	}
+	if attr.TdescAlias.Lptdesc == nil || attr.TdescAlias.Lptdesc.VT != uint16(VT_BSTR) {
+		t.Errorf("alias = %+v, want pointer to BSTR", attr.TdescAlias)
+	}
+}
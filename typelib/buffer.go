@@ -0,0 +1,148 @@
+package typelib
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// buffer reads the little-endian structures of a library. Reads past the
+// end return zero values and record an error, which parsers check once
+// they are done.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+func (b *buffer) fail(what string) {
+	if b.err == nil {
+		b.err = invalidData(what)
+	}
+}
+
+func (b *buffer) bytes(off, n int) []byte {
+	if off < 0 || n < 0 || n > len(b.data) || off > len(b.data)-n {
+		b.fail("read of " + strconv.Itoa(n) + " bytes at offset " + strconv.Itoa(off) + " out of bounds")
+		return nil
+	}
+	return b.data[off : off+n]
+}
+
+func (b *buffer) u8(off int) uint8 {
+	if p := b.bytes(off, 1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (b *buffer) u16(off int) uint16 {
+	if p := b.bytes(off, 2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (b *buffer) u32(off int) uint32 {
+	if p := b.bytes(off, 4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (b *buffer) i32(off int) int32 {
+	return int32(b.u32(off))
+}
+
+func (b *buffer) u64(off int) uint64 {
+	if p := b.bytes(off, 8); p != nil {
+		return binary.LittleEndian.Uint64(p)
+	}
+	return 0
+}
+
+func (b *buffer) guid(off int) (guid ole.GUID) {
+	p := b.bytes(off, 16)
+	if p == nil {
+		return
+	}
+	guid.Data1 = binary.LittleEndian.Uint32(p)
+	guid.Data2 = binary.LittleEndian.Uint16(p[4:])
+	guid.Data3 = binary.LittleEndian.Uint16(p[6:])
+	copy(guid.Data4[:], p[8:])
+	return
+}
+
+// cstring reads a NUL-terminated string.
+func (b *buffer) cstring(off int) string {
+	if off < 0 || off >= len(b.data) {
+		b.fail("string at offset " + strconv.Itoa(off) + " out of bounds")
+		return ""
+	}
+	end := off
+	for end < len(b.data) && b.data[end] != 0 {
+		end++
+	}
+	return decodeANSI(b.data[off:end])
+}
+
+// value reads a constant of type vt stored at off with its natural size.
+// Strings are not handled here, as each format stores them its own way.
+func (b *buffer) value(vt ole.VT, off int) Value {
+	v := Value{VT: vt}
+	switch vt {
+	case ole.VT_EMPTY, ole.VT_NULL:
+	case ole.VT_I1:
+		v.Val = int8(b.u8(off))
+	case ole.VT_UI1:
+		v.Val = b.u8(off)
+	case ole.VT_I2:
+		v.Val = int16(b.u16(off))
+	case ole.VT_UI2:
+		v.Val = b.u16(off)
+	case ole.VT_BOOL:
+		v.Val = b.u16(off) != 0
+	case ole.VT_I4, ole.VT_INT, ole.VT_ERROR, ole.VT_HRESULT:
+		v.Val = b.i32(off)
+	case ole.VT_UI4, ole.VT_UINT:
+		v.Val = b.u32(off)
+	case ole.VT_R4:
+		v.Val = math.Float32frombits(b.u32(off))
+	case ole.VT_I8, ole.VT_CY:
+		v.Val = int64(b.u64(off))
+	case ole.VT_UI8:
+		v.Val = b.u64(off)
+	case ole.VT_R8, ole.VT_DATE:
+		v.Val = math.Float64frombits(b.u64(off))
+	default:
+		b.fail("constant of unsupported type " + vt.String())
+	}
+	return v
+}
+
+// integer reads a constant of the integer type vt stored at off as a
+// 32-bit value, as SLTG stores all of them.
+func (b *buffer) integer(vt ole.VT, off int) Value {
+	n := b.i32(off)
+	v := Value{VT: vt}
+	switch vt {
+	case ole.VT_I1:
+		v.Val = int8(n)
+	case ole.VT_UI1:
+		v.Val = uint8(n)
+	case ole.VT_I2:
+		v.Val = int16(n)
+	case ole.VT_UI2:
+		v.Val = uint16(n)
+	case ole.VT_BOOL:
+		v.Val = n != 0
+	case ole.VT_I4, ole.VT_INT, ole.VT_ERROR, ole.VT_HRESULT:
+		v.Val = n
+	case ole.VT_UI4, ole.VT_UINT:
+		v.Val = uint32(n)
+	default:
+		b.fail("constant of unsupported type " + vt.String())
+	}
+	return v
+}
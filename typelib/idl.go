@@ -0,0 +1,586 @@
+package typelib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// WriteIDL writes lib as IDL, the way OleView shows type libraries, in the
+// syntax MIDL compiles. Imported types whose name is unknown are written as
+// commented IUnknown.
+func WriteIDL(w io.Writer, lib *Library) error {
+	p := &idlPrinter{lib: lib}
+	p.library()
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// WriteTypeIDL writes the IDL of the type t of lib.
+func WriteTypeIDL(w io.Writer, lib *Library, t *TypeInfo) error {
+	p := &idlPrinter{lib: lib}
+	p.typeInfo(t, "")
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// The GUIDs of the types of stdole2.tlb type libraries usually import.
+var stdoleTypes = map[string]string{
+	"{00000000-0000-0000-C000-000000000046}": "IUnknown",
+	"{00020400-0000-0000-C000-000000000046}": "IDispatch",
+	"{00020404-0000-0000-C000-000000000046}": "IEnumVARIANT",
+	"{BEF6E003-A874-101A-8BBA-00AA00300CAB}": "IFontDisp",
+	"{7BF80981-BF32-101A-8BBB-00AA00300CAB}": "IPictureDisp",
+}
+
+const (
+	libidStdOle2 = "{00020430-0000-0000-C000-000000000046}"
+	idlIndent    = "    "
+)
+
+// idlPrinter renders a library as IDL.
+type idlPrinter struct {
+	lib *Library
+	buf bytes.Buffer
+}
+
+func (p *idlPrinter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&p.buf, format, args...)
+}
+
+// attributes writes a block of attributes, one per line.
+func (p *idlPrinter) attributes(indent string, attrs []string) {
+	p.printf("%s[\n", indent)
+	for i, attr := range attrs {
+		p.printf("%s%s%s", indent, idlIndent, attr)
+		if i < len(attrs)-1 {
+			p.printf(",")
+		}
+		p.printf("\n")
+	}
+	p.printf("%s]\n", indent)
+}
+
+func (p *idlPrinter) library() {
+	lib := p.lib
+	attrs := []string{
+		"uuid(" + uuid(&lib.Attr.Guid) + ")",
+		fmt.Sprintf("version(%d.%d)", lib.Attr.WMajorVerNum, lib.Attr.WMinorVerNum),
+	}
+	if lib.Attr.Lcid != 0 {
+		attrs = append(attrs, fmt.Sprintf("lcid(0x%04x)", lib.Attr.Lcid))
+	}
+	attrs = append(attrs, helpAttributes(lib.DocString, lib.HelpContext, lib.HelpStringContext)...)
+	if lib.HelpFile != "" {
+		attrs = append(attrs, "helpfile("+quote(lib.HelpFile)+")")
+	}
+	if lib.HelpStringDll != "" {
+		attrs = append(attrs, "helpstringdll("+quote(lib.HelpStringDll)+")")
+	}
+	attrs = append(attrs, flagAttributes(uint32(lib.Attr.WLibFlags), libFlagNames)...)
+	attrs = append(attrs, customAttributes(lib.CustData)...)
+	p.attributes("", attrs)
+	p.printf("library %s\n{\n", lib.Name)
+
+	for _, imp := range lib.Imports {
+		path := imp.Path
+		if path == "" && strings.EqualFold(imp.Guid.String(), libidStdOle2) {
+			path = "stdole2.tlb"
+		}
+		if path == "" {
+			p.printf("%s// importlib of %s version %d.%d, whose file is unknown\n",
+				idlIndent, imp.Guid.String(), imp.MajorVerNum, imp.MinorVerNum)
+			continue
+		}
+		p.printf("%simportlib(%s);\n", idlIndent, quote(path))
+	}
+
+	var forward []string
+	for _, t := range lib.Types {
+		switch kind := p.kindKeyword(t); kind {
+		case "interface", "dispinterface", "coclass":
+			forward = append(forward, kind+" "+t.Name+";")
+		}
+	}
+	if len(forward) > 0 {
+		p.printf("\n%s// Forward declarations\n", idlIndent)
+		for _, decl := range forward {
+			p.printf("%s%s\n", idlIndent, decl)
+		}
+	}
+
+	for _, t := range lib.Types {
+		p.printf("\n")
+		p.typeInfo(t, idlIndent)
+	}
+	p.printf("};\n")
+}
+
+// kindKeyword returns the IDL keyword declaring t. Dual interfaces stored as
+// dispinterfaces are declared as interfaces.
+func (p *idlPrinter) kindKeyword(t *TypeInfo) string {
+	switch t.Attr.Typekind {
+	case ole.TKIND_ENUM:
+		return "enum"
+	case ole.TKIND_RECORD:
+		return "struct"
+	case ole.TKIND_UNION:
+		return "union"
+	case ole.TKIND_ALIAS:
+		return "typedef"
+	case ole.TKIND_MODULE:
+		return "module"
+	case ole.TKIND_INTERFACE:
+		return "interface"
+	case ole.TKIND_DISPATCH:
+		if t.Attr.WTypeFlags&ole.TYPEFLAG_FDUAL != 0 {
+			return "interface"
+		}
+		return "dispinterface"
+	case ole.TKIND_COCLASS:
+		return "coclass"
+	}
+	return ""
+}
+
+// typeInfo writes the declaration of t.
+func (p *idlPrinter) typeInfo(t *TypeInfo, indent string) {
+	attrs := p.typeAttributes(t)
+	kind := p.kindKeyword(t)
+	switch kind {
+	case "enum", "struct", "union":
+		p.printf("%stypedef ", indent)
+		if len(attrs) > 0 {
+			p.printf("[%s]\n%s", strings.Join(attrs, ", "), indent)
+		}
+		p.printf("%s {\n", kind)
+		for i, v := range t.Vars {
+			p.printf("%s%s", indent+idlIndent, prefixAttributes(p.varAttributes(v, false)))
+			if kind == "enum" {
+				p.printf("%s = %s", v.Name, idlValue(v.Value))
+				if i < len(t.Vars)-1 {
+					p.printf(",")
+				}
+			} else {
+				p.printf("%s;", p.declare(&v.Type, v.Name))
+			}
+			p.printf("\n")
+		}
+		p.printf("%s} %s;\n", indent, t.Name)
+	case "typedef":
+		attrs = append(attrs, "public")
+		p.printf("%stypedef [%s] %s;\n", indent, strings.Join(attrs, ", "), p.declare(&t.Attr.TdescAlias, t.Name))
+	case "module":
+		p.attributes(indent, attrs)
+		p.printf("%smodule %s {\n", indent, t.Name)
+		for _, f := range t.Funcs {
+			p.function(t, f, indent+idlIndent)
+		}
+		for _, v := range t.Vars {
+			p.printf("%s%sconst %s = %s;\n", indent+idlIndent, prefixAttributes(p.varAttributes(v, false)),
+				p.declare(&v.Type, v.Name), idlValue(v.Value))
+		}
+		p.printf("%s};\n", indent)
+	case "interface":
+		p.attributes(indent, attrs)
+		p.printf("%sinterface %s", indent, t.Name)
+		if len(t.ImplTypes) > 0 {
+			p.printf(" : %s", p.refName(t.ImplTypes[0].Hreftype))
+		}
+		p.printf(" {\n")
+		for _, f := range t.Funcs {
+			if t.Attr.Typekind == ole.TKIND_DISPATCH && IsInheritedDispatchMember(f.Memid) {
+				continue
+			}
+			p.function(t, f, indent+idlIndent)
+		}
+		p.printf("%s};\n", indent)
+	case "dispinterface":
+		p.attributes(indent, attrs)
+		p.printf("%sdispinterface %s {\n", indent, t.Name)
+		p.printf("%sproperties:\n", indent+idlIndent)
+		for _, v := range t.Vars {
+			p.printf("%s%s%s;\n", indent+idlIndent+idlIndent, prefixAttributes(p.varAttributes(v, true)),
+				p.declare(&v.Type, v.Name))
+		}
+		p.printf("%smethods:\n", indent+idlIndent)
+		for _, f := range t.Funcs {
+			p.function(t, f, indent+idlIndent+idlIndent)
+		}
+		p.printf("%s};\n", indent)
+	case "coclass":
+		p.attributes(indent, attrs)
+		p.printf("%scoclass %s {\n", indent, t.Name)
+		for _, impl := range t.ImplTypes {
+			keyword := "interface"
+			if ref := p.lib.Ref(impl.Hreftype); ref != nil && ref.Type != nil && p.kindKeyword(ref.Type) == "dispinterface" {
+				keyword = "dispinterface"
+			}
+			flags := flagAttributes(uint32(impl.Flags), implTypeFlagNames)
+			flags = append(flags, customAttributes(impl.CustData)...)
+			p.printf("%s%s%s %s;\n", indent+idlIndent, prefixAttributes(flags), keyword, p.refName(impl.Hreftype))
+		}
+		p.printf("%s};\n", indent)
+	}
+}
+
+// typeAttributes returns the attributes of t.
+func (p *idlPrinter) typeAttributes(t *TypeInfo) []string {
+	var attrs []string
+	if t.Attr.Typekind == ole.TKIND_INTERFACE {
+		attrs = append(attrs, "odl")
+	}
+	if !isNullGUID(&t.Attr.Guid) {
+		attrs = append(attrs, "uuid("+uuid(&t.Attr.Guid)+")")
+	}
+	if t.Attr.WMajorVerNum != 0 || t.Attr.WMinorVerNum != 0 {
+		attrs = append(attrs, fmt.Sprintf("version(%d.%d)", t.Attr.WMajorVerNum, t.Attr.WMinorVerNum))
+	}
+	attrs = append(attrs, helpAttributes(t.DocString, t.HelpContext, t.HelpStringContext)...)
+	if t.DllName != "" {
+		attrs = append(attrs, "dllname("+quote(t.DllName)+")")
+	}
+	flags := uint32(t.Attr.WTypeFlags)
+	if t.Attr.Typekind == ole.TKIND_COCLASS && flags&ole.TYPEFLAG_FCANCREATE == 0 {
+		attrs = append(attrs, "noncreatable")
+	}
+	// Dispatchable is implied by dual and dispinterface.
+	flags &^= ole.TYPEFLAG_FCANCREATE | ole.TYPEFLAG_FDISPATCHABLE
+	attrs = append(attrs, flagAttributes(flags, typeFlagNames)...)
+	return append(attrs, customAttributes(t.CustData)...)
+}
+
+// function writes the declaration of the method f of t.
+func (p *idlPrinter) function(t *TypeInfo, f *Func, indent string) {
+	var attrs []string
+	switch {
+	case t.Attr.Typekind == ole.TKIND_MODULE:
+		if f.Entry != "" {
+			attrs = append(attrs, "entry("+quote(f.Entry)+")")
+		} else {
+			attrs = append(attrs, fmt.Sprintf("entry(%d)", f.Ordinal))
+		}
+	case t.Attr.Typekind == ole.TKIND_DISPATCH || t.Attr.WTypeFlags&ole.TYPEFLAG_FDUAL != 0:
+		attrs = append(attrs, fmt.Sprintf("id(0x%08x)", uint32(f.Memid)))
+	}
+	switch f.InvKind {
+	case ole.INVOKE_PROPERTYGET:
+		attrs = append(attrs, "propget")
+	case ole.INVOKE_PROPERTYPUT:
+		attrs = append(attrs, "propput")
+	case ole.INVOKE_PROPERTYPUTREF:
+		attrs = append(attrs, "propputref")
+	}
+	attrs = append(attrs, flagAttributes(uint32(f.Flags), funcFlagNames)...)
+	if f.CParamsOpt == -1 {
+		attrs = append(attrs, "vararg")
+	}
+	attrs = append(attrs, helpAttributes(f.DocString, f.HelpContext, f.HelpStringContext)...)
+	attrs = append(attrs, customAttributes(f.CustData)...)
+
+	result := p.typeName(&f.Result)
+	if t.Attr.Typekind == ole.TKIND_MODULE {
+		switch f.CallConv {
+		case ole.CC_CDECL:
+			result += " _cdecl"
+		case ole.CC_PASCAL:
+			result += " _pascal"
+		case ole.CC_STDCALL:
+			result += " _stdcall"
+		}
+	}
+
+	params := make([]string, len(f.Params))
+	for i := range f.Params {
+		param := &f.Params[i]
+		name := param.Name
+		if name == "" {
+			name = "p" + strconv.Itoa(i)
+			if i == len(f.Params)-1 && f.InvKind&(ole.INVOKE_PROPERTYPUT|ole.INVOKE_PROPERTYPUTREF) != 0 {
+				name = "rhs"
+			}
+		}
+		params[i] = prefixAttributes(paramAttributes(param)) + p.declare(&param.Type, name)
+	}
+	if len(attrs) > 0 {
+		p.printf("%s[%s]\n", indent, strings.Join(attrs, ", "))
+	}
+	p.printf("%s%s %s(", indent, result, f.Name)
+	if len(params) > 1 {
+		// One parameter per line, as OleView lays them out.
+		sep := ",\n" + indent + idlIndent + idlIndent
+		p.printf("\n%s%s", indent+idlIndent+idlIndent, strings.Join(params, sep))
+	} else {
+		p.printf("%s", strings.Join(params, ""))
+	}
+	p.printf(");\n")
+}
+
+func paramAttributes(param *Param) []string {
+	var attrs []string
+	for _, flag := range []struct {
+		flag uint16
+		name string
+	}{
+		{ole.PARAMFLAG_FIN, "in"},
+		{ole.PARAMFLAG_FOUT, "out"},
+		{ole.PARAMFLAG_FLCID, "lcid"},
+		{ole.PARAMFLAG_FRETVAL, "retval"},
+	} {
+		if param.Flags&flag.flag != 0 {
+			attrs = append(attrs, flag.name)
+		}
+	}
+	// A default value makes a parameter optional.
+	if param.Flags&ole.PARAMFLAG_FOPT != 0 && param.Default == nil {
+		attrs = append(attrs, "optional")
+	}
+	if param.Default != nil {
+		attrs = append(attrs, "defaultvalue("+idlValue(param.Default)+")")
+	}
+	return append(attrs, customAttributes(param.CustData)...)
+}
+
+// varAttributes returns the attributes of a field, constant or property,
+// with its DISPID for dispinterface properties.
+func (p *idlPrinter) varAttributes(v *Var, withID bool) []string {
+	var attrs []string
+	if withID {
+		attrs = append(attrs, fmt.Sprintf("id(0x%08x)", uint32(v.Memid)))
+	}
+	attrs = append(attrs, flagAttributes(uint32(v.Flags), varFlagNames)...)
+	attrs = append(attrs, helpAttributes(v.DocString, v.HelpContext, v.HelpStringContext)...)
+	return append(attrs, customAttributes(v.CustData)...)
+}
+
+// declare returns the declaration of name of type desc, with the
+// dimensions of C arrays after the name.
+func (p *idlPrinter) declare(desc *ole.TYPEDESC, name string) string {
+	var dims string
+	for ole.VT(desc.VT) == ole.VT_CARRAY && desc.Lpadesc != nil {
+		for _, bound := range desc.Lpadesc.Bounds {
+			dims += fmt.Sprintf("[%d]", bound.Elements)
+		}
+		desc = &desc.Lpadesc.TdescElem
+	}
+	return p.typeName(desc) + " " + name + dims
+}
+
+// TypeName returns the IDL name of the type desc of a member of l, such as
+// long*, SAFEARRAY(VARIANT) or the name of a type of l. The dimensions of
+// arrays follow the name of their element type.
+func (l *Library) TypeName(desc *ole.TYPEDESC) string {
+	name := (&idlPrinter{lib: l}).declare(desc, "")
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		name = name[:i] + name[i+1:]
+	}
+	return name
+}
+
+// Names of the automation types in IDL.
+var idlTypeNames = map[ole.VT]string{
+	ole.VT_EMPTY:    "void",
+	ole.VT_VOID:     "void",
+	ole.VT_I1:       "char",
+	ole.VT_UI1:      "unsigned char",
+	ole.VT_I2:       "short",
+	ole.VT_UI2:      "unsigned short",
+	ole.VT_I4:       "long",
+	ole.VT_UI4:      "unsigned long",
+	ole.VT_INT:      "int",
+	ole.VT_UINT:     "unsigned int",
+	ole.VT_I8:       "int64",
+	ole.VT_UI8:      "uint64",
+	ole.VT_R4:       "single",
+	ole.VT_R8:       "double",
+	ole.VT_CY:       "CURRENCY",
+	ole.VT_DATE:     "DATE",
+	ole.VT_BSTR:     "BSTR",
+	ole.VT_DISPATCH: "IDispatch*",
+	ole.VT_ERROR:    "SCODE",
+	ole.VT_BOOL:     "VARIANT_BOOL",
+	ole.VT_VARIANT:  "VARIANT",
+	ole.VT_UNKNOWN:  "IUnknown*",
+	ole.VT_DECIMAL:  "DECIMAL",
+	ole.VT_HRESULT:  "HRESULT",
+	ole.VT_LPSTR:    "LPSTR",
+	ole.VT_LPWSTR:   "LPWSTR",
+	ole.VT_INT_PTR:  "INT_PTR",
+	ole.VT_UINT_PTR: "UINT_PTR",
+}
+
+// typeName returns the IDL name of the type desc.
+func (p *idlPrinter) typeName(desc *ole.TYPEDESC) string {
+	vt := ole.VT(desc.VT)
+	if name, ok := idlTypeNames[vt]; ok {
+		return name
+	}
+	switch vt {
+	case ole.VT_PTR:
+		if desc.Lptdesc != nil {
+			return p.typeName(desc.Lptdesc) + "*"
+		}
+	case ole.VT_SAFEARRAY:
+		if desc.Lptdesc != nil {
+			return "SAFEARRAY(" + p.typeName(desc.Lptdesc) + ")"
+		}
+	case ole.VT_CARRAY:
+		if desc.Lpadesc != nil {
+			return p.typeName(&desc.Lpadesc.TdescElem)
+		}
+	case ole.VT_USERDEFINED:
+		return p.refName(desc.Hreftype)
+	}
+	return "/* " + vt.String() + " */ void"
+}
+
+// refName returns the name of the type hreftype refers to.
+func (p *idlPrinter) refName(hreftype uint32) string {
+	ref := p.lib.Ref(hreftype)
+	switch {
+	case ref == nil:
+		return fmt.Sprintf("/* missing type 0x%x */ IUnknown", hreftype)
+	case ref.Type != nil:
+		return ref.Type.Name
+	case ref.Name != "":
+		return ref.Name
+	}
+	if name, ok := stdoleTypes[ref.Guid.String()]; ok {
+		return name
+	}
+	what := "type " + strconv.Itoa(ref.Index)
+	if !isNullGUID(&ref.Guid) {
+		what = ref.Guid.String()
+	}
+	if ref.Import != nil && ref.Import.Path != "" {
+		what += " of " + ref.Import.Path
+	}
+	if ref.Typekind == ole.TKIND_ENUM {
+		return "/* " + what + " */ long"
+	}
+	return "/* " + what + " */ IUnknown"
+}
+
+// Names of the flags of each kind of element, in the order of their bits.
+var (
+	libFlagNames = []string{"restricted", "control", "hidden"}
+
+	typeFlagNames = []string{
+		"appobject", "", "licensed", "predeclid", "hidden", "control", "dual",
+		"nonextensible", "oleautomation", "restricted", "aggregatable",
+		"replaceable", "", "reversebind", "proxy",
+	}
+	funcFlagNames = []string{
+		"restricted", "source", "bindable", "requestedit", "displaybind",
+		"defaultbind", "hidden", "usesgetlasterror", "defaultcollelem",
+		"uidefault", "nonbrowsable", "replaceable", "immediatebind",
+	}
+	varFlagNames = []string{
+		"readonly", "source", "bindable", "requestedit", "displaybind",
+		"defaultbind", "hidden", "restricted", "defaultcollelem", "uidefault",
+		"nonbrowsable", "replaceable", "immediatebind",
+	}
+	implTypeFlagNames = []string{"default", "source", "restricted", "defaultvtable"}
+)
+
+// flagAttributes returns the attributes for the bits of flags.
+func flagAttributes(flags uint32, names []string) []string {
+	var attrs []string
+	for bit, name := range names {
+		if flags&(1<<uint(bit)) != 0 && name != "" {
+			attrs = append(attrs, name)
+		}
+	}
+	return attrs
+}
+
+func helpAttributes(doc string, context, stringContext uint32) []string {
+	var attrs []string
+	if doc != "" {
+		attrs = append(attrs, "helpstring("+quote(doc)+")")
+	}
+	if context != 0 {
+		attrs = append(attrs, fmt.Sprintf("helpcontext(0x%08x)", context))
+	}
+	if stringContext != 0 {
+		attrs = append(attrs, fmt.Sprintf("helpstringcontext(0x%08x)", stringContext))
+	}
+	return attrs
+}
+
+func customAttributes(data []CustData) []string {
+	var attrs []string
+	for i := range data {
+		attrs = append(attrs, "custom("+uuid(&data[i].Guid)+", "+idlValue(&data[i].Value)+")")
+	}
+	return attrs
+}
+
+// prefixAttributes returns the attributes preceding a member, or nothing.
+func prefixAttributes(attrs []string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(attrs, ", ") + "] "
+}
+
+// uuid returns guid as the uuid attribute writes it, without braces.
+func uuid(guid *ole.GUID) string {
+	return strings.Trim(guid.String(), "{}")
+}
+
+func isNullGUID(guid *ole.GUID) bool {
+	return ole.IsEqualGUID(guid, &ole.GUID{})
+}
+
+// idlValue returns the IDL literal of a constant.
+func idlValue(v *Value) string {
+	if v == nil {
+		return "0"
+	}
+	switch val := v.Val.(type) {
+	case nil:
+		return "0"
+	case string:
+		return quote(val)
+	case bool:
+		if val {
+			return "-1"
+		}
+		return "0"
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	}
+	return fmt.Sprint(v.Val)
+}
+
+// quote returns s as a C string literal.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < ' ':
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
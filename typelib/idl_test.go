@@ -0,0 +1,66 @@
+package typelib
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// checkGolden compares got with the golden file, or rewrites it with -update.
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s; run go test -update to accept it:\n%s", golden, got)
+	}
+}
+
+func TestWriteIDL(t *testing.T) {
+	for _, name := range []string{"test.tlb", "test_sltg.tlb"} {
+		lib := parseTestFile(t, name)
+		var buf bytes.Buffer
+		if err := WriteIDL(&buf, lib); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, "testdata/"+name+".idl", buf.Bytes())
+	}
+}
+
+func TestWriteTypeIDL(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	var buf bytes.Buffer
+	if err := WriteTypeIDL(&buf, lib, lib.Type("_CalculatorEvents")); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+    uuid(5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E04)
+]
+dispinterface _CalculatorEvents {
+    properties:
+        [id(0x00000002), readonly] VARIANT_BOOL Busy;
+    methods:
+        [id(0x00000001)]
+        void Done([in] long result);
+};
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTypeIDL =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := quote("a\"b\\c\n\x01é"), `"a\"b\\c\n\x01é"`; got != want {
+		t.Errorf("quote = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package typelib
+
+import ole "github.com/go-ole/go-ole"
+
+// Load describes a type library loaded by COM. It is only implemented on
+// Windows; elsewhere use Parse.
+func Load(tlib *ole.ITypeLib) (*Library, error) {
+	return nil, ole.NewError(ole.E_NOTIMPL)
+}
+
+// LoadTypeInfo describes the library holding tinfo. It is only implemented
+// on Windows.
+func LoadTypeInfo(tinfo *ole.ITypeInfo) (*Library, *TypeInfo, error) {
+	return nil, nil, ole.NewError(ole.E_NOTIMPL)
+}
+
+// LoadType describes tinfo and its bases. It is only implemented on
+// Windows.
+func LoadType(tinfo *ole.ITypeInfo) (*Library, *TypeInfo, error) {
+	return nil, nil, ole.NewError(ole.E_NOTIMPL)
+}
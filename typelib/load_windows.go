@@ -0,0 +1,414 @@
+//go:build windows
+// +build windows
+
+package typelib
+
+import (
+	"math"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// Load describes a type library loaded by COM, such as the library of a
+// running object's type information. HREFTYPEs are renumbered as Parse
+// numbers them. Custom data is not read.
+func Load(tlib *ole.ITypeLib) (*Library, error) {
+	l := &loader{tlib: tlib, lib: &Library{Refs: map[uint32]*Ref{}}}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	l.lib.resolveKinds()
+	return l.lib, nil
+}
+
+// loader converts a library one type at a time.
+type loader struct {
+	tlib *ole.ITypeLib
+	lib  *Library
+
+	// external is the next HREFTYPE given to a type of another library,
+	// and externals those given so far. Types of other libraries are told
+	// apart by their index, as typedefs and records have no GUID.
+	external  uint32
+	externals map[externalType]uint32
+
+	// partial is set when only some types of the library are converted:
+	// the others are referred to as types of other libraries are.
+	partial bool
+}
+
+// externalType is a type of another library.
+type externalType struct {
+	lib   ole.GUID
+	major uint16
+	index uint32
+}
+
+func (l *loader) load() error {
+	if err := l.loadLibrary(); err != nil {
+		return err
+	}
+	lib := l.lib
+	count := int(l.tlib.GetTypeInfoCount())
+	lib.Types = make([]*TypeInfo, count)
+	for i := range lib.Types {
+		lib.Types[i] = &TypeInfo{}
+		lib.Refs[HrefOfIndex(i)] = &Ref{Type: lib.Types[i], Index: i}
+	}
+	for i := 0; i < count; i++ {
+		if err := l.loadType(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLibrary reads the attributes and documentation of the library.
+func (l *loader) loadLibrary() (err error) {
+	lib := l.lib
+	attr, err := l.tlib.GetLibAttr()
+	if err != nil {
+		return err
+	}
+	lib.Attr = *attr
+	lib.Name, lib.DocString, lib.HelpContext, lib.HelpFile, err = l.tlib.GetDocumentation(-1)
+	if err != nil {
+		return err
+	}
+	l.external = 1
+	l.externals = map[externalType]uint32{}
+	return nil
+}
+
+func (l *loader) loadType(index int) error {
+	tinfo, err := l.tlib.GetTypeInfo(uint32(index))
+	if err != nil {
+		return err
+	}
+	defer tinfo.Release()
+	return l.convert(tinfo, l.lib.Types[index])
+}
+
+// convert describes tinfo in t.
+func (l *loader) convert(tinfo *ole.ITypeInfo, t *TypeInfo) error {
+	attr, err := tinfo.GetTypeAttr()
+	if err != nil {
+		return err
+	}
+	t.Attr = *attr
+	if t.Name, t.DocString, t.HelpContext, _, err = tinfo.GetDocumentation(ole.MEMBERID_NIL); err != nil {
+		return err
+	}
+	if t.Attr.Typekind == ole.TKIND_ALIAS {
+		if err := l.remap(tinfo, &t.Attr.TdescAlias); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < int(attr.CImplTypes); i++ {
+		hreftype, err := tinfo.GetRefTypeOfImplType(uint32(i))
+		if err != nil {
+			return err
+		}
+		flags, err := tinfo.GetImplTypeFlags(uint32(i))
+		if err != nil {
+			return err
+		}
+		if hreftype, err = l.ref(tinfo, hreftype); err != nil {
+			return err
+		}
+		t.ImplTypes = append(t.ImplTypes, ImplType{Hreftype: hreftype, Flags: flags})
+	}
+	for i := 0; i < int(attr.CFuncs); i++ {
+		f, err := l.loadFunc(tinfo, t, i)
+		if err != nil {
+			return err
+		}
+		t.Funcs = append(t.Funcs, f)
+	}
+	for i := 0; i < int(attr.CVars); i++ {
+		v, err := l.loadVar(tinfo, i)
+		if err != nil {
+			return err
+		}
+		t.Vars = append(t.Vars, v)
+	}
+	return nil
+}
+
+func (l *loader) loadFunc(tinfo *ole.ITypeInfo, t *TypeInfo, index int) (*Func, error) {
+	desc, err := tinfo.GetFuncDesc(uint32(index))
+	if err != nil {
+		return nil, err
+	}
+	defer desc.Clear()
+
+	f := &Func{
+		Memid:      desc.Memid,
+		FuncKind:   desc.FuncKind,
+		InvKind:    desc.InvKind,
+		CallConv:   desc.CallConv,
+		OVft:       desc.OVft,
+		Flags:      desc.WFuncFlags,
+		Result:     desc.ElemdescFunc.Tdesc,
+		CParamsOpt: desc.CParamsOpt,
+	}
+	if f.Name, f.DocString, f.HelpContext, _, err = tinfo.GetDocumentation(desc.Memid); err != nil {
+		return nil, err
+	}
+	if err := l.remap(tinfo, &f.Result); err != nil {
+		return nil, err
+	}
+	// The names of the parameters follow the name of the function. The
+	// value of a property put has none.
+	names, err := tinfo.GetNames(desc.Memid)
+	if err != nil {
+		return nil, err
+	}
+	for i, elem := range desc.Params {
+		p := Param{Type: elem.Tdesc, Flags: elem.Flags}
+		if i+1 < len(names) {
+			p.Name = names[i+1]
+		}
+		if err := l.remap(tinfo, &p.Type); err != nil {
+			return nil, err
+		}
+		if elem.Default != nil {
+			value, err := variantValue(elem.Default)
+			if err != nil {
+				return nil, err
+			}
+			p.Default = &value
+		}
+		f.Params = append(f.Params, p)
+	}
+
+	if t.Attr.Typekind == ole.TKIND_MODULE {
+		dll, entry, ordinal, err := tinfo.GetDllEntry(desc.Memid, desc.InvKind)
+		if err != nil {
+			return nil, err
+		}
+		t.DllName, f.Entry, f.Ordinal = dll, entry, ordinal
+	}
+	return f, nil
+}
+
+func (l *loader) loadVar(tinfo *ole.ITypeInfo, index int) (*Var, error) {
+	desc, err := tinfo.GetVarDesc(uint32(index))
+	if err != nil {
+		return nil, err
+	}
+	defer desc.Clear()
+
+	v := &Var{
+		Memid:   desc.Memid,
+		VarKind: desc.VarKind,
+		Type:    desc.ElemdescVar.Tdesc,
+		Flags:   desc.WVarFlags,
+		OInst:   desc.OInst,
+	}
+	if v.Name, v.DocString, v.HelpContext, _, err = tinfo.GetDocumentation(desc.Memid); err != nil {
+		return nil, err
+	}
+	if err := l.remap(tinfo, &v.Type); err != nil {
+		return nil, err
+	}
+	if desc.Value != nil {
+		value, err := variantValue(desc.Value)
+		if err != nil {
+			return nil, err
+		}
+		v.Value = &value
+	}
+	return v, nil
+}
+
+// remap replaces the HREFTYPEs COM returned in desc by those of the Library.
+func (l *loader) remap(tinfo *ole.ITypeInfo, desc *ole.TYPEDESC) (err error) {
+	switch ole.VT(desc.VT) {
+	case ole.VT_PTR, ole.VT_SAFEARRAY:
+		if desc.Lptdesc != nil {
+			return l.remap(tinfo, desc.Lptdesc)
+		}
+	case ole.VT_CARRAY:
+		if desc.Lpadesc != nil {
+			return l.remap(tinfo, &desc.Lpadesc.TdescElem)
+		}
+	case ole.VT_USERDEFINED:
+		desc.Hreftype, err = l.ref(tinfo, desc.Hreftype)
+	}
+	return
+}
+
+// ref returns the Library HREFTYPE of the type hreftype refers to in tinfo:
+// HrefOfIndex for a type of the library, or a new odd HREFTYPE for a type
+// of another library, which is recorded with its import.
+func (l *loader) ref(tinfo *ole.ITypeInfo, hreftype uint32) (uint32, error) {
+	target, err := tinfo.GetRefTypeInfo(hreftype)
+	if err != nil {
+		return 0, err
+	}
+	defer target.Release()
+
+	tlib, index, err := target.GetContainingTypeLib()
+	if err != nil {
+		return 0, err
+	}
+	defer tlib.Release()
+	libAttr, err := tlib.GetLibAttr()
+	if err != nil {
+		return 0, err
+	}
+	local := ole.IsEqualGUID(&libAttr.Guid, &l.lib.Attr.Guid) && libAttr.WMajorVerNum == l.lib.Attr.WMajorVerNum
+	if local && !l.partial {
+		return HrefOfIndex(int(index)), nil
+	}
+
+	key := externalType{lib: libAttr.Guid, major: libAttr.WMajorVerNum, index: index}
+	if known, ok := l.externals[key]; ok {
+		return known, nil
+	}
+	attr, err := target.GetTypeAttr()
+	if err != nil {
+		return 0, err
+	}
+	name, _, _, _, err := target.GetDocumentation(ole.MEMBERID_NIL)
+	if err != nil {
+		return 0, err
+	}
+	ref := &Ref{
+		Guid:     attr.Guid,
+		Index:    int(index),
+		Typekind: attr.Typekind,
+		Name:     name,
+	}
+	if !local {
+		ref.Import = l.importOf(libAttr)
+	}
+	hreftype = l.external
+	l.external += 2
+	l.externals[key] = hreftype
+	l.lib.Refs[hreftype] = ref
+	return hreftype, nil
+}
+
+// importOf returns the import of the library attr describes, adding it on
+// first use. COM does not tell the file an import was found in; Path is
+// left empty.
+func (l *loader) importOf(attr *ole.TLIBATTR) *Import {
+	for _, imp := range l.lib.Imports {
+		if ole.IsEqualGUID(&imp.Guid, &attr.Guid) && imp.MajorVerNum == attr.WMajorVerNum {
+			return imp
+		}
+	}
+	imp := &Import{
+		Guid:        attr.Guid,
+		Lcid:        attr.Lcid,
+		MajorVerNum: attr.WMajorVerNum,
+		MinorVerNum: attr.WMinorVerNum,
+	}
+	l.lib.Imports = append(l.lib.Imports, imp)
+	return imp
+}
+
+// variantValue converts the value of a constant or default parameter.
+func variantValue(v *ole.VARIANT) (Value, error) {
+	value := Value{VT: v.VT}
+	bits := uint64(v.Val)
+	switch v.VT {
+	case ole.VT_EMPTY, ole.VT_NULL:
+	case ole.VT_I1:
+		value.Val = int8(bits)
+	case ole.VT_UI1:
+		value.Val = uint8(bits)
+	case ole.VT_I2:
+		value.Val = int16(bits)
+	case ole.VT_UI2:
+		value.Val = uint16(bits)
+	case ole.VT_BOOL:
+		value.Val = uint16(bits) != 0
+	case ole.VT_I4, ole.VT_INT, ole.VT_ERROR, ole.VT_HRESULT:
+		value.Val = int32(bits)
+	case ole.VT_UI4, ole.VT_UINT:
+		value.Val = uint32(bits)
+	case ole.VT_R4:
+		value.Val = math.Float32frombits(uint32(bits))
+	case ole.VT_I8, ole.VT_CY:
+		value.Val = int64(bits)
+	case ole.VT_UI8:
+		value.Val = bits
+	case ole.VT_R8, ole.VT_DATE:
+		value.Val = math.Float64frombits(bits)
+	case ole.VT_BSTR:
+		value.Val = ole.BstrToString(*(**uint16)(unsafe.Pointer(&v.Val)))
+	default:
+		return value, invalidData("constant of unsupported type " + v.VT.String())
+	}
+	return value, nil
+}
+
+// LoadTypeInfo describes the library holding tinfo, and returns it with the
+// description of tinfo.
+func LoadTypeInfo(tinfo *ole.ITypeInfo) (*Library, *TypeInfo, error) {
+	tlib, index, err := tinfo.GetContainingTypeLib()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tlib.Release()
+	lib, err := Load(tlib)
+	if err != nil {
+		return nil, nil, err
+	}
+	if int(index) >= len(lib.Types) {
+		return nil, nil, ole.NewError(ole.TYPE_E_ELEMENTNOTFOUND)
+	}
+	return lib, lib.Types[index], nil
+}
+
+// LoadType describes tinfo and the interfaces of its library it derives
+// from, without converting the other types of the library. The Library
+// returned holds only those types; the others tinfo refers to have a Ref
+// with their name and kind, and no Import.
+func LoadType(tinfo *ole.ITypeInfo) (*Library, *TypeInfo, error) {
+	tlib, _, err := tinfo.GetContainingTypeLib()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tlib.Release()
+	l := &loader{tlib: tlib, lib: &Library{Refs: map[uint32]*Ref{}}, partial: true}
+	if err := l.loadLibrary(); err != nil {
+		return nil, nil, err
+	}
+	t := &TypeInfo{}
+	if err := l.convert(tinfo, t); err != nil {
+		return nil, nil, err
+	}
+	l.lib.Types = append(l.lib.Types, t)
+
+	// Bases in other libraries, such as IDispatch, are left as references.
+	for base := t; len(base.ImplTypes) > 0 && isInterfaceKind(base.Attr.Typekind); {
+		ref := l.lib.Ref(base.ImplTypes[0].Hreftype)
+		if ref == nil || ref.Type != nil || ref.Import != nil {
+			break
+		}
+		binfo, err := tlib.GetTypeInfo(uint32(ref.Index))
+		if err != nil {
+			return nil, nil, err
+		}
+		ref.Type = &TypeInfo{}
+		err = l.convert(binfo, ref.Type)
+		binfo.Release()
+		if err != nil {
+			return nil, nil, err
+		}
+		l.lib.Types = append(l.lib.Types, ref.Type)
+		base = ref.Type
+	}
+	l.lib.resolveKinds()
+	return l.lib, t, nil
+}
+
+func isInterfaceKind(kind int32) bool {
+	return kind == ole.TKIND_INTERFACE || kind == ole.TKIND_DISPATCH
+}
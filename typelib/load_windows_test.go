@@ -0,0 +1,272 @@
+//go:build windows
+// +build windows
+
+package typelib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestLoad(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	tlib, err := ole.LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadRegTypeLib = %v", err)
+	}
+	defer tlib.Release()
+
+	lib, err := Load(tlib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lib.Name != "stdole" || !ole.IsEqualGUID(&lib.Attr.Guid, libidStdOle) {
+		t.Errorf("library = %+v", lib)
+	}
+	dispatch := lib.TypeOfGuid(ole.IID_IDispatch)
+	if dispatch == nil || dispatch.Name != "IDispatch" || len(dispatch.Funcs) != 4 {
+		t.Fatalf("IDispatch = %+v", dispatch)
+	}
+	base := lib.Ref(dispatch.ImplTypes[0].Hreftype)
+	if base == nil || base.Type == nil || base.Type.Name != "IUnknown" || base.Typekind != ole.TKIND_INTERFACE {
+		t.Errorf("base of IDispatch = %+v", base)
+	}
+	invoke := dispatch.Funcs[3]
+	if invoke.Name != "Invoke" || len(invoke.Params) != 8 {
+		t.Errorf("Invoke = %+v", invoke)
+	}
+}
+
+func TestLoadType(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	tlib, err := ole.LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadRegTypeLib = %v", err)
+	}
+	defer tlib.Release()
+	tinfo, err := tlib.GetTypeInfoOfGuid(ole.IID_IDispatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tinfo.Release()
+
+	lib, dispatch, err := LoadType(tinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lib.Name != "stdole" || len(lib.Types) != 2 || dispatch != lib.Types[0] || dispatch.Name != "IDispatch" {
+		t.Fatalf("library = %+v", lib)
+	}
+	base := lib.Ref(dispatch.ImplTypes[0].Hreftype)
+	if base == nil || base.Type != lib.Types[1] || base.Type.Name != "IUnknown" || len(base.Type.Funcs) != 3 {
+		t.Errorf("base of IDispatch = %+v", base)
+	}
+
+	// The records the methods take are named, not converted.
+	riid := dispatch.Funcs[2].Params[0].Type
+	if dispatch.Funcs[2].Name != "GetIDsOfNames" || riid.Lptdesc == nil {
+		t.Fatalf("GetIDsOfNames = %+v", dispatch.Funcs[2])
+	}
+	ref := lib.Ref(riid.Lptdesc.Hreftype)
+	if ref == nil || ref.Type != nil || ref.Import != nil || ref.Name != "GUID" || ref.Typekind != ole.TKIND_RECORD {
+		t.Errorf("GUID = %+v", ref)
+	}
+	if name := lib.TypeName(&riid); name != "GUID*" {
+		t.Errorf("TypeName(riid) = %q", name)
+	}
+}
+
+func TestWrite_loadTypeLib(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	dir, err := ioutil.TempDir("", "typelib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drawing.tlb")
+	if err := WriteFile(path, newTestModel()); err != nil {
+		t.Fatal(err)
+	}
+
+	tlib, err := ole.LoadTypeLib(path)
+	if err != nil {
+		t.Fatalf("LoadTypeLib = %v", err)
+	}
+	defer tlib.Release()
+	lib, err := Load(tlib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pen := lib.Type("IPen")
+	if lib.Name != "Drawing" || pen == nil || len(pen.Funcs) != 3 || pen.Funcs[2].Name != "Draw" ||
+		len(pen.Funcs[2].Params) != 3 || pen.Funcs[2].Params[1].Default == nil {
+		t.Errorf("library = %+v, IPen = %+v", lib, pen)
+	}
+}
+
+// Imported typedefs and records have no GUID; Load tells them apart.
+func TestLoad_importsWithoutGuid(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	stdole, err := ole.LoadRegTypeLib(libidStdOle, 2, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadRegTypeLib = %v", err)
+	}
+	var indexes []int
+	var names []string
+	var kinds []int32
+	for i := 0; i < int(stdole.GetTypeInfoCount()) && len(indexes) < 2; i++ {
+		tinfo, err := stdole.GetTypeInfo(uint32(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		attr, err := tinfo.GetTypeAttr()
+		name, _, _, _, _ := tinfo.GetDocumentation(ole.MEMBERID_NIL)
+		tinfo.Release()
+		if err == nil && attr.Guid == (ole.GUID{}) && (attr.Typekind == ole.TKIND_RECORD || attr.Typekind == ole.TKIND_ALIAS) {
+			indexes = append(indexes, i)
+			names = append(names, name)
+			kinds = append(kinds, attr.Typekind)
+		}
+	}
+	stdole.Release()
+	if len(indexes) < 2 {
+		t.Skip("stdole has fewer than two types without a GUID")
+	}
+
+	imp := &Import{Guid: *libidStdOle, MajorVerNum: 2, Path: "stdole2.tlb"}
+	field := func(name string, href uint32, offset uint32) *Var {
+		return &Var{Name: name, Memid: 0x40000000 + int32(offset/8), VarKind: ole.VAR_PERINSTANCE, OInst: offset,
+			Type: ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: href}}}
+	}
+	holder := &TypeInfo{
+		Name: "Holder",
+		Attr: ole.TYPEATTR{Typekind: ole.TKIND_RECORD, CbSizeInstance: 16, CbAlignment: 8, CVars: 2,
+			MemidConstructor: ole.MEMBERID_NIL, MemidDestructor: ole.MEMBERID_NIL},
+		Vars: []*Var{field("first", 1, 0), field("second", 13, 8)},
+	}
+	lib := &Library{
+		Name:    "Imports",
+		Attr:    ole.TLIBATTR{Guid: *ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E30}"), Syskind: ole.SYS_WIN64, WMajorVerNum: 1},
+		Types:   []*TypeInfo{holder},
+		Imports: []*Import{imp},
+		Refs: map[uint32]*Ref{
+			0:  {Type: holder, Typekind: ole.TKIND_RECORD},
+			1:  {Import: imp, Index: indexes[0], Typekind: kinds[0]},
+			13: {Import: imp, Index: indexes[1], Typekind: kinds[1]},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "typelib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "imports.tlb")
+	if err := WriteFile(path, lib); err != nil {
+		t.Fatal(err)
+	}
+	tlib, err := ole.LoadTypeLib(path)
+	if err != nil {
+		t.Fatalf("LoadTypeLib = %v", err)
+	}
+	defer tlib.Release()
+	loaded, err := Load(tlib)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedHolder := loaded.Type("Holder")
+	if loadedHolder == nil || len(loadedHolder.Vars) != 2 {
+		t.Fatalf("Holder = %+v", loadedHolder)
+	}
+	vars := loadedHolder.Vars
+	first, second := loaded.Ref(vars[0].Type.Lptdesc.Hreftype), loaded.Ref(vars[1].Type.Lptdesc.Hreftype)
+	if first == nil || second == nil || first.Index != indexes[0] || first.Name != names[0] ||
+		second.Index != indexes[1] || second.Name != names[1] {
+		t.Errorf("imported types = %+v and %+v, want %s and %s", first, second, names[0], names[1])
+	}
+}
+
+// The reader is checked against COM on the libraries of Windows:
+// stdole2.tlb, in the MSFT format, and stdole32.tlb, in the SLTG format.
+func TestReadFile_system(t *testing.T) {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	for _, test := range []struct {
+		file  string
+		major uint16
+	}{
+		{"stdole2.tlb", 2},
+		{"stdole32.tlb", 1},
+	} {
+		path := filepath.Join(os.Getenv("SystemRoot"), "System32", test.file)
+		if _, err := os.Stat(path); err != nil {
+			t.Logf("%s: %v", test.file, err)
+			continue
+		}
+		read, err := ReadFile(path)
+		if err != nil {
+			t.Errorf("ReadFile(%s) = %v", test.file, err)
+			continue
+		}
+		tlib, err := ole.LoadRegTypeLib(libidStdOle, test.major, 0, 0)
+		if err != nil {
+			t.Logf("LoadRegTypeLib(stdole %d) = %v", test.major, err)
+			continue
+		}
+		loaded, err := Load(tlib)
+		tlib.Release()
+		if err != nil {
+			t.Errorf("Load(%s) = %v", test.file, err)
+			continue
+		}
+
+		got, want := librarySummary(read), librarySummary(loaded)
+		if len(got) != len(want) {
+			t.Errorf("%s: ReadFile gives %d lines of summary, Load %d", test.file, len(got), len(want))
+		}
+		for i := 0; i < len(got) && i < len(want); i++ {
+			if got[i] != want[i] {
+				t.Errorf("%s: ReadFile gives %s, Load %s", test.file, got[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+// librarySummary describes the types of lib and their members, one line
+// each.
+func librarySummary(lib *Library) []string {
+	lines := []string{fmt.Sprintf("library %s %s %d.%d", lib.Name, lib.Attr.Guid.String(), lib.Attr.WMajorVerNum, lib.Attr.WMinorVerNum)}
+	for _, t := range lib.Types {
+		lines = append(lines, fmt.Sprintf("type %s kind %d %s flags %#x implements %d",
+			t.Name, t.Attr.Typekind, t.Attr.Guid.String(), t.Attr.WTypeFlags, len(t.ImplTypes)))
+		for _, f := range t.Funcs {
+			names := make([]string, len(f.Params))
+			for i, p := range f.Params {
+				names[i] = fmt.Sprintf("%s %s %#x", lib.TypeName(&p.Type), p.Name, p.Flags)
+			}
+			lines = append(lines, fmt.Sprintf("\tfunc %s %#x invkind %d returns %s (%s)",
+				f.Name, uint32(f.Memid), f.InvKind, lib.TypeName(&f.Result), strings.Join(names, ", ")))
+		}
+		for _, v := range t.Vars {
+			lines = append(lines, fmt.Sprintf("\tvar %s %#x kind %d %s", v.Name, uint32(v.Memid), v.VarKind, lib.TypeName(&v.Type)))
+		}
+	}
+	return lines
+}
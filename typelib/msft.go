@@ -0,0 +1,548 @@
+package typelib
+
+import (
+	ole "github.com/go-ole/go-ole"
+)
+
+// The MSFT format starts with a header, the offsets of the type infos and a
+// directory of segments holding the type infos, names, strings, GUIDs, type
+// descriptions, constants and custom data. Offsets within a segment are
+// relative to its start, -1 meaning none.
+const (
+	msftSignature = "MSFT"
+
+	msftHeaderSize = 0x54
+	typeInfoSize   = 0x64 // size of a type info record
+
+	// msftHelpDll is set in the flags of the header when the offset of
+	// the help string DLL follows it.
+	msftHelpDll = 0x100
+)
+
+// Segments of the directory.
+const (
+	segTypeInfo = iota
+	segImpInfo
+	segImpFiles
+	segRefTab
+	segGuidHash
+	segGuid
+	segNameHash
+	segName
+	segString
+	segTypeDesc
+	segArrayDesc
+	segCustData
+	segCustDataGuid
+	segUnknown0e
+	segUnknown0f
+	segCount
+)
+
+// Flags of an imported type.
+const impInfoByGuid = 0x10000
+
+// Bits of the FKCCIC field of a function record.
+const (
+	funcHasCustData = 0x80
+	funcHasDefaults = 0x1000
+	funcEntryIsOrd  = 0x2000
+)
+
+type segment struct {
+	offset int
+	length int
+}
+
+type msftReader struct {
+	buffer
+	segs        [segCount]segment
+	lib         *Library
+	imports     map[int32]*Import
+	dispatchRef int32
+	depth       int
+}
+
+func parseMSFT(data []byte) (*Library, error) {
+	r := &msftReader{buffer: buffer{data: data}, imports: map[int32]*Import{}}
+	lib := &Library{Refs: map[uint32]*Ref{}}
+	r.lib = lib
+
+	varflags := r.i32(0x14)
+	count := int(r.i32(0x20))
+	if count < 0 || count > len(data)/typeInfoSize {
+		return nil, invalidData("type info count")
+	}
+	dir := msftHeaderSize + 4*count
+	if varflags&msftHelpDll != 0 {
+		dir += 4
+	}
+	for i := range r.segs {
+		r.segs[i] = segment{int(r.i32(dir + 16*i)), int(r.i32(dir + 16*i + 4))}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.i32(dir+12) != 0x0f {
+		return nil, invalidData("segment directory")
+	}
+
+	version := r.u32(0x18)
+	lib.Attr = ole.TLIBATTR{
+		Guid:         r.guidAt(r.i32(0x08)),
+		Lcid:         r.u32(0x0c),
+		Syskind:      varflags & 0xf,
+		WMajorVerNum: uint16(version),
+		WMinorVerNum: uint16(version >> 16),
+		WLibFlags:    uint16(r.u32(0x1c)),
+	}
+	lib.Name = r.name(r.i32(0x38))
+	lib.DocString = r.str(r.i32(0x24))
+	lib.HelpStringContext = r.u32(0x28)
+	lib.HelpContext = r.u32(0x2c)
+	lib.HelpFile = r.str(r.i32(0x3c))
+	if varflags&msftHelpDll != 0 {
+		lib.HelpStringDll = r.str(r.i32(msftHeaderSize))
+	}
+	lib.CustData = r.custData(r.i32(0x40))
+	r.dispatchRef = r.i32(0x4c)
+
+	r.readImports()
+	lib.Types = make([]*TypeInfo, count)
+	for i := range lib.Types {
+		lib.Types[i] = &TypeInfo{}
+	}
+	for i, t := range lib.Types {
+		r.readTypeInfo(t, r.segs[segTypeInfo].offset+i*typeInfoSize)
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	if r.dispatchRef != -1 {
+		r.ref(uint32(r.dispatchRef))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	lib.resolveKinds()
+	return lib, nil
+}
+
+// segOffset returns the absolute offset of off within segment seg.
+func (r *msftReader) segOffset(seg int, off int32) int {
+	if r.segs[seg].offset < 0 || off < 0 || int(off) >= r.segs[seg].length {
+		r.fail("offset out of segment")
+		return -1
+	}
+	return r.segs[seg].offset + int(off)
+}
+
+func (r *msftReader) guidAt(off int32) ole.GUID {
+	if off < 0 {
+		return ole.GUID{}
+	}
+	return r.guid(r.segOffset(segGuid, off))
+}
+
+// name reads an entry of the name table: its hreftype, the offset of the
+// next name of its hash bucket and its length and hash, then its bytes.
+func (r *msftReader) name(off int32) string {
+	if off < 0 {
+		return ""
+	}
+	at := r.segOffset(segName, off)
+	length := int(r.u32(at+8) & 0xff)
+	return decodeANSI(r.bytes(at+12, length))
+}
+
+// str reads an entry of the string table: a 16-bit length, then the bytes.
+func (r *msftReader) str(off int32) string {
+	if off < 0 {
+		return ""
+	}
+	at := r.segOffset(segString, off)
+	length := int(r.u16(at))
+	return decodeANSI(r.bytes(at+2, length))
+}
+
+// value reads a constant. Negative offsets hold small values inline: the
+// type in bits 26 to 30 and the value in the low 26 bits. Others point to
+// the custom data segment, where the type precedes the value.
+func (r *msftReader) value(off int32) Value {
+	if off < 0 {
+		vt := ole.VT(uint32(off) >> 26 & 0x1f)
+		var raw [8]byte
+		packed := uint32(off) & 0x3ffffff
+		raw[0], raw[1], raw[2], raw[3] = byte(packed), byte(packed>>8), byte(packed>>16), byte(packed>>24)
+		inline := buffer{data: raw[:]}
+		v := inline.value(vt, 0)
+		if inline.err != nil {
+			r.fail("inline constant of type " + vt.String())
+		}
+		return v
+	}
+	at := r.segOffset(segCustData, off)
+	vt := ole.VT(r.u16(at))
+	if vt == ole.VT_BSTR {
+		length := r.i32(at + 2)
+		if length < 0 {
+			return Value{VT: vt, Val: ""}
+		}
+		return Value{VT: vt, Val: decodeANSI(r.bytes(at+6, int(length)))}
+	}
+	return r.buffer.value(vt, at+2)
+}
+
+// custData reads a list of custom data entries: the offsets of a GUID and a
+// value and of the next entry.
+func (r *msftReader) custData(off int32) []CustData {
+	var list []CustData
+	for n := 0; off >= 0 && r.err == nil; n++ {
+		if n > r.segs[segCustDataGuid].length/12 {
+			r.fail("custom data loop")
+			break
+		}
+		at := r.segOffset(segCustDataGuid, off)
+		list = append(list, CustData{
+			Guid:  r.guidAt(r.i32(at)),
+			Value: r.value(r.i32(at + 4)),
+		})
+		off = r.i32(at + 8)
+	}
+	return list
+}
+
+// readImports reads the imported libraries: the offset of their GUID, their
+// locale, version and file name, whose length is stored shifted by two.
+func (r *msftReader) readImports() {
+	seg := r.segs[segImpFiles]
+	if seg.offset < 0 {
+		return
+	}
+	for off := 0; off < seg.length && r.err == nil; {
+		at := seg.offset + off
+		size := int(r.u16(at+12)) >> 2
+		imp := &Import{
+			Guid:        r.guidAt(r.i32(at)),
+			Lcid:        r.u32(at + 4),
+			MajorVerNum: r.u16(at + 8),
+			MinorVerNum: r.u16(at + 10),
+			Path:        decodeANSI(r.bytes(at+14, size)),
+		}
+		r.imports[int32(off)] = imp
+		r.lib.Imports = append(r.lib.Imports, imp)
+		off = (off + 14 + size + 3) &^ 3
+	}
+}
+
+// ref resolves hreftype into the references of the library. The hreftypes
+// of types of the library are the offsets of their records; others are
+// offsets in the table of imported types, with the low bit set.
+func (r *msftReader) ref(hreftype uint32) {
+	if _, ok := r.lib.Refs[hreftype]; ok {
+		return
+	}
+	if hreftype&3 == 0 {
+		index := int(hreftype / typeInfoSize)
+		if hreftype%typeInfoSize != 0 || index >= len(r.lib.Types) {
+			r.fail("reference to a missing type")
+			return
+		}
+		r.lib.Refs[hreftype] = &Ref{Type: r.lib.Types[index], Index: index}
+		return
+	}
+	at := r.segOffset(segImpInfo, int32(hreftype&^3))
+	flags := r.i32(at)
+	imp := r.imports[r.i32(at+4)]
+	if imp == nil {
+		r.fail("reference to a missing import")
+		return
+	}
+	ref := &Ref{Import: imp, Typekind: flags >> 24 & 0xf}
+	if flags&impInfoByGuid != 0 {
+		ref.Guid = r.guidAt(r.i32(at + 8))
+		ref.Index = -1
+	} else {
+		ref.Index = int(r.i32(at + 8))
+	}
+	r.lib.Refs[hreftype] = ref
+}
+
+// typeDesc decodes the type code of a function, parameter, variable or
+// alias. Negative codes are base types, with the VARTYPE in the low bits.
+// Others are offsets in the type description segment, whose entries hold a
+// VARTYPE and the pointed type, the array description or the hreftype.
+func (r *msftReader) typeDesc(code int32) (desc ole.TYPEDESC) {
+	if code < 0 {
+		desc.VT = uint16(code) & uint16(ole.VT_TYPEMASK)
+		return
+	}
+	if r.depth++; r.depth > 64 {
+		r.fail("type description loop")
+		return
+	}
+	defer func() { r.depth-- }()
+
+	at := r.segOffset(segTypeDesc, code)
+	desc.VT = r.u16(at) & uint16(ole.VT_TYPEMASK)
+	target := r.i32(at + 4)
+	switch ole.VT(desc.VT) {
+	case ole.VT_PTR, ole.VT_SAFEARRAY:
+		var elem ole.TYPEDESC
+		if int16(target>>16) < 0 {
+			elem.VT = uint16(target) & uint16(ole.VT_TYPEMASK)
+		} else {
+			elem = r.typeDesc(target)
+		}
+		desc.Lptdesc = &elem
+	case ole.VT_CARRAY:
+		array := r.segOffset(segArrayDesc, target)
+		dims := int(r.u16(array + 4))
+		desc.Lpadesc = &ole.ARRAYDESC{
+			TdescElem: r.typeDesc(r.i32(array)),
+			Bounds:    make([]ole.SafeArrayBound, dims),
+		}
+		for i := range desc.Lpadesc.Bounds {
+			desc.Lpadesc.Bounds[i] = ole.SafeArrayBound{
+				Elements:   r.u32(array + 8 + 8*i),
+				LowerBound: r.i32(array + 12 + 8*i),
+			}
+		}
+	case ole.VT_USERDEFINED:
+		desc.Hreftype = uint32(target)
+		r.ref(desc.Hreftype)
+	}
+	return
+}
+
+// readTypeInfo reads the type info record at.
+func (r *msftReader) readTypeInfo(t *TypeInfo, at int) {
+	typekind := r.i32(at)
+	elements := r.u32(at + 0x18)
+	version := r.u32(at + 0x38)
+	t.Attr = ole.TYPEATTR{
+		Guid:             r.guidAt(r.i32(at + 0x2c)),
+		Lcid:             r.lib.Attr.Lcid,
+		MemidConstructor: ole.MEMBERID_NIL,
+		MemidDestructor:  ole.MEMBERID_NIL,
+		CbSizeInstance:   r.u32(at + 0x50),
+		Typekind:         typekind & 0xf,
+		CFuncs:           uint16(elements),
+		CVars:            uint16(elements >> 16),
+		CImplTypes:       r.u16(at + 0x4c),
+		CbSizeVft:        r.u16(at + 0x4e),
+		CbAlignment:      uint16(typekind >> 11 & 0x1f),
+		WTypeFlags:       uint16(r.u32(at + 0x30)),
+		WMajorVerNum:     uint16(version),
+		WMinorVerNum:     uint16(version >> 16),
+	}
+	t.Name = r.name(r.i32(at + 0x34))
+	t.DocString = r.str(r.i32(at + 0x3c))
+	t.HelpStringContext = r.u32(at + 0x40)
+	t.HelpContext = r.u32(at + 0x44)
+	t.CustData = r.custData(r.i32(at + 0x48))
+
+	datatype1 := r.i32(at + 0x54)
+	switch t.Attr.Typekind {
+	case ole.TKIND_ALIAS:
+		t.Attr.TdescAlias = r.typeDesc(datatype1)
+	case ole.TKIND_MODULE:
+		t.DllName = r.str(datatype1)
+	}
+
+	if t.Attr.CImplTypes > 0 {
+		switch t.Attr.Typekind {
+		case ole.TKIND_COCLASS:
+			t.ImplTypes = r.refRecords(datatype1, int(t.Attr.CImplTypes))
+		case ole.TKIND_DISPATCH:
+			// A dispinterface implements IDispatch, unless it wraps an
+			// interface.
+			href := datatype1
+			if href == -1 {
+				href = r.dispatchRef
+			}
+			if href != -1 {
+				t.ImplTypes = []ImplType{{Hreftype: uint32(href)}}
+			}
+		default:
+			t.ImplTypes = []ImplType{{Hreftype: uint32(datatype1)}}
+		}
+		for _, impl := range t.ImplTypes {
+			r.ref(impl.Hreftype)
+		}
+	}
+
+	memoffset := int(r.i32(at + 4))
+	if t.Attr.CFuncs > 0 || t.Attr.CVars > 0 {
+		r.readMembers(t, memoffset)
+	}
+}
+
+// refRecords reads the implemented interfaces of a coclass: records of a
+// hreftype, flags, custom data and the offset of the next record.
+func (r *msftReader) refRecords(off int32, count int) []ImplType {
+	impls := make([]ImplType, 0, count)
+	for i := 0; i < count && off >= 0 && r.err == nil; i++ {
+		at := r.segOffset(segRefTab, off)
+		impls = append(impls, ImplType{
+			Hreftype: r.u32(at),
+			Flags:    r.i32(at + 4),
+			CustData: r.custData(r.i32(at + 8)),
+		})
+		off = r.i32(at + 12)
+	}
+	return impls
+}
+
+// readMembers reads the functions and variables of a type. They start with
+// the length of the records, followed by the records of the functions then
+// of the variables. After the records come arrays of member IDs, name
+// offsets and record offsets, functions first.
+func (r *msftReader) readMembers(t *TypeInfo, at int) {
+	funcs, vars := int(t.Attr.CFuncs), int(t.Attr.CVars)
+	total := funcs + vars
+	infolen := int(r.i32(at))
+	tables := at + 4 + infolen
+	memid := func(i int) int32 { return r.i32(tables + 4*i) }
+	nameOffset := func(i int) int32 { return r.i32(tables + 4*(total+i)) }
+	record := func(i int) int { return at + 4 + int(r.i32(tables+4*(2*total+i))) }
+
+	if funcs > 0 {
+		t.Funcs = make([]*Func, funcs)
+	}
+	for i := range t.Funcs {
+		f := r.readFunc(record(i))
+		f.Memid = memid(i)
+		if off := nameOffset(i); off != -1 {
+			f.Name = r.name(off)
+		} else if i > 0 && isProperty(f.InvKind) && isProperty(t.Funcs[i-1].InvKind) {
+			// The second accessor of a property may share the name of the
+			// first.
+			f.Name = t.Funcs[i-1].Name
+		}
+		t.Funcs[i] = f
+		if r.err != nil {
+			return
+		}
+	}
+	if vars > 0 {
+		t.Vars = make([]*Var, vars)
+	}
+	for i := range t.Vars {
+		v := r.readVar(record(funcs + i))
+		v.Memid = memid(funcs + i)
+		v.Name = r.name(nameOffset(funcs + i))
+		t.Vars[i] = v
+		if r.err != nil {
+			return
+		}
+	}
+}
+
+func isProperty(invkind int32) bool {
+	return invkind&(ole.INVOKE_PROPERTYGET|ole.INVOKE_PROPERTYPUT|ole.INVOKE_PROPERTYPUTREF) != 0
+}
+
+// readFunc reads a function record: its length, return type, flags, vtable
+// offset, kinds and parameter counts, then optional fields up to the length
+// and the parameters: default values when the FKCCIC bit is set, then the
+// type, name and flags of each parameter.
+func (r *msftReader) readFunc(at int) *Func {
+	length := int(r.u16(at))
+	fkccic := r.u32(at + 16)
+	nargs := int(int16(r.u16(at + 20)))
+	if nargs < 0 {
+		r.fail("negative parameter count")
+		return &Func{}
+	}
+	f := &Func{
+		FuncKind:   int32(fkccic & 0x7),
+		InvKind:    int32(fkccic >> 3 & 0xf),
+		CallConv:   int32(fkccic >> 8 & 0xf),
+		OVft:       int16(r.u16(at+12)) &^ 1,
+		Flags:      uint16(r.u32(at + 8)),
+		Result:     r.typeDesc(r.i32(at + 4)),
+		CParamsOpt: int16(r.u16(at + 22)),
+	}
+
+	optional := length - 12*nargs
+	if fkccic&funcHasDefaults != 0 {
+		optional -= 4 * nargs
+	}
+	field := func(offset int) (int32, bool) {
+		if optional >= offset+4 {
+			return r.i32(at + offset), true
+		}
+		return 0, false
+	}
+	if v, ok := field(24); ok {
+		f.HelpContext = uint32(v)
+	}
+	if v, ok := field(28); ok {
+		f.DocString = r.str(v)
+	}
+	if v, ok := field(32); ok {
+		if fkccic&funcEntryIsOrd != 0 {
+			f.Ordinal = uint16(v)
+		} else {
+			f.Entry = r.str(v)
+		}
+	}
+	if v, ok := field(44); ok {
+		f.HelpStringContext = uint32(v)
+	}
+	if v, ok := field(48); ok && fkccic&funcHasCustData != 0 {
+		f.CustData = r.custData(v)
+	}
+
+	params := at + length - 12*nargs
+	defaults := params - 4*nargs
+	if nargs > 0 {
+		f.Params = make([]Param, nargs)
+	}
+	for i := range f.Params {
+		p := &f.Params[i]
+		info := params + 12*i
+		p.Type = r.typeDesc(r.i32(info))
+		p.Name = r.name(r.i32(info + 4))
+		p.Flags = uint16(r.u32(info + 8))
+		if p.Flags&ole.PARAMFLAG_FHASDEFAULT != 0 && fkccic&funcHasDefaults != 0 {
+			value := r.value(r.i32(defaults + 4*i))
+			p.Default = &value
+		}
+		if v, ok := field(52 + 4*i); ok && fkccic&funcHasCustData != 0 {
+			p.CustData = r.custData(v)
+		}
+	}
+	return f
+}
+
+// readVar reads a variable record: its length, type, flags, kind, value or
+// offset, then optional fields up to the length.
+func (r *msftReader) readVar(at int) *Var {
+	length := int(r.u16(at) & 0xff)
+	v := &Var{
+		Type:    r.typeDesc(r.i32(at + 4)),
+		Flags:   uint16(r.u32(at + 8)),
+		VarKind: int32(int16(r.u16(at + 12))),
+	}
+	if v.VarKind == ole.VAR_CONST {
+		value := r.value(r.i32(at + 16))
+		v.Value = &value
+	} else {
+		v.OInst = r.u32(at + 16)
+	}
+	if length >= 24 {
+		v.HelpContext = r.u32(at + 20)
+	}
+	if length >= 28 {
+		v.DocString = r.str(r.i32(at + 24))
+	}
+	if length >= 36 {
+		v.CustData = r.custData(r.i32(at + 32))
+	}
+	if length >= 40 {
+		v.HelpStringContext = r.u32(at + 36)
+	}
+	return v
+}
@@ -0,0 +1,832 @@
+package typelib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// Fields of the MSFT header and records that readers do not need but
+// LoadTypeLib expects, with the values MIDL and ICreateTypeLib2 write.
+const (
+	msftVersion     = 0x00010002
+	msftVarFlags    = 0x10
+	guidHashBuckets = 0x20
+	nameHashBuckets = 0x80
+	msftPadding     = 'W' // pads names, strings and constants
+	msftNoValue     = -1
+	msftLibGuidHref = -2 // hreftype of the entry of the library's GUID
+)
+
+// Sizes of the structures LoadTypeLib reconstitutes from function and
+// variable records, which the records announce so that their memory can be
+// allocated at once. They are those of 64-bit platforms, the larger.
+const (
+	sizeFuncDesc   = 88
+	sizeVarDesc    = 64
+	sizeElemDesc   = 32
+	sizeTypeDesc   = 16
+	sizeArrayDesc  = 32
+	sizeArrayBound = 8
+	sizeParamDescx = 32
+)
+
+// msftWriter lays a library out in the MSFT format: the segments are built
+// in memory, then written after the header and the segment directory,
+// followed by the member blocks of the types.
+type msftWriter struct {
+	lib     *Library
+	segs    [segCount][]byte
+	members [][]byte
+	err     error
+
+	names     map[string]int32
+	strings   map[string]int32
+	guids     map[ole.GUID]int32
+	typeDescs map[[8]byte]int32
+	nameCount int
+	nameChars int
+
+	// hrefs maps the hreftypes of the library to those written: the
+	// offsets of the type info records for its types, the offsets of
+	// entries of the import table with the low bit set for others.
+	hrefs       map[uint32]uint32
+	files       map[*Import]int32
+	dispatchRef int32
+}
+
+func writeMSFT(lib *Library) ([]byte, error) {
+	w := &msftWriter{
+		lib:         lib,
+		names:       map[string]int32{},
+		strings:     map[string]int32{},
+		guids:       map[ole.GUID]int32{},
+		typeDescs:   map[[8]byte]int32{},
+		hrefs:       map[uint32]uint32{},
+		files:       map[*Import]int32{},
+		dispatchRef: -1,
+	}
+	w.segs[segGuidHash] = hashTable(guidHashBuckets)
+	w.segs[segNameHash] = hashTable(nameHashBuckets)
+
+	libGuid := w.guid(lib.Attr.Guid, msftLibGuidHref)
+	for _, imp := range lib.Imports {
+		w.importFile(imp)
+	}
+	w.references()
+	// Names of the types come first, as MIDL writes them.
+	for i, t := range lib.Types {
+		w.name(t.Name, int32(HrefOfIndex(i)))
+	}
+	records := make([][]byte, len(lib.Types))
+	for i, t := range lib.Types {
+		records[i] = w.typeInfo(t, i)
+	}
+	libName := w.name(lib.Name, -1)
+	docString := w.str(lib.DocString)
+	helpFile := w.str(lib.HelpFile)
+	helpDll := w.str(lib.HelpStringDll)
+	custData := w.custData(lib.CustData)
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	varflags := lib.Attr.Syskind&0xf | msftVarFlags
+	if lib.HelpStringDll != "" {
+		varflags |= msftHelpDll
+	}
+	header := make([]byte, 0, msftHeaderSize)
+	header = append(header, msftSignature...)
+	header = appendU32(header, msftVersion)
+	header = appendI32(header, libGuid)
+	header = appendU32(header, lib.Attr.Lcid)
+	header = appendU32(header, lib.Attr.Lcid)
+	header = appendI32(header, varflags)
+	header = appendU32(header, uint32(lib.Attr.WMajorVerNum)|uint32(lib.Attr.WMinorVerNum)<<16)
+	header = appendU32(header, uint32(lib.Attr.WLibFlags))
+	header = appendI32(header, int32(len(lib.Types)))
+	header = appendI32(header, docString)
+	header = appendU32(header, lib.HelpStringContext)
+	header = appendU32(header, lib.HelpContext)
+	header = appendI32(header, int32(w.nameCount))
+	header = appendI32(header, int32(w.nameChars))
+	header = appendI32(header, libName)
+	header = appendI32(header, helpFile)
+	header = appendI32(header, custData)
+	header = appendI32(header, guidHashBuckets)
+	header = appendI32(header, nameHashBuckets)
+	header = appendI32(header, w.dispatchRef)
+	header = appendI32(header, int32(len(w.segs[segImpInfo])/12))
+	if varflags&msftHelpDll != 0 {
+		header = appendI32(header, helpDll)
+	}
+	for i := range lib.Types {
+		header = appendU32(header, HrefOfIndex(i))
+	}
+
+	// The member blocks follow the segments, so their offsets are known
+	// once all segments are.
+	for _, record := range records {
+		w.segs[segTypeInfo] = append(w.segs[segTypeInfo], record...)
+	}
+	pos := len(header) + 16*segCount
+	dir := make([]byte, 0, 16*segCount)
+	for _, seg := range w.segs {
+		if len(seg) == 0 {
+			dir = appendI32(dir, -1)
+		} else {
+			dir = appendI32(dir, int32(pos))
+		}
+		dir = appendI32(dir, int32(len(seg)))
+		dir = appendI32(dir, -1)
+		dir = appendI32(dir, 0x0f)
+		pos += len(seg)
+	}
+	for i, block := range w.members {
+		memoffset := int32(-1)
+		if block != nil {
+			memoffset = int32(pos)
+			pos += len(block)
+		}
+		binary.LittleEndian.PutUint32(w.segs[segTypeInfo][i*typeInfoSize+4:], uint32(memoffset))
+	}
+
+	out := make([]byte, 0, pos)
+	out = append(append(out, header...), dir...)
+	for _, seg := range w.segs {
+		out = append(out, seg...)
+	}
+	for _, block := range w.members {
+		out = append(out, block...)
+	}
+	return out, nil
+}
+
+func (w *msftWriter) fail(what string) {
+	if w.err == nil {
+		w.err = ole.NewErrorWithDescription(ole.E_INVALIDARG, "cannot write type library: "+what)
+	}
+}
+
+func hashTable(buckets int) []byte {
+	table := make([]byte, 4*buckets)
+	for i := range table {
+		table[i] = 0xff
+	}
+	return table
+}
+
+func appendU16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendI32(b []byte, v int32) []byte {
+	return appendU32(b, uint32(v))
+}
+
+// pad pads b to a multiple of four bytes.
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, msftPadding)
+	}
+	return b
+}
+
+// encodeANSI encodes the names and strings of a library as decodeANSI reads
+// them back.
+func (w *msftWriter) encodeANSI(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			w.fail("character " + strconv.QuoteRune(r) + " of " + strconv.Quote(s) + " has no 8-bit encoding")
+			return nil
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+// name returns the offset of the entry of name in the name table, adding
+// it when missing. Entries hold the hreftype of the type name names, the
+// next entry of their hash bucket and their length and hash.
+func (w *msftWriter) name(name string, hreftype int32) int32 {
+	if off, ok := w.names[name]; ok {
+		if hreftype != -1 {
+			binary.LittleEndian.PutUint32(w.segs[segName][off:], uint32(hreftype))
+		}
+		return off
+	}
+	b := w.encodeANSI(name)
+	if len(b) > 0xff {
+		w.fail("name " + strconv.Quote(name) + " too long")
+		return -1
+	}
+	hash := hashName(b)
+	bucket := 4 * int(hash&(nameHashBuckets-1))
+	off := int32(len(w.segs[segName]))
+	seg := appendI32(w.segs[segName], hreftype)
+	seg = append(seg, w.segs[segNameHash][bucket:bucket+4]...)
+	seg = appendU32(seg, uint32(len(b))|uint32(hash)<<16)
+	w.segs[segName] = pad(append(seg, b...))
+	binary.LittleEndian.PutUint32(w.segs[segNameHash][bucket:], uint32(off))
+	w.names[name] = off
+	w.nameCount++
+	w.nameChars += len(b) + 1
+	return off
+}
+
+// hashName returns the low word of the hash LHashValOfNameSys computes for
+// the Latin code pages, by which LoadTypeLib looks names up. Letters of
+// ASCII are folded to upper case; other bytes are hashed unchanged.
+func hashName(name []byte) uint16 {
+	hash := uint32(0x0deadbee)
+	for _, c := range name {
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		hash = 37*hash + uint32(c)
+	}
+	return uint16(hash % 65599)
+}
+
+// str returns the offset of s in the string table, -1 for an empty string.
+func (w *msftWriter) str(s string) int32 {
+	if s == "" {
+		return -1
+	}
+	if off, ok := w.strings[s]; ok {
+		return off
+	}
+	b := w.encodeANSI(s)
+	if len(b) > 0xffff {
+		w.fail("string too long")
+		return -1
+	}
+	off := int32(len(w.segs[segString]))
+	w.segs[segString] = pad(append(appendU16(w.segs[segString], uint16(len(b))), b...))
+	w.strings[s] = off
+	return off
+}
+
+// guid returns the offset of the entry of guid in the GUID table, adding it
+// when missing. Entries hold the hreftype of the type guid identifies and
+// the next entry of their hash bucket.
+func (w *msftWriter) guid(guid ole.GUID, hreftype int32) int32 {
+	if off, ok := w.guids[guid]; ok {
+		return off
+	}
+	var b [16]byte
+	binary.LittleEndian.PutUint32(b[:], guid.Data1)
+	binary.LittleEndian.PutUint16(b[4:], guid.Data2)
+	binary.LittleEndian.PutUint16(b[6:], guid.Data3)
+	copy(b[8:], guid.Data4[:])
+	var hash uint16
+	for i := 0; i < 16; i += 2 {
+		hash ^= binary.LittleEndian.Uint16(b[i:])
+	}
+	bucket := 4 * int(hash&(guidHashBuckets-1))
+
+	off := int32(len(w.segs[segGuid]))
+	seg := appendI32(append(w.segs[segGuid], b[:]...), hreftype)
+	w.segs[segGuid] = append(seg, w.segs[segGuidHash][bucket:bucket+4]...)
+	binary.LittleEndian.PutUint32(w.segs[segGuidHash][bucket:], uint32(off))
+	w.guids[guid] = off
+	return off
+}
+
+// optionalGuid returns the offset of guid, or -1 when it is null.
+func (w *msftWriter) optionalGuid(guid ole.GUID, hreftype int32) int32 {
+	if guid == (ole.GUID{}) {
+		return -1
+	}
+	return w.guid(guid, hreftype)
+}
+
+// importFile adds an imported library to the table of imported files.
+func (w *msftWriter) importFile(imp *Import) int32 {
+	if off, ok := w.files[imp]; ok {
+		return off
+	}
+	path := w.encodeANSI(imp.Path)
+	if len(path) > 0x3fff {
+		w.fail("import path too long")
+		return -1
+	}
+	off := int32(len(w.segs[segImpFiles]))
+	seg := appendI32(w.segs[segImpFiles], w.guid(imp.Guid, -1))
+	seg = appendU32(seg, imp.Lcid)
+	seg = appendU16(seg, imp.MajorVerNum)
+	seg = appendU16(seg, imp.MinorVerNum)
+	seg = appendU16(seg, uint16(len(path))<<2)
+	w.segs[segImpFiles] = pad(append(seg, path...))
+	w.files[imp] = off
+	return off
+}
+
+// references maps the hreftypes of the library to those written, adding
+// the imported types to the import table in the order of their hreftypes.
+// Imported types whose kind is unknown are recorded as interfaces, which
+// they most often are.
+func (w *msftWriter) references() {
+	index := make(map[*TypeInfo]int, len(w.lib.Types))
+	for i, t := range w.lib.Types {
+		index[t] = i
+	}
+	hrefs := make([]uint32, 0, len(w.lib.Refs))
+	for href := range w.lib.Refs {
+		hrefs = append(hrefs, href)
+	}
+	sort.Slice(hrefs, func(i, j int) bool { return hrefs[i] < hrefs[j] })
+
+	for _, href := range hrefs {
+		ref := w.lib.Refs[href]
+		switch {
+		case ref.Type != nil:
+			i, ok := index[ref.Type]
+			if !ok {
+				w.fail("reference to " + ref.Type.Name + ", a type of another library")
+				return
+			}
+			w.hrefs[href] = HrefOfIndex(i)
+		case ref.Import != nil:
+			kind := ref.Typekind
+			if kind < 0 {
+				kind = ole.TKIND_INTERFACE
+			}
+			flags := uint32(kind&0xf) << 24
+			target := int32(ref.Index)
+			if ref.Guid != (ole.GUID{}) {
+				flags |= impInfoByGuid
+				target = w.guid(ref.Guid, -1)
+			}
+			off := int32(len(w.segs[segImpInfo]))
+			seg := appendU32(w.segs[segImpInfo], flags)
+			seg = appendI32(seg, w.importFile(ref.Import))
+			w.segs[segImpInfo] = appendI32(seg, target)
+			w.hrefs[href] = uint32(off) | 1
+			if ole.IsEqualGUID(&ref.Guid, ole.IID_IDispatch) && w.dispatchRef == -1 {
+				w.dispatchRef = off | 1
+			}
+		default:
+			w.fail("reference " + strconv.Itoa(int(href)) + " to no type")
+			return
+		}
+	}
+}
+
+// href returns the written hreftype of hreftype.
+func (w *msftWriter) href(hreftype uint32) uint32 {
+	href, ok := w.hrefs[hreftype]
+	if !ok {
+		w.fail("hreftype " + strconv.Itoa(int(hreftype)) + " missing from Refs")
+	}
+	return href
+}
+
+// typeDesc returns the code of a type: base types are encoded in negative
+// codes, others are entries of the type description segment, shared by
+// equal types.
+func (w *msftWriter) typeDesc(desc *ole.TYPEDESC) int32 {
+	vt := ole.VT(desc.VT)
+	var entry [8]byte
+	switch vt {
+	case ole.VT_PTR, ole.VT_SAFEARRAY:
+		if desc.Lptdesc == nil {
+			w.fail(vt.String() + " without element type")
+			return -1
+		}
+		target := w.typeDesc(desc.Lptdesc)
+		// The high word tells what the target is: a base type, or an
+		// entry referring to a user-defined type or not.
+		mix := uint16(0x7ffe)
+		if target < 0 {
+			mix = uint16(target>>16) & uint16(ole.VT_TYPEMASK)
+		} else if w.err == nil && binary.LittleEndian.Uint16(w.segs[segTypeDesc][target+2:]) == 0x7fff {
+			mix = 0x7fff
+		}
+		binary.LittleEndian.PutUint16(entry[2:], mix)
+		binary.LittleEndian.PutUint32(entry[4:], uint32(target))
+	case ole.VT_CARRAY:
+		if desc.Lpadesc == nil {
+			w.fail("VT_CARRAY without array description")
+			return -1
+		}
+		binary.LittleEndian.PutUint16(entry[2:], 0x7ffe)
+		binary.LittleEndian.PutUint32(entry[4:], uint32(w.arrayDesc(desc.Lpadesc)))
+	case ole.VT_USERDEFINED:
+		binary.LittleEndian.PutUint16(entry[2:], 0x7fff)
+		binary.LittleEndian.PutUint32(entry[4:], w.href(desc.Hreftype))
+	default:
+		return int32(0x80000000 | uint32(vt)<<16 | uint32(vt))
+	}
+	binary.LittleEndian.PutUint16(entry[:], uint16(vt))
+	if off, ok := w.typeDescs[entry]; ok {
+		return off
+	}
+	off := int32(len(w.segs[segTypeDesc]))
+	w.segs[segTypeDesc] = append(w.segs[segTypeDesc], entry[:]...)
+	w.typeDescs[entry] = off
+	return off
+}
+
+// arrayDesc adds an array description: the code of the element type, the
+// count of dimensions and the size of their bounds, then the bounds.
+func (w *msftWriter) arrayDesc(desc *ole.ARRAYDESC) int32 {
+	elem := w.typeDesc(&desc.TdescElem)
+	dims := len(desc.Bounds)
+	if dims == 0 || dims > 0x1fff {
+		w.fail("array of " + strconv.Itoa(dims) + " dimensions")
+		return -1
+	}
+	off := int32(len(w.segs[segArrayDesc]))
+	seg := appendI32(w.segs[segArrayDesc], elem)
+	seg = appendU16(seg, uint16(dims))
+	seg = appendU16(seg, uint16(dims*sizeArrayBound))
+	for _, bound := range desc.Bounds {
+		seg = appendU32(seg, bound.Elements)
+		seg = appendI32(seg, bound.LowerBound)
+	}
+	w.segs[segArrayDesc] = seg
+	return off
+}
+
+// decodedSize returns the memory the types that desc refers to take once
+// reconstituted.
+func decodedSize(desc *ole.TYPEDESC) int {
+	switch ole.VT(desc.VT) {
+	case ole.VT_PTR, ole.VT_SAFEARRAY:
+		if desc.Lptdesc != nil {
+			return sizeTypeDesc + decodedSize(desc.Lptdesc)
+		}
+	case ole.VT_CARRAY:
+		if desc.Lpadesc != nil && len(desc.Lpadesc.Bounds) > 0 {
+			return sizeArrayDesc + (len(desc.Lpadesc.Bounds)-1)*sizeArrayBound + decodedSize(&desc.Lpadesc.TdescElem)
+		}
+	}
+	return 0
+}
+
+// value returns the code of a constant. Small values are packed in the
+// code, as the reader expects; others are stored in the custom data
+// segment after their type.
+func (w *msftWriter) value(v *Value) int32 {
+	raw, ok := valueBytes(v)
+	if !ok {
+		w.fail(fmt.Sprintf("constant %v of type %s", v.Val, v.VT))
+		return msftNoValue
+	}
+	if v.VT != ole.VT_BSTR && v.VT <= 0x1f && len(raw) <= 4 {
+		var packed [4]byte
+		copy(packed[:], raw)
+		if n := binary.LittleEndian.Uint32(packed[:]); n < 1<<26 {
+			return int32(0x80000000 | uint32(v.VT)<<26 | n)
+		}
+	}
+	off := int32(len(w.segs[segCustData]))
+	seg := appendU16(w.segs[segCustData], uint16(v.VT))
+	if v.VT == ole.VT_BSTR {
+		s := w.encodeANSI(v.Val.(string))
+		seg = append(appendI32(seg, int32(len(s))), s...)
+	} else {
+		seg = append(seg, raw...)
+	}
+	w.segs[segCustData] = pad(seg)
+	return off
+}
+
+// valueBytes returns the bytes of a constant in its natural size, and
+// whether its value suits its type. Strings have no bytes here, as value
+// encodes them.
+func valueBytes(v *Value) ([]byte, bool) {
+	var n uint64
+	switch val := v.Val.(type) {
+	case nil:
+		return nil, v.VT == ole.VT_EMPTY || v.VT == ole.VT_NULL
+	case string:
+		return nil, v.VT == ole.VT_BSTR
+	case bool:
+		if val {
+			n = 0xffff
+		}
+	case int8:
+		n = uint64(val)
+	case int16:
+		n = uint64(val)
+	case int32:
+		n = uint64(val)
+	case int64:
+		n = uint64(val)
+	case uint8:
+		n = uint64(val)
+	case uint16:
+		n = uint64(val)
+	case uint32:
+		n = uint64(val)
+	case uint64:
+		n = val
+	case float32:
+		if v.VT != ole.VT_R4 {
+			return nil, false
+		}
+		n = uint64(math.Float32bits(val))
+	case float64:
+		if v.VT != ole.VT_R8 && v.VT != ole.VT_DATE {
+			return nil, false
+		}
+		n = math.Float64bits(val)
+	default:
+		return nil, false
+	}
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	switch v.VT {
+	case ole.VT_I1, ole.VT_UI1:
+		return b[:1], true
+	case ole.VT_I2, ole.VT_UI2, ole.VT_BOOL:
+		return b[:2], true
+	case ole.VT_I4, ole.VT_UI4, ole.VT_INT, ole.VT_UINT, ole.VT_ERROR, ole.VT_HRESULT, ole.VT_R4:
+		return b[:4], true
+	case ole.VT_I8, ole.VT_UI8, ole.VT_CY, ole.VT_R8, ole.VT_DATE:
+		return b[:8], true
+	}
+	return nil, false
+}
+
+// custData adds a list of custom data entries and returns the offset of
+// its first entry, or -1 when it is empty.
+func (w *msftWriter) custData(list []CustData) int32 {
+	if len(list) == 0 {
+		return -1
+	}
+	first := int32(len(w.segs[segCustDataGuid]))
+	for i := range list {
+		guid := w.guid(list[i].Guid, -1)
+		value := w.value(&list[i].Value)
+		next := int32(-1)
+		if i < len(list)-1 {
+			next = int32(len(w.segs[segCustDataGuid])) + 12
+		}
+		seg := appendI32(w.segs[segCustDataGuid], guid)
+		seg = appendI32(seg, value)
+		w.segs[segCustDataGuid] = appendI32(seg, next)
+	}
+	return first
+}
+
+// typeInfo returns the record of the type at index and adds the block of
+// its members. The offset of the block is filled in once known.
+func (w *msftWriter) typeInfo(t *TypeInfo, index int) []byte {
+	kind := t.Attr.Typekind
+	href := int32(HrefOfIndex(index))
+	datatype1, datatype2 := int32(-1), int32(0)
+	cimpl := len(t.ImplTypes)
+	switch kind {
+	case ole.TKIND_ALIAS:
+		datatype1 = w.typeDesc(&t.Attr.TdescAlias)
+	case ole.TKIND_MODULE:
+		datatype1 = w.str(t.DllName)
+	case ole.TKIND_COCLASS:
+		datatype1 = w.refRecords(t.ImplTypes)
+	case ole.TKIND_INTERFACE, ole.TKIND_DISPATCH:
+		if cimpl > 1 {
+			w.fail(t.Name + " has more than one base interface")
+			return nil
+		}
+		if cimpl == 1 {
+			// A dispinterface implementing the IDispatch of the header
+			// leaves it implicit.
+			datatype1 = int32(w.href(t.ImplTypes[0].Hreftype))
+			if kind == ole.TKIND_DISPATCH && datatype1 == w.dispatchRef {
+				datatype1 = -1
+			}
+		}
+		// The slots of the vtable and the count of base interfaces.
+		ptrSize := 4
+		if w.lib.Attr.Syskind == ole.SYS_WIN64 {
+			ptrSize = 8
+		}
+		datatype2 = int32(int(t.Attr.CbSizeVft)/ptrSize<<16 | cimpl)
+	default:
+		cimpl = 0
+	}
+	w.members = append(w.members, w.memberBlock(t))
+
+	r := make([]byte, 0, typeInfoSize)
+	r = appendI32(r, kind&0xf|int32(t.Attr.CbAlignment&0x1f)<<11)
+	r = appendI32(r, -1) // memoffset
+	r = appendI32(r, 0)
+	r = appendI32(r, 0)
+	r = appendI32(r, 3)
+	r = appendI32(r, 0)
+	r = appendU32(r, uint32(len(t.Funcs))|uint32(len(t.Vars))<<16)
+	r = append(r, make([]byte, 16)...)
+	r = appendI32(r, w.optionalGuid(t.Attr.Guid, href))
+	r = appendU32(r, uint32(t.Attr.WTypeFlags))
+	r = appendI32(r, w.name(t.Name, href))
+	r = appendU32(r, uint32(t.Attr.WMajorVerNum)|uint32(t.Attr.WMinorVerNum)<<16)
+	r = appendI32(r, w.str(t.DocString))
+	r = appendU32(r, t.HelpStringContext)
+	r = appendU32(r, t.HelpContext)
+	r = appendI32(r, w.custData(t.CustData))
+	r = appendU16(r, uint16(cimpl))
+	r = appendU16(r, t.Attr.CbSizeVft)
+	r = appendU32(r, t.Attr.CbSizeInstance)
+	r = appendI32(r, datatype1)
+	r = appendI32(r, datatype2)
+	r = appendI32(r, 0)
+	return appendI32(r, -1)
+}
+
+// refRecords adds the records of the interfaces of a coclass, each linked
+// to the next, and returns the offset of the first.
+func (w *msftWriter) refRecords(impls []ImplType) int32 {
+	if len(impls) == 0 {
+		return -1
+	}
+	first := int32(len(w.segs[segRefTab]))
+	for i, impl := range impls {
+		href := w.href(impl.Hreftype)
+		custData := w.custData(impl.CustData)
+		next := int32(-1)
+		if i < len(impls)-1 {
+			next = int32(len(w.segs[segRefTab])) + 16
+		}
+		seg := appendU32(w.segs[segRefTab], href)
+		seg = appendI32(seg, impl.Flags)
+		seg = appendI32(seg, custData)
+		w.segs[segRefTab] = appendI32(seg, next)
+	}
+	return first
+}
+
+// memberBlock returns the block of the functions and variables of t, laid
+// out as readMembers reads it, or nil when t has none.
+func (w *msftWriter) memberBlock(t *TypeInfo) []byte {
+	total := len(t.Funcs) + len(t.Vars)
+	if total == 0 {
+		return nil
+	}
+	var records []byte
+	memids := make([]byte, 0, 4*total)
+	names := make([]byte, 0, 4*total)
+	offsets := make([]byte, 0, 4*total)
+	for i, f := range t.Funcs {
+		offsets = appendI32(offsets, int32(len(records)))
+		records = append(records, w.funcRecord(f, i)...)
+		memids = appendI32(memids, f.Memid)
+		names = appendI32(names, w.memberName(f.Name))
+	}
+	for i, v := range t.Vars {
+		offsets = appendI32(offsets, int32(len(records)))
+		records = append(records, w.varRecord(v, len(t.Funcs)+i)...)
+		memids = appendI32(memids, v.Memid)
+		names = appendI32(names, w.memberName(v.Name))
+	}
+	block := appendI32(make([]byte, 0, 4+len(records)+12*total), int32(len(records)))
+	block = append(block, records...)
+	block = append(block, memids...)
+	block = append(block, names...)
+	return append(block, offsets...)
+}
+
+func (w *msftWriter) memberName(name string) int32 {
+	if name == "" {
+		return -1
+	}
+	return w.name(name, -1)
+}
+
+// funcRecord returns the record of a function: see readFunc. Optional
+// fields are written up to the last one the function needs.
+func (w *msftWriter) funcRecord(f *Func, index int) []byte {
+	nargs := len(f.Params)
+	if nargs > 0x7fff {
+		w.fail(f.Name + " has too many parameters")
+		return nil
+	}
+	fkccic := uint32(f.FuncKind&0x7) | uint32(f.InvKind&0xf)<<3 | uint32(f.CallConv&0xf)<<8
+
+	hasCustData := len(f.CustData) > 0
+	hasDefaults := false
+	size := sizeFuncDesc + decodedSize(&f.Result)
+	for i := range f.Params {
+		p := &f.Params[i]
+		hasCustData = hasCustData || len(p.CustData) > 0
+		size += sizeElemDesc + decodedSize(&p.Type)
+		if p.Flags&ole.PARAMFLAG_FHASDEFAULT != 0 && p.Default != nil {
+			hasDefaults = true
+			size += sizeParamDescx
+		}
+	}
+
+	fields := 0
+	switch {
+	case hasCustData:
+		fields = 7 + nargs
+		fkccic |= funcHasCustData
+	case f.HelpStringContext != 0:
+		fields = 6
+	case f.Entry != "" || f.Ordinal != 0:
+		fields = 3
+	case f.DocString != "":
+		fields = 2
+	case f.HelpContext != 0:
+		fields = 1
+	}
+	entry := w.str(f.Entry)
+	if f.Entry == "" && f.Ordinal != 0 {
+		entry = int32(f.Ordinal)
+		fkccic |= funcEntryIsOrd
+	}
+	optional := []int32{int32(f.HelpContext), w.str(f.DocString), entry, -1, -1, int32(f.HelpStringContext), w.custData(f.CustData)}
+	for i := range f.Params {
+		optional = append(optional, w.custData(f.Params[i].CustData))
+	}
+	if hasDefaults {
+		fkccic |= funcHasDefaults
+	}
+
+	var body []byte
+	for _, v := range optional[:fields] {
+		body = appendI32(body, v)
+	}
+	if hasDefaults {
+		for i := range f.Params {
+			p := &f.Params[i]
+			if p.Flags&ole.PARAMFLAG_FHASDEFAULT != 0 && p.Default != nil {
+				body = appendI32(body, w.value(p.Default))
+			} else {
+				body = appendI32(body, msftNoValue)
+			}
+		}
+	}
+	for i := range f.Params {
+		p := &f.Params[i]
+		body = appendI32(body, w.typeDesc(&p.Type))
+		body = appendI32(body, w.memberName(p.Name))
+		body = appendU32(body, uint32(p.Flags))
+	}
+
+	length := 24 + len(body)
+	if length > 0xffff || size > 0xffff {
+		w.fail(f.Name + " too large")
+		return nil
+	}
+	r := make([]byte, 0, length)
+	r = appendU16(r, uint16(length))
+	r = appendU16(r, uint16(index))
+	r = appendI32(r, w.typeDesc(&f.Result))
+	r = appendU32(r, uint32(f.Flags))
+	r = appendU16(r, uint16(f.OVft))
+	r = appendU16(r, uint16(size))
+	r = appendU32(r, fkccic)
+	r = appendU16(r, uint16(nargs))
+	r = appendU16(r, uint16(f.CParamsOpt))
+	return append(r, body...)
+}
+
+// varRecord returns the record of a variable: see readVar.
+func (w *msftWriter) varRecord(v *Var, index int) []byte {
+	value := int32(v.OInst)
+	if v.VarKind == ole.VAR_CONST {
+		if v.Value == nil {
+			w.fail("constant " + v.Name + " without value")
+			return nil
+		}
+		value = w.value(v.Value)
+	}
+
+	fields := 0
+	switch {
+	case v.HelpStringContext != 0:
+		fields = 5
+	case len(v.CustData) > 0:
+		fields = 4
+	case v.DocString != "":
+		fields = 2
+	case v.HelpContext != 0:
+		fields = 1
+	}
+	optional := []int32{int32(v.HelpContext), w.str(v.DocString), -1, w.custData(v.CustData), int32(v.HelpStringContext)}
+
+	r := make([]byte, 0, 20+4*fields)
+	r = appendU16(r, uint16(20+4*fields))
+	r = appendU16(r, uint16(index))
+	r = appendI32(r, w.typeDesc(&v.Type))
+	r = appendU32(r, uint32(v.Flags))
+	r = appendU16(r, uint16(v.VarKind))
+	r = appendU16(r, uint16(sizeVarDesc+decodedSize(&v.Type)))
+	r = appendI32(r, value)
+	for _, field := range optional[:fields] {
+		r = appendI32(r, field)
+	}
+	return r
+}
@@ -0,0 +1,245 @@
+package typelib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// roundTrip writes lib and parses the result.
+func roundTrip(t *testing.T, lib *Library) *Library {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, lib); err != nil {
+		t.Fatalf("Write = %v", err)
+	}
+	written, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse = %v", err)
+	}
+	return written
+}
+
+func TestWrite_msft(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	written := roundTrip(t, lib)
+	if !reflect.DeepEqual(written, lib) {
+		for i := range lib.Types {
+			if !reflect.DeepEqual(written.Types[i], lib.Types[i]) {
+				t.Errorf("%s = %+v, want %+v", lib.Types[i].Name, written.Types[i], lib.Types[i])
+			}
+		}
+		t.Fatalf("written library differs")
+	}
+
+	// Writing again gives the same bytes.
+	var first, second bytes.Buffer
+	if err := Write(&first, lib); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&second, written); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("writing the written library gives other bytes")
+	}
+}
+
+func TestWrite_sltg(t *testing.T) {
+	// SLTG libraries are converted: their IDL does not change.
+	lib := parseTestFile(t, "test_sltg.tlb")
+	var want, got bytes.Buffer
+	if err := WriteIDL(&want, lib); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIDL(&got, roundTrip(t, lib)); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("IDL of the written library =\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+// newTestModel returns a library built in Go, with the fields of its types
+// that Parse derives from the library, such as their counts, filled in.
+func newTestModel() *Library {
+	stdole := &Import{Guid: *libidStdOle, MajorVerNum: 2, Path: "stdole2.tlb"}
+	dispatch := &Ref{Import: stdole, Guid: *ole.IID_IDispatch, Index: -1, Typekind: ole.TKIND_DISPATCH}
+	unknown := &Ref{Import: stdole, Guid: *ole.IID_IUnknown, Index: -1, Typekind: ole.TKIND_INTERFACE}
+
+	color := &TypeInfo{
+		Name:      "Color",
+		DocString: "Colors of a pen",
+		Attr:      ole.TYPEATTR{Typekind: ole.TKIND_ENUM, CbSizeInstance: 4, CbAlignment: 4},
+		Vars: []*Var{
+			{Name: "Red", Memid: 0x40000000, VarKind: ole.VAR_CONST, Type: ole.TYPEDESC{VT: uint16(ole.VT_I4)},
+				Value: &Value{VT: ole.VT_I4, Val: int32(0)}},
+			{Name: "Invisible", Memid: 0x40000001, VarKind: ole.VAR_CONST, Type: ole.TYPEDESC{VT: uint16(ole.VT_I4)},
+				Value: &Value{VT: ole.VT_I4, Val: int32(-2)}, DocString: "Draws nothing", HelpContext: 3},
+		},
+	}
+	point := &TypeInfo{
+		Name: "Point",
+		Attr: ole.TYPEATTR{Typekind: ole.TKIND_RECORD, CbSizeInstance: 16, CbAlignment: 8},
+		Vars: []*Var{
+			{Name: "x", Memid: 0x40000000, VarKind: ole.VAR_PERINSTANCE, Type: ole.TYPEDESC{VT: uint16(ole.VT_R8)}},
+			{Name: "y", Memid: 0x40000001, VarKind: ole.VAR_PERINSTANCE, Type: ole.TYPEDESC{VT: uint16(ole.VT_R8)}, OInst: 8,
+				CustData: []CustData{{Guid: *custID, Value: Value{VT: ole.VT_R8, Val: 0.5}}}},
+		},
+	}
+	pen := &TypeInfo{
+		Name:              "IPen",
+		DocString:         "A pen",
+		HelpContext:       7,
+		HelpStringContext: 8,
+		Attr: ole.TYPEATTR{
+			Guid:       *ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E20}"),
+			Typekind:   ole.TKIND_INTERFACE,
+			WTypeFlags: ole.TYPEFLAG_FDUAL | ole.TYPEFLAG_FOLEAUTOMATION | ole.TYPEFLAG_FDISPATCHABLE,
+			CbSizeVft:  40, CbSizeInstance: 4, CbAlignment: 4, WMajorVerNum: 1, WMinorVerNum: 3,
+		},
+		ImplTypes: []ImplType{{Hreftype: 1}},
+		Funcs: []*Func{
+			{Name: "Color", Memid: 1, FuncKind: ole.FUNC_PUREVIRTUAL, InvKind: ole.INVOKE_PROPERTYGET,
+				CallConv: ole.CC_STDCALL, OVft: 28, Result: ole.TYPEDESC{VT: uint16(ole.VT_HRESULT)},
+				Params: []Param{{Name: "color", Flags: ole.PARAMFLAG_FOUT | ole.PARAMFLAG_FRETVAL, Type: ole.TYPEDESC{
+					VT: uint16(ole.VT_PTR), Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: 0}}}}},
+			{Name: "Color", Memid: 1, FuncKind: ole.FUNC_PUREVIRTUAL, InvKind: ole.INVOKE_PROPERTYPUT,
+				CallConv: ole.CC_STDCALL, OVft: 32, Result: ole.TYPEDESC{VT: uint16(ole.VT_HRESULT)},
+				Params: []Param{{Name: "color", Flags: ole.PARAMFLAG_FIN, Type: ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: 0}}}},
+			{Name: "Draw", Memid: 2, FuncKind: ole.FUNC_PUREVIRTUAL, InvKind: ole.INVOKE_FUNC, CallConv: ole.CC_STDCALL,
+				OVft: 36, Result: ole.TYPEDESC{VT: uint16(ole.VT_HRESULT)}, DocString: "Draws a line",
+				HelpStringContext: 12, CustData: []CustData{{Guid: *custTool, Value: Value{VT: ole.VT_BSTR, Val: "olegen"}}},
+				Params: []Param{
+					{Name: "points", Flags: ole.PARAMFLAG_FIN, Type: ole.TYPEDESC{VT: uint16(ole.VT_SAFEARRAY),
+						Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: HrefOfIndex(1)}}},
+					{Name: "width", Flags: ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOPT | ole.PARAMFLAG_FHASDEFAULT,
+						Type: ole.TYPEDESC{VT: uint16(ole.VT_R4)}, Default: &Value{VT: ole.VT_R4, Val: float32(1.5)},
+						CustData: []CustData{{Guid: *custArg, Value: Value{VT: ole.VT_BOOL, Val: true}}}},
+					{Name: "owner", Flags: ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOPT, Type: ole.TYPEDESC{VT: uint16(ole.VT_PTR),
+						Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: 13}}},
+				}},
+		},
+	}
+	events := &TypeInfo{
+		Name: "_PenEvents",
+		Attr: ole.TYPEATTR{
+			Guid:     *ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E21}"),
+			Typekind: ole.TKIND_DISPATCH, WTypeFlags: ole.TYPEFLAG_FHIDDEN, CbSizeVft: 28, CbSizeInstance: 4, CbAlignment: 4,
+		},
+		ImplTypes: []ImplType{{Hreftype: 1}},
+		Funcs: []*Func{{Name: "Moved", Memid: 1, FuncKind: ole.FUNC_DISPATCH, InvKind: ole.INVOKE_FUNC, CallConv: ole.CC_STDCALL,
+			Result: ole.TYPEDESC{VT: uint16(ole.VT_VOID)},
+			Params: []Param{{Name: "to", Flags: ole.PARAMFLAG_FIN, Type: ole.TYPEDESC{VT: uint16(ole.VT_VARIANT)}}}}},
+		Vars: []*Var{{Name: "Ink", Memid: 2, VarKind: ole.VAR_DISPATCH, Type: ole.TYPEDESC{VT: uint16(ole.VT_CARRAY),
+			Lpadesc: &ole.ARRAYDESC{TdescElem: ole.TYPEDESC{VT: uint16(ole.VT_UI1)}, Bounds: []ole.SafeArrayBound{{Elements: 4}}}},
+			HelpStringContext: 5}},
+	}
+	penClass := &TypeInfo{
+		Name:      "Pen",
+		DocString: "Pen class",
+		Attr: ole.TYPEATTR{
+			Guid:     *ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E22}"),
+			Typekind: ole.TKIND_COCLASS, WTypeFlags: ole.TYPEFLAG_FCANCREATE, CbSizeInstance: 4, CbAlignment: 4,
+		},
+		ImplTypes: []ImplType{
+			{Hreftype: HrefOfIndex(2), Flags: ole.IMPLTYPEFLAG_FDEFAULT},
+			{Hreftype: HrefOfIndex(3), Flags: ole.IMPLTYPEFLAG_FDEFAULT | ole.IMPLTYPEFLAG_FSOURCE,
+				CustData: []CustData{{Guid: *custID, Value: Value{VT: ole.VT_I8, Val: int64(1) << 40}}}},
+		},
+	}
+	lib := &Library{
+		Name:          "Drawing",
+		DocString:     "Drawing library, façade",
+		HelpFile:      "drawing.chm",
+		HelpContext:   1,
+		HelpStringDll: "drawing.dll",
+		Attr: ole.TLIBATTR{Guid: *ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E2F}"), Lcid: 0x409,
+			Syskind: ole.SYS_WIN64, WMajorVerNum: 2, WMinorVerNum: 1, WLibFlags: ole.LIBFLAG_FHASDISKIMAGE},
+		Types:    []*TypeInfo{color, point, pen, events, penClass},
+		Imports:  []*Import{stdole},
+		CustData: []CustData{{Guid: *custTool, Value: Value{VT: ole.VT_UI2, Val: uint16(40000)}}},
+		Refs: map[uint32]*Ref{
+			0:              {Type: color, Index: 0, Typekind: ole.TKIND_ENUM},
+			HrefOfIndex(1): {Type: point, Index: 1, Typekind: ole.TKIND_RECORD},
+			HrefOfIndex(2): {Type: pen, Index: 2, Typekind: ole.TKIND_INTERFACE},
+			HrefOfIndex(3): {Type: events, Index: 3, Typekind: ole.TKIND_DISPATCH},
+			// Imported types have the hreftypes of their entries in the
+			// table of imports, as Write numbers them.
+			1:  dispatch,
+			13: unknown,
+		},
+	}
+	for _, t := range lib.Types {
+		t.Attr.Lcid = lib.Attr.Lcid
+		t.Attr.MemidConstructor = ole.MEMBERID_NIL
+		t.Attr.MemidDestructor = ole.MEMBERID_NIL
+		t.Attr.CFuncs = uint16(len(t.Funcs))
+		t.Attr.CVars = uint16(len(t.Vars))
+		t.Attr.CImplTypes = uint16(len(t.ImplTypes))
+	}
+	return lib
+}
+
+func TestWrite_model(t *testing.T) {
+	want := newTestModel()
+	got := roundTrip(t, want)
+	for i := range want.Types {
+		if i < len(got.Types) && !reflect.DeepEqual(got.Types[i], want.Types[i]) {
+			t.Errorf("%s = %+v, want %+v", want.Types[i].Name, got.Types[i], want.Types[i])
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("library = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typelib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := parseTestFile(t, "test.tlb")
+	path := filepath.Join(dir, "test.tlb")
+	if err := WriteFile(path, lib); err != nil {
+		t.Fatalf("WriteFile = %v", err)
+	}
+	written, err := ReadFile(path)
+	if err != nil || written.Name != lib.Name || len(written.Types) != len(lib.Types) {
+		t.Errorf("ReadFile = %+v, %v", written, err)
+	}
+}
+
+func TestWrite_invalid(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		change func(lib *Library)
+	}{
+		{"unencodable name", func(lib *Library) { lib.Types[0].Name = "Couleur→" }},
+		{"missing reference", func(lib *Library) { delete(lib.Refs, HrefOfIndex(1)) }},
+		{"foreign type", func(lib *Library) { lib.Refs[0].Type = &TypeInfo{Name: "Other"} }},
+		{"constant without value", func(lib *Library) { lib.Types[0].Vars[0].Value = nil }},
+		{"value of another type", func(lib *Library) { lib.Types[0].Vars[0].Value.Val = "red" }},
+		{"pointer without target", func(lib *Library) { lib.Types[2].Funcs[0].Params[0].Type.Lptdesc = nil }},
+	} {
+		lib := newTestModel()
+		tt.change(lib)
+		if err := Write(ioutil.Discard, lib); err == nil {
+			t.Errorf("%s: Write succeeded", tt.name)
+		}
+	}
+}
+
+func TestHashName(t *testing.T) {
+	// Names are looked up regardless of case.
+	if hashName([]byte("Draw")) != hashName([]byte("DRAW")) || hashName([]byte("Draw")) == hashName([]byte("Drew")) {
+		t.Error("hashName does not fold case")
+	}
+}
@@ -0,0 +1,127 @@
+package typelib
+
+import (
+	"bytes"
+	"debug/pe"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// Index of the resource table in the data directories of a PE image.
+const peResourceDirectory = 2
+
+// Resource returns the TYPELIB resource with the ID index of a PE image, as
+// LoadTypeLib reads "file.dll\2". The first language of the resource is
+// returned.
+func Resource(image []byte, index int) ([]byte, error) {
+	f, err := pe.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, ole.NewErrorWithDescription(ole.TYPE_E_CANTLOADLIBRARY, err.Error())
+	}
+	defer f.Close()
+
+	var dir pe.DataDirectory
+	switch header := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if len(header.DataDirectory) > peResourceDirectory {
+			dir = header.DataDirectory[peResourceDirectory]
+		}
+	case *pe.OptionalHeader64:
+		if len(header.DataDirectory) > peResourceDirectory {
+			dir = header.DataDirectory[peResourceDirectory]
+		}
+	}
+	if dir.Size == 0 {
+		return nil, resourceError("image has no resources")
+	}
+	rsrc, base := section(f, dir.VirtualAddress)
+	if rsrc == nil {
+		return nil, resourceError("resource table outside of the sections")
+	}
+
+	r := &buffer{data: rsrc}
+	root := int(dir.VirtualAddress - base)
+	types := r.findEntry(root, root, func(name string, id int) bool {
+		return strings.EqualFold(name, "TYPELIB")
+	})
+	if types < 0 {
+		return nil, resourceError("no TYPELIB resource")
+	}
+	ids := r.findEntry(root, types, func(name string, id int) bool {
+		return name == "" && id == index
+	})
+	if ids < 0 {
+		return nil, resourceError("no TYPELIB resource " + strconv.Itoa(index))
+	}
+	// The first language, whose entry points to the description of the
+	// data: its address and size.
+	data := r.findEntry(root, ids, func(string, int) bool { return true })
+	if r.err != nil || data < 0 {
+		return nil, resourceError("invalid resource table")
+	}
+	rva, size := r.u32(data), r.u32(data+4)
+	if r.err != nil {
+		return nil, resourceError("invalid resource table")
+	}
+	content, start := section(f, rva)
+	if content == nil || uint64(rva-start)+uint64(size) > uint64(len(content)) {
+		return nil, resourceError("resource data outside of the sections")
+	}
+	return content[rva-start : rva-start+size], nil
+}
+
+// section returns the content and address of the section holding rva.
+func section(f *pe.File, rva uint32) ([]byte, uint32) {
+	for _, s := range f.Sections {
+		if rva >= s.VirtualAddress && rva-s.VirtualAddress < s.Size {
+			data, err := s.Data()
+			if err != nil {
+				return nil, 0
+			}
+			return data, s.VirtualAddress
+		}
+	}
+	return nil, 0
+}
+
+// findEntry looks up an entry of the resource directory at dir, and returns
+// the offset of its subdirectory or data description, or -1. A directory
+// holds a 16-byte header with the counts of named and numbered entries,
+// then the entries: a name or ID and an offset, both relative to root, whose
+// high bits tell a named entry and a subdirectory.
+func (b *buffer) findEntry(root, dir int, match func(name string, id int) bool) int {
+	count := int(b.u16(dir+12)) + int(b.u16(dir+14))
+	for i := 0; i < count && b.err == nil; i++ {
+		at := dir + 16 + 8*i
+		key, offset := b.u32(at), b.u32(at+4)
+		var name string
+		var id int
+		if key&0x80000000 != 0 {
+			name = b.utf16String(root + int(key&0x7fffffff))
+		} else {
+			id = int(key & 0xffff)
+		}
+		if match(name, id) {
+			return root + int(offset&0x7fffffff)
+		}
+	}
+	return -1
+}
+
+// utf16String reads a string of the resource table: a 16-bit length in characters,
+// then the UTF-16 characters.
+func (b *buffer) utf16String(off int) string {
+	n := int(b.u16(off))
+	chars := make([]uint16, n)
+	for i := range chars {
+		chars[i] = b.u16(off + 2 + 2*i)
+	}
+	return string(utf16.Decode(chars))
+}
+
+func resourceError(what string) error {
+	return ole.NewErrorWithDescription(ole.TYPE_E_CANTLOADLIBRARY, what)
+}
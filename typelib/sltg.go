@@ -0,0 +1,650 @@
+package typelib
+
+import (
+	"strconv"
+	"strings"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// The SLTG format starts with a header and a table of block entries, each
+// giving the length of a block, the offset of its index string and the
+// entry of the next block. The magic, the index strings and padding follow,
+// then the blocks in the order of the chain: one per type, then the library
+// block, which also holds the table of types and the name table.
+//
+// The format is undocumented; this reader follows the layout found in the
+// libraries in the wild, as described by the Wine project.
+const (
+	sltgSignature = "SLTG"
+
+	sltgHeaderSize   = 0x24
+	sltgBlkEntrySize = 8
+	sltgMagicSize    = 13
+	sltgIndexSize    = 11
+	sltgPadSize      = 9
+
+	sltgLibMagic      = 0x51cc
+	sltgTypeInfoMagic = 0x0501
+	sltgImplMagic     = 0x004a
+	sltgRefMagic      = 0xdf
+
+	sltgLibFiller     = 0x40  // bytes between the library block and the types
+	sltgTypeEntrySize = 38    // size of a type entry without its strings
+	sltgNameTableSkip = 0x218 // bytes before the first name
+
+	sltgMemberHeaderSize = 9
+	sltgRefNames         = 0x4f // offset of the names of a reference table
+)
+
+// Function kinds of the magic of a function.
+const (
+	sltgFuncFlagsPresent = 0x20
+	sltgFunc             = 0x4c
+	sltgDispatchFunc     = 0xcb
+	sltgStaticFunc       = 0x8b
+)
+
+// Magics and flags of a variable.
+const (
+	sltgVar          = 0x0a
+	sltgVarWithFlags = 0x2a
+
+	sltgVarTypeInline  = 0x02
+	sltgVarValueInline = 0x08
+	sltgVarConst       = 0x10
+	sltgVarDispatch    = 0x40
+	sltgVarReadonly    = 0x80
+)
+
+// A type word holds the VARTYPE in its low 6 bits, with flags above.
+const (
+	sltgTypeMask    = 0x3f
+	sltgTypePointer = 0xe00
+	sltgTypeRetval  = 0x80
+	sltgTypeLcid    = 0x2000
+	sltgTypeOut     = 0x4000
+	sltgTypeInOut   = 0x8000
+	sltgTypeNoFlags = 0xc000
+)
+
+type sltgBlock struct {
+	at     int
+	length int
+	index  string
+}
+
+// sltgType is the entry of a type in the library block.
+type sltgType struct {
+	index       string
+	nameOffset  int
+	helpContext uint32
+	guid        ole.GUID
+}
+
+type sltgReader struct {
+	buffer
+	lib       *Library
+	nameTable int
+	imports   map[int]*Import
+	external  map[string]uint32
+}
+
+func parseSLTG(data []byte) (*Library, error) {
+	r := &sltgReader{
+		buffer:   buffer{data: data},
+		lib:      &Library{Refs: map[uint32]*Ref{}},
+		imports:  map[int]*Import{},
+		external: map[string]uint32{},
+	}
+
+	entries := int(r.u16(4)) - 1
+	first := int(r.u16(10))
+	if entries < 1 || first < 1 || first > entries {
+		return nil, invalidData("block entries")
+	}
+	magic := sltgHeaderSize + sltgBlkEntrySize*entries
+	if string(r.bytes(magic+1, 7)) != "CompObj" || string(r.bytes(magic+9, 3)) != "dir" {
+		return nil, invalidData("SLTG magic")
+	}
+	count := entries - 1
+	at := magic + sltgMagicSize + sltgIndexSize*count + sltgPadSize
+
+	var blocks []sltgBlock
+	for entry := first - 1; ; {
+		e := sltgHeaderSize + sltgBlkEntrySize*entry
+		block := sltgBlock{
+			at:     at,
+			length: int(r.u32(e)),
+			index:  r.cstring(magic + int(r.u16(e+4))),
+		}
+		blocks = append(blocks, block)
+		at += block.length
+		next := int(r.u16(e + 6))
+		if next == 0 || r.err != nil {
+			break
+		}
+		if next > entries || len(blocks) > entries {
+			return nil, invalidData("block chain")
+		}
+		entry = next - 1
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(blocks) != entries {
+		return nil, invalidData("block count")
+	}
+
+	types := r.readLibBlock(blocks[count].at, count)
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.lib.Types = make([]*TypeInfo, count)
+	for i := range r.lib.Types {
+		r.lib.Types[i] = &TypeInfo{}
+	}
+	for i, t := range r.lib.Types {
+		if blocks[i].index != types[i].index {
+			return nil, invalidData("index of type " + strconv.Itoa(i))
+		}
+		t.Name = r.cstring(r.nameTable + types[i].nameOffset)
+		t.HelpContext = types[i].helpContext
+		t.Attr.Guid = types[i].guid
+		r.readTypeInfo(t, blocks[i].at)
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	r.lib.resolveKinds()
+	return r.lib, nil
+}
+
+// str reads a string made of a 16-bit length, 0xffff for none, and bytes.
+// It returns the string and the bytes read.
+func (r *sltgReader) str(at int) (string, int) {
+	length := r.u16(at)
+	if length == 0xffff {
+		return "", 2
+	}
+	return decodeANSI(r.bytes(at+2, int(length))), 2 + int(length)
+}
+
+// name reads a NUL-terminated name of the name table.
+func (r *sltgReader) name(offset int) string {
+	return r.cstring(r.nameTable + offset)
+}
+
+// readLibBlock reads the attributes of the library, the entries of its
+// count types and locates the name table.
+func (r *sltgReader) readLibBlock(at int, count int) []sltgType {
+	lib := r.lib
+	if r.u16(at) != sltgLibMagic {
+		r.fail("library block magic")
+		return nil
+	}
+	nameOffset := int(r.u16(at + 4))
+	p := at + 6
+	if w := r.u16(p); w != 0xffff {
+		p += int(w)
+	}
+	p += 2
+	var n int
+	lib.DocString, n = r.str(p)
+	p += n
+	lib.HelpFile, n = r.str(p)
+	p += n
+	lib.HelpContext = r.u32(p)
+	lib.Attr = ole.TLIBATTR{
+		Syskind:      int32(r.u16(p + 4)),
+		Lcid:         uint32(r.u16(p + 6)),
+		WLibFlags:    r.u16(p + 12),
+		WMajorVerNum: r.u16(p + 14),
+		WMinorVerNum: r.u16(p + 16),
+		Guid:         r.guid(p + 18),
+	}
+	p += 34 + sltgLibFiller
+
+	types := make([]sltgType, count)
+	for i := range types {
+		var length int
+		if w := r.u16(p + 2); w != 0xffff {
+			types[i].index = decodeANSI(r.bytes(p+4, int(w)))
+			length += int(w)
+		}
+		if w := r.u16(p + 4 + length); w != 0xffff {
+			length += int(w)
+		}
+		types[i].nameOffset = int(r.u16(p + 8 + length))
+		if extra := r.u16(p + 10 + length); extra != 0 {
+			length += int(extra)
+		}
+		types[i].helpContext = r.u32(p + 14 + length)
+		types[i].guid = r.guid(p + 20 + length)
+		p += sltgTypeEntrySize + length
+		if r.err != nil {
+			return nil
+		}
+	}
+
+	// A word, then the offset of the name table from the library block,
+	// which may start with 0x20 more bytes.
+	r.nameTable = at + int(r.u32(p+2))
+	if r.u16(r.nameTable) == 0x0200 {
+		r.nameTable += 0x20
+	}
+	r.nameTable += sltgNameTableSkip
+	lib.Name = r.name(nameOffset)
+	return types
+}
+
+// readTypeInfo reads the block of a type: a header, the reference table and
+// the members, followed by a tail with the counts and sizes of the type.
+func (r *sltgReader) readTypeInfo(t *TypeInfo, at int) {
+	if r.u16(at) != sltgTypeInfoMagic {
+		r.fail("type magic")
+		return
+	}
+	var refs []uint32
+	if href := r.u32(at + 2); href != 0xffffffff {
+		refs = r.readRefs(at + int(href))
+	}
+	members := at + int(r.u32(at+0x0a))
+	blk := members + sltgMemberHeaderSize
+	tail := blk + int(r.u32(members+5))
+
+	t.Attr.Lcid = r.lib.Attr.Lcid
+	t.Attr.MemidConstructor = ole.MEMBERID_NIL
+	t.Attr.MemidDestructor = ole.MEMBERID_NIL
+	t.Attr.Typekind = int32(r.u8(at + 0x1d))
+	t.Attr.WMajorVerNum = r.u16(at + 0x12)
+	t.Attr.WMinorVerNum = r.u16(at + 0x14)
+	t.Attr.WTypeFlags = uint16(r.u8(at+0x1a))>>3 | uint16(r.u8(at+0x1b))<<5
+	t.Attr.CbSizeInstance = uint32(r.u16(tail + 0x20))
+	t.Attr.CbAlignment = r.u16(tail + 0x22)
+	t.Attr.CbSizeVft = r.u16(tail + 0x28)
+
+	cFuncs, cVars := int(r.u16(tail)), int(r.u16(tail+2))
+	if off := r.u16(tail + 0x0c); off != 0xffff {
+		t.ImplTypes = r.readImpls(blk, blk+int(off), refs)
+	}
+	if off := r.u16(tail + 0x08); off != 0xffff && cFuncs > 0 {
+		t.Funcs = r.readFuncs(blk, blk+int(off), cFuncs, refs)
+	}
+	if off := r.u16(tail + 0x0a); off != 0xffff && cVars > 0 {
+		t.Vars = r.readVars(blk, blk+int(off), cVars, refs)
+	}
+	if t.Attr.Typekind == ole.TKIND_ALIAS {
+		alias := r.u16(tail + 0x14)
+		if r.u16(tail+0x1c) != 0 {
+			t.Attr.TdescAlias.VT = alias
+		} else {
+			t.Attr.TdescAlias, _ = r.typeDesc(blk, blk+int(alias), refs)
+		}
+	}
+	t.Attr.CFuncs = uint16(len(t.Funcs))
+	t.Attr.CVars = uint16(len(t.Vars))
+	t.Attr.CImplTypes = uint16(len(t.ImplTypes))
+}
+
+// readRefs reads the reference table of a type and returns the hreftypes of
+// its references. Each reference is a name like *\R<lib>*#<index>, where lib
+// is ffff for the types of the library or else the offset in the name table
+// of the description of an imported library.
+func (r *sltgReader) readRefs(at int) []uint32 {
+	if r.u8(at) != sltgRefMagic {
+		r.fail("reference table magic")
+		return nil
+	}
+	number := int(r.u32(at + 0x44))
+	if number < 0 || number > len(r.data) {
+		r.fail("reference table size")
+		return nil
+	}
+	p := at + sltgRefNames + number
+	refs := make([]uint32, number>>3)
+	for i := range refs {
+		name, n := r.str(p)
+		p += n
+		var lib, index uint64
+		var err error
+		fields := strings.SplitN(strings.TrimPrefix(name, `*\R`), "*#", 2)
+		if len(fields) == 2 {
+			if lib, err = strconv.ParseUint(fields[0], 16, 32); err == nil {
+				index, err = strconv.ParseUint(fields[1], 16, 32)
+			}
+		}
+		if len(fields) != 2 || err != nil {
+			r.fail("reference " + name)
+			return nil
+		}
+		refs[i] = r.ref(int(lib), int(index))
+		if r.err != nil {
+			return nil
+		}
+	}
+	return refs
+}
+
+// ref returns the hreftype of the type at index in the library lib.
+func (r *sltgReader) ref(lib int, index int) uint32 {
+	if lib == 0xffff {
+		if index >= len(r.lib.Types) {
+			r.fail("reference to a missing type")
+			return 0
+		}
+		href := HrefOfIndex(index)
+		r.lib.Refs[href] = &Ref{Type: r.lib.Types[index], Index: index}
+		return href
+	}
+	key := strconv.Itoa(lib) + "#" + strconv.Itoa(index)
+	if href, ok := r.external[key]; ok {
+		return href
+	}
+	imp := r.readImport(lib)
+	if imp == nil {
+		return 0
+	}
+	href := uint32(12*len(r.external)) | 1
+	r.external[key] = href
+	r.lib.Refs[href] = &Ref{Import: imp, Index: index, Typekind: -1}
+	return href
+}
+
+// readImport reads the description of an imported library, a name like
+// *\G{<libid>}#<major>.<minor>#<lcid>#<path>#.
+func (r *sltgReader) readImport(offset int) *Import {
+	if imp, ok := r.imports[offset]; ok {
+		return imp
+	}
+	desc := r.name(offset)
+	fields := strings.SplitN(strings.TrimPrefix(desc, `*\G`), "#", 5)
+	if len(fields) != 5 {
+		r.fail("import " + desc)
+		return nil
+	}
+	guid := ole.NewGUID(fields[0])
+	version := strings.SplitN(fields[1], ".", 2)
+	if guid == nil || len(version) != 2 {
+		r.fail("import " + desc)
+		return nil
+	}
+	major, err1 := strconv.ParseUint(version[0], 10, 16)
+	minor, err2 := strconv.ParseUint(version[1], 10, 16)
+	lcid, err3 := strconv.ParseUint(fields[2], 16, 32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		r.fail("import " + desc)
+		return nil
+	}
+	imp := &Import{
+		Guid:        *guid,
+		Lcid:        uint32(lcid),
+		MajorVerNum: uint16(major),
+		MinorVerNum: uint16(minor),
+		Path:        fields[3],
+	}
+	r.imports[offset] = imp
+	r.lib.Imports = append(r.lib.Imports, imp)
+	return imp
+}
+
+// localRef returns the hreftype of reference i of a type.
+func (r *sltgReader) localRef(refs []uint32, i int) uint32 {
+	if i >= len(refs) {
+		r.fail("reference " + strconv.Itoa(i) + " out of the table")
+		return 0
+	}
+	return refs[i]
+}
+
+// paramFlags returns the PARAMFLAG_* flags of the first word of a type.
+func paramFlags(w uint16) uint16 {
+	var flags uint16
+	switch {
+	case w&sltgTypeNoFlags == sltgTypeNoFlags:
+		flags = ole.PARAMFLAG_NONE
+	case w&sltgTypeInOut != 0:
+		flags = ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOUT
+	case w&sltgTypeOut != 0:
+		flags = ole.PARAMFLAG_FOUT
+	default:
+		flags = ole.PARAMFLAG_FIN
+	}
+	if w&sltgTypeLcid != 0 {
+		flags |= ole.PARAMFLAG_FLCID
+	}
+	if w&sltgTypeRetval != 0 {
+		flags |= ole.PARAMFLAG_FRETVAL
+	}
+	return flags
+}
+
+// typeDesc decodes the type words at, whose offsets are relative to blk. It
+// returns the type and the offset of the following word.
+func (r *sltgReader) typeDesc(blk int, at int, refs []uint32) (ole.TYPEDESC, int) {
+	var desc ole.TYPEDESC
+	cur := &desc
+	pointTo := func() {
+		cur.VT = uint16(ole.VT_PTR)
+		cur.Lptdesc = &ole.TYPEDESC{}
+		cur = cur.Lptdesc
+	}
+	for n := 0; n < 64 && r.err == nil; n++ {
+		w := r.u16(at)
+		if w&sltgTypePointer == sltgTypePointer {
+			pointTo()
+		}
+		switch vt := ole.VT(w & sltgTypeMask); vt {
+		case ole.VT_PTR:
+			pointTo()
+		case ole.VT_USERDEFINED:
+			cur.VT = uint16(vt)
+			cur.Hreftype = r.localRef(refs, int(r.u16(at+2))/4)
+			return desc, at + 4
+		case ole.VT_CARRAY:
+			// The next word is the offset of a SAFEARRAY, whose bounds
+			// follow its 16-byte header.
+			array := blk + int(r.u16(at+2))
+			bounds := make([]ole.SafeArrayBound, r.u16(array))
+			for i := range bounds {
+				bounds[i] = ole.SafeArrayBound{
+					Elements:   r.u32(array + 16 + 8*i),
+					LowerBound: r.i32(array + 20 + 8*i),
+				}
+			}
+			cur.VT = uint16(vt)
+			cur.Lpadesc = &ole.ARRAYDESC{Bounds: bounds}
+			cur = &cur.Lpadesc.TdescElem
+			at += 2
+		case ole.VT_SAFEARRAY:
+			cur.VT = uint16(vt)
+			cur.Lptdesc = &ole.TYPEDESC{}
+			cur = cur.Lptdesc
+			at += 2
+		default:
+			cur.VT = uint16(vt)
+			return desc, at + 2
+		}
+		at += 2
+	}
+	r.fail("type description too deep")
+	return desc, at
+}
+
+// readImpls reads the implemented interfaces, chained by offsets relative to
+// blk.
+func (r *sltgReader) readImpls(blk int, at int, refs []uint32) []ImplType {
+	var impls []ImplType
+	for n := 0; r.err == nil; n++ {
+		if r.u16(at) != sltgImplMagic || n > 0xffff {
+			r.fail("implemented interface")
+			return nil
+		}
+		impls = append(impls, ImplType{
+			Hreftype: r.localRef(refs, int(r.u16(at+0x0a))),
+			Flags:    int32(r.u8(at + 6)),
+		})
+		next := r.u16(at + 2)
+		if next == 0xffff {
+			break
+		}
+		at = blk + int(next)
+	}
+	return impls
+}
+
+// readFuncs reads count functions, chained by offsets relative to blk.
+func (r *sltgReader) readFuncs(blk int, at int, count int, refs []uint32) []*Func {
+	funcs := make([]*Func, 0, count)
+	for len(funcs) < count && r.err == nil {
+		magic := r.u8(at)
+		f := &Func{
+			Name:        r.name(int(r.u16(at + 4))),
+			Memid:       r.i32(at + 6),
+			HelpContext: uint32(r.u16(at + 10)),
+			InvKind:     int32(r.u8(at+1) >> 4),
+			CallConv:    int32(r.u8(at+16) & 0x7),
+			CParamsOpt:  int16(r.u8(at+17)&0x7e) >> 1,
+			OVft:        int16(r.u16(at+20)) &^ 1,
+		}
+		switch magic &^ sltgFuncFlagsPresent {
+		case sltgFunc:
+			f.FuncKind = ole.FUNC_PUREVIRTUAL
+		case sltgDispatchFunc:
+			f.FuncKind = ole.FUNC_DISPATCH
+		case sltgStaticFunc:
+			f.FuncKind = ole.FUNC_STATIC
+		default:
+			r.fail("function magic")
+			return nil
+		}
+		if magic&sltgFuncFlagsPresent != 0 {
+			f.Flags = r.u16(at + 22)
+		}
+		if r.u8(at+17)&0x80 != 0 {
+			f.Result, _ = r.typeDesc(blk, at+18, refs)
+		} else {
+			f.Result, _ = r.typeDesc(blk, blk+int(r.u16(at+18)), refs)
+		}
+
+		if n := int(r.u8(at+16) >> 3); n > 0 {
+			f.Params = make([]Param, n)
+		}
+		arg := blk + int(r.u16(at+14))
+		for i := range f.Params {
+			p := &f.Params[i]
+			// A parameter starts with the offset of its name: when it
+			// points to the first letter, the offset of the type follows;
+			// when it points to the second one, the type itself follows.
+			// 0xffff and 0xfffe mark unnamed parameters of either kind.
+			name := r.u16(arg)
+			offsetFollows := name == 0xfffe
+			if name < 0xfffe {
+				prev := r.u8(r.nameTable + int(name) - 1)
+				if prev != 0 && !isAlnum(prev) {
+					offsetFollows = true
+					p.Name = r.name(int(name))
+				} else {
+					p.Name = r.name(int(name) - 1)
+				}
+			}
+			arg += 2
+			typeAt := arg
+			if offsetFollows {
+				typeAt = blk + int(r.u16(arg))
+				arg += 2
+			}
+			p.Flags = paramFlags(r.u16(typeAt))
+			var next int
+			p.Type, next = r.typeDesc(blk, typeAt, refs)
+			if !offsetFollows {
+				arg = next
+			}
+			if len(f.Params)-i <= int(f.CParamsOpt) {
+				p.Flags |= ole.PARAMFLAG_FOPT
+			}
+		}
+		funcs = append(funcs, f)
+
+		next := r.u16(at + 2)
+		if next == 0xffff {
+			break
+		}
+		at = blk + int(next)
+	}
+	return funcs
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// readVars reads count variables, chained by offsets relative to blk.
+func (r *sltgReader) readVars(blk int, at int, count int, refs []uint32) []*Var {
+	vars := make([]*Var, 0, count)
+	for len(vars) < count && r.err == nil {
+		magic, flags := r.u8(at), r.u8(at+1)
+		if magic != sltgVar && magic != sltgVarWithFlags {
+			r.fail("variable magic")
+			return nil
+		}
+		v := &Var{
+			Memid:       r.i32(at + 10),
+			HelpContext: uint32(r.u16(at + 14)),
+		}
+		if name := r.u16(at + 4); name == 0xfffe && len(vars) > 0 {
+			v.Name = vars[len(vars)-1].Name
+		} else {
+			v.Name = r.name(int(name))
+		}
+		typeAt := at + 8
+		if flags&sltgVarTypeInline == 0 {
+			typeAt = blk + int(r.u16(at+8))
+		}
+		v.Type, _ = r.typeDesc(blk, typeAt, refs)
+
+		offset := r.u16(at + 6)
+		switch {
+		case flags&sltgVarDispatch != 0:
+			v.VarKind = ole.VAR_DISPATCH
+		case flags&sltgVarConst != 0:
+			v.VarKind = ole.VAR_CONST
+			value := r.constant(blk, v.Type.VT, offset, flags&sltgVarValueInline != 0)
+			v.Value = &value
+		default:
+			v.VarKind = ole.VAR_PERINSTANCE
+			v.OInst = uint32(offset)
+		}
+		if magic == sltgVarWithFlags {
+			v.Flags = r.u16(at + 18)
+		}
+		if flags&sltgVarReadonly != 0 {
+			v.Flags |= ole.VARFLAG_FREADONLY
+		}
+		vars = append(vars, v)
+
+		next := r.u16(at + 2)
+		if next == 0xffff {
+			break
+		}
+		at = blk + int(next)
+	}
+	return vars
+}
+
+// constant reads the value of a constant of type vt: offset itself when
+// inline, or else the offset relative to blk of a string or 32-bit integer.
+func (r *sltgReader) constant(blk int, vt uint16, offset uint16, inline bool) Value {
+	switch ole.VT(vt) {
+	case ole.VT_LPSTR, ole.VT_LPWSTR, ole.VT_BSTR:
+		s, _ := r.str(blk + int(offset))
+		return Value{VT: ole.VT_BSTR, Val: s}
+	}
+	if inline {
+		var raw [4]byte
+		raw[0], raw[1] = byte(offset), byte(offset>>8)
+		b := buffer{data: raw[:]}
+		return b.integer(ole.VT(vt), 0)
+	}
+	return r.integer(ole.VT(vt), blk+int(offset))
+}
@@ -0,0 +1,77 @@
+package typelib
+
+import (
+	"reflect"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestParseSLTG(t *testing.T) {
+	lib := parseTestFile(t, "test_sltg.tlb")
+	if lib.Name != "GoOleTestSLTG" || lib.DocString != "SLTG test library" || lib.HelpContext != 42 ||
+		lib.Attr.Lcid != 0x409 || lib.Attr.WMajorVerNum != 1 {
+		t.Errorf("library = %+v", lib)
+	}
+	if len(lib.Types) != 3 {
+		t.Fatalf("%d types", len(lib.Types))
+	}
+
+	color := mustType(t, lib, "Color", ole.TKIND_ENUM)
+	if color.HelpContext != 1 || len(color.Vars) != 2 {
+		t.Fatalf("Color = %+v", color)
+	}
+	// Red is stored in the variable, Blue apart.
+	for i, want := range []int32{0, 0x10000} {
+		if v := color.Vars[i].Value; v == nil || v.VT != ole.VT_I4 || v.Val != want {
+			t.Errorf("%s = %+v, want %d", color.Vars[i].Name, v, want)
+		}
+	}
+
+	size := mustType(t, lib, "Size", ole.TKIND_RECORD)
+	if size.Attr.CbSizeInstance != 8 || len(size.Vars) != 2 || size.Vars[1].Name != "h" ||
+		size.Vars[1].OInst != 4 || size.Vars[1].Flags != ole.VARFLAG_FREADONLY {
+		t.Errorf("Size = %+v", size)
+	}
+
+	shape := mustType(t, lib, "IShape", ole.TKIND_INTERFACE)
+	if shape.Attr.WTypeFlags != ole.TYPEFLAG_FDUAL|ole.TYPEFLAG_FOLEAUTOMATION || shape.Attr.CbSizeVft != 36 {
+		t.Errorf("Attr = %+v", shape.Attr)
+	}
+	if len(shape.ImplTypes) != 1 {
+		t.Fatalf("ImplTypes = %+v", shape.ImplTypes)
+	}
+	base := lib.Ref(shape.ImplTypes[0].Hreftype)
+	if base == nil || base.Import == nil || !ole.IsEqualGUID(&base.Import.Guid, libidStdOle) ||
+		base.Import.Path != "stdole2.tlb" || base.Index != 6 || base.Typekind != -1 {
+		t.Errorf("base = %+v", base)
+	}
+
+	if len(shape.Funcs) != 2 {
+		t.Fatalf("Funcs = %+v", shape.Funcs)
+	}
+	area := shape.Funcs[0]
+	wantArea := []Param{{
+		Name:  "area",
+		Type:  ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_R8)}},
+		Flags: ole.PARAMFLAG_FOUT | ole.PARAMFLAG_FRETVAL,
+	}}
+	if area.Name != "Area" || area.OVft != 28 || area.Result.VT != uint16(ole.VT_HRESULT) ||
+		!reflect.DeepEqual(area.Params, wantArea) {
+		t.Errorf("Area = %+v %+v", area, area.Params)
+	}
+
+	scale := shape.Funcs[1]
+	wantScale := []Param{
+		{Name: "factor", Type: ole.TYPEDESC{VT: uint16(ole.VT_I4)}, Flags: ole.PARAMFLAG_FIN},
+		{Name: "c", Type: ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: HrefOfIndex(0)},
+			Flags: ole.PARAMFLAG_FIN | ole.PARAMFLAG_FOPT},
+	}
+	if scale.Name != "Scale" || scale.Flags != ole.FUNCFLAG_FHIDDEN || scale.HelpContext != 7 ||
+		scale.CParamsOpt != 1 || scale.Result.VT != uint16(ole.VT_HRESULT) || !reflect.DeepEqual(scale.Params, wantScale) {
+		t.Errorf("Scale = %+v %+v", scale, scale.Params)
+	}
+	if ref := lib.Ref(HrefOfIndex(0)); ref == nil || ref.Type != color || ref.Typekind != ole.TKIND_ENUM {
+		t.Errorf("Ref = %+v", ref)
+	}
+}
@@ -0,0 +1,281 @@
+// Package typelib reads type libraries without COM, so that they can be
+// inspected on any platform: to generate code, or to compare the interfaces
+// of two versions of a component.
+//
+// It reads the MSFT format written by MIDL and ICreateTypeLib2, and the
+// older SLTG format still found in some system libraries, from .tlb files or
+// from the TYPELIB resources of DLLs and executables. The result is a Library
+// described with the vocabulary of the ole package: TKIND_*, FUNC_*,
+// INVOKE_*, PARAMFLAG_* and the TYPEATTR and TYPEDESC structures.
+//
+// Libraries, read or built in Go, are written back in the MSFT format, as
+// ICreateTypeLib2 writes them, for LoadTypeLib and RegisterTypeLib.
+package typelib
+
+import (
+	"io"
+	"io/ioutil"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// Library is a type library.
+type Library struct {
+	Name              string
+	DocString         string
+	HelpFile          string
+	HelpContext       uint32
+	HelpStringContext uint32
+	HelpStringDll     string
+	Attr              ole.TLIBATTR
+	Types             []*TypeInfo
+	Imports           []*Import
+	CustData          []CustData
+
+	// Refs resolves the HREFTYPEs of user-defined types and implemented
+	// interfaces. The types of the library have the HREFTYPE
+	// HrefOfIndex(i); imported types have odd ones.
+	Refs map[uint32]*Ref
+}
+
+// TypeInfo is a type of a library. Attr.Typekind tells its kind; Attr.Guid,
+// the counts and sizes of Attr are those stored in the library.
+type TypeInfo struct {
+	Name              string
+	DocString         string
+	HelpContext       uint32
+	HelpStringContext uint32
+	Attr              ole.TYPEATTR
+
+	// DllName is the DLL of a TKIND_MODULE.
+	DllName string
+
+	Funcs     []*Func
+	Vars      []*Var
+	ImplTypes []ImplType
+	CustData  []CustData
+}
+
+// Func is a method or property accessor of an interface or dispinterface,
+// or a function of a module.
+type Func struct {
+	Name              string
+	DocString         string
+	HelpContext       uint32
+	HelpStringContext uint32
+	Memid             int32
+	FuncKind          int32 // FUNC_*
+	InvKind           int32 // INVOKE_*
+	CallConv          int32 // CC_*
+	OVft              int16
+	Flags             uint16 // FUNCFLAG_*
+	Result            ole.TYPEDESC
+	Params            []Param
+	CParamsOpt        int16
+
+	// Entry names the DLL entry point of a module function, or is empty
+	// when Ordinal identifies it.
+	Entry   string
+	Ordinal uint16
+
+	CustData []CustData
+}
+
+// Param is a parameter of a function.
+type Param struct {
+	Name     string
+	Type     ole.TYPEDESC
+	Flags    uint16 // PARAMFLAG_*
+	Default  *Value // with PARAMFLAG_FHASDEFAULT
+	CustData []CustData
+}
+
+// Var is a constant of an enumeration or module, a field of a record or a
+// property of a dispinterface.
+type Var struct {
+	Name              string
+	DocString         string
+	HelpContext       uint32
+	HelpStringContext uint32
+	Memid             int32
+	VarKind           int32 // VAR_*
+	Type              ole.TYPEDESC
+	Flags             uint16 // VARFLAG_*
+	OInst             uint32 // offset of a VAR_PERINSTANCE field
+	Value             *Value // value of a VAR_CONST
+	CustData          []CustData
+}
+
+// ImplType is an interface implemented or inherited by a type.
+type ImplType struct {
+	Hreftype uint32
+	Flags    int32 // IMPLTYPEFLAG_*
+	CustData []CustData
+}
+
+// CustData is a custom attribute, as set with the custom IDL attribute.
+type CustData struct {
+	Guid  ole.GUID
+	Value Value
+}
+
+// Value is a constant. Val holds an int8, int16, int32, int64, uint8,
+// uint16, uint32, uint64, float32, float64, bool or string according to VT;
+// it is nil for VT_EMPTY and VT_NULL. The 64-bit VT_CY holds its scaled
+// int64, VT_DATE its float64.
+type Value struct {
+	VT  ole.VT
+	Val interface{}
+}
+
+// Import is a type library whose types are referenced.
+type Import struct {
+	Guid        ole.GUID
+	Lcid        uint32
+	MajorVerNum uint16
+	MinorVerNum uint16
+	Path        string
+}
+
+// Ref is the type an HREFTYPE stands for: the type at Index in the library,
+// or a type of an imported library named by GUID or, when Guid is null, by
+// index. Typekind is the TKIND_* of the type, or -1 when the library does not
+// record the kind of an imported type.
+type Ref struct {
+	Type     *TypeInfo
+	Import   *Import
+	Guid     ole.GUID
+	Index    int
+	Typekind int32
+
+	// Name is the name of an imported type when known. Libraries do not
+	// record it; Load reads it from the imported library.
+	Name string
+}
+
+// HrefOfIndex returns the HREFTYPE of the type at index in its library.
+func HrefOfIndex(index int) uint32 {
+	return uint32(index) * typeInfoSize
+}
+
+// IsInheritedDispatchMember reports whether memid is one of the IUnknown
+// and IDispatch methods the dispinterface side of a dual interface repeats.
+func IsInheritedDispatchMember(memid int32) bool {
+	high := uint32(memid) >> 16
+	return high == 0x6000 || high == 0x6001
+}
+
+// resolveKinds sets the kind of the references to types of the library,
+// once all of them have been read.
+func (l *Library) resolveKinds() {
+	for _, ref := range l.Refs {
+		if ref.Type != nil {
+			ref.Typekind = ref.Type.Attr.Typekind
+		}
+	}
+}
+
+// Ref returns the type hreftype stands for, or nil.
+func (l *Library) Ref(hreftype uint32) *Ref {
+	return l.Refs[hreftype]
+}
+
+// Type returns the type called name, or nil.
+func (l *Library) Type(name string) *TypeInfo {
+	for _, t := range l.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// TypeOfGuid returns the type identified by guid, or nil.
+func (l *Library) TypeOfGuid(guid *ole.GUID) *TypeInfo {
+	for _, t := range l.Types {
+		if ole.IsEqualGUID(&t.Attr.Guid, guid) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Parse reads a type library in the MSFT or SLTG format, or the first type
+// library embedded in a PE image.
+func Parse(data []byte) (*Library, error) {
+	switch {
+	case hasSignature(data, msftSignature):
+		return parseMSFT(data)
+	case hasSignature(data, sltgSignature):
+		return parseSLTG(data)
+	case len(data) >= 2 && data[0] == 'M' && data[1] == 'Z':
+		tlb, err := Resource(data, 1)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(tlb)
+	}
+	return nil, ole.NewErrorWithDescription(ole.TYPE_E_UNSUPFORMAT, "not a type library")
+}
+
+// ReadFile reads the type library of a .tlb file, or the first one embedded
+// in a DLL or executable.
+func ReadFile(path string) (*Library, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Write writes lib in the MSFT format. The hreftypes of the library are
+// renumbered: its types get those of their index, imported types those of
+// the table of imports. Names and strings must be encodable in 8 bits,
+// which the format stores them in.
+func Write(w io.Writer, lib *Library) error {
+	data, err := writeMSFT(lib)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteFile writes lib to a .tlb file.
+func WriteFile(path string, lib *Library) error {
+	data, err := writeMSFT(lib)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+func hasSignature(data []byte, signature string) bool {
+	return len(data) >= len(signature) && string(data[:len(signature)]) == signature
+}
+
+// decodeANSI decodes the 8-bit names and strings of a library. Both formats
+// store them in the code page of the library; bytes above 0x7F are read as
+// Latin-1.
+func decodeANSI(b []byte) string {
+	ascii := true
+	for _, c := range b {
+		if c >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// invalidData returns the error of a malformed library.
+func invalidData(what string) error {
+	return ole.NewErrorWithDescription(ole.TYPE_E_INVDATAREAD, "invalid type library: "+what)
+}
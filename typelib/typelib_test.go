@@ -0,0 +1,290 @@
+package typelib
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var (
+	libidTest       = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E01}")
+	iidCalculator   = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E02}")
+	clsidCalculator = ole.NewGUID("{5E7A7F3C-1D2B-4C8E-9A61-0F3B2D4C5E03}")
+	libidStdOle     = ole.NewGUID("{00020430-0000-0000-C000-000000000046}")
+	custTool        = ole.NewGUID("{C0DE0001-0000-4000-8000-000000000001}")
+	custID          = ole.NewGUID("{C0DE0002-0000-4000-8000-000000000002}")
+	custArg         = ole.NewGUID("{C0DE0003-0000-4000-8000-000000000003}")
+)
+
+func readTestFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func parseTestFile(t *testing.T, name string) *Library {
+	lib, err := ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ReadFile(%s) = %v", name, err)
+	}
+	return lib
+}
+
+func mustType(t *testing.T, lib *Library, name string, kind int32) *TypeInfo {
+	typ := lib.Type(name)
+	if typ == nil {
+		t.Fatalf("no type %s", name)
+	}
+	if typ.Attr.Typekind != kind {
+		t.Fatalf("%s is of kind %d, want %d", name, typ.Attr.Typekind, kind)
+	}
+	return typ
+}
+
+func TestParseMSFT_library(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	if lib.Name != "GoOleTest" || lib.DocString != "Go OLE test library" || lib.HelpFile != "goole.chm" ||
+		lib.HelpContext != 100 || lib.HelpStringDll != "goolehelp.dll" {
+		t.Errorf("library = %q %q %q %d %q", lib.Name, lib.DocString, lib.HelpFile, lib.HelpContext, lib.HelpStringDll)
+	}
+	attr := lib.Attr
+	if !ole.IsEqualGUID(&attr.Guid, libidTest) || attr.Syskind != ole.SYS_WIN32 ||
+		attr.WMajorVerNum != 1 || attr.WMinorVerNum != 2 || attr.WLibFlags != ole.LIBFLAG_FHASDISKIMAGE {
+		t.Errorf("Attr = %+v", attr)
+	}
+	wantCustData := []CustData{
+		{Guid: *custTool, Value: Value{VT: ole.VT_BSTR, Val: "handwritten"}},
+		{Guid: *custID, Value: Value{VT: ole.VT_I4, Val: int32(42)}},
+	}
+	if !reflect.DeepEqual(lib.CustData, wantCustData) {
+		t.Errorf("CustData = %+v, want %+v", lib.CustData, wantCustData)
+	}
+	if len(lib.Imports) != 1 || !ole.IsEqualGUID(&lib.Imports[0].Guid, libidStdOle) ||
+		lib.Imports[0].MajorVerNum != 2 || lib.Imports[0].Path != "stdole2.tlb" {
+		t.Errorf("Imports = %+v", lib.Imports)
+	}
+
+	var names []string
+	for _, typ := range lib.Types {
+		names = append(names, typ.Name)
+	}
+	want := []string{"Shape", "Point", "Handle", "ICalculator", "_CalculatorEvents", "Calculator", "Native"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("types = %v, want %v", names, want)
+	}
+	if typ := lib.TypeOfGuid(clsidCalculator); typ == nil || typ.Name != "Calculator" {
+		t.Errorf("TypeOfGuid = %+v", typ)
+	}
+}
+
+func TestParseMSFT_enum(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	shape := mustType(t, lib, "Shape", ole.TKIND_ENUM)
+	if shape.DocString != "Shapes that can be drawn" || shape.Attr.CVars != 4 || shape.Attr.CbSizeInstance != 4 {
+		t.Errorf("Shape = %+v", shape)
+	}
+	// Small values are packed in the records, others stored apart.
+	want := map[string]int32{"ShapeCircle": 0, "ShapeSquare": 1, "ShapeHuge": 100000000, "ShapeNone": -1}
+	for _, v := range shape.Vars {
+		if v.VarKind != ole.VAR_CONST || v.Value == nil || v.Value.VT != ole.VT_I4 || v.Value.Val != want[v.Name] {
+			t.Errorf("%s = %+v %+v, want %d", v.Name, v, v.Value, want[v.Name])
+		}
+	}
+}
+
+func TestParseMSFT_record(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	point := mustType(t, lib, "Point", ole.TKIND_RECORD)
+	if len(point.Vars) != 4 || point.Attr.CbSizeInstance != 36 || point.Attr.CbAlignment != 4 {
+		t.Fatalf("Point = %+v", point)
+	}
+	label := point.Vars[2]
+	if label.Name != "label" || label.VarKind != ole.VAR_PERINSTANCE || label.OInst != 8 ||
+		label.Type.VT != uint16(ole.VT_BSTR) || label.DocString != "Display name" {
+		t.Errorf("label = %+v", label)
+	}
+	data := point.Vars[3]
+	wantType := ole.TYPEDESC{VT: uint16(ole.VT_CARRAY), Lpadesc: &ole.ARRAYDESC{
+		TdescElem: ole.TYPEDESC{VT: uint16(ole.VT_I4)},
+		Bounds:    []ole.SafeArrayBound{{Elements: 2}, {Elements: 3, LowerBound: 1}},
+	}}
+	if data.OInst != 12 || !reflect.DeepEqual(data.Type, wantType) {
+		t.Errorf("data = %+v %+v", data, data.Type.Lpadesc)
+	}
+
+	handle := mustType(t, lib, "Handle", ole.TKIND_ALIAS)
+	if handle.Attr.TdescAlias.VT != uint16(ole.VT_I4) {
+		t.Errorf("Handle = %+v", handle.Attr.TdescAlias)
+	}
+}
+
+func TestParseMSFT_interface(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	calc := mustType(t, lib, "ICalculator", ole.TKIND_INTERFACE)
+	attr := calc.Attr
+	if !ole.IsEqualGUID(&attr.Guid, iidCalculator) || attr.CFuncs != 5 || attr.CbSizeVft != 48 ||
+		attr.WTypeFlags != ole.TYPEFLAG_FDUAL|ole.TYPEFLAG_FOLEAUTOMATION|ole.TYPEFLAG_FDISPATCHABLE {
+		t.Errorf("Attr = %+v", attr)
+	}
+	if calc.DocString != "Calculator interface" || calc.HelpContext != 5 {
+		t.Errorf("documentation = %q %d", calc.DocString, calc.HelpContext)
+	}
+	if len(calc.CustData) != 1 || calc.CustData[0].Value.Val != "ICalculator" {
+		t.Errorf("CustData = %+v", calc.CustData)
+	}
+
+	// It derives from IDispatch, imported from stdole2 by GUID.
+	if len(calc.ImplTypes) != 1 {
+		t.Fatalf("ImplTypes = %+v", calc.ImplTypes)
+	}
+	base := lib.Ref(calc.ImplTypes[0].Hreftype)
+	if base == nil || base.Type != nil || base.Import != lib.Imports[0] ||
+		!ole.IsEqualGUID(&base.Guid, ole.IID_IDispatch) || base.Typekind != ole.TKIND_DISPATCH {
+		t.Errorf("base = %+v", base)
+	}
+
+	add := calc.Funcs[0]
+	if add.Name != "Add" || add.DocString != "Adds two numbers" || add.HelpContext != 10 ||
+		add.FuncKind != ole.FUNC_PUREVIRTUAL || add.InvKind != ole.INVOKE_FUNC || add.CallConv != ole.CC_STDCALL ||
+		add.OVft != 28 || add.Result.VT != uint16(ole.VT_HRESULT) || len(add.Params) != 3 {
+		t.Errorf("Add = %+v", add)
+	}
+	result := add.Params[2]
+	if result.Name != "result" || result.Flags != ole.PARAMFLAG_FOUT|ole.PARAMFLAG_FRETVAL ||
+		result.Type.VT != uint16(ole.VT_PTR) || result.Type.Lptdesc.VT != uint16(ole.VT_I4) {
+		t.Errorf("result = %+v", result)
+	}
+
+	// The property accessors share a name and member ID.
+	get, put := calc.Funcs[1], calc.Funcs[2]
+	if get.Name != "Total" || put.Name != "Total" || get.Memid != 1 || put.Memid != 1 ||
+		get.InvKind != ole.INVOKE_PROPERTYGET || put.InvKind != ole.INVOKE_PROPERTYPUT {
+		t.Errorf("Total = %+v, %+v", get, put)
+	}
+
+	move := calc.Funcs[3]
+	to := move.Params[0].Type
+	if to.VT != uint16(ole.VT_PTR) || to.Lptdesc.VT != uint16(ole.VT_USERDEFINED) {
+		t.Fatalf("to = %+v", to)
+	}
+	if ref := lib.Ref(to.Lptdesc.Hreftype); ref == nil || ref.Type != lib.Type("Point") || ref.Typekind != ole.TKIND_RECORD {
+		t.Errorf("ref of to = %+v", ref)
+	}
+	defaults := []*Value{nil, {VT: ole.VT_I4, Val: int32(1)}, {VT: ole.VT_BSTR, Val: "fast"}}
+	for i, p := range move.Params {
+		if !reflect.DeepEqual(p.Default, defaults[i]) {
+			t.Errorf("default of %s = %+v, want %+v", p.Name, p.Default, defaults[i])
+		}
+	}
+
+	draw := calc.Funcs[4]
+	shapes, canvas := draw.Params[0], draw.Params[1]
+	if shapes.Type.VT != uint16(ole.VT_SAFEARRAY) || shapes.Type.Lptdesc.VT != uint16(ole.VT_VARIANT) {
+		t.Errorf("shapes = %+v", shapes.Type)
+	}
+	wantCanvas := ole.TYPEDESC{VT: uint16(ole.VT_PTR), Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_PTR),
+		Lptdesc: &ole.TYPEDESC{VT: uint16(ole.VT_USERDEFINED), Hreftype: calc.ImplTypes[0].Hreftype}}}
+	if !reflect.DeepEqual(canvas.Type, wantCanvas) {
+		t.Errorf("canvas = %+v", canvas.Type)
+	}
+	wantFunc := []CustData{{Guid: *custID, Value: Value{VT: ole.VT_I4, Val: int32(70000000)}}}
+	wantParam := []CustData{{Guid: *custArg, Value: Value{VT: ole.VT_I4, Val: int32(7)}}}
+	if !reflect.DeepEqual(draw.CustData, wantFunc) || !reflect.DeepEqual(shapes.CustData, wantParam) ||
+		canvas.CustData != nil {
+		t.Errorf("CustData = %+v, %+v, %+v", draw.CustData, shapes.CustData, canvas.CustData)
+	}
+}
+
+func TestParseMSFT_dispatchAndCoclass(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	events := mustType(t, lib, "_CalculatorEvents", ole.TKIND_DISPATCH)
+	if len(events.Funcs) != 1 || events.Funcs[0].FuncKind != ole.FUNC_DISPATCH ||
+		events.Funcs[0].Result.VT != uint16(ole.VT_VOID) {
+		t.Errorf("Funcs = %+v", events.Funcs)
+	}
+	if len(events.Vars) != 1 || events.Vars[0].VarKind != ole.VAR_DISPATCH || events.Vars[0].Flags != ole.VARFLAG_FREADONLY {
+		t.Errorf("Vars = %+v", events.Vars)
+	}
+	// A dispinterface implements the IDispatch of the library header.
+	if len(events.ImplTypes) != 1 || !ole.IsEqualGUID(&lib.Ref(events.ImplTypes[0].Hreftype).Guid, ole.IID_IDispatch) {
+		t.Errorf("ImplTypes = %+v", events.ImplTypes)
+	}
+
+	calc := mustType(t, lib, "Calculator", ole.TKIND_COCLASS)
+	want := []ImplType{
+		{Hreftype: HrefOfIndex(3), Flags: ole.IMPLTYPEFLAG_FDEFAULT},
+		{Hreftype: HrefOfIndex(4), Flags: ole.IMPLTYPEFLAG_FDEFAULT | ole.IMPLTYPEFLAG_FSOURCE},
+	}
+	if !reflect.DeepEqual(calc.ImplTypes, want) {
+		t.Errorf("ImplTypes = %+v, want %+v", calc.ImplTypes, want)
+	}
+	if ref := lib.Ref(want[1].Hreftype); ref == nil || ref.Type != events || ref.Index != 4 {
+		t.Errorf("Ref = %+v", ref)
+	}
+}
+
+func TestParseMSFT_module(t *testing.T) {
+	lib := parseTestFile(t, "test.tlb")
+	native := mustType(t, lib, "Native", ole.TKIND_MODULE)
+	if native.DllName != "gooletest.dll" || len(native.Funcs) != 2 || len(native.Vars) != 2 {
+		t.Fatalf("Native = %+v", native)
+	}
+	version, reset := native.Funcs[0], native.Funcs[1]
+	if version.FuncKind != ole.FUNC_STATIC || version.Entry != "GetVersion" || version.Ordinal != 0 {
+		t.Errorf("Version = %+v", version)
+	}
+	if reset.Entry != "" || reset.Ordinal != 5 {
+		t.Errorf("Reset = %+v", reset)
+	}
+	if v := native.Vars[1].Value; v == nil || v.VT != ole.VT_BSTR || v.Val != "hello" {
+		t.Errorf("Greeting = %+v", v)
+	}
+}
+
+func TestParse_resources(t *testing.T) {
+	image := readTestFile(t, "test.dll")
+	lib, err := Parse(image)
+	if err != nil || lib.Name != "GoOleTest" {
+		t.Fatalf("Parse = %v, %v", lib, err)
+	}
+	tlb, err := Resource(image, 2)
+	if err != nil {
+		t.Fatalf("Resource(2) = %v", err)
+	}
+	if lib, err = Parse(tlb); err != nil || lib.Name != "GoOleTestSLTG" {
+		t.Errorf("Parse(resource 2) = %v, %v", lib, err)
+	}
+	if _, err := Resource(image, 3); err == nil {
+		t.Error("Resource(3) succeeded")
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("MSF"), []byte("not a type library"), []byte("MZ")} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Parse(%q) succeeded", data)
+		}
+	}
+
+	// Damaged libraries must fail or parse, never panic.
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range []string{"test.tlb", "test_sltg.tlb", "test.dll"} {
+		data := readTestFile(t, name)
+		for n := 0; n < len(data); n += 7 {
+			Parse(data[:n])
+		}
+		for i := 0; i < 2000; i++ {
+			damaged := append([]byte(nil), data...)
+			for j := 0; j < 4; j++ {
+				damaged[rng.Intn(len(damaged))] = byte(rng.Intn(256))
+			}
+			Parse(damaged)
+		}
+	}
+}
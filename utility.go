@@ -74,6 +74,16 @@ func BstrToString(p *uint16) string {
 	return string(utf16.Decode(a))
 }
 
+// takeBSTR converts a BSTR returned by a COM call to a Go string and frees it.
+func takeBSTR(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	s := BstrToString(p)
+	SysFreeString((*int16)(unsafe.Pointer(p)))
+	return s
+}
+
 // lpOleStrLen returns the length of Unicode string.
 func lpOleStrLen(p *uint16) (length int64) {
 	if p == nil {
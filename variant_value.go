@@ -0,0 +1,153 @@
+package ole
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// variantDateEpoch is day zero of VT_DATE values.
+var variantDateEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// TimeToVariantDate converts the wall clock time of t to a VT_DATE value.
+func TimeToVariantDate(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	seconds := wall.Unix() - variantDateEpoch.Unix()
+	days := seconds / 86400
+	if seconds%86400 < 0 {
+		days--
+	}
+	fraction := (float64(seconds-days*86400) + float64(wall.Nanosecond())/1e9) / 86400
+	if days < 0 {
+		// Negative dates count days backwards but the time of day forwards.
+		return float64(days) - fraction
+	}
+	return float64(days) + fraction
+}
+
+// VariantDateToTime converts a VT_DATE value to UTC time, rounded to the
+// millisecond.
+func VariantDateToTime(date float64) time.Time {
+	days := math.Trunc(date)
+	milliseconds := math.Floor(math.Abs(date-days)*86400000 + 0.5)
+	return variantDateEpoch.AddDate(0, 0, int(days)).Add(time.Duration(milliseconds) * time.Millisecond)
+}
+
+// NewVariantFromValue converts a Go value to a VARIANT that owns its data.
+//
+// Strings are copied into newly allocated BSTRs, interface pointers are
+// AddRef'd and slices are copied into SAFEARRAYs, so the VARIANT must be
+// cleared by whoever ends up owning it. A VARIANT value is taken over as it is.
+// Other named types are converted according to their underlying kind.
+func NewVariantFromValue(value interface{}) (VARIANT, error) {
+	switch v := value.(type) {
+	case nil:
+		return NewVariant(VT_NULL, 0), nil
+	case Nothing:
+		if v == EMPTY {
+			return NewVariant(VT_EMPTY, 0), nil
+		}
+		return NewVariant(VT_NULL, 0), nil
+	case VARIANT:
+		return v, nil
+	case time.Time:
+		return NewVariant(VT_DATE, int64(math.Float64bits(TimeToVariantDate(v)))), nil
+	case *IDispatch:
+		if v != nil {
+			v.AddRef()
+		}
+		return NewVariant(VT_DISPATCH, int64(uintptr(unsafe.Pointer(v)))), nil
+	case *IUnknown:
+		if v != nil {
+			v.AddRef()
+		}
+		return NewVariant(VT_UNKNOWN, int64(uintptr(unsafe.Pointer(v)))), nil
+	case []byte:
+		return byteSliceVariant(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return NewVariant(VT_BOOL, 0xffff), nil
+		}
+		return NewVariant(VT_BOOL, 0), nil
+	case reflect.Int8:
+		return NewVariant(VT_I1, rv.Int()), nil
+	case reflect.Int16:
+		return NewVariant(VT_I2, rv.Int()), nil
+	case reflect.Int32:
+		return NewVariant(VT_I4, rv.Int()), nil
+	case reflect.Int64:
+		return NewVariant(VT_I8, rv.Int()), nil
+	case reflect.Int:
+		if i := rv.Int(); i >= math.MinInt32 && i <= math.MaxInt32 {
+			return NewVariant(VT_I4, i), nil
+		}
+		return NewVariant(VT_I8, rv.Int()), nil
+	case reflect.Uint8:
+		return NewVariant(VT_UI1, int64(rv.Uint())), nil
+	case reflect.Uint16:
+		return NewVariant(VT_UI2, int64(rv.Uint())), nil
+	case reflect.Uint32:
+		return NewVariant(VT_UI4, int64(rv.Uint())), nil
+	case reflect.Uint64:
+		return NewVariant(VT_UI8, int64(rv.Uint())), nil
+	case reflect.Uint, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxUint32 {
+			return NewVariant(VT_UI4, int64(u)), nil
+		}
+		return NewVariant(VT_UI8, int64(rv.Uint())), nil
+	case reflect.Float32:
+		return NewVariant(VT_R4, int64(math.Float32bits(float32(rv.Float())))), nil
+	case reflect.Float64:
+		return NewVariant(VT_R8, int64(math.Float64bits(rv.Float()))), nil
+	case reflect.String:
+		return NewVariant(VT_BSTR, int64(uintptr(unsafe.Pointer(SysAllocStringLen(rv.String()))))), nil
+	case reflect.Slice, reflect.Array:
+		return variantSliceVariant(rv)
+	}
+	return VARIANT{}, NewErrorWithDescription(DISP_E_TYPEMISMATCH, fmt.Sprintf("cannot convert %T to VARIANT", value))
+}
+
+// byteSliceVariant copies bytes into a VT_ARRAY|VT_UI1 VARIANT.
+func byteSliceVariant(bytes []byte) (VARIANT, error) {
+	array, err := safeArrayCreateVector(VT_UI1, 0, uint32(len(bytes)))
+	if err != nil {
+		return VARIANT{}, err
+	}
+	for i := range bytes {
+		err = safeArrayPutElement(array, int64(i), uintptr(unsafe.Pointer(&bytes[i])))
+		if err != nil {
+			safeArrayDestroy(array)
+			return VARIANT{}, err
+		}
+	}
+	return NewVariant(VT_ARRAY|VT_UI1, int64(uintptr(unsafe.Pointer(array)))), nil
+}
+
+// variantSliceVariant converts every element and stores them in a
+// VT_ARRAY|VT_VARIANT VARIANT, the array type scripting clients understand.
+func variantSliceVariant(rv reflect.Value) (VARIANT, error) {
+	array, err := safeArrayCreateVector(VT_VARIANT, 0, uint32(rv.Len()))
+	if err != nil {
+		return VARIANT{}, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		var element VARIANT
+		element, err = NewVariantFromValue(rv.Index(i).Interface())
+		if err == nil {
+			// SafeArrayPutElement copies the VARIANT.
+			err = safeArrayPutElement(array, int64(i), uintptr(unsafe.Pointer(&element)))
+			VariantClear(&element)
+		}
+		if err != nil {
+			safeArrayDestroy(array)
+			return VARIANT{}, err
+		}
+	}
+	return NewVariant(VT_ARRAY|VT_VARIANT, int64(uintptr(unsafe.Pointer(array)))), nil
+}
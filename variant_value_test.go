@@ -0,0 +1,109 @@
+package ole
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var variantDateFixtures = []struct {
+	Time time.Time
+	Date float64
+}{
+	{time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC), 0},
+	{time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), 2},
+	{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), 36526.5},
+	{time.Date(1899, 12, 29, 6, 0, 0, 0, time.UTC), -1.25},
+	{time.Date(1800, 1, 1, 18, 0, 0, 0, time.UTC), -36522.75},
+	{time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC), 2958465},
+}
+
+func TestTimeToVariantDate(t *testing.T) {
+	for _, f := range variantDateFixtures {
+		if actual := TimeToVariantDate(f.Time); math.Abs(actual-f.Date) > 1e-9 {
+			t.Errorf("TimeToVariantDate(%v) = %v, expected %v", f.Time, actual, f.Date)
+		}
+		if actual := VariantDateToTime(f.Date); !actual.Equal(f.Time) {
+			t.Errorf("VariantDateToTime(%v) = %v, expected %v", f.Date, actual, f.Time)
+		}
+	}
+}
+
+func TestTimeToVariantDate_wallClock(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	local := time.Date(2020, 2, 29, 13, 30, 0, 0, zone)
+	expected := time.Date(2020, 2, 29, 13, 30, 0, 0, time.UTC)
+	if actual := VariantDateToTime(TimeToVariantDate(local)); !actual.Equal(expected) {
+		t.Errorf("expected wall clock %v, received %v", expected, actual)
+	}
+}
+
+func TestNewVariantFromValue(t *testing.T) {
+	type named int16
+
+	fixtures := []struct {
+		Value interface{}
+		VT    VT
+		Val   int64
+	}{
+		{nil, VT_NULL, 0},
+		{EMPTY, VT_EMPTY, 0},
+		{true, VT_BOOL, 0xffff},
+		{false, VT_BOOL, 0},
+		{int8(-2), VT_I1, -2},
+		{uint8(200), VT_UI1, 200},
+		{int16(-300), VT_I2, -300},
+		{named(7), VT_I2, 7},
+		{uint16(60000), VT_UI2, 60000},
+		{int32(-70000), VT_I4, -70000},
+		{uint32(4000000000), VT_UI4, 4000000000},
+		{int64(-1) << 40, VT_I8, int64(-1) << 40},
+		{uint64(1) << 63, VT_UI8, math.MinInt64},
+		{int(42), VT_I4, 42},
+		{uint(42), VT_UI4, 42},
+		{float32(1.5), VT_R4, int64(math.Float32bits(1.5))},
+		{float64(-2.25), VT_R8, int64(math.Float64bits(-2.25))},
+		{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), VT_DATE, int64(math.Float64bits(36526.5))},
+		{NewVariant(VT_I2, 5), VT_I2, 5},
+	}
+	for _, f := range fixtures {
+		v, err := NewVariantFromValue(f.Value)
+		if err != nil {
+			t.Errorf("NewVariantFromValue(%#v) returned %v", f.Value, err)
+			continue
+		}
+		if v.VT != f.VT || v.Val != f.Val {
+			t.Errorf("NewVariantFromValue(%#v) = %v %#x, expected %v %#x", f.Value, v.VT, v.Val, f.VT, f.Val)
+		}
+	}
+
+	if strconv.IntSize == 64 {
+		shift := uint(40)
+		if v, _ := NewVariantFromValue(int(1) << shift); v.VT != VT_I8 || v.Val != 1<<40 {
+			t.Errorf("NewVariantFromValue(1 << 40) = %v %#x, expected VT_I8", v.VT, v.Val)
+		}
+	}
+
+	if _, err := NewVariantFromValue(struct{}{}); err == nil {
+		t.Error("NewVariantFromValue accepted a struct")
+	}
+}
+
+func TestDISPPARAMS(t *testing.T) {
+	args := []VARIANT{NewVariant(VT_I4, 3), NewVariant(VT_I4, 2), NewVariant(VT_I4, 1)}
+	named := []int32{DISPID_PROPERTYPUT}
+	params := NewDISPPARAMS(args, named)
+
+	if len(params.Args()) != 3 || params.Args()[2].Val != 1 {
+		t.Errorf("Args() = %v", params.Args())
+	}
+	if len(params.NamedArgs()) != 1 || params.NamedArgs()[0] != DISPID_PROPERTYPUT {
+		t.Errorf("NamedArgs() = %v", params.NamedArgs())
+	}
+
+	empty := NewDISPPARAMS(nil, nil)
+	if empty.Args() != nil || empty.NamedArgs() != nil {
+		t.Error("empty DISPPARAMS returned arguments")
+	}
+}